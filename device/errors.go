@@ -24,4 +24,5 @@ var (
 	ErrorDeviceClosed                 = errors.New("That device has been closed")
 	ErrorTransactionsClosed           = errors.New("Transactions are closed for that device")
 	ErrorTransactionsAlreadyClosed    = errors.New("That Transactions is already closed")
+	ErrorPartnerMismatch              = errors.New("The device is not accessible to the caller's partners")
 )
@@ -12,6 +12,8 @@ import (
 	"github.com/go-kit/kit/log/level"
 	"github.com/gorilla/mux"
 	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/secure"
+	"github.com/jithin-kg/webpa-common/secure/handler"
 	"github.com/jithin-kg/webpa-common/xhttp"
 	"github.com/xmidt-org/wrp-go/v3"
 )
@@ -33,6 +35,41 @@ func Timeout(o *Options) func(http.Handler) http.Handler {
 	}
 }
 
+// RequireTrust returns an Alice-style constructor which rejects requests whose secure trust level,
+// as established by the authorization handlers in secure/handler, is below the given minimum.
+// The device handlers apply the same check through their MinimumTrust fields.
+func RequireTrust(minimum string) func(http.Handler) http.Handler {
+	return func(delegate http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			if allowTrust(response, request, minimum) {
+				delegate.ServeHTTP(response, request)
+			}
+		})
+	}
+}
+
+// allowTrust tests if a request's secure trust level meets the given minimum, writing a
+// StatusForbidden response if it does not.
+func allowTrust(response http.ResponseWriter, request *http.Request, minimum string) bool {
+	trust := secure.Untrusted
+	if values, ok := handler.FromContext(request.Context()); ok {
+		trust = values.Trust
+	}
+
+	if !secure.TrustAtLeast(trust, minimum) {
+		xhttp.WriteErrorf(
+			response,
+			http.StatusForbidden,
+			"Insufficient trust: %s",
+			trust,
+		)
+
+		return false
+	}
+
+	return true
+}
+
 // IDFromRequest is a strategy type for extracting the device identifier from an HTTP request
 type IDFromRequest func(*http.Request) (ID, error)
 
@@ -114,6 +151,17 @@ type MessageHandler struct {
 
 	// Router is the device message Router to use.  This field is required.
 	Router Router
+
+	// Registry is used to look up the destination device for partner enforcement.  This field
+	// is required if Partners is set; without it, every request is rejected.
+	Registry Registry
+
+	// Partners is the optional partner enforcement for requests.  If unset, no partner
+	// enforcement is done.
+	Partners *PartnerCheck
+
+	// MinimumTrust is the optional secure trust level required of requests.  If unset, trust is not checked.
+	MinimumTrust string
 }
 
 func (mh *MessageHandler) logger() log.Logger {
@@ -139,7 +187,37 @@ func (mh *MessageHandler) decodeRequest(httpRequest *http.Request) (deviceReques
 	return
 }
 
+// allowPartners applies partner enforcement to the destination device, if connected.  Requests
+// for devices that cannot be found are allowed through so that routing reports the usual errors.
+// Partner enforcement without a Registry fails closed.
+func (mh *MessageHandler) allowPartners(deviceRequest *Request) bool {
+	if mh.Partners == nil {
+		return true
+	}
+
+	if mh.Registry == nil {
+		mh.logger().Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "partner enforcement requires a registry")
+		return false
+	}
+
+	id, err := deviceRequest.ID()
+	if err != nil {
+		return true
+	}
+
+	d, ok := mh.Registry.Get(id)
+	if !ok {
+		return true
+	}
+
+	return mh.Partners.Allow(deviceRequest.Context(), d)
+}
+
 func (mh *MessageHandler) ServeHTTP(httpResponse http.ResponseWriter, httpRequest *http.Request) {
+	if len(mh.MinimumTrust) > 0 && !allowTrust(httpResponse, httpRequest, mh.MinimumTrust) {
+		return
+	}
+
 	deviceRequest, err := mh.decodeRequest(httpRequest)
 	if err != nil {
 		mh.logger().Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "Unable to decode request", logging.ErrorKey(), err)
@@ -166,6 +244,16 @@ func (mh *MessageHandler) ServeHTTP(httpResponse http.ResponseWriter, httpReques
 		return
 	}
 
+	if !mh.allowPartners(deviceRequest) {
+		xhttp.WriteError(
+			httpResponse,
+			http.StatusForbidden,
+			ErrorPartnerMismatch,
+		)
+
+		return
+	}
+
 	// deviceRequest carries the context through the routing infrastructure
 	if deviceResponse, err := mh.Router.Route(deviceRequest); err != nil {
 		code := http.StatusGatewayTimeout
@@ -230,6 +318,14 @@ type ListHandler struct {
 	Registry Registry
 	Refresh  time.Duration
 
+	// Partners is the optional partner enforcement for the device list.  When set, only devices
+	// the caller is allowed to access are listed, and the list is computed for each request
+	// rather than cached.
+	Partners *PartnerCheck
+
+	// MinimumTrust is the optional secure trust level required of requests.  If unset, trust is not checked.
+	MinimumTrust string
+
 	lock        sync.RWMutex
 	cacheExpiry time.Time
 	cache       bytes.Buffer
@@ -269,38 +365,66 @@ func (lh *ListHandler) updateCache() []byte {
 
 	if lh.cacheExpiry.Before(lh._now()) {
 		lh.cache.Reset()
-		lh.cache.WriteString(`{"devices":[`)
+		lh.writeList(&lh.cache, func(Interface) bool { return true })
+		lh.cacheBytes = lh.cache.Bytes()
+		lh.cacheExpiry = lh._now().Add(lh.refresh())
+	}
 
-		needsSeparator := false
-		lh.Registry.VisitAll(func(d Interface) bool {
-			if needsSeparator {
-				lh.cache.WriteString(`,`)
-			}
+	return lh.cacheBytes
+}
 
-			if data, err := d.MarshalJSON(); err != nil {
-				lh.cache.WriteString(
-					fmt.Sprintf(`{"id": "%s", "error": "%s"}`, d.ID(), err),
-				)
-			} else {
-				lh.cache.Write(data)
-			}
+// writeList writes the JSON device list to the given buffer, including only those devices
+// that pass the given filter.
+func (lh *ListHandler) writeList(output *bytes.Buffer, filter func(Interface) bool) {
+	output.WriteString(`{"devices":[`)
 
-			needsSeparator = true
+	needsSeparator := false
+	lh.Registry.VisitAll(func(d Interface) bool {
+		if !filter(d) {
 			return true
-		})
+		}
 
-		lh.cache.WriteString(`]}`)
-		lh.cacheBytes = lh.cache.Bytes()
-		lh.cacheExpiry = lh._now().Add(lh.refresh())
-	}
+		if needsSeparator {
+			output.WriteString(`,`)
+		}
 
-	return lh.cacheBytes
+		if data, err := d.MarshalJSON(); err != nil {
+			output.WriteString(
+				fmt.Sprintf(`{"id": "%s", "error": "%s"}`, d.ID(), err),
+			)
+		} else {
+			output.Write(data)
+		}
+
+		needsSeparator = true
+		return true
+	})
+
+	output.WriteString(`]}`)
 }
 
 func (lh *ListHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
 	lh.Logger.Log(level.Key(), level.DebugValue(), "handler", "ListHandler", logging.MessageKey(), "ServeHTTP")
+	if len(lh.MinimumTrust) > 0 && !allowTrust(response, request, lh.MinimumTrust) {
+		return
+	}
+
 	response.Header().Set("Content-Type", "application/json")
 
+	if lh.Partners != nil {
+		var (
+			ctx    = request.Context()
+			output bytes.Buffer
+		)
+
+		lh.writeList(&output, func(d Interface) bool {
+			return lh.Partners.Allow(ctx, d)
+		})
+
+		response.Write(output.Bytes())
+		return
+	}
+
 	if cacheBytes, expired := lh.tryCache(); expired {
 		response.Write(lh.updateCache())
 	} else {
@@ -314,10 +438,21 @@ type StatHandler struct {
 	Logger   log.Logger
 	Registry Registry
 	Variable string
+
+	// Partners is the optional partner enforcement for device statistics.  If unset, no
+	// partner enforcement is done.
+	Partners *PartnerCheck
+
+	// MinimumTrust is the optional secure trust level required of requests.  If unset, trust is not checked.
+	MinimumTrust string
 }
 
 func (sh *StatHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
 	sh.Logger.Log(level.Key(), level.DebugValue(), "handler", "StatHandler", logging.MessageKey(), "ServeHTTP")
+	if len(sh.MinimumTrust) > 0 && !allowTrust(response, request, sh.MinimumTrust) {
+		return
+	}
+
 	vars := mux.Vars(request)
 	if len(vars) == 0 {
 		sh.Logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "no path variables present for request")
@@ -345,6 +480,11 @@ func (sh *StatHandler) ServeHTTP(response http.ResponseWriter, request *http.Req
 		return
 	}
 
+	if !sh.Partners.Allow(request.Context(), d) {
+		response.WriteHeader(http.StatusForbidden)
+		return
+	}
+
 	data, err := d.MarshalJSON()
 	if err != nil {
 		sh.Logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to marshal device as JSON", "deviceName", name, logging.ErrorKey(), err)
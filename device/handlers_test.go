@@ -17,6 +17,7 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/jithin-kg/webpa-common/logging"
+	securehandler "github.com/jithin-kg/webpa-common/secure/handler"
 	"github.com/xmidt-org/wrp-go/v3"
 )
 
@@ -71,6 +72,82 @@ func TestTimeout(t *testing.T) {
 	)
 }
 
+func testRequireTrust(t *testing.T, trust, minimum string, expectedStatusCode int) {
+	var (
+		assert   = assert.New(t)
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/", nil)
+
+		handler = RequireTrust(minimum)(
+			http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+				response.WriteHeader(http.StatusOK)
+			}),
+		)
+	)
+
+	if len(trust) > 0 {
+		request = request.WithContext(
+			securehandler.NewContextWithValue(request.Context(), &securehandler.ContextValues{Trust: trust}),
+		)
+	}
+
+	handler.ServeHTTP(response, request)
+	assert.Equal(expectedStatusCode, response.Code)
+}
+
+func TestRequireTrust(t *testing.T) {
+	t.Run("NoSecureContext", func(t *testing.T) { testRequireTrust(t, "", "0", http.StatusOK) })
+	t.Run("NoSecureContextMinimum", func(t *testing.T) { testRequireTrust(t, "", "1000", http.StatusForbidden) })
+	t.Run("Sufficient", func(t *testing.T) { testRequireTrust(t, "1000", "1000", http.StatusOK) })
+	t.Run("Insufficient", func(t *testing.T) { testRequireTrust(t, "100", "1000", http.StatusForbidden) })
+}
+
+func TestHandlersMinimumTrust(t *testing.T) {
+	var (
+		logger   = logging.NewTestLogger(nil, t)
+		router   = new(mockRouter)
+		registry = new(MockRegistry)
+
+		handlers = map[string]http.Handler{
+			"MessageHandler": &MessageHandler{Logger: logger, Router: router, MinimumTrust: "1000"},
+			"ListHandler":    &ListHandler{Logger: logger, Registry: registry, MinimumTrust: "1000"},
+			"StatHandler":    &StatHandler{Logger: logger, Registry: registry, Variable: "deviceID", MinimumTrust: "1000"},
+		}
+	)
+
+	for name, handler := range handlers {
+		t.Run(name, func(t *testing.T) {
+			var (
+				response = httptest.NewRecorder()
+				request  = httptest.NewRequest("POST", "/", nil).WithContext(
+					securehandler.NewContextWithValue(context.Background(), &securehandler.ContextValues{Trust: "100"}),
+				)
+			)
+
+			handler.ServeHTTP(response, request)
+			assert.Equal(t, http.StatusForbidden, response.Code)
+		})
+	}
+
+	t.Run("Sufficient", func(t *testing.T) {
+		var (
+			assert   = assert.New(t)
+			response = httptest.NewRecorder()
+			request  = httptest.NewRequest("GET", "/", nil).WithContext(
+				securehandler.NewContextWithValue(context.Background(), &securehandler.ContextValues{Trust: "1000"}),
+			)
+		)
+
+		registry.On("VisitAll", mock.AnythingOfType("func(device.Interface) bool")).Return(0).Once()
+		handlers["ListHandler"].ServeHTTP(response, request)
+		assert.Equal(http.StatusOK, response.Code)
+		assert.JSONEq(`{"devices":[]}`, response.Body.String())
+	})
+
+	router.AssertExpectations(t)
+	registry.AssertExpectations(t)
+}
+
 func testUseIDFNilStrategy(t *testing.T) {
 	var (
 		assert   = assert.New(t)
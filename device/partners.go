@@ -0,0 +1,132 @@
+package device
+
+import (
+	"context"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/secure/handler"
+)
+
+// WildcardPartnerID is the partner id which, when present in either a caller's allowed partners
+// or a device's connection-time partners, matches any other partner id.
+const WildcardPartnerID = "*"
+
+// PartnerPolicy describes what happens when a caller's allowed partners do not intersect
+// the partners a device connected with.
+type PartnerPolicy string
+
+const (
+	// PartnerReject denies access to the device.  This is the default policy.
+	PartnerReject PartnerPolicy = "reject"
+
+	// PartnerLog permits access to the device, but logs the mismatch.
+	PartnerLog PartnerPolicy = "log"
+
+	// PartnerAllow permits access to the device without any further action.
+	PartnerAllow PartnerPolicy = "allow"
+)
+
+// PartnerCheck enforces partner-scoped access to devices.  A caller, as described by the
+// secure handler.ContextValues in the request context, may only reach devices whose
+// connection-time partner ids intersect the caller's allowed partners.
+//
+// A nil *PartnerCheck permits all access, which preserves the behavior of handlers that
+// do not configure partner enforcement.
+type PartnerCheck struct {
+	// Policy is the action taken when partners do not intersect.  If unset, PartnerReject is used.
+	Policy PartnerPolicy
+
+	// Logger is the sink for mismatch logging.  If unset, logging.DefaultLogger() is used.
+	Logger log.Logger
+}
+
+func (pc *PartnerCheck) policy() PartnerPolicy {
+	if len(pc.Policy) > 0 {
+		return pc.Policy
+	}
+
+	return PartnerReject
+}
+
+func (pc *PartnerCheck) logger() log.Logger {
+	if pc.Logger != nil {
+		return pc.Logger
+	}
+
+	return logging.DefaultLogger()
+}
+
+// Allow tests if the caller described by the given context may access the given device.
+// A context without secure information never matches, and is subject to the Policy like
+// any other mismatch.
+func (pc *PartnerCheck) Allow(ctx context.Context, d Interface) bool {
+	if pc == nil {
+		return true
+	}
+
+	var callerPartnerIDs []string
+	if values, ok := handler.FromContext(ctx); ok {
+		callerPartnerIDs = values.PartnerIDs
+	}
+
+	devicePartnerIDs := d.PartnerIDs()
+	if PartnersIntersect(callerPartnerIDs, devicePartnerIDs) {
+		return true
+	}
+
+	switch pc.policy() {
+	case PartnerAllow:
+		return true
+
+	case PartnerLog:
+		pc.logger().Log(
+			level.Key(), level.WarnValue(),
+			logging.MessageKey(), "partner mismatch",
+			"id", d.ID(),
+			"callerPartnerIDs", callerPartnerIDs,
+			"devicePartnerIDs", devicePartnerIDs,
+		)
+
+		return true
+
+	default:
+		pc.logger().Log(
+			level.Key(), level.DebugValue(),
+			logging.MessageKey(), "partner mismatch, access denied",
+			"id", d.ID(),
+			"callerPartnerIDs", callerPartnerIDs,
+			"devicePartnerIDs", devicePartnerIDs,
+		)
+
+		return false
+	}
+}
+
+// PartnersIntersect tests if two sets of partner ids have at least one partner in common.
+// A caller with WildcardPartnerID matches every device, while a device with WildcardPartnerID
+// matches every caller that has at least one allowed partner.
+func PartnersIntersect(callerPartnerIDs, devicePartnerIDs []string) bool {
+	for _, p := range callerPartnerIDs {
+		if p == WildcardPartnerID {
+			return true
+		}
+	}
+
+	for _, p := range devicePartnerIDs {
+		if p == WildcardPartnerID {
+			return len(callerPartnerIDs) > 0
+		}
+	}
+
+	for _, c := range callerPartnerIDs {
+		for _, p := range devicePartnerIDs {
+			if c == p {
+				return true
+			}
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,263 @@
+package device
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/secure/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+func partnerContext(partnerIDs ...string) context.Context {
+	return handler.NewContextWithValue(
+		context.Background(),
+		&handler.ContextValues{PartnerIDs: partnerIDs},
+	)
+}
+
+func TestPartnersIntersect(t *testing.T) {
+	testData := []struct {
+		caller   []string
+		device   []string
+		expected bool
+	}{
+		{nil, nil, false},
+		{[]string{"A"}, nil, false},
+		{nil, []string{"A"}, false},
+		{[]string{"A", "B"}, []string{"B"}, true},
+		{[]string{"A", "B"}, []string{"C"}, false},
+		{[]string{"*"}, nil, true},
+		{[]string{"*"}, []string{"C"}, true},
+		{[]string{"A"}, []string{"*"}, true},
+		{nil, []string{"*"}, false},
+	}
+
+	for _, record := range testData {
+		assert.Equal(t, record.expected, PartnersIntersect(record.caller, record.device), "caller: %v, device: %v", record.caller, record.device)
+	}
+}
+
+func testPartnerCheckAllowNil(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		device = new(MockDevice)
+		pc     *PartnerCheck
+	)
+
+	assert.True(pc.Allow(context.Background(), device))
+	device.AssertExpectations(t)
+}
+
+func testPartnerCheckAllowMatch(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		device = new(MockDevice)
+		pc     = &PartnerCheck{Logger: logging.NewTestLogger(nil, t)}
+	)
+
+	device.On("PartnerIDs").Return([]string{"comcast"}).Once()
+	assert.True(pc.Allow(partnerContext("foo", "comcast"), device))
+	device.AssertExpectations(t)
+}
+
+func testPartnerCheckAllowMismatch(t *testing.T, policy PartnerPolicy, expected bool) {
+	var (
+		assert = assert.New(t)
+		device = new(MockDevice)
+		pc     = &PartnerCheck{Policy: policy, Logger: logging.NewTestLogger(nil, t)}
+	)
+
+	device.On("PartnerIDs").Return([]string{"comcast"}).Once()
+	device.On("ID").Return(ID("mac:112233445566")).Maybe()
+	assert.Equal(expected, pc.Allow(partnerContext("foo"), device))
+	device.AssertExpectations(t)
+}
+
+func testPartnerCheckAllowNoSecureContext(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		device = new(MockDevice)
+		pc     = &PartnerCheck{Logger: logging.NewTestLogger(nil, t)}
+	)
+
+	device.On("PartnerIDs").Return([]string{"comcast"}).Once()
+	device.On("ID").Return(ID("mac:112233445566")).Once()
+	assert.False(pc.Allow(context.Background(), device))
+	device.AssertExpectations(t)
+}
+
+func TestPartnerCheck(t *testing.T) {
+	t.Run("Allow", func(t *testing.T) {
+		t.Run("Nil", testPartnerCheckAllowNil)
+		t.Run("Match", testPartnerCheckAllowMatch)
+		t.Run("NoSecureContext", testPartnerCheckAllowNoSecureContext)
+
+		t.Run("Mismatch", func(t *testing.T) {
+			t.Run("Default", func(t *testing.T) { testPartnerCheckAllowMismatch(t, "", false) })
+			t.Run("Reject", func(t *testing.T) { testPartnerCheckAllowMismatch(t, PartnerReject, false) })
+			t.Run("Log", func(t *testing.T) { testPartnerCheckAllowMismatch(t, PartnerLog, true) })
+			t.Run("Allow", func(t *testing.T) { testPartnerCheckAllowMismatch(t, PartnerAllow, true) })
+		})
+	})
+}
+
+func testMessageHandlerServeHTTPPartnerMismatch(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		message = &wrp.SimpleEvent{
+			Source:      "test.com",
+			Destination: "mac:112233445566",
+		}
+
+		requestContents []byte
+	)
+
+	require.NoError(wrp.NewEncoderBytes(&requestContents, wrp.Msgpack).Encode(message))
+
+	var (
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/foo", bytes.NewReader(requestContents)).WithContext(partnerContext("foo"))
+
+		device   = new(MockDevice)
+		router   = new(mockRouter)
+		registry = new(MockRegistry)
+		handler  = MessageHandler{
+			Logger:   logging.NewTestLogger(nil, t),
+			Router:   router,
+			Registry: registry,
+			Partners: &PartnerCheck{Logger: logging.NewTestLogger(nil, t)},
+		}
+	)
+
+	request.Header.Set("Content-Type", wrp.Msgpack.ContentType())
+	registry.On("Get", ID("mac:112233445566")).Return(device, true).Once()
+	device.On("PartnerIDs").Return([]string{"comcast"}).Once()
+	device.On("ID").Return(ID("mac:112233445566")).Once()
+
+	handler.ServeHTTP(response, request)
+	assert.Equal(http.StatusForbidden, response.Code)
+
+	device.AssertExpectations(t)
+	router.AssertExpectations(t)
+	registry.AssertExpectations(t)
+}
+
+func testMessageHandlerServeHTTPPartnersNoRegistry(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		message = &wrp.SimpleEvent{
+			Source:      "test.com",
+			Destination: "mac:112233445566",
+		}
+
+		requestContents []byte
+	)
+
+	require.NoError(wrp.NewEncoderBytes(&requestContents, wrp.Msgpack).Encode(message))
+
+	var (
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("POST", "/foo", bytes.NewReader(requestContents)).WithContext(partnerContext("comcast"))
+
+		router  = new(mockRouter)
+		handler = MessageHandler{
+			Logger:   logging.NewTestLogger(nil, t),
+			Router:   router,
+			Partners: &PartnerCheck{Logger: logging.NewTestLogger(nil, t)},
+		}
+	)
+
+	// partner enforcement cannot be done without a registry, so the request is rejected
+	request.Header.Set("Content-Type", wrp.Msgpack.ContentType())
+	handler.ServeHTTP(response, request)
+	assert.Equal(http.StatusForbidden, response.Code)
+
+	router.AssertExpectations(t)
+}
+
+func testStatHandlerPartnerMismatch(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		registry = new(MockRegistry)
+		device   = new(MockDevice)
+
+		handler = StatHandler{
+			Logger:   logging.NewTestLogger(nil, t),
+			Registry: registry,
+			Variable: "deviceID",
+			Partners: &PartnerCheck{Logger: logging.NewTestLogger(nil, t)},
+		}
+
+		router   = mux.NewRouter()
+		request  = httptest.NewRequest("GET", "/mac:112233445566", nil).WithContext(partnerContext("foo"))
+		response = httptest.NewRecorder()
+	)
+
+	router.Handle("/{deviceID}", &handler)
+	registry.On("Get", ID("mac:112233445566")).Return(device, true).Once()
+	device.On("PartnerIDs").Return([]string{"comcast"}).Once()
+	device.On("ID").Return(ID("mac:112233445566")).Once()
+
+	router.ServeHTTP(response, request)
+	assert.Equal(http.StatusForbidden, response.Code)
+	registry.AssertExpectations(t)
+	device.AssertExpectations(t)
+}
+
+func testListHandlerPartners(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		registry = new(MockRegistry)
+		allowed  = new(MockDevice)
+		denied   = new(MockDevice)
+
+		handler = ListHandler{
+			Logger:   logging.NewTestLogger(nil, t),
+			Registry: registry,
+			Partners: &PartnerCheck{Logger: logging.NewTestLogger(nil, t)},
+		}
+
+		request  = httptest.NewRequest("GET", "/", nil).WithContext(partnerContext("comcast"))
+		response = httptest.NewRecorder()
+	)
+
+	allowed.On("PartnerIDs").Return([]string{"comcast"}).Once()
+	allowed.On("MarshalJSON").Return([]byte(`{"id": "allowed"}`), (error)(nil)).Once()
+	denied.On("PartnerIDs").Return([]string{"other"}).Once()
+	denied.On("ID").Return(ID("mac:112233445566")).Once()
+
+	registry.On("VisitAll", mock.AnythingOfType("func(device.Interface) bool")).
+		Run(func(arguments mock.Arguments) {
+			visitor := arguments.Get(0).(func(Interface) bool)
+			visitor(denied)
+			visitor(allowed)
+		}).
+		Return(2).Once()
+
+	handler.ServeHTTP(response, request)
+	assert.Equal(http.StatusOK, response.Code)
+	assert.JSONEq(`{"devices":[{"id": "allowed"}]}`, response.Body.String())
+
+	registry.AssertExpectations(t)
+	allowed.AssertExpectations(t)
+	denied.AssertExpectations(t)
+}
+
+func TestPartnerEnforcement(t *testing.T) {
+	t.Run("MessageHandler", testMessageHandlerServeHTTPPartnerMismatch)
+	t.Run("MessageHandlerNoRegistry", testMessageHandlerServeHTTPPartnersNoRegistry)
+	t.Run("StatHandler", testStatHandlerPartnerMismatch)
+	t.Run("ListHandler", testListHandlerPartners)
+}
@@ -37,6 +37,7 @@ require (
 	github.com/prometheus/client_golang v0.9.3
 	github.com/rubyist/circuitbreaker v2.2.0+incompatible
 	github.com/samuel/go-zookeeper v0.0.0-20180130194729-c4fab1ac1bec
+	github.com/spaolacci/murmur3 v0.0.0-20180118202830-f09979ecbc72
 	github.com/spf13/pflag v1.0.3
 	github.com/spf13/viper v1.6.1
 	github.com/stretchr/testify v1.3.0
@@ -45,5 +46,5 @@ require (
 	github.com/xmidt-org/wrp-go/v3 v3.0.1
 	golang.org/x/sys v0.7.0 // indirect
 	gopkg.in/natefinch/lumberjack.v2 v2.0.0
-	gopkg.in/yaml.v2 v2.2.4 // indirect
+	gopkg.in/yaml.v2 v2.2.4
 )
@@ -3,8 +3,12 @@ package mhook
 import (
 	"context"
 	"fmt"
+	"net/http"
+	"sort"
 
 	"github.com/go-kit/kit/endpoint"
+	"github.com/jithin-kg/webpa-common/webhook"
+	"github.com/jithin-kg/webpa-common/xhttp"
 )
 
 func newAddWebhookEndpoint(s Service) endpoint.Endpoint {
@@ -21,3 +25,79 @@ func newGetAllWebhooksEndpoint(s Service) endpoint.Endpoint {
 		return s.AllWebhooks(r.owner)
 	}
 }
+
+func newDeleteWebhookEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		r := request.(*deleteWebhookRequest)
+		err := s.Delete(r.owner, r.url)
+		if err == ErrOwnerNotFound || err == ErrWebhookNotFound {
+			return nil, &xhttp.Error{Code: http.StatusNotFound, Text: err.Error()}
+		}
+
+		return nil, err
+	}
+}
+
+func newGetAllOwnersWebhooksEndpoint(s Service) endpoint.Endpoint {
+	return func(ctx context.Context, _ interface{}) (interface{}, error) {
+		return s.AllWebhooksByOwner()
+	}
+}
+
+func newGetDeliveriesEndpoint(s Service, h DeliveryHistory) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		r := request.(*getDeliveriesRequest)
+		owned, err := ownedURLs(s, r.owner)
+		if err != nil {
+			return nil, err
+		}
+
+		if len(r.url) > 0 {
+			if !owned[r.url] {
+				return nil, &xhttp.Error{Code: http.StatusNotFound, Text: ErrWebhookNotFound.Error()}
+			}
+
+			owned = map[string]bool{r.url: true}
+		}
+
+		records := []webhook.DeliveryRecord{}
+		for url := range owned {
+			records = append(records, h.Deliveries(r.owner, url, r.from, r.to)...)
+		}
+
+		sort.SliceStable(records, func(i, j int) bool {
+			return records[i].Timestamp.Before(records[j].Timestamp)
+		})
+
+		return records, nil
+	}
+}
+
+func newReplayEndpoint(s Service, h DeliveryHistory) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		r := request.(*replayRequest)
+		owned, err := ownedURLs(s, r.owner)
+		if err != nil {
+			return nil, err
+		}
+
+		if !owned[r.url] {
+			return nil, &xhttp.Error{Code: http.StatusNotFound, Text: ErrWebhookNotFound.Error()}
+		}
+
+		count, err := h.Replay(r.owner, r.url, r.from, r.to)
+		switch err {
+		case nil:
+			return count, nil
+
+		case webhook.ErrSubscriberNotFound:
+			return nil, &xhttp.Error{Code: http.StatusNotFound, Text: err.Error()}
+
+		case webhook.ErrDispatcherStopped:
+			return nil, &xhttp.Error{Code: http.StatusServiceUnavailable, Text: err.Error()}
+
+		default:
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package mhook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+)
+
+// fileWebhookStore is a WebhookStore which persists every change to a single JSON file.
+// Writes are atomic, so the file always holds either the previous or the next set of
+// webhooks even if the process dies mid-write.
+type fileWebhookStore struct {
+	path   string
+	store  map[string]map[string]*Webhook // owner -> url -> Webhook
+	mu     sync.RWMutex
+	logger *loggerGroup
+}
+
+// NewFileWebhookStore creates a WebhookStore backed by the file at the given path.  If the file
+// exists, the webhooks it holds are loaded, less any that have expired.  Otherwise, the file is
+// created on the first change.
+func NewFileWebhookStore(path string, logger log.Logger) (WebhookStore, error) {
+	fs := &fileWebhookStore{
+		path:   path,
+		store:  make(map[string]map[string]*Webhook),
+		logger: newLoggerGroup(logger),
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return fs, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var contents map[string][]*Webhook
+	if len(data) > 0 {
+		if err := json.Unmarshal(data, &contents); err != nil {
+			return nil, fmt.Errorf("Unable to read webhooks from %s: %s", path, err)
+		}
+	}
+
+	now := time.Now()
+	for owner, webhooks := range contents {
+		for _, w := range webhooks {
+			if w == nil || expired(w, now) {
+				continue
+			}
+
+			if fs.store[owner] == nil {
+				fs.store[owner] = make(map[string]*Webhook)
+			}
+
+			fs.store[owner][w.Config.URL] = w
+		}
+	}
+
+	fs.logger.Debug.Log("msg", "loaded webhooks", "path", path, "owners", len(fs.store))
+	return fs, nil
+}
+
+// writeFileAtomic writes data to a temporary file in the same directory as path, then renames it over path
+func writeFileAtomic(path string, data []byte, perm os.FileMode) (err error) {
+	temp, err := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			temp.Close()
+			os.Remove(temp.Name())
+		}
+	}()
+
+	if _, err = temp.Write(data); err != nil {
+		return
+	}
+
+	if err = temp.Sync(); err != nil {
+		return
+	}
+
+	if err = temp.Close(); err != nil {
+		return
+	}
+
+	if err = os.Chmod(temp.Name(), perm); err != nil {
+		return
+	}
+
+	return os.Rename(temp.Name(), path)
+}
+
+// update applies a change to a copy of the webhooks, persists the copy, and then replaces the current
+// webhooks with it.  If the change or the write fails, the current webhooks are left untouched.
+// This method must be called under the write lock.
+func (fs *fileWebhookStore) update(change func(map[string]map[string]*Webhook) error) error {
+	next := make(map[string]map[string]*Webhook, len(fs.store))
+	for owner, webhooks := range fs.store {
+		next[owner] = make(map[string]*Webhook, len(webhooks))
+		for url, w := range webhooks {
+			next[owner][url] = w
+		}
+	}
+
+	if err := change(next); err != nil {
+		return err
+	}
+
+	contents := make(map[string][]*Webhook, len(next))
+	for owner, webhooks := range next {
+		if len(webhooks) == 0 {
+			delete(next, owner)
+			continue
+		}
+
+		for _, w := range webhooks {
+			contents[owner] = append(contents[owner], w)
+		}
+	}
+
+	data, err := json.Marshal(contents)
+	if err != nil {
+		return err
+	}
+
+	// the file holds secrets, so it is only readable by this process's user
+	if err := writeFileAtomic(fs.path, data, 0600); err != nil {
+		fs.logger.Error.Log("msg", "unable to write webhooks", "path", fs.path, "error", err)
+		return err
+	}
+
+	fs.store = next
+	return nil
+}
+
+func (fs *fileWebhookStore) Add(owner string, w *Webhook) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.update(func(store map[string]map[string]*Webhook) error {
+		if store[owner] == nil {
+			store[owner] = make(map[string]*Webhook)
+		}
+
+		store[owner][w.Config.URL] = w
+		return nil
+	})
+}
+
+func (fs *fileWebhookStore) Delete(owner string, url string) error {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	return fs.update(func(store map[string]map[string]*Webhook) error {
+		if store[owner] == nil {
+			return ErrOwnerNotFound
+		}
+
+		if _, ok := store[owner][url]; !ok {
+			return ErrWebhookNotFound
+		}
+
+		delete(store[owner], url)
+		return nil
+	})
+}
+
+func (fs *fileWebhookStore) AllWebhooks(owner string) ([]*Webhook, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	webhooks := make([]*Webhook, 0, len(fs.store[owner]))
+	for _, w := range fs.store[owner] {
+		webhooks = append(webhooks, w)
+	}
+
+	return webhooks, nil
+}
+
+func (fs *fileWebhookStore) AllWebhooksByOwner() (map[string][]*Webhook, error) {
+	fs.mu.RLock()
+	defer fs.mu.RUnlock()
+
+	byOwner := make(map[string][]*Webhook, len(fs.store))
+	for owner, webhooks := range fs.store {
+		for _, w := range webhooks {
+			byOwner[owner] = append(byOwner[owner], w)
+		}
+	}
+
+	return byOwner, nil
+}
+
+func (fs *fileWebhookStore) Compact(now time.Time) (int, error) {
+	fs.mu.Lock()
+	defer fs.mu.Unlock()
+
+	removed := 0
+	for _, webhooks := range fs.store {
+		for _, w := range webhooks {
+			if expired(w, now) {
+				removed++
+			}
+		}
+	}
+
+	// avoid rewriting the file when nothing has expired
+	if removed == 0 {
+		return 0, nil
+	}
+
+	err := fs.update(func(store map[string]map[string]*Webhook) error {
+		for _, webhooks := range store {
+			for url, w := range webhooks {
+				if expired(w, now) {
+					delete(webhooks, url)
+				}
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return 0, err
+	}
+
+	return removed, nil
+}
@@ -5,13 +5,32 @@ import (
 	"net/http"
 
 	kithttp "github.com/go-kit/kit/transport/http"
+	"github.com/jithin-kg/webpa-common/webhook"
 )
 
 func NewAddWebhookHandler(s Service) http.Handler {
 	fmt.Println("__hanlder.go: NewAddWebhookHandler() called")
+	return NewValidatingAddWebhookHandler(s, nil)
+}
+
+// defaultValidator creates the webhook.Validator used when none is supplied, which rejects webhooks
+// that target restricted addresses
+func defaultValidator() webhook.Validator {
+	// without AllowedNetworks, there is nothing that can fail to parse
+	v, _ := webhook.NewValidator(nil)
+	return v
+}
+
+// NewValidatingAddWebhookHandler returns a handler which adds webhooks once they pass the given validator.
+// If the validator is nil, a validator with the default webhook.ValidatorOptions is used.
+func NewValidatingAddWebhookHandler(s Service, v webhook.Validator) http.Handler {
+	if v == nil {
+		v = defaultValidator()
+	}
+
 	return kithttp.NewServer(
 		newAddWebhookEndpoint(s),
-		decodeAddWebhookRequest,
+		newAddWebhookRequestDecoder(v),
 		encodeAddWebhookResponse,
 		kithttp.ServerErrorEncoder(errorEncoder),
 	)
@@ -25,3 +44,49 @@ func NewGetAllWebhooksHandler(s Service) http.Handler {
 		kithttp.ServerErrorEncoder(errorEncoder),
 	)
 }
+
+// NewDeleteWebhookHandler returns a handler which removes one of the caller's webhooks.  The webhook's
+// URL is taken from the url query parameter or, failing that, from a JSON body of the form {"url": "..."}.
+func NewDeleteWebhookHandler(s Service) http.Handler {
+	return kithttp.NewServer(
+		newDeleteWebhookEndpoint(s),
+		decodeDeleteWebhookRequest,
+		encodeDeleteWebhookResponse,
+		kithttp.ServerErrorEncoder(errorEncoder),
+	)
+}
+
+// NewGetAllOwnersWebhooksHandler returns a handler which lists the webhooks of every owner.
+// This handler is for administrative use, and callers are responsible for restricting access to it.
+func NewGetAllOwnersWebhooksHandler(s Service) http.Handler {
+	return kithttp.NewServer(
+		newGetAllOwnersWebhooksEndpoint(s),
+		kithttp.NopRequestDecoder,
+		encodeGetAllOwnersWebhooksResponse,
+		kithttp.ServerErrorEncoder(errorEncoder),
+	)
+}
+
+// NewGetDeliveriesHandler returns a handler which lists the delivery records of the caller's webhooks,
+// oldest first.  The url query parameter restricts the records to a single webhook, and the from and
+// to query parameters, in RFC3339 format, restrict the records to a time range.
+func NewGetDeliveriesHandler(s Service, h DeliveryHistory) http.Handler {
+	return kithttp.NewServer(
+		newGetDeliveriesEndpoint(s, h),
+		decodeGetDeliveriesRequest,
+		encodeGetDeliveriesResponse,
+		kithttp.ServerErrorEncoder(errorEncoder),
+	)
+}
+
+// NewReplayHandler returns a handler which redelivers recent events to one of the caller's webhooks.
+// The request body is JSON of the form {"url": "...", "from": "...", "to": "..."}, where from and to
+// are optional RFC3339 times bounding the events to replay.
+func NewReplayHandler(s Service, h DeliveryHistory) http.Handler {
+	return kithttp.NewServer(
+		newReplayEndpoint(s, h),
+		decodeReplayRequest,
+		encodeReplayResponse,
+		kithttp.ServerErrorEncoder(errorEncoder),
+	)
+}
@@ -0,0 +1,317 @@
+package mhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jithin-kg/webpa-common/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDeleteWebhookHandler(t *testing.T) {
+	testData := []struct {
+		description  string
+		target       string
+		body         string
+		expectedCode int
+		expectedURLs []string
+	}{
+		{"Query", "/hooks?url=http://example.com/one", "", http.StatusOK, []string{"http://example.com/two"}},
+		{"Body", "/hooks", `{"url": "http://example.com/two"}`, http.StatusOK, []string{"http://example.com/one"}},
+		{"NotFound", "/hooks?url=http://example.com/nosuch", "", http.StatusNotFound, []string{"http://example.com/one", "http://example.com/two"}},
+		{"MissingURL", "/hooks", "", http.StatusBadRequest, []string{"http://example.com/one", "http://example.com/two"}},
+		{"InvalidBody", "/hooks", "{", http.StatusBadRequest, []string{"http://example.com/one", "http://example.com/two"}},
+	}
+
+	for _, record := range testData {
+		t.Run(record.description, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+
+				now    = time.Now()
+				svc, _ = newTestService(&now)
+
+				response = httptest.NewRecorder()
+				request  = httptest.NewRequest("DELETE", record.target, strings.NewReader(record.body))
+			)
+
+			require.NoError(svc.Add("owner", newTestWebhook("http://example.com/one", now.Add(time.Hour))))
+			require.NoError(svc.Add("owner", newTestWebhook("http://example.com/two", now.Add(time.Hour))))
+
+			request.Header.Set(ClientIDHeader, "owner")
+			NewDeleteWebhookHandler(svc).ServeHTTP(response, request)
+			assert.Equal(record.expectedCode, response.Code)
+
+			webhooks, err := svc.AllWebhooks("owner")
+			require.NoError(err)
+
+			var actual []Webhook
+			for _, w := range webhooks {
+				actual = append(actual, *w)
+			}
+
+			assert.Equal(record.expectedURLs, webhookURLs(actual))
+		})
+	}
+}
+
+func TestNewGetAllOwnersWebhooksHandler(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		now    = time.Now()
+		svc, _ = newTestService(&now)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/hooks/all", nil)
+	)
+
+	require.NoError(svc.Add("owner1", newTestWebhook("http://example.com/one", now.Add(time.Hour))))
+	require.NoError(svc.Add("owner2", newTestWebhook("http://example.com/two", now.Add(time.Hour))))
+
+	NewGetAllOwnersWebhooksHandler(svc).ServeHTTP(response, request)
+	assert.Equal(http.StatusOK, response.Code)
+
+	var byOwner map[string][]Webhook
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &byOwner))
+	require.Len(byOwner, 2)
+	require.Len(byOwner["owner1"], 1)
+	assert.Equal("http://example.com/one", byOwner["owner1"][0].Config.URL)
+	assert.Equal("<obfuscated>", byOwner["owner1"][0].Config.Secret)
+
+	// obfuscation does not alter the stored webhooks
+	webhooks, err := svc.AllWebhooks("owner1")
+	require.NoError(err)
+	assert.Equal("secret", webhooks[0].Config.Secret)
+}
+
+func TestNewGetAllWebhooksHandler(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		now    = time.Now()
+		svc, _ = newTestService(&now)
+
+		response = httptest.NewRecorder()
+		request  = httptest.NewRequest("GET", "/hooks", nil)
+	)
+
+	require.NoError(svc.Add("owner", newTestWebhook("http://example.com/", now.Add(time.Hour))))
+	request.Header.Set(ClientIDHeader, "owner")
+
+	NewGetAllWebhooksHandler(svc).ServeHTTP(response, request)
+	assert.Equal(http.StatusOK, response.Code)
+
+	var webhooks []Webhook
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &webhooks))
+	require.Len(webhooks, 1)
+	assert.Equal("<obfuscated>", webhooks[0].Config.Secret)
+}
+
+func TestNewAddWebhookHandlerRenews(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		now    = time.Now()
+		svc, _ = newTestService(&now)
+		body   = `{"config": {"url": "http://93.184.216.34/"}, "events": [".*"], "until": "2000-01-01T00:00:00Z"}`
+	)
+
+	for i := 0; i < 2; i++ {
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+		request.Header.Set(ClientIDHeader, "owner")
+		request.RemoteAddr = "127.0.0.1:1234"
+
+		NewAddWebhookHandler(svc).ServeHTTP(response, request)
+		require.Equal(http.StatusOK, response.Code)
+	}
+
+	webhooks, err := svc.AllWebhooks("owner")
+	require.NoError(err)
+	require.Len(webhooks, 1)
+	assert.WithinDuration(time.Now().Add(defaultWebhookExpiration), webhooks[0].Until, time.Minute)
+	assert.Equal("127.0.0.1", webhooks[0].Address)
+}
+
+func TestNewValidatingAddWebhookHandler(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		now    = time.Now()
+		svc, _ = newTestService(&now)
+	)
+
+	v, err := webhook.NewValidator(&webhook.ValidatorOptions{RequireHTTPS: true})
+	require.NoError(err)
+
+	for _, body := range []string{
+		`{"config": {"url": "http://example.com/"}, "events": [".*"]}`,
+		`{"config": {"url": "https://127.0.0.1/"}, "events": [".*"]}`,
+		`{"config": {"url": "https://93.184.216.34/", "alt_urls": ["https://10.0.0.1/"]}, "events": [".*"]}`,
+		`{"config": {"url": "https://93.184.216.34/"}, "failure_url": "http://93.184.216.34/", "events": [".*"]}`,
+	} {
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+		request.Header.Set(ClientIDHeader, "owner")
+
+		NewValidatingAddWebhookHandler(svc, v).ServeHTTP(response, request)
+		assert.Equal(http.StatusBadRequest, response.Code, body)
+	}
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest("POST", "/hook", strings.NewReader(`{"config": {"url": "https://93.184.216.34/"}, "events": [".*"]}`))
+	request.Header.Set(ClientIDHeader, "owner")
+
+	NewValidatingAddWebhookHandler(svc, v).ServeHTTP(response, request)
+	require.Equal(http.StatusOK, response.Code)
+
+	webhooks, err := svc.AllWebhooks("owner")
+	require.NoError(err)
+	require.Len(webhooks, 1)
+	assert.Equal("https://93.184.216.34/", webhooks[0].Config.URL)
+}
+
+func TestNewAddWebhookHandlerRestricted(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		now    = time.Now()
+		svc, _ = newTestService(&now)
+	)
+
+	for _, body := range []string{
+		`{"config": {"url": "http://127.0.0.1/"}, "events": [".*"]}`,
+		`{"config": {"url": "http://169.254.169.254/"}, "events": [".*"]}`,
+		`{"config": {"url": "http://93.184.216.34/"}, "failure_url": "http://10.0.0.1/", "events": [".*"]}`,
+	} {
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+		request.Header.Set(ClientIDHeader, "owner")
+
+		NewAddWebhookHandler(svc).ServeHTTP(response, request)
+		assert.Equal(http.StatusBadRequest, response.Code, body)
+	}
+
+	webhooks, err := svc.AllWebhooks("owner")
+	assert.NoError(err)
+	assert.Empty(webhooks)
+}
+
+func TestNewAddWebhookHandlerInvalidMatcher(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		now    = time.Now()
+		svc, _ = newTestService(&now)
+	)
+
+	for _, body := range []string{
+		`{"config": {"url": "http://93.184.216.34/"}, "events": ["("]}`,
+		`{"config": {"url": "http://93.184.216.34/"}, "events": [".*"], "matcher": {"any": [{"convey": {"hw-model": ["("]}}]}}`,
+	} {
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest("POST", "/hook", strings.NewReader(body))
+		request.Header.Set(ClientIDHeader, "owner")
+
+		NewAddWebhookHandler(svc).ServeHTTP(response, request)
+		assert.Equal(http.StatusBadRequest, response.Code, body)
+		assert.Contains(response.Body.String(), "invalid", body)
+	}
+
+	webhooks, err := svc.AllWebhooks("owner")
+	assert.NoError(err)
+	assert.Empty(webhooks)
+}
+
+func TestSecretsAreWriteOnly(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		now    = time.Now()
+		svc, _ = newTestService(&now)
+
+		unsigned = newTestWebhook("http://example.com/unsigned", now.Add(time.Hour))
+		rotated  = newTestWebhook("http://example.com/rotated", now.Add(time.Hour))
+	)
+
+	unsigned.Config.Secret = ""
+	require.NoError(svc.Add("owner", unsigned))
+
+	// renewing with a new secret makes the original secret the previous secret
+	original := newTestWebhook("http://example.com/rotated", now.Add(time.Hour))
+	original.Config.Secret = "previous"
+	require.NoError(svc.Add("owner", original))
+	require.NoError(svc.Add("owner", rotated))
+
+	for _, handler := range []http.Handler{NewGetAllWebhooksHandler(svc), NewGetAllOwnersWebhooksHandler(svc)} {
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest("GET", "/hooks", nil)
+		request.Header.Set(ClientIDHeader, "owner")
+
+		handler.ServeHTTP(response, request)
+		require.Equal(http.StatusOK, response.Code)
+		assert.NotContains(response.Body.String(), `:"secret"`)
+		assert.NotContains(response.Body.String(), `:"previous"`)
+		// only the secrets that are set are obfuscated
+		assert.Equal(2, strings.Count(response.Body.String(), "obfuscated"))
+	}
+}
+
+func TestNewAddWebhookHandlerSignatureAlgorithm(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		now    = time.Now()
+		svc, _ = newTestService(&now)
+	)
+
+	for algorithm, expected := range map[string]int{"md5": http.StatusBadRequest, "sha512": http.StatusOK} {
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest("POST", "/hook", strings.NewReader(`{"config": {"url": "http://93.184.216.34/", "secret": "secret", "signature_algorithm": "`+algorithm+`"}, "events": [".*"]}`))
+		request.Header.Set(ClientIDHeader, "owner")
+
+		NewAddWebhookHandler(svc).ServeHTTP(response, request)
+		assert.Equal(expected, response.Code, algorithm)
+	}
+
+	webhooks, err := svc.AllWebhooks("owner")
+	require.NoError(err)
+	require.Len(webhooks, 1)
+	assert.Equal("sha512", webhooks[0].Config.SignatureAlgorithm)
+}
+
+func TestNewAddWebhookHandlerIgnoresPreviousSecret(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		now    = time.Now()
+		svc, _ = newTestService(&now)
+	)
+
+	response := httptest.NewRecorder()
+	request := httptest.NewRequest("POST", "/hook", strings.NewReader(`{"config": {"url": "http://93.184.216.34/", "secret": "secret", "previous_secret": "injected", "previous_secret_until": "2100-01-01T00:00:00Z"}, "events": [".*"]}`))
+	request.Header.Set(ClientIDHeader, "owner")
+
+	NewAddWebhookHandler(svc).ServeHTTP(response, request)
+	require.Equal(http.StatusOK, response.Code)
+
+	webhooks, err := svc.AllWebhooks("owner")
+	require.NoError(err)
+	require.Len(webhooks, 1)
+	assert.Empty(webhooks[0].Config.PreviousSecret)
+	assert.Zero(webhooks[0].Config.PreviousSecretUntil)
+}
@@ -0,0 +1,37 @@
+package mhook
+
+import (
+	"time"
+
+	"github.com/jithin-kg/webpa-common/webhook"
+)
+
+// DeliveryHistory is the source of delivery records and replays for the delivery handlers.
+// *webhook.Dispatcher implements this interface.  Subscribers are identified by their owners and
+// webhook URLs, so owners that share a URL do not see each other's records.
+type DeliveryHistory interface {
+	// Deliveries returns the delivery records of a subscriber whose events were dispatched within [from, to].
+	// A zero from or to leaves that end of the range unbounded.
+	Deliveries(owner, url string, from, to time.Time) []webhook.DeliveryRecord
+
+	// Replay redelivers a subscriber's retained events that were dispatched within [from, to],
+	// returning the number of events replayed
+	Replay(owner, url string, from, to time.Time) (int, error)
+}
+
+// ownedURLs returns the URLs of an owner's webhooks
+func ownedURLs(s Service, owner string) (map[string]bool, error) {
+	webhooks, err := s.AllWebhooks(owner)
+	if err == ErrOwnerNotFound {
+		return map[string]bool{}, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	urls := make(map[string]bool, len(webhooks))
+	for _, w := range webhooks {
+		urls[w.Config.URL] = true
+	}
+
+	return urls, nil
+}
@@ -0,0 +1,190 @@
+package mhook
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/jithin-kg/webpa-common/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeHistory is a DeliveryHistory backed by fixed records, keyed by owner and URL
+type fakeHistory struct {
+	records  map[[2]string][]webhook.DeliveryRecord
+	replayed []string
+	err      error
+}
+
+func (fh *fakeHistory) Deliveries(owner, url string, from, to time.Time) []webhook.DeliveryRecord {
+	var result []webhook.DeliveryRecord
+	for _, r := range fh.records[[2]string{owner, url}] {
+		if (from.IsZero() || !r.Timestamp.Before(from)) && (to.IsZero() || !r.Timestamp.After(to)) {
+			result = append(result, r)
+		}
+	}
+
+	return result
+}
+
+func (fh *fakeHistory) Replay(owner, url string, from, to time.Time) (int, error) {
+	if fh.err != nil {
+		return 0, fh.err
+	}
+
+	fh.replayed = append(fh.replayed, url)
+	return len(fh.Deliveries(owner, url, from, to)), nil
+}
+
+func newTestHistory(start time.Time) *fakeHistory {
+	return &fakeHistory{
+		records: map[[2]string][]webhook.DeliveryRecord{
+			{"owner", "http://example.com/one"}: {
+				{Timestamp: start, URL: "http://example.com/one", TransactionUUID: "a"},
+				{Timestamp: start.Add(2 * time.Minute), URL: "http://example.com/one", TransactionUUID: "c"},
+			},
+			{"owner", "http://example.com/two"}: {
+				{Timestamp: start.Add(time.Minute), URL: "http://example.com/two", TransactionUUID: "b"},
+			},
+			{"other", "http://example.com/other"}: {
+				{Timestamp: start, URL: "http://example.com/other", TransactionUUID: "x"},
+			},
+			// another owner's webhook with the same URL as one of owner's
+			{"other", "http://example.com/one"}: {
+				{Timestamp: start, URL: "http://example.com/one", TransactionUUID: "y"},
+				{Timestamp: start.Add(time.Minute), URL: "http://example.com/one", TransactionUUID: "z"},
+			},
+		},
+	}
+}
+
+func TestNewGetDeliveriesHandler(t *testing.T) {
+	var (
+		start = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+		from  = start.Add(time.Minute).Format(time.RFC3339)
+	)
+
+	testData := []struct {
+		description  string
+		owner        string
+		target       string
+		expectedCode int
+		expected     []string
+	}{
+		{"All", "owner", "/deliveries", http.StatusOK, []string{"a", "b", "c"}},
+		{"URL", "owner", "/deliveries?url=http://example.com/one", http.StatusOK, []string{"a", "c"}},
+		{"From", "owner", "/deliveries?from=" + from, http.StatusOK, []string{"b", "c"}},
+		{"To", "owner", "/deliveries?to=" + from, http.StatusOK, []string{"a", "b"}},
+		{"NoOwner", "nosuch", "/deliveries", http.StatusOK, []string{}},
+		{"SharedURL", "other", "/deliveries?url=http://example.com/one", http.StatusOK, []string{"y", "z"}},
+		{"NotOwned", "owner", "/deliveries?url=http://example.com/other", http.StatusNotFound, nil},
+		{"InvalidFrom", "owner", "/deliveries?from=yesterday", http.StatusBadRequest, nil},
+		{"InvalidTo", "owner", "/deliveries?to=tomorrow", http.StatusBadRequest, nil},
+	}
+
+	for _, record := range testData {
+		t.Run(record.description, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+
+				now    = time.Now()
+				svc, _ = newTestService(&now)
+
+				response = httptest.NewRecorder()
+				request  = httptest.NewRequest("GET", record.target, nil)
+			)
+
+			require.NoError(svc.Add("owner", newTestWebhook("http://example.com/one", now.Add(time.Hour))))
+			require.NoError(svc.Add("owner", newTestWebhook("http://example.com/two", now.Add(time.Hour))))
+			require.NoError(svc.Add("other", newTestWebhook("http://example.com/other", now.Add(time.Hour))))
+			require.NoError(svc.Add("other", newTestWebhook("http://example.com/one", now.Add(time.Hour))))
+
+			request.Header.Set(ClientIDHeader, record.owner)
+			NewGetDeliveriesHandler(svc, newTestHistory(start)).ServeHTTP(response, request)
+			require.Equal(record.expectedCode, response.Code)
+			if record.expectedCode != http.StatusOK {
+				return
+			}
+
+			var records []webhook.DeliveryRecord
+			require.NoError(json.Unmarshal(response.Body.Bytes(), &records))
+
+			actual := []string{}
+			for _, r := range records {
+				actual = append(actual, r.TransactionUUID)
+			}
+
+			assert.Equal(record.expected, actual)
+		})
+	}
+}
+
+func TestNewReplayHandler(t *testing.T) {
+	testData := []struct {
+		description      string
+		body             string
+		err              error
+		expectedCode     int
+		expectedReplayed int
+	}{
+		{"All", `{"url": "http://example.com/one"}`, nil, http.StatusOK, 2},
+		{"Range", `{"url": "http://example.com/one", "from": "2020-01-01T00:01:00Z", "to": "2020-01-01T00:05:00Z"}`, nil, http.StatusOK, 1},
+		{"NotOwned", `{"url": "http://example.com/other"}`, nil, http.StatusNotFound, 0},
+		{"NoSubscriber", `{"url": "http://example.com/one"}`, webhook.ErrSubscriberNotFound, http.StatusNotFound, 0},
+		{"Stopped", `{"url": "http://example.com/one"}`, webhook.ErrDispatcherStopped, http.StatusServiceUnavailable, 0},
+		{"MissingURL", `{}`, nil, http.StatusBadRequest, 0},
+		{"InvalidBody", `{`, nil, http.StatusBadRequest, 0},
+		{"InvalidTime", `{"url": "http://example.com/one", "from": "yesterday"}`, nil, http.StatusBadRequest, 0},
+	}
+
+	for _, record := range testData {
+		t.Run(record.description, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+
+				now     = time.Now()
+				svc, _  = newTestService(&now)
+				history = newTestHistory(time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC))
+
+				response = httptest.NewRecorder()
+				request  = httptest.NewRequest("POST", "/replay", strings.NewReader(record.body))
+			)
+
+			history.err = record.err
+			require.NoError(svc.Add("owner", newTestWebhook("http://example.com/one", now.Add(time.Hour))))
+			require.NoError(svc.Add("other", newTestWebhook("http://example.com/other", now.Add(time.Hour))))
+
+			request.Header.Set(ClientIDHeader, "owner")
+			NewReplayHandler(svc, history).ServeHTTP(response, request)
+			require.Equal(record.expectedCode, response.Code)
+			if record.expectedCode != http.StatusOK {
+				assert.Empty(history.replayed)
+				return
+			}
+
+			var body struct {
+				Message  string `json:"message"`
+				Replayed int    `json:"replayed"`
+			}
+
+			require.NoError(json.Unmarshal(response.Body.Bytes(), &body))
+			assert.Equal("Success", body.Message)
+			assert.Equal(record.expectedReplayed, body.Replayed)
+			assert.Equal([]string{"http://example.com/one"}, history.replayed)
+		})
+	}
+}
+
+func TestDispatcherIsDeliveryHistory(t *testing.T) {
+	d := webhook.NewDispatcher(nil, webhook.NewList(nil))
+	defer d.Stop()
+
+	var h DeliveryHistory = d
+	assert.Empty(t, h.Deliveries("owner", "http://example.com/", time.Time{}, time.Time{}))
+}
@@ -0,0 +1,214 @@
+package mhook
+
+import (
+	"encoding/json"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/hashicorp/consul/api"
+)
+
+const (
+	// DefaultKVPrefix is the key prefix used by a KV WebhookStore when none is supplied
+	DefaultKVPrefix = "webhooks/"
+)
+
+// KV is a minimal key-value store abstraction that a WebhookStore can be built on
+type KV interface {
+	// Get returns the value of a key, or nil if the key does not exist
+	Get(key string) ([]byte, error)
+
+	// Put stores a value, replacing any existing value
+	Put(key string, value []byte) error
+
+	// Delete removes a key.  Deleting a key that does not exist is not an error.
+	Delete(key string) error
+
+	// List returns all keys and values whose keys start with the given prefix
+	List(prefix string) (map[string][]byte, error)
+}
+
+// kvWebhookStore is a WebhookStore which stores each webhook as a JSON value under the
+// key <prefix><owner>/<url>, with the owner and url path escaped
+type kvWebhookStore struct {
+	kv     KV
+	prefix string
+	logger *loggerGroup
+}
+
+// NewKVWebhookStore creates a WebhookStore backed by the given KV.  If prefix is empty,
+// DefaultKVPrefix is used.
+func NewKVWebhookStore(kv KV, prefix string, logger log.Logger) WebhookStore {
+	if len(prefix) == 0 {
+		prefix = DefaultKVPrefix
+	} else if !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+
+	return &kvWebhookStore{
+		kv:     kv,
+		prefix: prefix,
+		logger: newLoggerGroup(logger),
+	}
+}
+
+func (ks *kvWebhookStore) ownerPrefix(owner string) string {
+	return ks.prefix + url.PathEscape(owner) + "/"
+}
+
+func (ks *kvWebhookStore) key(owner, webhookURL string) string {
+	return ks.ownerPrefix(owner) + url.PathEscape(webhookURL)
+}
+
+func (ks *kvWebhookStore) Add(owner string, w *Webhook) error {
+	value, err := json.Marshal(w)
+	if err != nil {
+		return err
+	}
+
+	return ks.kv.Put(ks.key(owner, w.Config.URL), value)
+}
+
+func (ks *kvWebhookStore) Delete(owner string, webhookURL string) error {
+	pairs, err := ks.kv.List(ks.ownerPrefix(owner))
+	if err != nil {
+		return err
+	} else if len(pairs) == 0 {
+		return ErrOwnerNotFound
+	}
+
+	key := ks.key(owner, webhookURL)
+	if _, ok := pairs[key]; !ok {
+		return ErrWebhookNotFound
+	}
+
+	return ks.kv.Delete(key)
+}
+
+// decode unmarshals a stored webhook, logging and returning nil if the value is malformed
+func (ks *kvWebhookStore) decode(key string, value []byte) *Webhook {
+	w := new(Webhook)
+	if err := json.Unmarshal(value, w); err != nil {
+		ks.logger.Error.Log("msg", "skipping malformed webhook", "key", key, "error", err)
+		return nil
+	}
+
+	return w
+}
+
+func (ks *kvWebhookStore) AllWebhooks(owner string) ([]*Webhook, error) {
+	pairs, err := ks.kv.List(ks.ownerPrefix(owner))
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]*Webhook, 0, len(pairs))
+	for key, value := range pairs {
+		if w := ks.decode(key, value); w != nil {
+			webhooks = append(webhooks, w)
+		}
+	}
+
+	return webhooks, nil
+}
+
+func (ks *kvWebhookStore) AllWebhooksByOwner() (map[string][]*Webhook, error) {
+	pairs, err := ks.kv.List(ks.prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	byOwner := make(map[string][]*Webhook)
+	for key, value := range pairs {
+		escaped := strings.SplitN(strings.TrimPrefix(key, ks.prefix), "/", 2)[0]
+		owner, err := url.PathUnescape(escaped)
+		if err != nil {
+			ks.logger.Error.Log("msg", "skipping webhook with malformed owner", "key", key, "error", err)
+			continue
+		}
+
+		if w := ks.decode(key, value); w != nil {
+			byOwner[owner] = append(byOwner[owner], w)
+		}
+	}
+
+	return byOwner, nil
+}
+
+func (ks *kvWebhookStore) Compact(now time.Time) (int, error) {
+	pairs, err := ks.kv.List(ks.prefix)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for key, value := range pairs {
+		if w := ks.decode(key, value); w == nil || !expired(w, now) {
+			continue
+		}
+
+		if err := ks.kv.Delete(key); err != nil {
+			return removed, err
+		}
+
+		removed++
+	}
+
+	return removed, nil
+}
+
+// ConsulKVClient is the subset of the consul KV API used by a consul-backed KV.
+// The *api.KV returned by (*api.Client).KV() implements this interface.
+type ConsulKVClient interface {
+	Get(key string, q *api.QueryOptions) (*api.KVPair, *api.QueryMeta, error)
+	List(prefix string, q *api.QueryOptions) (api.KVPairs, *api.QueryMeta, error)
+	Put(p *api.KVPair, q *api.WriteOptions) (*api.WriteMeta, error)
+	Delete(key string, w *api.WriteOptions) (*api.WriteMeta, error)
+}
+
+// consulKV adapts the consul KV API onto the KV interface
+type consulKV struct {
+	client ConsulKVClient
+}
+
+// NewConsulKV creates a KV backed by consul.  For example:
+//
+//	store := NewKVWebhookStore(NewConsulKV(client.KV()), "webpa/webhooks", logger)
+func NewConsulKV(client ConsulKVClient) KV {
+	return consulKV{client}
+}
+
+func (c consulKV) Get(key string) ([]byte, error) {
+	pair, _, err := c.client.Get(key, nil)
+	if err != nil || pair == nil {
+		return nil, err
+	}
+
+	return pair.Value, nil
+}
+
+func (c consulKV) Put(key string, value []byte) error {
+	_, err := c.client.Put(&api.KVPair{Key: key, Value: value}, nil)
+	return err
+}
+
+func (c consulKV) Delete(key string) error {
+	_, err := c.client.Delete(key, nil)
+	return err
+}
+
+func (c consulKV) List(prefix string) (map[string][]byte, error) {
+	pairs, _, err := c.client.List(prefix, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string][]byte, len(pairs))
+	for _, pair := range pairs {
+		values[pair.Key] = pair.Value
+	}
+
+	return values, nil
+}
@@ -0,0 +1,97 @@
+package mhook
+
+import (
+	"sync/atomic"
+
+	"github.com/jithin-kg/webpa-common/webhook"
+)
+
+// List is a Watch which exposes the most recent webhooks as a webhook.List, so that
+// subscriptions managed by this package can be served by a webhook.Dispatcher.
+type List struct {
+	value atomic.Value
+}
+
+// NewList creates an empty List
+func NewList() *List {
+	l := new(List)
+	l.value.Store([]webhook.W{})
+	return l
+}
+
+// toW converts a Webhook into the equivalent webhook.W
+func toW(wh Webhook) webhook.W {
+	var w webhook.W
+	w.Address = wh.Address
+	w.Owner = wh.Owner
+	w.Config.URL = wh.Config.URL
+	w.Config.ContentType = wh.Config.ContentType
+	w.Config.Secret = wh.Config.Secret
+	w.Config.SignatureAlgorithm = wh.Config.SignatureAlgorithm
+	w.Config.PreviousSecret = wh.Config.PreviousSecret
+	w.Config.PreviousSecretUntil = wh.Config.PreviousSecretUntil
+	w.Config.AlternativeURLs = wh.Config.AlternativeURLs
+	w.FailureURL = wh.FailureURL
+	w.Events = wh.Events
+	w.Matcher = toMatcher(wh.Matcher)
+	w.Duration = wh.Duration
+	w.Until = wh.Until
+
+	if len(w.Matcher.DeviceId) == 0 {
+		w.Matcher.DeviceId = []string{".*"}
+	}
+
+	return w
+}
+
+// toMatcher converts a Matcher, including any nested matchers, to the webhook package's type
+func toMatcher(m Matcher) webhook.Matcher {
+	wm := webhook.Matcher{
+		DeviceId:    m.DeviceID,
+		Source:      m.Source,
+		Destination: m.Destination,
+		PartnerIDs:  m.PartnerIDs,
+		Convey:      m.Convey,
+		Metadata:    m.Metadata,
+	}
+
+	for _, nested := range m.All {
+		wm.All = append(wm.All, toMatcher(nested))
+	}
+
+	for _, nested := range m.Any {
+		wm.Any = append(wm.Any, toMatcher(nested))
+	}
+
+	if m.Not != nil {
+		not := toMatcher(*m.Not)
+		wm.Not = &not
+	}
+
+	return wm
+}
+
+// Update replaces the contents of this List
+func (l *List) Update(webhooks []Webhook) {
+	list := make([]webhook.W, len(webhooks))
+	for i, wh := range webhooks {
+		list[i] = toW(wh)
+	}
+
+	l.value.Store(list)
+}
+
+func (l *List) Len() int {
+	list, _ := l.value.Load().([]webhook.W)
+	return len(list)
+}
+
+func (l *List) Get(index int) *webhook.W {
+	list, _ := l.value.Load().([]webhook.W)
+	return &list[index]
+}
+
+func (l *List) Snapshot() []webhook.W {
+	list, _ := l.value.Load().([]webhook.W)
+	return list
+}
@@ -0,0 +1,77 @@
+package mhook
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jithin-kg/webpa-common/webhook"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestList(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		l      = NewList()
+
+		wh Webhook
+	)
+
+	var _ webhook.List = l
+	var _ Watch = l
+
+	assert.Equal(0, l.Len())
+
+	wh.Config.URL = "http://example.com/hook"
+	wh.Config.Secret = "secret"
+	wh.Config.SignatureAlgorithm = webhook.SignatureSHA256
+	wh.Config.PreviousSecret = "previous"
+	wh.Config.PreviousSecretUntil = time.Now().Add(time.Hour)
+	wh.Events = []string{"device-status/.*"}
+	l.Update([]Webhook{wh})
+
+	assert.Equal(1, l.Len())
+	w := l.Get(0)
+	assert.Equal("http://example.com/hook", w.ID())
+	assert.Equal("secret", w.Config.Secret)
+	assert.Equal(webhook.SignatureSHA256, w.Config.SignatureAlgorithm)
+	assert.Equal([]string{"secret", "previous"}, w.ActiveSecrets(time.Now()))
+	assert.Equal([]string{"device-status/.*"}, w.Events)
+	assert.Equal([]string{".*"}, w.Matcher.DeviceId)
+
+	wh.Matcher.DeviceID = []string{"mac:112233445566"}
+	l.Update([]Webhook{wh, wh})
+	assert.Equal(2, l.Len())
+	assert.Equal([]string{"mac:112233445566"}, l.Get(1).Matcher.DeviceId)
+
+	l.Update(nil)
+	assert.Equal(0, l.Len())
+}
+
+func TestToMatcher(t *testing.T) {
+	m := Matcher{
+		DeviceID:    []string{"mac:.*"},
+		Source:      []string{"source"},
+		Destination: []string{"destination"},
+		PartnerIDs:  []string{"comcast"},
+		Convey:      map[string][]string{"hw-model": {"TG1682"}},
+		Metadata:    map[string][]string{"/trust": {"1000"}},
+		All:         []Matcher{{Source: []string{"all"}}},
+		Any:         []Matcher{{Source: []string{"any"}}, {Not: &Matcher{Source: []string{"nested"}}}},
+		Not:         &Matcher{Source: []string{"not"}},
+	}
+
+	assert.Equal(t,
+		webhook.Matcher{
+			DeviceId:    []string{"mac:.*"},
+			Source:      []string{"source"},
+			Destination: []string{"destination"},
+			PartnerIDs:  []string{"comcast"},
+			Convey:      map[string][]string{"hw-model": {"TG1682"}},
+			Metadata:    map[string][]string{"/trust": {"1000"}},
+			All:         []webhook.Matcher{{Source: []string{"all"}}},
+			Any:         []webhook.Matcher{{Source: []string{"any"}}, {Not: &webhook.Matcher{Source: []string{"nested"}}}},
+			Not:         &webhook.Matcher{Source: []string{"not"}},
+		},
+		toMatcher(m),
+	)
+}
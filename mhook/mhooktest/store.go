@@ -0,0 +1,203 @@
+// Package mhooktest provides testing support for mhook, including a conformance suite
+// for WebhookStore implementations.
+package mhooktest
+
+import (
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/jithin-kg/webpa-common/mhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// StoreFactory creates a new, empty WebhookStore for a single test
+type StoreFactory func(*testing.T) mhook.WebhookStore
+
+// NewWebhook is a convenience for creating a webhook with the given URL and expiry
+func NewWebhook(url string, until time.Time) *mhook.Webhook {
+	w := new(mhook.Webhook)
+	w.Config.URL = url
+	w.Config.ContentType = "application/json"
+	w.Config.Secret = "secret"
+	w.Events = []string{".*"}
+	w.Matcher.DeviceID = []string{".*"}
+	w.Until = until.UTC().Truncate(time.Second)
+	return w
+}
+
+func urls(webhooks []*mhook.Webhook) []string {
+	result := make([]string, 0, len(webhooks))
+	for _, w := range webhooks {
+		result = append(result, w.Config.URL)
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+// TestStore runs the conformance tests that all WebhookStore implementations must pass
+func TestStore(t *testing.T, factory StoreFactory) {
+	t.Run("Empty", func(t *testing.T) { testStoreEmpty(t, factory(t)) })
+	t.Run("Add", func(t *testing.T) { testStoreAdd(t, factory(t)) })
+	t.Run("Replace", func(t *testing.T) { testStoreReplace(t, factory(t)) })
+	t.Run("Owners", func(t *testing.T) { testStoreOwners(t, factory(t)) })
+	t.Run("Delete", func(t *testing.T) { testStoreDelete(t, factory(t)) })
+	t.Run("Compact", func(t *testing.T) { testStoreCompact(t, factory(t)) })
+}
+
+func testStoreEmpty(t *testing.T, store mhook.WebhookStore) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	webhooks, err := store.AllWebhooks("owner")
+	require.NoError(err)
+	assert.Empty(webhooks)
+
+	byOwner, err := store.AllWebhooksByOwner()
+	require.NoError(err)
+	assert.Empty(byOwner)
+
+	removed, err := store.Compact(time.Now())
+	assert.NoError(err)
+	assert.Zero(removed)
+}
+
+func testStoreAdd(t *testing.T, store mhook.WebhookStore) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		until    = time.Now().Add(time.Hour)
+		expected = NewWebhook("http://example.com/one", until)
+	)
+
+	expected.Address = "127.0.0.1"
+	expected.FailureURL = "http://example.com/failure"
+	expected.Config.AlternativeURLs = []string{"http://example.com/alternate"}
+	expected.Matcher.DeviceID = []string{"mac:112233445566"}
+
+	require.NoError(store.Add("owner", expected))
+	require.NoError(store.Add("owner", NewWebhook("http://example.com/two", until)))
+
+	webhooks, err := store.AllWebhooks("owner")
+	require.NoError(err)
+	assert.Equal([]string{"http://example.com/one", "http://example.com/two"}, urls(webhooks))
+
+	for _, actual := range webhooks {
+		if actual.Config.URL == expected.Config.URL {
+			assert.Equal(*expected, *actual)
+		}
+	}
+}
+
+func testStoreReplace(t *testing.T, store mhook.WebhookStore) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		original = NewWebhook("http://example.com/", time.Now().Add(time.Hour))
+		renewed  = NewWebhook("http://example.com/", time.Now().Add(2*time.Hour))
+	)
+
+	renewed.Events = []string{"device-status/.*"}
+	require.NoError(store.Add("owner", original))
+	require.NoError(store.Add("owner", renewed))
+
+	webhooks, err := store.AllWebhooks("owner")
+	require.NoError(err)
+	require.Len(webhooks, 1)
+	assert.Equal(*renewed, *webhooks[0])
+}
+
+func testStoreOwners(t *testing.T, store mhook.WebhookStore) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		until = time.Now().Add(time.Hour)
+	)
+
+	// the same URL may be registered by different owners, including owners with unusual characters
+	require.NoError(store.Add("owner", NewWebhook("http://example.com/", until)))
+	require.NoError(store.Add("owner/other", NewWebhook("http://example.com/", until)))
+	require.NoError(store.Add("owner/other", NewWebhook("http://example.com/other", until)))
+
+	webhooks, err := store.AllWebhooks("owner")
+	require.NoError(err)
+	assert.Equal([]string{"http://example.com/"}, urls(webhooks))
+
+	webhooks, err = store.AllWebhooks("owner/other")
+	require.NoError(err)
+	assert.Equal([]string{"http://example.com/", "http://example.com/other"}, urls(webhooks))
+
+	byOwner, err := store.AllWebhooksByOwner()
+	require.NoError(err)
+	require.Len(byOwner, 2)
+	assert.Equal([]string{"http://example.com/"}, urls(byOwner["owner"]))
+	assert.Equal([]string{"http://example.com/", "http://example.com/other"}, urls(byOwner["owner/other"]))
+}
+
+func testStoreDelete(t *testing.T, store mhook.WebhookStore) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		until = time.Now().Add(time.Hour)
+	)
+
+	assert.Equal(mhook.ErrOwnerNotFound, store.Delete("owner", "http://example.com/one"))
+
+	require.NoError(store.Add("owner", NewWebhook("http://example.com/one", until)))
+	require.NoError(store.Add("owner", NewWebhook("http://example.com/two", until)))
+	assert.Equal(mhook.ErrWebhookNotFound, store.Delete("owner", "http://example.com/nosuch"))
+
+	require.NoError(store.Delete("owner", "http://example.com/one"))
+	webhooks, err := store.AllWebhooks("owner")
+	require.NoError(err)
+	assert.Equal([]string{"http://example.com/two"}, urls(webhooks))
+
+	// once an owner has no webhooks, it no longer exists
+	require.NoError(store.Delete("owner", "http://example.com/two"))
+	assert.Equal(mhook.ErrOwnerNotFound, store.Delete("owner", "http://example.com/two"))
+
+	// a deleted webhook can be added again
+	assert.NoError(store.Add("owner", NewWebhook("http://example.com/one", until)))
+}
+
+func testStoreCompact(t *testing.T, store mhook.WebhookStore) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		now = time.Now()
+	)
+
+	require.NoError(store.Add("owner", NewWebhook("http://example.com/expired", now.Add(-time.Minute))))
+	require.NoError(store.Add("owner", NewWebhook("http://example.com/current", now.Add(time.Hour))))
+	require.NoError(store.Add("owner", NewWebhook("http://example.com/forever", time.Time{})))
+	require.NoError(store.Add("other", NewWebhook("http://example.com/expired", now.Add(-time.Hour))))
+
+	removed, err := store.Compact(now)
+	require.NoError(err)
+	assert.Equal(2, removed)
+
+	webhooks, err := store.AllWebhooks("owner")
+	require.NoError(err)
+	assert.Equal([]string{"http://example.com/current", "http://example.com/forever"}, urls(webhooks))
+
+	webhooks, err = store.AllWebhooks("other")
+	require.NoError(err)
+	assert.Empty(webhooks)
+
+	byOwner, err := store.AllWebhooksByOwner()
+	require.NoError(err)
+	assert.Len(byOwner, 1)
+
+	removed, err = store.Compact(now)
+	assert.NoError(err)
+	assert.Zero(removed)
+}
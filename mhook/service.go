@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"runtime/debug"
+	"sync"
 	"time"
 
 	"io/ioutil"
@@ -11,14 +12,36 @@ import (
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
 	"github.com/go-kit/kit/metrics/generic"
+	"github.com/jithin-kg/webpa-common/webhook"
 
 	"gopkg.in/yaml.v2"
 )
 
-// Service describes the core operations around webhook subscriptions.
+const (
+	// DefaultExpiryInterval is how often a Service removes webhooks whose Until has passed
+	DefaultExpiryInterval = 30 * time.Second
+
+	// DefaultSecretRotationGracePeriod is how long deliveries continue to be signed with a webhook's
+	// previous secret after it is renewed with a new secret
+	DefaultSecretRotationGracePeriod = webhook.DefaultSecretRotationGracePeriod
+)
+
+// Service describes the core operations around webhook subscriptions.  Every change to the set
+// of webhooks, including the expiry of webhooks, is propagated to the service's watches along with
+// the current webhooks of all owners.
 type Service interface {
+	// Add registers a webhook, replacing and thereby renewing any existing webhook with the same owner and URL.
+	// If the existing webhook has a different secret, that secret remains active for a grace period.
 	Add(owner string, w *Webhook) error
+
+	// Delete removes an owner's webhook
+	Delete(owner string, url string) error
+
 	AllWebhooks(owner string) ([]*Webhook, error)
+
+	// AllWebhooksByOwner returns the webhooks of all owners.  This is intended for administrative use.
+	AllWebhooksByOwner() (map[string][]*Webhook, error)
+
 	AddWebhookFromYaml(yamlFile string) error
 }
 
@@ -28,30 +51,141 @@ type loggerGroup struct {
 }
 
 type service struct {
+	// lock serializes changes to the store with the updates they cause, so that watches always
+	// receive the most recent webhooks last
+	lock sync.Mutex
+
 	store    WebhookStore
 	callback func([]Webhook)
 	logger   *loggerGroup
+	now      func() time.Time
+}
+
+// update sends the current, unexpired webhooks of all owners to the callback.  This method must
+// be invoked under the lock.
+func (s *service) update() error {
+	byOwner, err := s.store.AllWebhooksByOwner()
+	if err != nil {
+		return err
+	}
+
+	var (
+		now      = s.now()
+		webhooks []Webhook
+	)
+
+	for owner, ownerWebhooks := range byOwner {
+		for _, wh := range ownerWebhooks {
+			if !expired(wh, now) {
+				copyOf := *wh
+				copyOf.Owner = owner
+				webhooks = append(webhooks, copyOf)
+			}
+		}
+	}
+
+	s.callback(webhooks)
+	return nil
+}
+
+// expire removes expired webhooks from the store, updating the watches if any were removed
+func (s *service) expire() {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	removed, err := s.store.Compact(s.now())
+	if err != nil {
+		s.logger.Error.Log("msg", "unable to remove expired webhooks", "error", err)
+	}
+
+	if removed > 0 {
+		s.logger.Debug.Log("msg", "removed expired webhooks", "count", removed)
+		if err := s.update(); err != nil {
+			s.logger.Error.Log("msg", "unable to update watches", "error", err)
+		}
+	}
+}
+
+// rotate sets a webhook's previous secret from the webhook it replaces.  A renewal with the same secret
+// keeps any rotation in progress, while a renewal with a new secret begins a grace period for the old one.
+// Any previous secret supplied by the caller is discarded, so that only a real rotation can set one.
+// This method must be invoked under the lock.
+func (s *service) rotate(owner string, w *Webhook) {
+	w.Config.PreviousSecret = ""
+	w.Config.PreviousSecretUntil = time.Time{}
+
+	existing, err := s.store.AllWebhooks(owner)
+	if err != nil {
+		return
+	}
+
+	for _, e := range existing {
+		if e.Config.URL != w.Config.URL {
+			continue
+		}
+
+		if e.Config.Secret == w.Config.Secret {
+			w.Config.PreviousSecret = e.Config.PreviousSecret
+			w.Config.PreviousSecretUntil = e.Config.PreviousSecretUntil
+		} else if len(e.Config.Secret) > 0 {
+			s.logger.Debug.Log("msg", "webhook secret rotated", "owner", owner, "url", w.Config.URL)
+			w.Config.PreviousSecret = e.Config.Secret
+			w.Config.PreviousSecretUntil = s.now().Add(DefaultSecretRotationGracePeriod)
+		}
+
+		return
+	}
 }
 
 func (s *service) Add(owner string, w *Webhook) error {
-	s.logger.Debug.Log("msg", "Add() called", "owner", owner, "webhook", fmt.Sprintf("%+v", w))
+	s.logger.Debug.Log("msg", "Add() called", "owner", owner, "url", w.Config.URL)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	s.rotate(owner, w)
 	err := s.store.Add(owner, w)
 	if err != nil {
 		return err
 	}
-	allWebhooks, err := s.AllWebhooks(owner)
+
+	return s.update()
+}
+
+func (s *service) Delete(owner string, url string) error {
+	s.logger.Debug.Log("msg", "Delete() called", "owner", owner, "url", url)
+	s.lock.Lock()
+	defer s.lock.Unlock()
+
+	err := s.store.Delete(owner, url)
 	if err != nil {
 		return err
 	}
 
-	webhooks := make([]Webhook, len(allWebhooks))
-	for i, wh := range allWebhooks {
-		webhooks[i] = *wh
+	return s.update()
+}
+
+// AllWebhooksByOwner returns the unexpired webhooks of all owners.  Expired webhooks remain in the
+// store until the next compaction, so they are filtered here.
+func (s *service) AllWebhooksByOwner() (map[string][]*Webhook, error) {
+	byOwner, err := s.store.AllWebhooksByOwner()
+	if err != nil {
+		return nil, err
 	}
 
-	s.callback(webhooks)
+	var (
+		now    = s.now()
+		result = make(map[string][]*Webhook, len(byOwner))
+	)
 
-	return nil
+	for owner, ownerWebhooks := range byOwner {
+		for _, wh := range ownerWebhooks {
+			if !expired(wh, now) {
+				result[owner] = append(result[owner], wh)
+			}
+		}
+	}
+
+	return result, nil
 }
 
 func (s *service) AllWebhooks(owner string) ([]*Webhook, error) {
@@ -68,27 +202,43 @@ func (s *service) AllWebhooks(owner string) ([]*Webhook, error) {
 		return nil, err
 	}
 
-	webhooksPtr := make([]*Webhook, len(webhooks))
+	// expired webhooks remain in the store until the next compaction
+	var (
+		now         = s.now()
+		webhooksPtr = make([]*Webhook, 0, len(webhooks))
+	)
 
-	for i, wh := range webhooks {
-		webhooksPtr[i] = wh
+	for _, wh := range webhooks {
+		if !expired(wh, now) {
+			webhooksPtr = append(webhooksPtr, wh)
+		}
 	}
 
 	return webhooksPtr, nil
 }
 
+// Initialize creates a Service backed by an in-memory WebhookStore
 func Initialize(watches ...Watch) (Service, func(), error) {
+	return InitializeWithStore(nil, watches...)
+}
+
+// InitializeWithStore creates a Service backed by the given WebhookStore, such as one created by
+// NewFileWebhookStore or NewKVWebhookStore.  If store is nil, an in-memory store is used.
+func InitializeWithStore(store WebhookStore, watches ...Watch) (Service, func(), error) {
 	rootLogger := log.NewLogfmtLogger(os.Stdout)
 	rootLogger = log.With(rootLogger, "ts", log.DefaultTimestampUTC)
 	rootLogger = log.With(rootLogger, "caller", log.DefaultCaller)
 	loggers := newLoggerGroup(rootLogger)
 	loggers.Debug.Log("msg", "initialize called")
 	watches = append(watches, webhookListSizeWatch(generic.NewGauge(WebhookListSizeGauge)))
-	store := NewWebhookStore(loggers)
+	if store == nil {
+		store = NewWebhookStore(loggers)
+	}
 
 	svc := &service{
 		store:  store,
 		logger: loggers,
+		now:    time.Now,
 		callback: func(webhooks []Webhook) {
 			// here watches is empty, so update will never be called
 			for _, watch := range watches {
@@ -96,7 +246,31 @@ func Initialize(watches ...Watch) (Service, func(), error) {
 			}
 		},
 	}
-	return svc, func() { /*...*/ }, nil
+
+	// make webhooks that survived a restart available to the watches
+	if err := svc.update(); err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		ticker   = time.NewTicker(DefaultExpiryInterval)
+		done     = make(chan struct{})
+		stopOnce sync.Once
+	)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				svc.expire()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return svc, func() { stopOnce.Do(func() { close(done) }) }, nil
 }
 func (s *service) AddWebhookFromYaml(yamlFile string) error {
 	data, err := ioutil.ReadFile(yamlFile)
@@ -2,6 +2,8 @@ package mhook
 
 import (
 	"fmt"
+	"sort"
+	"sync"
 	"testing"
 	"time"
 
@@ -15,10 +17,13 @@ func TestService(t *testing.T) {
 	wh := &Webhook{
 		Address: "https://client.example.com",
 		Config: struct {
-			URL             string   `json:"url"`
-			ContentType     string   `json:"content_type"`
-			Secret          string   `json:"secret,omitempty"`
-			AlternativeURLs []string `json:"alt_urls,omitempty"`
+			URL                 string    `json:"url"`
+			ContentType         string    `json:"content_type"`
+			Secret              string    `json:"secret,omitempty"`
+			SignatureAlgorithm  string    `json:"signature_algorithm,omitempty"`
+			PreviousSecret      string    `json:"previous_secret,omitempty"`
+			PreviousSecretUntil time.Time `json:"previous_secret_until,omitempty"`
+			AlternativeURLs     []string  `json:"alt_urls,omitempty"`
 		}{
 			URL:         "https://example.com/webhook",
 			ContentType: "application/json",
@@ -26,9 +31,7 @@ func TestService(t *testing.T) {
 		},
 		FailureURL: "https://failure.example.com",
 		Events:     []string{"event1", "event2"},
-		Matcher: struct {
-			DeviceID []string `json:"device_id"`
-		}{
+		Matcher: Matcher{
 			DeviceID: []string{"device1", "device2"},
 		},
 		Duration: time.Second * 5,
@@ -67,10 +70,13 @@ func TestService(t *testing.T) {
 	wh2 := &Webhook{
 		Address: "https://client2.example.com",
 		Config: struct {
-			URL             string   `json:"url"`
-			ContentType     string   `json:"content_type"`
-			Secret          string   `json:"secret,omitempty"`
-			AlternativeURLs []string `json:"alt_urls,omitempty"`
+			URL                 string    `json:"url"`
+			ContentType         string    `json:"content_type"`
+			Secret              string    `json:"secret,omitempty"`
+			SignatureAlgorithm  string    `json:"signature_algorithm,omitempty"`
+			PreviousSecret      string    `json:"previous_secret,omitempty"`
+			PreviousSecretUntil time.Time `json:"previous_secret_until,omitempty"`
+			AlternativeURLs     []string  `json:"alt_urls,omitempty"`
 		}{
 			URL:         "https://example.com/webhook2",
 			ContentType: "application/json",
@@ -78,9 +84,7 @@ func TestService(t *testing.T) {
 		},
 		FailureURL: "https://failure2.example.com",
 		Events:     []string{"event1", "event2"},
-		Matcher: struct {
-			DeviceID []string `json:"device_id"`
-		}{
+		Matcher: Matcher{
 			DeviceID: []string{"device1", "device2"},
 		},
 		Duration: time.Second * 5,
@@ -99,3 +103,225 @@ func TestService(t *testing.T) {
 	time.Sleep(time.Second * 10)
 
 }
+
+func newTestWebhook(url string, until time.Time) *Webhook {
+	w := new(Webhook)
+	w.Config.URL = url
+	w.Config.Secret = "secret"
+	w.Events = []string{".*"}
+	w.Until = until
+	return w
+}
+
+func newTestService(now *time.Time) (*service, *[][]Webhook) {
+	updates := new([][]Webhook)
+	return &service{
+		store:    NewWebhookStore(nil),
+		logger:   newLoggerGroup(nil),
+		now:      func() time.Time { return *now },
+		callback: func(webhooks []Webhook) { *updates = append(*updates, webhooks) },
+	}, updates
+}
+
+func webhookURLs(webhooks []Webhook) []string {
+	result := make([]string, 0, len(webhooks))
+	for _, w := range webhooks {
+		result = append(result, w.Config.URL)
+	}
+
+	sort.Strings(result)
+	return result
+}
+
+func TestServiceCRUD(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		now          = time.Now()
+		svc, updates = newTestService(&now)
+	)
+
+	// watches receive the webhooks of all owners
+	require.NoError(svc.Add("owner1", newTestWebhook("http://example.com/one", now.Add(time.Minute))))
+	require.NoError(svc.Add("owner2", newTestWebhook("http://example.com/two", now.Add(time.Minute))))
+	require.Len(*updates, 2)
+	assert.Equal([]string{"http://example.com/one", "http://example.com/two"}, webhookURLs((*updates)[1]))
+	for _, w := range (*updates)[1] {
+		assert.Equal(map[string]string{"http://example.com/one": "owner1", "http://example.com/two": "owner2"}[w.Config.URL], w.Owner)
+	}
+
+	// re-adding a webhook renews it
+	require.NoError(svc.Add("owner1", newTestWebhook("http://example.com/one", now.Add(time.Hour))))
+	webhooks, err := svc.AllWebhooks("owner1")
+	require.NoError(err)
+	require.Len(webhooks, 1)
+	assert.Equal(now.Add(time.Hour), webhooks[0].Until)
+
+	byOwner, err := svc.AllWebhooksByOwner()
+	require.NoError(err)
+	assert.Len(byOwner, 2)
+
+	assert.Equal(ErrWebhookNotFound, svc.Delete("owner1", "http://example.com/two"))
+	require.Len(*updates, 3)
+
+	require.NoError(svc.Delete("owner2", "http://example.com/two"))
+	require.Len(*updates, 4)
+	assert.Equal([]string{"http://example.com/one"}, webhookURLs((*updates)[3]))
+}
+
+func TestServiceExpire(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		now          = time.Now()
+		svc, updates = newTestService(&now)
+	)
+
+	require.NoError(svc.Add("owner", newTestWebhook("http://example.com/short", now.Add(time.Minute))))
+	require.NoError(svc.Add("owner", newTestWebhook("http://example.com/long", now.Add(time.Hour))))
+	require.NoError(svc.Add("owner", newTestWebhook("http://example.com/forever", time.Time{})))
+	require.Len(*updates, 3)
+
+	// nothing has expired, so the watches are not updated
+	svc.expire()
+	assert.Len(*updates, 3)
+
+	now = now.Add(5 * time.Minute)
+	svc.expire()
+	require.Len(*updates, 4)
+	assert.Equal([]string{"http://example.com/forever", "http://example.com/long"}, webhookURLs((*updates)[3]))
+
+	webhooks, err := svc.AllWebhooks("owner")
+	require.NoError(err)
+	assert.Len(webhooks, 2)
+}
+
+func TestServiceFiltersUncompactedWebhooks(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		now    = time.Now()
+		svc, _ = newTestService(&now)
+	)
+
+	require.NoError(svc.Add("owner", newTestWebhook("http://example.com/short", now.Add(time.Minute))))
+	require.NoError(svc.Add("owner", newTestWebhook("http://example.com/long", now.Add(time.Hour))))
+	require.NoError(svc.Add("other", newTestWebhook("http://example.com/short", now.Add(time.Minute))))
+
+	// the short webhooks have expired, but have not yet been compacted
+	now = now.Add(5 * time.Minute)
+
+	webhooks, err := svc.AllWebhooks("owner")
+	require.NoError(err)
+	require.Len(webhooks, 1)
+	assert.Equal("http://example.com/long", webhooks[0].Config.URL)
+
+	byOwner, err := svc.AllWebhooksByOwner()
+	require.NoError(err)
+	require.Len(byOwner, 1)
+	require.Len(byOwner["owner"], 1)
+	assert.Equal("http://example.com/long", byOwner["owner"][0].Config.URL)
+}
+
+func TestServiceConcurrentUpdates(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		now          = time.Now()
+		svc, updates = newTestService(&now)
+		wg           sync.WaitGroup
+		expected     []string
+	)
+
+	for i := 0; i < 20; i++ {
+		url := fmt.Sprintf("http://example.com/%02d", i)
+		expected = append(expected, url)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			assert.NoError(svc.Add("owner", newTestWebhook(url, now.Add(time.Hour))))
+		}()
+	}
+
+	wg.Wait()
+
+	// the last update the watches receive reflects every change
+	assert.Len(*updates, 20)
+	assert.Equal(expected, webhookURLs((*updates)[len(*updates)-1]))
+}
+
+func TestInitializeWithStore(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		store   = NewWebhookStore(nil)
+		updates [][]Webhook
+	)
+
+	require.NoError(store.Add("owner", newTestWebhook("http://example.com/", time.Now().Add(time.Hour))))
+	require.NoError(store.Add("owner", newTestWebhook("http://example.com/expired", time.Now().Add(-time.Hour))))
+
+	// webhooks already in the store are sent to the watches
+	_, cleanup, err := InitializeWithStore(store, WatchFunc(func(webhooks []Webhook) {
+		updates = append(updates, webhooks)
+	}))
+
+	require.NoError(err)
+	cleanup()
+	cleanup()
+
+	require.Len(updates, 1)
+	assert.Equal([]string{"http://example.com/"}, webhookURLs(updates[0]))
+}
+
+func TestServiceRotatesSecrets(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		now    = time.Now()
+		svc, _ = newTestService(&now)
+
+		current = func() *Webhook {
+			webhooks, err := svc.AllWebhooks("owner")
+			require.NoError(err)
+			require.Len(webhooks, 1)
+			return webhooks[0]
+		}
+	)
+
+	// a previous secret cannot be supplied by the caller
+	initial := newTestWebhook("http://example.com/", now.Add(time.Hour))
+	initial.Config.PreviousSecret = "injected"
+	initial.Config.PreviousSecretUntil = now.Add(time.Hour)
+	require.NoError(svc.Add("owner", initial))
+	assert.Empty(current().Config.PreviousSecret)
+	assert.Zero(current().Config.PreviousSecretUntil)
+
+	// renewing with a new secret keeps the old secret active for the grace period
+	rotated := newTestWebhook("http://example.com/", now.Add(time.Hour))
+	rotated.Config.Secret = "rotated"
+	require.NoError(svc.Add("owner", rotated))
+	assert.Equal("rotated", current().Config.Secret)
+	assert.Equal("secret", current().Config.PreviousSecret)
+	assert.Equal(now.Add(DefaultSecretRotationGracePeriod), current().Config.PreviousSecretUntil)
+
+	// renewing with the same secret keeps the rotation in progress
+	now = now.Add(time.Minute)
+	renewed := newTestWebhook("http://example.com/", now.Add(time.Hour))
+	renewed.Config.Secret = "rotated"
+	require.NoError(svc.Add("owner", renewed))
+	assert.Equal("secret", current().Config.PreviousSecret)
+	assert.Equal(now.Add(DefaultSecretRotationGracePeriod-time.Minute), current().Config.PreviousSecretUntil)
+
+	// other owners' webhooks are unaffected
+	require.NoError(svc.Add("other", newTestWebhook("http://example.com/", now.Add(time.Hour))))
+	webhooks, err := svc.AllWebhooks("other")
+	require.NoError(err)
+	assert.Empty(webhooks[0].Config.PreviousSecret)
+}
@@ -0,0 +1,173 @@
+package mhook_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/jithin-kg/webpa-common/mhook"
+	"github.com/jithin-kg/webpa-common/mhook/mhooktest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeConsulKV is an in-memory mhook.ConsulKVClient
+type fakeConsulKV struct {
+	lock  sync.Mutex
+	pairs map[string][]byte
+}
+
+func newFakeConsulKV() *fakeConsulKV {
+	return &fakeConsulKV{pairs: make(map[string][]byte)}
+}
+
+func (f *fakeConsulKV) Get(key string, _ *api.QueryOptions) (*api.KVPair, *api.QueryMeta, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if value, ok := f.pairs[key]; ok {
+		return &api.KVPair{Key: key, Value: value}, nil, nil
+	}
+
+	return nil, nil, nil
+}
+
+func (f *fakeConsulKV) List(prefix string, _ *api.QueryOptions) (api.KVPairs, *api.QueryMeta, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	var pairs api.KVPairs
+	for key, value := range f.pairs {
+		if strings.HasPrefix(key, prefix) {
+			pairs = append(pairs, &api.KVPair{Key: key, Value: value})
+		}
+	}
+
+	return pairs, nil, nil
+}
+
+func (f *fakeConsulKV) Put(p *api.KVPair, _ *api.WriteOptions) (*api.WriteMeta, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.pairs[p.Key] = p.Value
+	return nil, nil
+}
+
+func (f *fakeConsulKV) Delete(key string, _ *api.WriteOptions) (*api.WriteMeta, error) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	delete(f.pairs, key)
+	return nil, nil
+}
+
+func newTempDir(t *testing.T, parent string) string {
+	dir, err := ioutil.TempDir(parent, "mhook")
+	require.NoError(t, err)
+	return dir
+}
+
+func TestMemoryWebhookStore(t *testing.T) {
+	mhooktest.TestStore(t, func(*testing.T) mhook.WebhookStore {
+		return mhook.NewWebhookStore(nil)
+	})
+}
+
+func TestFileWebhookStore(t *testing.T) {
+	parent := newTempDir(t, "")
+	defer os.RemoveAll(parent)
+
+	mhooktest.TestStore(t, func(t *testing.T) mhook.WebhookStore {
+		store, err := mhook.NewFileWebhookStore(filepath.Join(newTempDir(t, parent), "webhooks.json"), nil)
+		require.NoError(t, err)
+		return store
+	})
+
+	t.Run("Reopen", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			dir  = newTempDir(t, parent)
+			path = filepath.Join(dir, "webhooks.json")
+			now  = time.Now()
+		)
+
+		store, err := mhook.NewFileWebhookStore(path, nil)
+		require.NoError(err)
+
+		expected := mhooktest.NewWebhook("http://example.com/current", now.Add(time.Hour))
+		require.NoError(store.Add("owner", expected))
+		require.NoError(store.Add("owner", mhooktest.NewWebhook("http://example.com/expired", now.Add(-time.Minute))))
+
+		info, err := os.Stat(path)
+		require.NoError(err)
+		assert.Equal(os.FileMode(0600), info.Mode().Perm())
+
+		// only the store's file remains after writes
+		files, err := ioutil.ReadDir(dir)
+		require.NoError(err)
+		assert.Len(files, 1)
+
+		// expired webhooks are discarded when the file is loaded
+		reopened, err := mhook.NewFileWebhookStore(path, nil)
+		require.NoError(err)
+
+		webhooks, err := reopened.AllWebhooks("owner")
+		require.NoError(err)
+		require.Len(webhooks, 1)
+		assert.Equal(*expected, *webhooks[0])
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		path := filepath.Join(newTempDir(t, parent), "webhooks.json")
+		require.NoError(t, ioutil.WriteFile(path, []byte("this is not JSON"), 0600))
+
+		store, err := mhook.NewFileWebhookStore(path, nil)
+		assert.Nil(t, store)
+		assert.Error(t, err)
+	})
+
+	t.Run("WriteFailure", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			dir = newTempDir(t, parent)
+		)
+
+		store, err := mhook.NewFileWebhookStore(filepath.Join(dir, "missing", "webhooks.json"), nil)
+		require.NoError(err)
+
+		// failed writes leave the store unchanged
+		assert.Error(store.Add("owner", mhooktest.NewWebhook("http://example.com/", time.Now().Add(time.Hour))))
+		webhooks, err := store.AllWebhooks("owner")
+		require.NoError(err)
+		assert.Empty(webhooks)
+	})
+}
+
+func TestKVWebhookStore(t *testing.T) {
+	mhooktest.TestStore(t, func(*testing.T) mhook.WebhookStore {
+		return mhook.NewKVWebhookStore(mhook.NewConsulKV(newFakeConsulKV()), "", nil)
+	})
+
+	t.Run("Prefix", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			consul = newFakeConsulKV()
+			store  = mhook.NewKVWebhookStore(mhook.NewConsulKV(consul), "webpa/webhooks", nil)
+		)
+
+		require.NoError(store.Add("owner", mhooktest.NewWebhook("http://example.com/hook", time.Now().Add(time.Hour))))
+		assert.Contains(consul.pairs, "webpa/webhooks/owner/http:%2F%2Fexample.com%2Fhook")
+	})
+}
@@ -11,6 +11,7 @@ import (
 
 	kithttp "github.com/go-kit/kit/transport/http"
 
+	"github.com/jithin-kg/webpa-common/webhook"
 	"github.com/jithin-kg/webpa-common/xhttp"
 	"github.com/xmidt-org/bascule"
 )
@@ -29,6 +30,9 @@ const (
 	jsonContentType   string = "application/json"
 )
 
+// obfuscatedSecret replaces secrets in responses
+const obfuscatedSecret = "<obfuscated>"
+
 type getAllWebhooksRequest struct {
 	owner string
 }
@@ -38,6 +42,25 @@ type addWebhookRequest struct {
 	webhook *Webhook
 }
 
+type deleteWebhookRequest struct {
+	owner string
+	url   string
+}
+
+type getDeliveriesRequest struct {
+	owner string
+	url   string
+	from  time.Time
+	to    time.Time
+}
+
+type replayRequest struct {
+	owner string
+	url   string
+	from  time.Time
+	to    time.Time
+}
+
 func decodeGetAllWebhooksRequest(ctx context.Context, r *http.Request) (interface{}, error) {
 	return &getAllWebhooksRequest{
 		owner: getOwner(r),
@@ -45,8 +68,7 @@ func decodeGetAllWebhooksRequest(ctx context.Context, r *http.Request) (interfac
 }
 
 func encodeGetAllWebhooksResponse(ctx context.Context, rw http.ResponseWriter, response interface{}) error {
-	webhooks := response.([]Webhook)
-	obfuscateSecrets(webhooks)
+	webhooks := obfuscateSecrets(response.([]*Webhook))
 	encodedWebhooks, err := json.Marshal(&webhooks)
 	if err != nil {
 		return err
@@ -57,34 +79,185 @@ func encodeGetAllWebhooksResponse(ctx context.Context, rw http.ResponseWriter, r
 	return err
 }
 
-func decodeAddWebhookRequest(ctx context.Context, r *http.Request) (interface{}, error) {
-	requestPayload, err := ioutil.ReadAll(r.Body)
+func encodeGetAllOwnersWebhooksResponse(ctx context.Context, rw http.ResponseWriter, response interface{}) error {
+	byOwner := response.(map[string][]*Webhook)
+	obfuscated := make(map[string][]Webhook, len(byOwner))
+	for owner, webhooks := range byOwner {
+		obfuscated[owner] = obfuscateSecrets(webhooks)
+	}
+
+	encoded, err := json.Marshal(obfuscated)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	webhook := new(Webhook)
 
-	err = json.Unmarshal(requestPayload, webhook)
+	rw.Header().Set(contentTypeHeader, jsonContentType)
+	_, err = rw.Write(encoded)
+	return err
+}
+
+func decodeDeleteWebhookRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	request := &deleteWebhookRequest{
+		owner: getOwner(r),
+		url:   r.URL.Query().Get("url"),
+	}
+
+	if len(request.url) == 0 {
+		var body struct {
+			URL string `json:"url"`
+		}
+
+		if payload, err := ioutil.ReadAll(r.Body); err == nil && len(payload) > 0 {
+			if err := json.Unmarshal(payload, &body); err != nil {
+				return nil, &xhttp.Error{Code: http.StatusBadRequest, Text: "invalid request body"}
+			}
+		}
+
+		request.url = body.URL
+	}
+
+	if strings.TrimSpace(request.url) == "" {
+		return nil, &xhttp.Error{Code: http.StatusBadRequest, Text: "missing webhook url"}
+	}
+
+	return request, nil
+}
+
+func encodeDeleteWebhookResponse(ctx context.Context, rw http.ResponseWriter, _ interface{}) error {
+	rw.Header().Set(contentTypeHeader, jsonContentType)
+	rw.Write([]byte(`{"message": "Success"}`))
+	return nil
+}
+
+// parseTimeParameter parses an optional RFC3339 query parameter
+func parseTimeParameter(r *http.Request, name string) (time.Time, error) {
+	value := r.URL.Query().Get(name)
+	if len(value) == 0 {
+		return time.Time{}, nil
+	}
+
+	t, err := time.Parse(time.RFC3339, value)
 	if err != nil {
-		//TODO: we should get rid of this if we can. It's not listed in our swagger page but I'm keeping it just to
-		// match the current behavior.
-		webhook, err = getFirstFromList(requestPayload)
-		if err != nil {
-			return nil, err
+		return time.Time{}, &xhttp.Error{Code: http.StatusBadRequest, Text: "invalid " + name + " time"}
+	}
+
+	return t, nil
+}
+
+func decodeGetDeliveriesRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var (
+		request = &getDeliveriesRequest{
+			owner: getOwner(r),
+			url:   r.URL.Query().Get("url"),
 		}
+
+		err error
+	)
+
+	if request.from, err = parseTimeParameter(r, "from"); err != nil {
+		return nil, err
 	}
 
-	err = validateWebhook(webhook, r.RemoteAddr)
+	if request.to, err = parseTimeParameter(r, "to"); err != nil {
+		return nil, err
+	}
+
+	return request, nil
+}
+
+func encodeGetDeliveriesResponse(ctx context.Context, rw http.ResponseWriter, response interface{}) error {
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		return err
+	}
+
+	rw.Header().Set(contentTypeHeader, jsonContentType)
+	_, err = rw.Write(encoded)
+	return err
+}
+
+func decodeReplayRequest(ctx context.Context, r *http.Request) (interface{}, error) {
+	var body struct {
+		URL  string    `json:"url"`
+		From time.Time `json:"from"`
+		To   time.Time `json:"to"`
+	}
+
+	payload, err := ioutil.ReadAll(r.Body)
 	if err != nil {
 		return nil, err
 	}
 
-	return &addWebhookRequest{
-		owner:   getOwner(r),
-		webhook: webhook,
+	if err := json.Unmarshal(payload, &body); err != nil {
+		return nil, &xhttp.Error{Code: http.StatusBadRequest, Text: "invalid request body"}
+	}
+
+	if strings.TrimSpace(body.URL) == "" {
+		return nil, &xhttp.Error{Code: http.StatusBadRequest, Text: "missing webhook url"}
+	}
+
+	return &replayRequest{
+		owner: getOwner(r),
+		url:   body.URL,
+		from:  body.From,
+		to:    body.To,
 	}, nil
 }
 
+func encodeReplayResponse(ctx context.Context, rw http.ResponseWriter, response interface{}) error {
+	encoded, err := json.Marshal(map[string]interface{}{
+		"message":  "Success",
+		"replayed": response.(int),
+	})
+
+	if err != nil {
+		return err
+	}
+
+	rw.Header().Set(contentTypeHeader, jsonContentType)
+	_, err = rw.Write(encoded)
+	return err
+}
+
+// newAddWebhookRequestDecoder creates the decoder for add requests.  The validator, if not nil, is applied
+// to each webhook after its basic fields are checked.
+func newAddWebhookRequestDecoder(v webhook.Validator) kithttp.DecodeRequestFunc {
+	return func(ctx context.Context, r *http.Request) (interface{}, error) {
+		requestPayload, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			return nil, err
+		}
+		wh := new(Webhook)
+
+		err = json.Unmarshal(requestPayload, wh)
+		if err != nil {
+			//TODO: we should get rid of this if we can. It's not listed in our swagger page but I'm keeping it just to
+			// match the current behavior.
+			wh, err = getFirstFromList(requestPayload)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		err = validateWebhook(wh, r.RemoteAddr)
+		if err != nil {
+			return nil, err
+		}
+
+		if v != nil {
+			w := toW(*wh)
+			if err := v.Validate(ctx, &w); err != nil {
+				return nil, err
+			}
+		}
+
+		return &addWebhookRequest{
+			owner:   getOwner(r),
+			webhook: wh,
+		}, nil
+	}
+}
+
 func encodeAddWebhookResponse(ctx context.Context, rw http.ResponseWriter, _ interface{}) error {
 	rw.Header().Set(contentTypeHeader, jsonContentType)
 	rw.Write([]byte(`{"message": "Success"}`))
@@ -123,10 +296,35 @@ func getFirstFromList(requestPayload []byte) (*Webhook, error) {
 	return &webhooks[0], nil
 }
 
-func obfuscateSecrets(webhooks []Webhook) {
-	for i := range webhooks {
-		webhooks[i].Config.Secret = "<obfuscated>"
+// obfuscate hides a secret, while still indicating whether one is set
+func obfuscate(secret string) string {
+	if len(secret) > 0 {
+		return obfuscatedSecret
 	}
+
+	return ""
+}
+
+// obfuscateSecrets returns copies of the given webhooks with their secrets hidden.  Secrets are write-only,
+// so every handler that returns webhooks must use this function.
+func obfuscateSecrets(webhooks []*Webhook) []Webhook {
+	obfuscated := make([]Webhook, len(webhooks))
+	for i, w := range webhooks {
+		obfuscated[i] = *w
+		obfuscated[i].Config.Secret = obfuscate(w.Config.Secret)
+		obfuscated[i].Config.PreviousSecret = obfuscate(w.Config.PreviousSecret)
+	}
+
+	return obfuscated
+}
+
+// validateSignatureAlgorithm rejects webhooks with signature algorithms the dispatcher cannot use
+func validateSignatureAlgorithm(algorithm string) error {
+	if err := webhook.ValidateSignatureAlgorithm(algorithm); err != nil {
+		return &xhttp.Error{Code: http.StatusBadRequest, Text: err.Error()}
+	}
+
+	return nil
 }
 
 func validateWebhook(webhook *Webhook, requestOriginAddress string) (err error) {
@@ -144,6 +342,15 @@ func validateWebhook(webhook *Webhook, requestOriginAddress string) (err error)
 		webhook.Matcher.DeviceID = []string{".*"} // match anything
 	}
 
+	w := toW(*webhook)
+	if _, err := w.Compile(); err != nil {
+		return &xhttp.Error{Code: http.StatusBadRequest, Text: err.Error()}
+	}
+
+	if err := validateSignatureAlgorithm(w.Config.SignatureAlgorithm); err != nil {
+		return err
+	}
+
 	if webhook.Address == "" && requestOriginAddress != "" {
 		host, _, err := net.SplitHostPort(requestOriginAddress)
 		if err != nil {
@@ -152,12 +359,13 @@ func validateWebhook(webhook *Webhook, requestOriginAddress string) (err error)
 		webhook.Address = host
 	}
 
-	// always set duration to default
-	webhook.Duration = defaultWebhookExpiration
+	// the previous secret is only ever set when the service rotates the secret
+	webhook.Config.PreviousSecret = ""
+	webhook.Config.PreviousSecretUntil = time.Time{}
 
-	// if &webhook.Until == nil || webhook.Until.Equal(time.Time{}) {
-	// 	webhook.Until = time.Now().Add(webhook.Duration)
-	// }
+	// always set duration to default, and renew the registration for that duration
+	webhook.Duration = defaultWebhookExpiration
+	webhook.Until = time.Now().Add(webhook.Duration)
 
 	return nil
 }
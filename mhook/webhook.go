@@ -17,10 +17,23 @@ type Webhook struct {
 		// ContentType is content type value to set WRP messages to (unless already specified in the WRP).
 		ContentType string `json:"content_type"`
 
-		// Secret is the string value for the SHA1 HMAC.
+		// Secret is the string value for the HMAC signature of each delivery.  It is write-only, and is
+		// obfuscated when webhooks are listed.
 		// (Optional, set to "" to disable behavior).
 		Secret string `json:"secret,omitempty"`
 
+		// SignatureAlgorithm is the HMAC algorithm used to sign deliveries: sha1, sha256, or sha512.
+		// (Optional, defaults to sha1 for compatibility).
+		SignatureAlgorithm string `json:"signature_algorithm,omitempty"`
+
+		// PreviousSecret is the secret replaced by the most recent rotation.  Deliveries are signed with
+		// both secrets until PreviousSecretUntil.  It is set automatically when a webhook is renewed with a
+		// new secret, and is obfuscated when webhooks are listed.
+		PreviousSecret string `json:"previous_secret,omitempty"`
+
+		// PreviousSecretUntil is the end of the grace period for PreviousSecret.
+		PreviousSecretUntil time.Time `json:"previous_secret_until,omitempty"`
+
 		// AlternativeURLs is a list of explicit URLs that should be round robin through on failure cases to the main URL.
 		AlternativeURLs []string `json:"alt_urls,omitempty"`
 	} `json:"config"`
@@ -33,10 +46,7 @@ type Webhook struct {
 	Events []string `json:"events"`
 
 	// Matcher type contains values to match against the metadata.
-	Matcher struct {
-		// DeviceID is the list of regular expressions to match device id type against.
-		DeviceID []string `json:"device_id"`
-	} `json:"matcher,omitempty"`
+	Matcher Matcher `json:"matcher,omitempty"`
 
 	// Duration describes how long the subscription lasts once added.
 	// Deprecated. User input is ignored and value is always 5m.
@@ -44,4 +54,40 @@ type Webhook struct {
 
 	// Until describes the time this subscription expires.
 	Until time.Time `json:"until"`
+
+	// Owner is the owner of the subscription.  It is not stored, and is only set on the webhooks
+	// passed to watches.
+	Owner string `json:"-"`
+}
+
+// Matcher contains the criteria, beyond event type, that an event must meet to be delivered to a webhook.
+// Each field is a list of regular expressions, any of which may match.  Empty fields impose no constraint.
+// See webhook.Matcher for the details of how matchers are evaluated and composed.
+type Matcher struct {
+	// DeviceID is the list of regular expressions to match device id type against.
+	DeviceID []string `json:"device_id"`
+
+	// Source is the list of regular expressions to match the WRP source against.
+	Source []string `json:"source,omitempty"`
+
+	// Destination is the list of regular expressions to match the WRP destination against.
+	Destination []string `json:"destination,omitempty"`
+
+	// PartnerIDs is the list of regular expressions to match the device's partner ids against.
+	PartnerIDs []string `json:"partner_ids,omitempty"`
+
+	// Convey maps device convey keys, such as hw-model, onto regular expressions for their values.
+	Convey map[string][]string `json:"convey,omitempty"`
+
+	// Metadata maps WRP metadata keys onto regular expressions for their values.
+	Metadata map[string][]string `json:"metadata,omitempty"`
+
+	// All is a list of matchers which must all match.
+	All []Matcher `json:"all,omitempty"`
+
+	// Any is a list of matchers of which at least one must match.
+	Any []Matcher `json:"any,omitempty"`
+
+	// Not is a matcher which must not match.
+	Not *Matcher `json:"not,omitempty"`
 }
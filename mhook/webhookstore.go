@@ -4,20 +4,48 @@ import (
 	"errors"
 	"fmt"
 	"sync"
+	"time"
 )
 
+var (
+	ErrOwnerNotFound   = errors.New("owner not found")
+	ErrWebhookNotFound = errors.New("webhook not found")
+)
+
+// WebhookStore is the storage strategy for webhook subscriptions.  Webhooks are keyed by owner
+// and Config.URL.
 type WebhookStore interface {
+	// Add stores a webhook, replacing any existing webhook with the same owner and URL
 	Add(owner string, w *Webhook) error
+
 	Delete(owner string, url string) error
 	AllWebhooks(owner string) ([]*Webhook, error)
+
+	// AllWebhooksByOwner returns the webhooks of every owner
+	AllWebhooksByOwner() (map[string][]*Webhook, error)
+
+	// Compact removes all webhooks whose Until is before the given time, returning the
+	// number of webhooks removed.  Webhooks with a zero Until never expire.
+	Compact(now time.Time) (int, error)
+}
+
+// expired tests if a webhook's Until has passed
+func expired(w *Webhook, now time.Time) bool {
+	return !w.Until.IsZero() && w.Until.Before(now)
 }
+
 type webhookStore struct {
 	store  map[string]map[string]*Webhook // owner -> url -> Webhook
 	mu     sync.RWMutex
 	logger *loggerGroup
 }
 
+// NewWebhookStore creates an in-memory WebhookStore.  A nil logger discards log output.
 func NewWebhookStore(logger *loggerGroup) WebhookStore {
+	if logger == nil {
+		logger = newLoggerGroup(nil)
+	}
+
 	return &webhookStore{
 		store:  make(map[string]map[string]*Webhook),
 		logger: logger,
@@ -33,12 +61,7 @@ func (ws *webhookStore) Add(owner string, w *Webhook) error {
 		ws.store[owner] = make(map[string]*Webhook)
 	}
 
-	// Check if the webhook already exists
-	if _, ok := ws.store[owner][w.Config.URL]; ok {
-		return errors.New("webhook already exists")
-	}
-
-	// Add the webhook
+	// Add the webhook, replacing any existing registration
 	ws.store[owner][w.Config.URL] = w
 	// Log the added webhook
 	ws.logger.Debug.Log("msg", fmt.Sprintf("Add() Added webhook: %+v\n", *w))
@@ -52,16 +75,19 @@ func (ws *webhookStore) Delete(owner string, url string) error {
 
 	// Check if the owner's map exists
 	if ws.store[owner] == nil {
-		return errors.New("owner not found")
+		return ErrOwnerNotFound
 	}
 
 	// Check if the webhook exists
 	if _, ok := ws.store[owner][url]; !ok {
-		return errors.New("webhook not found")
+		return ErrWebhookNotFound
 	}
 
-	// Delete the webhook
+	// Delete the webhook, along with the owner once it has no more webhooks
 	delete(ws.store[owner], url)
+	if len(ws.store[owner]) == 0 {
+		delete(ws.store, owner)
+	}
 
 	return nil
 }
@@ -84,3 +110,38 @@ func (ws *webhookStore) AllWebhooks(owner string) ([]*Webhook, error) {
 	ws.logger.Debug.Log("msg", fmt.Sprintf("AllWebhooks() current store is : %+v\n", ws.store))
 	return webhooks, nil
 }
+
+func (ws *webhookStore) AllWebhooksByOwner() (map[string][]*Webhook, error) {
+	ws.mu.RLock()
+	defer ws.mu.RUnlock()
+
+	byOwner := make(map[string][]*Webhook, len(ws.store))
+	for owner, webhooks := range ws.store {
+		for _, w := range webhooks {
+			byOwner[owner] = append(byOwner[owner], w)
+		}
+	}
+
+	return byOwner, nil
+}
+
+func (ws *webhookStore) Compact(now time.Time) (int, error) {
+	ws.mu.Lock()
+	defer ws.mu.Unlock()
+
+	removed := 0
+	for owner, webhooks := range ws.store {
+		for url, w := range webhooks {
+			if expired(w, now) {
+				delete(webhooks, url)
+				removed++
+			}
+		}
+
+		if len(webhooks) == 0 {
+			delete(ws.store, owner)
+		}
+	}
+
+	return removed, nil
+}
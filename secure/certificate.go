@@ -0,0 +1,216 @@
+package secure
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"strings"
+)
+
+const (
+	// Certificate is the TokenType for tokens that represent a verified client certificate.
+	// Tokens of this type are never produced by ParseAuthorization.
+	Certificate TokenType = "Certificate"
+
+	// SPIFFEScheme is the URI scheme of SPIFFE identifiers carried as certificate SAN URIs
+	SPIFFEScheme = "spiffe"
+)
+
+var (
+	ErrorNoPeerCertificate = errors.New("No verified client certificate in context")
+)
+
+type peerCertificateKey struct{}
+
+// WithPeerCertificate returns a context carrying the given verified client certificate
+func WithPeerCertificate(ctx context.Context, cert *x509.Certificate) context.Context {
+	return context.WithValue(ctx, peerCertificateKey{}, cert)
+}
+
+// PeerCertificateFromContext returns the verified client certificate in the context, if any
+func PeerCertificateFromContext(ctx context.Context) (*x509.Certificate, bool) {
+	cert, ok := ctx.Value(peerCertificateKey{}).(*x509.Certificate)
+	return cert, ok && cert != nil
+}
+
+// NewCertificateToken creates a Token of type Certificate for the given client certificate.
+// The token's value is the certificate's subject common name.
+func NewCertificateToken(cert *x509.Certificate) *Token {
+	return &Token{
+		tokenType: Certificate,
+		value:     cert.Subject.CommonName,
+		trust:     Untrusted,
+	}
+}
+
+// CertificateIdentity is the security information established for a client certificate.
+// It carries the same information that would otherwise come from JWT claims.
+type CertificateIdentity struct {
+	// Subject is the principal, i.e. the SPIFFE ID, SAN URI, or common name that matched
+	Subject string
+
+	PartnerIDs   []string
+	Capabilities []string
+	Trust        string
+}
+
+// CertificateRule maps client certificates onto a CertificateIdentity.  At least one of
+// CommonName, URI, or SPIFFEID must be set, and all that are set must match.  Each of these
+// may end with "*", in which case it matches as a prefix.
+type CertificateRule struct {
+	CommonName string `json:"commonName"`
+	URI        string `json:"uri"`
+	SPIFFEID   string `json:"spiffeID"`
+
+	PartnerIDs   []string `json:"partnerIDs"`
+	Capabilities []string `json:"capabilities"`
+	Trust        string   `json:"trust"`
+}
+
+// matchPattern tests if value matches pattern, which may end in a "*" wildcard
+func matchPattern(pattern, value string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(value, pattern[:len(pattern)-1])
+	}
+
+	return pattern == value
+}
+
+// matchURI returns the first SAN URI with the given scheme that matches the pattern.
+// An empty scheme matches any URI.
+func matchURI(pattern, scheme string, cert *x509.Certificate) (string, bool) {
+	for _, u := range cert.URIs {
+		if len(scheme) > 0 && u.Scheme != scheme {
+			continue
+		}
+
+		if value := u.String(); matchPattern(pattern, value) {
+			return value, true
+		}
+	}
+
+	return "", false
+}
+
+// Match tests if the given certificate matches this rule.  If so, the identity is returned.
+func (r CertificateRule) Match(cert *x509.Certificate) (CertificateIdentity, bool) {
+	if len(r.CommonName) == 0 && len(r.URI) == 0 && len(r.SPIFFEID) == 0 {
+		return CertificateIdentity{}, false
+	}
+
+	subject := cert.Subject.CommonName
+	if len(r.CommonName) > 0 && !matchPattern(r.CommonName, subject) {
+		return CertificateIdentity{}, false
+	}
+
+	if len(r.URI) > 0 {
+		value, ok := matchURI(r.URI, "", cert)
+		if !ok {
+			return CertificateIdentity{}, false
+		}
+
+		subject = value
+	}
+
+	if len(r.SPIFFEID) > 0 {
+		value, ok := matchURI(r.SPIFFEID, SPIFFEScheme, cert)
+		if !ok {
+			return CertificateIdentity{}, false
+		}
+
+		subject = value
+	}
+
+	trust := r.Trust
+	if len(trust) == 0 {
+		trust = Untrusted
+	}
+
+	return CertificateIdentity{
+		Subject:      subject,
+		PartnerIDs:   append([]string{}, r.PartnerIDs...),
+		Capabilities: append([]string{}, r.Capabilities...),
+		Trust:        trust,
+	}, true
+}
+
+// CertificateIdentifier is the strategy for establishing an identity from a verified client certificate
+type CertificateIdentifier interface {
+	// Identify returns the identity of the given certificate, or false if the certificate is unknown
+	Identify(*x509.Certificate) (CertificateIdentity, bool)
+}
+
+// CertificateValidator provides validation for tokens of type Certificate.  The client certificate
+// is taken from the context, as established by WithPeerCertificate.  Rules are evaluated in order,
+// and the first matching rule establishes the identity.
+//
+// Consistent with JWSValidator, a certificate is only valid if its identity grants at least
+// one capability.
+type CertificateValidator struct {
+	Rules []CertificateRule `json:"rules"`
+
+	// Trust is the optional policy used to compute the trust of valid certificates.  The policy sees
+	// the certificate's issuer and the Subject of the identity established by the matching rule, so
+	// trust rules can be bound to particular clients rather than to every certificate of an issuer.
+	// If unset, the trust of the matching rule is used.
+	Trust *TrustPolicy `json:"trust"`
+
+	measures *JWTValidationMeasures
+}
+
+func (v CertificateValidator) Identify(cert *x509.Certificate) (CertificateIdentity, bool) {
+	for _, r := range v.Rules {
+		if identity, ok := r.Match(cert); ok {
+			return identity, true
+		}
+	}
+
+	return CertificateIdentity{}, false
+}
+
+func (v CertificateValidator) observe(reason string) {
+	if v.measures != nil {
+		v.measures.ValidationReason.With("reason", reason).Add(1)
+	}
+}
+
+func (v CertificateValidator) Validate(ctx context.Context, token *Token) (bool, error) {
+	if token.Type() != Certificate {
+		return false, nil
+	}
+
+	cert, ok := PeerCertificateFromContext(ctx)
+	if !ok {
+		v.observe("missing_certificate")
+		return false, ErrorNoPeerCertificate
+	}
+
+	identity, ok := v.Identify(cert)
+	if !ok {
+		v.observe("unknown_certificate")
+		return false, nil
+	}
+
+	if len(identity.Capabilities) == 0 {
+		v.observe("no_capabilities")
+		return false, nil
+	}
+
+	if v.Trust != nil {
+		token.trust, token.trustRule = v.Trust.Compute(TrustInput{
+			Issuer:            cert.Issuer.CommonName,
+			Subject:           identity.Subject,
+			ClientCertificate: true,
+		})
+	} else {
+		token.trust = identity.Trust
+	}
+
+	v.observe("ok")
+	return true, nil
+}
+
+//DefineMeasures defines the metrics tool used by CertificateValidator
+func (v *CertificateValidator) DefineMeasures(m *JWTValidationMeasures) {
+	v.measures = m
+}
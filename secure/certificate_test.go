@@ -0,0 +1,167 @@
+package secure
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testCertificate(commonName string, uris ...string) *x509.Certificate {
+	cert := &x509.Certificate{
+		Subject: pkix.Name{CommonName: commonName},
+	}
+
+	for _, u := range uris {
+		parsed, err := url.Parse(u)
+		if err != nil {
+			panic(err)
+		}
+
+		cert.URIs = append(cert.URIs, parsed)
+	}
+
+	return cert
+}
+
+func TestPeerCertificateContext(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		cert   = testCertificate("test")
+	)
+
+	actual, ok := PeerCertificateFromContext(context.Background())
+	assert.Nil(actual)
+	assert.False(ok)
+
+	actual, ok = PeerCertificateFromContext(WithPeerCertificate(context.Background(), cert))
+	assert.Equal(cert, actual)
+	assert.True(ok)
+}
+
+func TestNewCertificateToken(t *testing.T) {
+	assert := assert.New(t)
+	token := NewCertificateToken(testCertificate("test"))
+
+	assert.Equal(Certificate, token.Type())
+	assert.Equal("test", token.Value())
+	assert.Equal(Untrusted, token.Trust())
+}
+
+func TestCertificateRuleMatch(t *testing.T) {
+	var testData = []struct {
+		rule            CertificateRule
+		cert            *x509.Certificate
+		expectedMatch   bool
+		expectedSubject string
+		expectedTrust   string
+	}{
+		{CertificateRule{}, testCertificate("test"), false, "", ""},
+		{CertificateRule{CommonName: "test", Trust: "1000"}, testCertificate("test"), true, "test", "1000"},
+		{CertificateRule{CommonName: "test"}, testCertificate("other"), false, "", ""},
+		{CertificateRule{CommonName: "svc-*"}, testCertificate("svc-talaria"), true, "svc-talaria", Untrusted},
+		{CertificateRule{URI: "https://example.com/*"}, testCertificate("test", "https://example.com/svc"), true, "https://example.com/svc", Untrusted},
+		{CertificateRule{URI: "https://example.com/*"}, testCertificate("test", "https://other.com/svc"), false, "", ""},
+		{CertificateRule{SPIFFEID: "spiffe://example.org/talaria"}, testCertificate("test", "https://example.com", "spiffe://example.org/talaria"), true, "spiffe://example.org/talaria", Untrusted},
+		{CertificateRule{SPIFFEID: "spiffe://example.org/*"}, testCertificate("test", "https://example.org/talaria"), false, "", ""},
+		{CertificateRule{CommonName: "test", SPIFFEID: "spiffe://example.org/*"}, testCertificate("other", "spiffe://example.org/talaria"), false, "", ""},
+	}
+
+	for _, record := range testData {
+		identity, ok := record.rule.Match(record.cert)
+		assert.Equal(t, record.expectedMatch, ok, "%#v", record.rule)
+		assert.Equal(t, record.expectedSubject, identity.Subject, "%#v", record.rule)
+		assert.Equal(t, record.expectedTrust, identity.Trust, "%#v", record.rule)
+	}
+}
+
+func TestCertificateValidator(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		validator = CertificateValidator{
+			Rules: []CertificateRule{
+				{CommonName: "nocaps"},
+				{
+					SPIFFEID:     "spiffe://example.org/*",
+					PartnerIDs:   []string{"comcast"},
+					Capabilities: []string{"x1:webpa:api:.*:all"},
+					Trust:        "1000",
+				},
+			},
+		}
+	)
+
+	t.Run("WrongTokenType", func(t *testing.T) {
+		valid, err := validator.Validate(context.Background(), &Token{tokenType: Bearer})
+		assert.False(valid)
+		assert.NoError(err)
+	})
+
+	t.Run("NoCertificate", func(t *testing.T) {
+		valid, err := validator.Validate(context.Background(), NewCertificateToken(testCertificate("test")))
+		assert.False(valid)
+		assert.Equal(ErrorNoPeerCertificate, err)
+	})
+
+	t.Run("UnknownCertificate", func(t *testing.T) {
+		cert := testCertificate("unknown")
+		valid, err := validator.Validate(WithPeerCertificate(context.Background(), cert), NewCertificateToken(cert))
+		assert.False(valid)
+		assert.NoError(err)
+	})
+
+	t.Run("NoCapabilities", func(t *testing.T) {
+		cert := testCertificate("nocaps")
+		valid, err := validator.Validate(WithPeerCertificate(context.Background(), cert), NewCertificateToken(cert))
+		assert.False(valid)
+		assert.NoError(err)
+	})
+
+	t.Run("Success", func(t *testing.T) {
+		var (
+			cert  = testCertificate("test", "spiffe://example.org/talaria")
+			token = NewCertificateToken(cert)
+		)
+
+		valid, err := validator.Validate(WithPeerCertificate(context.Background(), cert), token)
+		assert.True(valid)
+		assert.NoError(err)
+		assert.Equal("1000", token.Trust())
+
+		identity, ok := validator.Identify(cert)
+		require.True(ok)
+		assert.Equal("spiffe://example.org/talaria", identity.Subject)
+		assert.Equal([]string{"comcast"}, identity.PartnerIDs)
+	})
+
+	t.Run("TrustPolicySubject", func(t *testing.T) {
+		withPolicy := validator
+		withPolicy.Trust = &TrustPolicy{
+			Rules: []TrustRule{
+				{Name: "talaria", Subject: "spiffe://example.org/talaria", Trust: "1000"},
+			},
+			Default: "100",
+		}
+
+		for subject, expected := range map[string]string{
+			"spiffe://example.org/talaria": "1000",
+			"spiffe://example.org/other":   "100",
+		} {
+			var (
+				cert  = testCertificate("test", subject)
+				token = NewCertificateToken(cert)
+			)
+
+			valid, err := withPolicy.Validate(WithPeerCertificate(context.Background(), cert), token)
+			assert.True(valid)
+			assert.NoError(err)
+			assert.Equal(expected, token.Trust(), subject)
+		}
+	})
+}
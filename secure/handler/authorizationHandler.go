@@ -109,7 +109,13 @@ func (a AuthorizationHandler) Decorate(delegate http.Handler) http.Handler {
 			Trust:  secure.Untrusted, // trust isn't set on the token until validation (ugh)
 		}
 
-		sharedContext := NewContextWithValue(request.Context(), contextValues)
+		ctx := request.Context()
+		if request.TLS != nil && len(request.TLS.VerifiedChains) > 0 && len(request.TLS.VerifiedChains[0]) > 0 {
+			// makes client certificate presence available to trust policies
+			ctx = secure.WithPeerCertificate(ctx, request.TLS.VerifiedChains[0][0])
+		}
+
+		sharedContext := NewContextWithValue(ctx, contextValues)
 
 		valid, err := a.Validator.Validate(sharedContext, token)
 		if err == nil && valid {
@@ -120,6 +126,7 @@ func (a AuthorizationHandler) Decorate(delegate http.Handler) http.Handler {
 			// this is absolutely horrible, but it's the only way we can do it for now.
 			// TODO: address this in a redesign
 			contextValues.Trust = token.Trust()
+			contextValues.TrustRule = token.TrustRule()
 			delegate.ServeHTTP(response, request.WithContext(sharedContext))
 			return
 		}
@@ -0,0 +1,124 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/go-kit/kit/log"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/secure"
+	"github.com/jithin-kg/webpa-common/xhttp"
+)
+
+// CertificateValidator is the behavior required of validators used to authenticate
+// requests by client certificate.  secure.CertificateValidator implements this interface.
+type CertificateValidator interface {
+	secure.Validator
+	secure.CertificateIdentifier
+}
+
+// CertificateAuthorizationHandler provides decoration for http.Handler instances and will
+// ensure that requests present a verified client certificate which passes the validator.
+// On success, the ContextValues in the request context are populated from the certificate's
+// identity just as AuthorizationHandler populates them from a JWT.
+//
+// Only certificates that were verified during the TLS handshake are considered.  Servers
+// must request client certificates, e.g. with tls.VerifyClientCertIfGiven, for this handler
+// to be useful.
+type CertificateAuthorizationHandler struct {
+	ForbiddenStatusCode int
+	Validator           CertificateValidator
+	Logger              log.Logger
+	measures            *secure.JWTValidationMeasures
+}
+
+// forbiddenStatusCode returns a.ForbiddenStatusCode if supplied, otherwise
+// http.StatusForbidden is returned
+func (a CertificateAuthorizationHandler) forbiddenStatusCode() int {
+	if a.ForbiddenStatusCode > 0 {
+		return a.ForbiddenStatusCode
+	}
+
+	return http.StatusForbidden
+}
+
+func (a CertificateAuthorizationHandler) logger() log.Logger {
+	if a.Logger != nil {
+		return a.Logger
+	}
+
+	return logging.DefaultLogger()
+}
+
+// Decorate provides an Alice-compatible constructor that validates requests
+// using the configuration specified.
+func (a CertificateAuthorizationHandler) Decorate(delegate http.Handler) http.Handler {
+	// if there is no validator, there's no point in decorating anything
+	if a.Validator == nil {
+		return delegate
+	}
+
+	var (
+		forbiddenStatusCode = a.forbiddenStatusCode()
+		errorLog            = logging.Error(a.logger())
+	)
+
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		if request.TLS == nil || len(request.TLS.VerifiedChains) == 0 || len(request.TLS.VerifiedChains[0]) == 0 {
+			errorLog.Log(logging.MessageKey(), "missing verified client certificate")
+			xhttp.WriteError(response, forbiddenStatusCode, "missing verified client certificate")
+
+			if a.measures != nil {
+				a.measures.ValidationReason.With("reason", "missing_certificate").Add(1)
+			}
+			return
+		}
+
+		var (
+			cert  = request.TLS.VerifiedChains[0][0]
+			token = secure.NewCertificateToken(cert)
+
+			contextValues = &ContextValues{
+				Method: request.Method,
+				Path:   request.URL.Path,
+				Trust:  secure.Untrusted,
+			}
+
+			sharedContext = NewContextWithValue(
+				secure.WithPeerCertificate(request.Context(), cert),
+				contextValues,
+			)
+		)
+
+		valid, err := a.Validator.Validate(sharedContext, token)
+		if err == nil && valid {
+			if identity, ok := a.Validator.Identify(cert); ok {
+				contextValues.SatClientID = identity.Subject
+				contextValues.PartnerIDs = identity.PartnerIDs
+			}
+
+			contextValues.Trust = token.Trust()
+			contextValues.TrustRule = token.TrustRule()
+			delegate.ServeHTTP(response, request.WithContext(sharedContext))
+			return
+		}
+
+		errorLog.Log(
+			logging.MessageKey(), "request denied",
+			"validator-response", valid,
+			"validator-error", err,
+			"subject", cert.Subject.String(),
+			"method", request.Method,
+			"url", request.URL,
+			"user-agent", request.Header.Get("User-Agent"),
+			"content-length", request.ContentLength,
+			"remoteAddress", request.RemoteAddr,
+		)
+
+		xhttp.WriteError(response, forbiddenStatusCode, "request denied")
+	})
+}
+
+//DefineMeasures facilitates clients to define authHandler metrics tools
+func (a *CertificateAuthorizationHandler) DefineMeasures(m *secure.JWTValidationMeasures) {
+	a.measures = m
+}
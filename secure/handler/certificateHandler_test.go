@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/secure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newCertificateRequest(commonName string) *http.Request {
+	request := httptest.NewRequest("GET", "/api/v2/device", nil)
+	request.TLS = &tls.ConnectionState{
+		VerifiedChains: [][]*x509.Certificate{
+			{{Subject: pkix.Name{CommonName: commonName}}},
+		},
+	}
+
+	return request
+}
+
+func testCertificateAuthorizationHandlerNoDecoration(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		nextCalled = false
+		next       = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			nextCalled = true
+		})
+
+		handler = CertificateAuthorizationHandler{
+			Logger: logging.NewTestLogger(nil, t),
+		}
+
+		decorated = handler.Decorate(next)
+	)
+
+	require.NotNil(decorated)
+	decorated.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	assert.True(nextCalled)
+}
+
+func testCertificateAuthorizationHandlerNoCertificate(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		nextCalled = false
+		next       = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			nextCalled = true
+		})
+
+		handler = CertificateAuthorizationHandler{
+			Logger:              logging.NewTestLogger(nil, t),
+			ForbiddenStatusCode: http.StatusUnauthorized,
+			Validator:           secure.CertificateValidator{},
+		}
+
+		response = httptest.NewRecorder()
+	)
+
+	handler.Decorate(next).ServeHTTP(response, httptest.NewRequest("GET", "/", nil))
+	assert.Equal(http.StatusUnauthorized, response.Code)
+	assert.False(nextCalled)
+}
+
+func testCertificateAuthorizationHandlerDenied(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		nextCalled = false
+		next       = http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			nextCalled = true
+		})
+
+		handler = CertificateAuthorizationHandler{
+			Logger: logging.NewTestLogger(nil, t),
+			Validator: secure.CertificateValidator{
+				Rules: []secure.CertificateRule{{CommonName: "talaria", Capabilities: []string{"x1:webpa:api:.*:all"}}},
+			},
+		}
+
+		response = httptest.NewRecorder()
+	)
+
+	handler.Decorate(next).ServeHTTP(response, newCertificateRequest("unknown"))
+	assert.Equal(http.StatusForbidden, response.Code)
+	assert.False(nextCalled)
+}
+
+func testCertificateAuthorizationHandlerSuccess(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		actualValues *ContextValues
+		next         = http.HandlerFunc(func(_ http.ResponseWriter, request *http.Request) {
+			actualValues, _ = FromContext(request.Context())
+		})
+
+		handler = CertificateAuthorizationHandler{
+			Logger: logging.NewTestLogger(nil, t),
+			Validator: secure.CertificateValidator{
+				Rules: []secure.CertificateRule{
+					{
+						CommonName:   "talaria",
+						PartnerIDs:   []string{"comcast"},
+						Capabilities: []string{"x1:webpa:api:.*:all"},
+						Trust:        "1000",
+					},
+				},
+			},
+		}
+
+		response = httptest.NewRecorder()
+	)
+
+	handler.Decorate(next).ServeHTTP(response, newCertificateRequest("talaria"))
+	assert.Equal(http.StatusOK, response.Code)
+	require.NotNil(actualValues)
+	assert.Equal(
+		ContextValues{
+			SatClientID: "talaria",
+			Method:      "GET",
+			Path:        "/api/v2/device",
+			PartnerIDs:  []string{"comcast"},
+			Trust:       "1000",
+		},
+		*actualValues,
+	)
+}
+
+func TestCertificateAuthorizationHandler(t *testing.T) {
+	t.Run("NoDecoration", testCertificateAuthorizationHandlerNoDecoration)
+	t.Run("NoCertificate", testCertificateAuthorizationHandlerNoCertificate)
+	t.Run("Denied", testCertificateAuthorizationHandlerDenied)
+	t.Run("Success", testCertificateAuthorizationHandlerSuccess)
+}
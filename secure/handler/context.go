@@ -11,6 +11,9 @@ type ContextValues struct {
 	Path        string
 	PartnerIDs  []string
 	Trust       string
+
+	// TrustRule is the name of the secure.TrustPolicy rule that established Trust, if any
+	TrustRule string
 }
 
 //NewContextWithValue returns a context with the specified context values
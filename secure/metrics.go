@@ -11,6 +11,7 @@ const (
 	JWTValidationReasonCounter = "jwt_validation_reason"
 	NBFHistogram               = "jwt_from_nbf_seconds"
 	EXPHistogram               = "jwt_from_exp_seconds"
+	JWTValidationCacheCounter  = "jwt_validation_cache"
 )
 
 //Metrics returns the Metrics relevant to this package
@@ -34,6 +35,12 @@ func Metrics() []xmetrics.Metric {
 			Help:    "Difference (in seconds) between time of JWT validation and exp (including leeway)",
 			Buckets: []float64{-61, -11, -2, -1, 0, 9, 60},
 		},
+		xmetrics.Metric{
+			Name:       JWTValidationCacheCounter,
+			Type:       xmetrics.CounterType,
+			Help:       "Counter for validation cache lookups per outcome (hit or miss)",
+			LabelNames: []string{"outcome"},
+		},
 	}
 }
 
@@ -42,6 +49,7 @@ type JWTValidationMeasures struct {
 	NBFHistogram     *gokitprometheus.Histogram
 	ExpHistogram     *gokitprometheus.Histogram
 	ValidationReason metrics.Counter
+	ValidationCache  metrics.Counter
 }
 
 //NewJWTValidationMeasures realizes desired metrics
@@ -50,5 +58,6 @@ func NewJWTValidationMeasures(r xmetrics.Registry) *JWTValidationMeasures {
 		NBFHistogram:     gokitprometheus.NewHistogram(r.NewHistogramVec(NBFHistogram)),
 		ExpHistogram:     gokitprometheus.NewHistogram(r.NewHistogramVec(EXPHistogram)),
 		ValidationReason: r.NewCounter(JWTValidationReasonCounter),
+		ValidationCache:  r.NewCounter(JWTValidationCacheCounter),
 	}
 }
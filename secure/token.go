@@ -40,6 +40,7 @@ type Token struct {
 	tokenType TokenType
 	value     string
 	trust     string
+	trustRule string
 }
 
 // String returns an on-the-wire representation of this token, suitable
@@ -65,6 +66,12 @@ func (t *Token) Trust() string {
 	return t.trust
 }
 
+// TrustRule returns the name of the TrustPolicy rule that established this token's
+// trust, if any.  This will be empty if no TrustPolicy was applied during validation.
+func (t *Token) TrustRule() string {
+	return t.trustRule
+}
+
 func (t *Token) Bytes() []byte {
 	return []byte(t.value)
 }
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+var supportedNumericDateLayouts = []string{
+	time.RFC3339,
+	time.RFC822,
+	time.RFC822Z,
+}
+
+// stringSlice is a flag.Value that accumulates each occurrence of a flag
+type stringSlice []string
+
+func (s *stringSlice) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSlice) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// ParseNumericDate computes an absolute time from text, which may be a unix timestamp,
+// a time.Duration relative to now, or an absolute date in RFC3339 or RFC822 formats.
+func ParseNumericDate(text string, now time.Time) (time.Time, error) {
+	if value, err := strconv.ParseInt(text, 10, 64); err == nil {
+		return time.Unix(value, 0), nil
+	}
+
+	if duration, err := time.ParseDuration(text); err == nil {
+		return now.Add(duration), nil
+	}
+
+	for _, layout := range supportedNumericDateLayouts {
+		if value, err := time.Parse(layout, text); err == nil {
+			return value, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("Unparseable datetime: %s", text)
+}
+
+// LoadClaims reads a file of arbitrary claims.  Files with a .yaml or .yml extension are
+// parsed as YAML, and everything else as JSON.
+func LoadClaims(path string) (map[string]interface{}, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		var raw map[interface{}]interface{}
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, err
+		}
+
+		return normalizeYAML(raw).(map[string]interface{}), nil
+
+	default:
+		claims := make(map[string]interface{})
+		err := json.Unmarshal(data, &claims)
+		return claims, err
+	}
+}
+
+// normalizeYAML converts the map[interface{}]interface{} values produced by the YAML
+// decoder into the map[string]interface{} values required for JSON encoding
+func normalizeYAML(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		normalized := make(map[string]interface{}, len(v))
+		for key, element := range v {
+			normalized[fmt.Sprint(key)] = normalizeYAML(element)
+		}
+
+		return normalized
+
+	case []interface{}:
+		normalized := make([]interface{}, len(v))
+		for i, element := range v {
+			normalized[i] = normalizeYAML(element)
+		}
+
+		return normalized
+
+	default:
+		return v
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeTestFile writes contents to a file with the given name in a new temporary directory
+func writeTestFile(t *testing.T, name, contents string) (string, func()) {
+	dir, err := ioutil.TempDir("", "jwt")
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, name)
+	require.NoError(t, ioutil.WriteFile(path, []byte(contents), 0600))
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestParseNumericDate(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		now    = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+	)
+
+	var testData = []struct {
+		text        string
+		expected    time.Time
+		expectError bool
+	}{
+		{"1577836800", time.Unix(1577836800, 0), false},
+		{"24h", now.Add(24 * time.Hour), false},
+		{"-5m", now.Add(-5 * time.Minute), false},
+		{"2021-06-01T12:00:00Z", time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC), false},
+		{"01 Jun 21 12:00 UTC", time.Date(2021, time.June, 1, 12, 0, 0, 0, time.UTC), false},
+		{"not a date", time.Time{}, true},
+	}
+
+	for _, record := range testData {
+		actual, err := ParseNumericDate(record.text, now)
+		assert.Equal(record.expectError, err != nil, record.text)
+		assert.True(record.expected.Equal(actual), "%s: expected %s, got %s", record.text, record.expected, actual)
+	}
+}
+
+func TestLoadClaims(t *testing.T) {
+	var testData = []struct {
+		name        string
+		contents    string
+		expected    map[string]interface{}
+		expectError bool
+	}{
+		{
+			"claims.json",
+			`{"sub": "client:1234", "allowedResources": {"allowedPartners": ["comcast"]}, "version": 2}`,
+			map[string]interface{}{
+				"sub":              "client:1234",
+				"allowedResources": map[string]interface{}{"allowedPartners": []interface{}{"comcast"}},
+				"version":          2.0,
+			},
+			false,
+		},
+		{
+			"claims.yaml",
+			"sub: client:1234\nallowedResources:\n  allowedPartners:\n    - comcast\n",
+			map[string]interface{}{
+				"sub":              "client:1234",
+				"allowedResources": map[string]interface{}{"allowedPartners": []interface{}{"comcast"}},
+			},
+			false,
+		},
+		{"claims.json", `{"sub": `, nil, true},
+		{"claims.yml", "sub: [", nil, true},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			path, cleanup := writeTestFile(t, record.name, record.contents)
+			defer cleanup()
+
+			claims, err := LoadClaims(path)
+			if record.expectError {
+				assert.Error(err)
+			} else {
+				assert.NoError(err)
+				assert.Equal(record.expected, claims)
+			}
+		})
+	}
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := LoadClaims("/nosuch/claims.json")
+		assert.Error(t, err)
+	})
+}
+
+func TestStringSlice(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		s      stringSlice
+	)
+
+	assert.NoError(s.Set("a"))
+	assert.NoError(s.Set("b"))
+	assert.Equal(stringSlice{"a", "b"}, s)
+	assert.Equal("a,b", s.String())
+}
@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"strings"
+
+	"github.com/SermoDigital/jose/crypto"
+)
+
+var (
+	ErrorNoSuchKey         = errors.New("No key with that kid in the JWK set")
+	ErrorUnsupportedKey    = errors.New("Unsupported key type")
+	ErrorNoSigningKey      = errors.New("A private key or HMAC secret is required for signing")
+	ErrorAlgorithmMismatch = errors.New("The algorithm is not compatible with the key")
+)
+
+// Key is a loaded key, suitable for signing or verification depending on what was loaded.
+// Exactly one of the fields is set.
+type Key struct {
+	RSAPrivate *rsa.PrivateKey
+	RSAPublic  *rsa.PublicKey
+	ECPrivate  *ecdsa.PrivateKey
+	ECPublic   *ecdsa.PublicKey
+	Secret     []byte
+}
+
+// SigningKey returns the value the jose library expects for signing
+func (k *Key) SigningKey() (interface{}, error) {
+	switch {
+	case k.RSAPrivate != nil:
+		return k.RSAPrivate, nil
+	case k.ECPrivate != nil:
+		return k.ECPrivate, nil
+	case k.Secret != nil:
+		return k.Secret, nil
+	default:
+		return nil, ErrorNoSigningKey
+	}
+}
+
+// VerifyKey returns the value the jose library expects for verification
+func (k *Key) VerifyKey() interface{} {
+	switch {
+	case k.RSAPrivate != nil:
+		return &k.RSAPrivate.PublicKey
+	case k.RSAPublic != nil:
+		return k.RSAPublic
+	case k.ECPrivate != nil:
+		return &k.ECPrivate.PublicKey
+	case k.ECPublic != nil:
+		return k.ECPublic
+	default:
+		return k.Secret
+	}
+}
+
+// curve returns the elliptic curve of this key, if it is an EC key
+func (k *Key) curve() elliptic.Curve {
+	switch {
+	case k.ECPrivate != nil:
+		return k.ECPrivate.Curve
+	case k.ECPublic != nil:
+		return k.ECPublic.Curve
+	default:
+		return nil
+	}
+}
+
+// DefaultSigningMethod returns the signing method to use for this key when none is specified
+func (k *Key) DefaultSigningMethod() crypto.SigningMethod {
+	if curve := k.curve(); curve != nil {
+		switch curve.Params().BitSize {
+		case 384:
+			return crypto.SigningMethodES384
+		case 521:
+			return crypto.SigningMethodES512
+		default:
+			return crypto.SigningMethodES256
+		}
+	}
+
+	if k.Secret != nil {
+		return crypto.SigningMethodHS256
+	}
+
+	return crypto.SigningMethodRS256
+}
+
+// Compatible tests if the given algorithm can be used with this key
+func (k *Key) Compatible(alg string) bool {
+	switch {
+	case k.curve() != nil:
+		return strings.HasPrefix(alg, "ES")
+	case k.Secret != nil:
+		return strings.HasPrefix(alg, "HS")
+	default:
+		return strings.HasPrefix(alg, "RS") || strings.HasPrefix(alg, "PS")
+	}
+}
+
+// readKeySource reads the contents of a file or, for http and https URIs, a remote resource
+func readKeySource(source string) ([]byte, error) {
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		response, err := http.Get(source)
+		if err != nil {
+			return nil, err
+		}
+
+		defer response.Body.Close()
+		if response.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("Unable to fetch %s: %s", source, response.Status)
+		}
+
+		return ioutil.ReadAll(response.Body)
+	}
+
+	return ioutil.ReadFile(source)
+}
+
+// LoadKey loads a key from a file or URI.  PEM-encoded RSA and EC keys, public or private, are
+// supported as are JWK sets, in which case the kid selects the key.  Anything else is treated
+// as a raw HMAC secret.
+func LoadKey(source, kid string) (*Key, error) {
+	data, err := readKeySource(source)
+	if err != nil {
+		return nil, err
+	}
+
+	trimmed := bytes.TrimSpace(data)
+	if block, _ := pem.Decode(trimmed); block != nil {
+		return parsePEMKey(block)
+	}
+
+	if len(trimmed) > 0 && trimmed[0] == '{' {
+		return parseJWKS(trimmed, kid)
+	}
+
+	return &Key{Secret: trimmed}, nil
+}
+
+func parsePEMKey(block *pem.Block) (*Key, error) {
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		return &Key{RSAPrivate: key}, err
+
+	case "RSA PUBLIC KEY":
+		key, err := x509.ParsePKCS1PublicKey(block.Bytes)
+		return &Key{RSAPublic: key}, err
+
+	case "EC PRIVATE KEY":
+		key, err := x509.ParseECPrivateKey(block.Bytes)
+		return &Key{ECPrivate: key}, err
+
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		return newKey(key)
+
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		return newKey(key)
+
+	case "CERTIFICATE":
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+
+		return newKey(cert.PublicKey)
+
+	default:
+		return nil, fmt.Errorf("Unsupported PEM block type: %s", block.Type)
+	}
+}
+
+func newKey(raw interface{}) (*Key, error) {
+	switch key := raw.(type) {
+	case *rsa.PrivateKey:
+		return &Key{RSAPrivate: key}, nil
+	case *rsa.PublicKey:
+		return &Key{RSAPublic: key}, nil
+	case *ecdsa.PrivateKey:
+		return &Key{ECPrivate: key}, nil
+	case *ecdsa.PublicKey:
+		return &Key{ECPublic: key}, nil
+	default:
+		return nil, ErrorUnsupportedKey
+	}
+}
+
+// JWK is the subset of RFC 7517 JSON Web Key members needed for verification
+type JWK struct {
+	KeyType string `json:"kty"`
+	KeyID   string `json:"kid"`
+
+	// RSA
+	N string `json:"n"`
+	E string `json:"e"`
+
+	// EC
+	Curve string `json:"crv"`
+	X     string `json:"x"`
+	Y     string `json:"y"`
+
+	// oct
+	K string `json:"k"`
+}
+
+// JWKS is an RFC 7517 JSON Web Key set
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	decoded, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil {
+		return nil, err
+	}
+
+	return new(big.Int).SetBytes(decoded), nil
+}
+
+func parseJWKS(data []byte, kid string) (*Key, error) {
+	var set JWKS
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, err
+	}
+
+	// a lone JWK is also accepted
+	if len(set.Keys) == 0 {
+		var single JWK
+		if err := json.Unmarshal(data, &single); err != nil {
+			return nil, err
+		}
+
+		set.Keys = append(set.Keys, single)
+	}
+
+	for _, jwk := range set.Keys {
+		if len(kid) == 0 || jwk.KeyID == kid {
+			return jwk.Key()
+		}
+	}
+
+	return nil, ErrorNoSuchKey
+}
+
+// Key converts this JWK into a verification key
+func (jwk JWK) Key() (*Key, error) {
+	switch jwk.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(jwk.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(jwk.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Key{RSAPublic: &rsa.PublicKey{N: n, E: int(e.Int64())}}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch jwk.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("Unsupported curve: %s", jwk.Curve)
+		}
+
+		x, err := decodeBigInt(jwk.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(jwk.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		return &Key{ECPublic: &ecdsa.PublicKey{Curve: curve, X: x, Y: y}}, nil
+
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(jwk.K, "="))
+		if err != nil {
+			return nil, err
+		}
+
+		return &Key{Secret: secret}, nil
+
+	default:
+		return nil, ErrorUnsupportedKey
+	}
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	testRSAKey, _ = rsa.GenerateKey(rand.Reader, 1024)
+	testECKey, _  = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+)
+
+func encodePEM(t *testing.T, blockType string, der []byte) string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}))
+}
+
+func encodeBigInt(value *big.Int) string {
+	return base64.RawURLEncoding.EncodeToString(value.Bytes())
+}
+
+func testLoadKeyPEM(t *testing.T) {
+	ecDER, err := x509.MarshalECPrivateKey(testECKey)
+	require.NoError(t, err)
+
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(testRSAKey)
+	require.NoError(t, err)
+
+	pkix, err := x509.MarshalPKIXPublicKey(&testECKey.PublicKey)
+	require.NoError(t, err)
+
+	var testData = []struct {
+		name     string
+		contents string
+		expected *Key
+	}{
+		{"RSAPrivate", encodePEM(t, "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(testRSAKey)), &Key{RSAPrivate: testRSAKey}},
+		{"RSAPublic", encodePEM(t, "RSA PUBLIC KEY", x509.MarshalPKCS1PublicKey(&testRSAKey.PublicKey)), &Key{RSAPublic: &testRSAKey.PublicKey}},
+		{"ECPrivate", encodePEM(t, "EC PRIVATE KEY", ecDER), &Key{ECPrivate: testECKey}},
+		{"PKCS8", encodePEM(t, "PRIVATE KEY", pkcs8), &Key{RSAPrivate: testRSAKey}},
+		{"PKIX", encodePEM(t, "PUBLIC KEY", pkix), &Key{ECPublic: &testECKey.PublicKey}},
+		{"Secret", "  secret\n", &Key{Secret: []byte("secret")}},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			path, cleanup := writeTestFile(t, "key", record.contents)
+			defer cleanup()
+
+			key, err := LoadKey(path, "")
+			require.NoError(t, err)
+
+			// compare the keys by their behavior, as parsing may fill in precomputed values
+			assert.Equal(t, record.expected.VerifyKey() != nil, key.VerifyKey() != nil)
+			assert.Equal(t, record.expected.DefaultSigningMethod(), key.DefaultSigningMethod())
+			assert.Equal(t, fmt.Sprint(record.expected.VerifyKey()), fmt.Sprint(key.VerifyKey()))
+		})
+	}
+
+	t.Run("UnsupportedBlock", func(t *testing.T) {
+		path, cleanup := writeTestFile(t, "key", encodePEM(t, "DSA PRIVATE KEY", []byte{1, 2, 3}))
+		defer cleanup()
+
+		_, err := LoadKey(path, "")
+		assert.Error(t, err)
+	})
+
+	t.Run("Missing", func(t *testing.T) {
+		_, err := LoadKey("/nosuch/key", "")
+		assert.Error(t, err)
+	})
+}
+
+func testLoadKeyJWKS(t *testing.T) {
+	jwks := fmt.Sprintf(
+		`{"keys": [
+			{"kty": "RSA", "kid": "rsa", "n": "%s", "e": "%s"},
+			{"kty": "EC", "kid": "ec", "crv": "P-256", "x": "%s", "y": "%s"},
+			{"kty": "oct", "kid": "oct", "k": "%s"},
+			{"kty": "unknown", "kid": "unknown"}
+		]}`,
+		encodeBigInt(testRSAKey.N),
+		encodeBigInt(big.NewInt(int64(testRSAKey.E))),
+		encodeBigInt(testECKey.X),
+		encodeBigInt(testECKey.Y),
+		base64.RawURLEncoding.EncodeToString([]byte("secret")),
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(response, jwks)
+	}))
+
+	defer server.Close()
+
+	var testData = []struct {
+		kid         string
+		expected    *Key
+		expectedErr error
+	}{
+		{"", &Key{RSAPublic: &testRSAKey.PublicKey}, nil},
+		{"rsa", &Key{RSAPublic: &testRSAKey.PublicKey}, nil},
+		{"ec", &Key{ECPublic: &testECKey.PublicKey}, nil},
+		{"oct", &Key{Secret: []byte("secret")}, nil},
+		{"unknown", nil, ErrorUnsupportedKey},
+		{"nosuch", nil, ErrorNoSuchKey},
+	}
+
+	for _, record := range testData {
+		t.Run(record.kid, func(t *testing.T) {
+			assert := assert.New(t)
+
+			key, err := LoadKey(server.URL, record.kid)
+			assert.Equal(record.expectedErr, err)
+			if record.expected != nil {
+				assert.Equal(record.expected, key)
+			}
+		})
+	}
+}
+
+func TestLoadKey(t *testing.T) {
+	t.Run("PEM", testLoadKeyPEM)
+	t.Run("JWKS", testLoadKeyJWKS)
+}
+
+func TestKey(t *testing.T) {
+	var testData = []struct {
+		name          string
+		key           *Key
+		signing       bool
+		defaultMethod crypto.SigningMethod
+		compatible    []string
+		incompatible  []string
+	}{
+		{"RSAPrivate", &Key{RSAPrivate: testRSAKey}, true, crypto.SigningMethodRS256, []string{"RS256", "PS512"}, []string{"ES256", "HS256", "none"}},
+		{"RSAPublic", &Key{RSAPublic: &testRSAKey.PublicKey}, false, crypto.SigningMethodRS256, []string{"RS384"}, []string{"ES256", "HS256", "none"}},
+		{"ECPrivate", &Key{ECPrivate: testECKey}, true, crypto.SigningMethodES256, []string{"ES256"}, []string{"RS256", "HS256", "none"}},
+		{"ECPublic", &Key{ECPublic: &testECKey.PublicKey}, false, crypto.SigningMethodES256, []string{"ES256"}, []string{"RS256", "HS256", "none"}},
+		{"Secret", &Key{Secret: []byte("secret")}, true, crypto.SigningMethodHS256, []string{"HS256", "HS512"}, []string{"RS256", "ES256", "none"}},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			signingKey, err := record.key.SigningKey()
+			if record.signing {
+				assert.NoError(err)
+				assert.NotNil(signingKey)
+			} else {
+				assert.Equal(ErrorNoSigningKey, err)
+			}
+
+			assert.Equal(record.defaultMethod, record.key.DefaultSigningMethod())
+			for _, alg := range record.compatible {
+				assert.True(record.key.Compatible(alg), alg)
+			}
+
+			for _, alg := range record.incompatible {
+				assert.False(record.key.Compatible(alg), alg)
+			}
+		})
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"os"
 )
@@ -54,7 +55,40 @@ func displayToken(token []byte) error {
 	return nil
 }
 
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage:\n")
+	fmt.Fprintf(os.Stderr, "  %s [-t token]             display the header and payload of a token\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s mint [flags]           issue a signed token\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "  %s verify [flags]         decode and validate a token, printing a report\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "\nUse %s <command> -h for the flags of each command.\n", os.Args[0])
+}
+
+// runCommand executes a subcommand, exiting with a nonzero status on failure
+func runCommand(command func([]string, io.Writer) error, arguments []string) {
+	if err := command(arguments, os.Stdout); err != nil {
+		if err != flag.ErrHelp {
+			fmt.Fprintf(os.Stderr, "%s\n", err)
+		}
+
+		os.Exit(1)
+	}
+}
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "mint":
+			runCommand(Mint, os.Args[2:])
+			return
+
+		case "verify":
+			runCommand(Verify, os.Args[2:])
+			return
+		}
+	}
+
+	flag.Usage = usage
+
 	var arguments Arguments
 	flag.StringVar(&arguments.Token, "t", "", "The JWT token.  If not supplied, a token is expected from stdin.")
 	flag.StringVar(&arguments.KeyURI, "k", "", "the URI of a public key for verification (optional)")
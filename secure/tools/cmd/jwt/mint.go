@@ -0,0 +1,171 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/jithin-kg/webpa-common/basculechecks"
+)
+
+// MintArguments are the command line arguments for the mint command
+type MintArguments struct {
+	KeyFile    string
+	KeyID      string
+	Algorithm  string
+	ClaimsFile string
+
+	Subject   string
+	Issuer    string
+	Audience  stringSlice
+	NotBefore string
+	Expires   string
+
+	Capabilities stringSlice
+	Partners     stringSlice
+}
+
+func (ma *MintArguments) signingMethod(key *Key) (crypto.SigningMethod, error) {
+	if len(ma.Algorithm) == 0 {
+		return key.DefaultSigningMethod(), nil
+	}
+
+	method := jws.GetSigningMethod(ma.Algorithm)
+	if method == nil {
+		return nil, fmt.Errorf("Unsupported algorithm: %s", ma.Algorithm)
+	}
+
+	if !key.Compatible(method.Alg()) {
+		return nil, ErrorAlgorithmMismatch
+	}
+
+	return method, nil
+}
+
+// claims assembles the claims for a new token.  Values from command line arguments take
+// precedence over those in the claims file.
+func (ma *MintArguments) claims(now time.Time) (jws.Claims, error) {
+	claims := make(jws.Claims)
+	if len(ma.ClaimsFile) > 0 {
+		loaded, err := LoadClaims(ma.ClaimsFile)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, v := range loaded {
+			claims.Set(k, v)
+		}
+	}
+
+	claims.SetIssuedAt(now)
+
+	if len(ma.Subject) > 0 {
+		claims.SetSubject(ma.Subject)
+	}
+
+	if len(ma.Issuer) > 0 {
+		claims.SetIssuer(ma.Issuer)
+	}
+
+	if len(ma.Audience) > 0 {
+		claims.SetAudience(ma.Audience...)
+	}
+
+	if len(ma.NotBefore) > 0 {
+		nbf, err := ParseNumericDate(ma.NotBefore, now)
+		if err != nil {
+			return nil, err
+		}
+
+		claims.SetNotBefore(nbf)
+	}
+
+	if len(ma.Expires) > 0 {
+		exp, err := ParseNumericDate(ma.Expires, now)
+		if err != nil {
+			return nil, err
+		}
+
+		claims.SetExpiration(exp)
+	}
+
+	if len(ma.Capabilities) > 0 {
+		claims.Set(basculechecks.CapabilityKey, []string(ma.Capabilities))
+	}
+
+	if len(ma.Partners) > 0 {
+		allowedResources, _ := claims.Get("allowedResources").(map[string]interface{})
+		if allowedResources == nil {
+			allowedResources = make(map[string]interface{})
+		}
+
+		allowedResources["allowedPartners"] = []string(ma.Partners)
+		claims.Set("allowedResources", allowedResources)
+	}
+
+	return claims, nil
+}
+
+// Mint issues a signed JWT using the given arguments, writing the compact serialization to output
+func Mint(arguments []string, output io.Writer) error {
+	var (
+		ma    MintArguments
+		flags = flag.NewFlagSet("mint", flag.ContinueOnError)
+	)
+
+	flags.StringVar(&ma.KeyFile, "k", "", "the PEM-encoded RSA or EC private key, or HMAC secret, used for signing (required)")
+	flags.StringVar(&ma.KeyID, "kid", "", "the kid header of the token (optional)")
+	flags.StringVar(&ma.Algorithm, "alg", "", "the signing algorithm.  If not supplied, a default appropriate for the key is used.")
+	flags.StringVar(&ma.ClaimsFile, "claims", "", "a JSON or YAML file of arbitrary claims (optional)")
+	flags.StringVar(&ma.Subject, "sub", "", "the sub claim")
+	flags.StringVar(&ma.Issuer, "iss", "", "the iss claim")
+	flags.Var(&ma.Audience, "aud", "an aud claim value.  May be repeated.")
+	flags.StringVar(&ma.NotBefore, "nbf", "", "the nbf claim, as a unix time, a duration relative to now, or an RFC3339 date")
+	flags.StringVar(&ma.Expires, "exp", "", "the exp claim, as a unix time, a duration relative to now, or an RFC3339 date.  If not supplied, any exp in the claims file is used.")
+	flags.Var(&ma.Capabilities, "cap", "a capability.  May be repeated.")
+	flags.Var(&ma.Partners, "partner", "an allowed partner.  May be repeated.")
+
+	if err := flags.Parse(arguments); err != nil {
+		return err
+	}
+
+	if len(ma.KeyFile) == 0 {
+		return ErrorNoSigningKey
+	}
+
+	key, err := LoadKey(ma.KeyFile, ma.KeyID)
+	if err != nil {
+		return err
+	}
+
+	signingKey, err := key.SigningKey()
+	if err != nil {
+		return err
+	}
+
+	method, err := ma.signingMethod(key)
+	if err != nil {
+		return err
+	}
+
+	claims, err := ma.claims(time.Now())
+	if err != nil {
+		return err
+	}
+
+	token := jws.NewJWT(claims, method)
+	if len(ma.KeyID) > 0 {
+		token.(jws.JWS).Protected().Set("kid", ma.KeyID)
+	}
+
+	serialized, err := token.Serialize(signingKey)
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(output, "%s\n", serialized)
+	return err
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/jithin-kg/webpa-common/basculechecks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// mintTestToken runs the mint command with a secret key and parses the resulting token
+func mintTestToken(t *testing.T, arguments ...string) (jws.JWS, error) {
+	keyFile, cleanup := writeTestFile(t, "secret", "secret")
+	defer cleanup()
+
+	var output bytes.Buffer
+	if err := Mint(append([]string{"-k", keyFile}, arguments...), &output); err != nil {
+		return nil, err
+	}
+
+	token, err := jws.ParseJWT(bytes.TrimSpace(output.Bytes()))
+	require.NoError(t, err)
+	require.NoError(t, token.Validate([]byte("secret"), crypto.SigningMethodHS256))
+	return token.(jws.JWS), nil
+}
+
+func testMintClaims(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	token, err := mintTestToken(t, "-kid", "test", "-sub", "client:1234", "-iss", "issuer", "-aud", "a", "-aud", "b", "-cap", "x:webpa:api:.*:all", "-partner", "comcast")
+	require.NoError(err)
+
+	assert.Equal("test", token.Protected().Get("kid"))
+	assert.Equal("HS256", token.Protected().Get("alg"))
+
+	claims := token.Payload().(jws.Claims)
+	assert.Equal("client:1234", claims["sub"])
+	assert.Equal("issuer", claims["iss"])
+	assert.Equal([]interface{}{"a", "b"}, claims["aud"])
+	assert.Equal([]interface{}{"x:webpa:api:.*:all"}, claims[basculechecks.CapabilityKey])
+	assert.Equal(map[string]interface{}{"allowedPartners": []interface{}{"comcast"}}, claims["allowedResources"])
+	assert.Contains(claims, "iat")
+	assert.NotContains(claims, "exp")
+}
+
+func testMintExpiration(t *testing.T) {
+	claimsFile, cleanup := writeTestFile(t, "claims.json", `{"sub": "fromfile", "exp": 4102444800}`)
+	defer cleanup()
+
+	var testData = []struct {
+		name      string
+		arguments []string
+		expected  time.Time
+	}{
+		{"FromClaimsFile", []string{"-claims", claimsFile}, time.Unix(4102444800, 0)},
+		{"Override", []string{"-claims", claimsFile, "-exp", "1893456000"}, time.Unix(1893456000, 0)},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+			)
+
+			token, err := mintTestToken(t, record.arguments...)
+			require.NoError(err)
+
+			claims := token.Payload().(jws.Claims)
+			assert.Equal("fromfile", claims["sub"])
+			assert.Equal(float64(record.expected.Unix()), claims["exp"])
+		})
+	}
+}
+
+func testMintErrors(t *testing.T) {
+	var testData = []struct {
+		name        string
+		arguments   []string
+		expectedErr error
+	}{
+		{"AlgorithmMismatch", []string{"-alg", "RS256"}, ErrorAlgorithmMismatch},
+		{"BadExpiration", []string{"-exp", "not a date"}, nil},
+		{"MissingClaimsFile", []string{"-claims", "/nosuch/claims.json"}, nil},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			_, err := mintTestToken(t, record.arguments...)
+			assert.Error(t, err)
+			if record.expectedErr != nil {
+				assert.Equal(t, record.expectedErr, err)
+			}
+		})
+	}
+
+	t.Run("NoKey", func(t *testing.T) {
+		var output bytes.Buffer
+		assert.Equal(t, ErrorNoSigningKey, Mint([]string{"-sub", "client:1234"}, &output))
+	})
+}
+
+func TestMint(t *testing.T) {
+	t.Run("Claims", testMintClaims)
+	t.Run("Expiration", testMintExpiration)
+	t.Run("Errors", testMintErrors)
+}
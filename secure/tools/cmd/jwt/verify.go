@@ -0,0 +1,319 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/SermoDigital/jose/jwt"
+	"github.com/jithin-kg/webpa-common/basculechecks"
+	"github.com/jithin-kg/webpa-common/secure"
+)
+
+var (
+	ErrorTokenInvalid   = errors.New("The token failed validation")
+	ErrorUnsecuredToken = errors.New("Unsecured tokens are not accepted")
+	ErrorClaimMismatch  = errors.New("The claim does not have the expected value")
+)
+
+// registeredClaims are the expected claims that jwt.Validator checks itself.  All other expected
+// claims are compared by value.
+var registeredClaims = map[string]bool{
+	"iss": true,
+	"sub": true,
+	"aud": true,
+	"iat": true,
+	"jti": true,
+}
+
+// VerifyArguments are the command line arguments for the verify command
+type VerifyArguments struct {
+	Token         string
+	KeySource     string
+	KeyID         string
+	ValidatorFile string
+}
+
+// Check is the outcome of a single validation check
+type Check struct {
+	Name string
+	Err  error
+}
+
+// Report is the full validation report for a token
+type Report struct {
+	Header map[string]interface{}
+	Claims jwt.Claims
+	Checks []Check
+}
+
+// Valid tests if all checks passed
+func (r *Report) Valid() bool {
+	for _, c := range r.Checks {
+		if c.Err != nil {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (r *Report) add(name string, err error) {
+	r.Checks = append(r.Checks, Check{Name: name, Err: err})
+}
+
+func describeTime(now, value time.Time) string {
+	if value.After(now) {
+		return fmt.Sprintf("%s (in %s)", value.Format(time.RFC3339), value.Sub(now).Round(time.Second))
+	}
+
+	return fmt.Sprintf("%s (%s ago)", value.Format(time.RFC3339), now.Sub(value).Round(time.Second))
+}
+
+// Print writes a human readable form of this report
+func (r *Report) Print(output io.Writer, now time.Time) error {
+	var buffer bytes.Buffer
+
+	header, _ := json.MarshalIndent(r.Header, "", "  ")
+	fmt.Fprintf(&buffer, "Header:\n%s\n\n", header)
+
+	claims, _ := json.MarshalIndent(r.Claims, "", "  ")
+	fmt.Fprintf(&buffer, "Claims:\n%s\n\n", claims)
+
+	if iat, ok := r.Claims.IssuedAt(); ok {
+		fmt.Fprintf(&buffer, "Issued At:  %s\n", describeTime(now, iat))
+	}
+
+	if nbf, ok := r.Claims.NotBefore(); ok {
+		fmt.Fprintf(&buffer, "Not Before: %s\n", describeTime(now, nbf))
+	}
+
+	if exp, ok := r.Claims.Expiration(); ok {
+		fmt.Fprintf(&buffer, "Expires:    %s\n", describeTime(now, exp))
+	} else {
+		fmt.Fprintf(&buffer, "Expires:    never\n")
+	}
+
+	fmt.Fprintf(&buffer, "\nChecks:\n")
+	for _, c := range r.Checks {
+		if c.Err == nil {
+			fmt.Fprintf(&buffer, "  %-14s PASS\n", c.Name)
+		} else {
+			fmt.Fprintf(&buffer, "  %-14s FAIL: %s\n", c.Name, c.Err)
+		}
+	}
+
+	if r.Valid() {
+		fmt.Fprintf(&buffer, "\nResult: VALID\n")
+	} else {
+		fmt.Fprintf(&buffer, "\nResult: INVALID\n")
+	}
+
+	_, err := output.Write(buffer.Bytes())
+	return err
+}
+
+// loadValidatorFactory reads a JWTValidatorFactory configuration.  jwt.Claims expects base64
+// when unmarshalling JSON, so the expected claims are decoded separately as plain JSON.
+func loadValidatorFactory(path string) (*secure.JWTValidatorFactory, error) {
+	factory := new(secure.JWTValidatorFactory)
+	if len(path) == 0 {
+		return factory, nil
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var configuration struct {
+		Expected  map[string]interface{} `json:"expected"`
+		ExpLeeway int                    `json:"expLeeway"`
+		NbfLeeway int                    `json:"nbfLeeway"`
+	}
+
+	if err := json.Unmarshal(data, &configuration); err != nil {
+		return nil, err
+	}
+
+	factory.Expected = jwt.Claims(configuration.Expected)
+	factory.ExpLeeway = configuration.ExpLeeway
+	factory.NbfLeeway = configuration.NbfLeeway
+	return factory, nil
+}
+
+// NewReport validates a token, performing each check of the JWSValidator and JWTValidatorFactory
+// independently so that all failures are reported rather than just the first one.
+func NewReport(token []byte, key *Key, factory *secure.JWTValidatorFactory, now time.Time) (*Report, error) {
+	parsed, err := jws.ParseJWT(bytes.TrimSpace(token))
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		jwsToken = parsed.(jws.JWS)
+		report   = &Report{
+			Header: map[string]interface{}(jwsToken.Protected()),
+			Claims: parsed.Claims(),
+		}
+	)
+
+	// the token's alg header is only trusted once it is known to be a real signature
+	// algorithm that suits the key, otherwise a token could choose how it is verified
+	alg, _ := jwsToken.Protected().Get("alg").(string)
+	switch method := jws.GetSigningMethod(alg); {
+	case method == nil:
+		report.add("alg", secure.ErrorNoSigningMethod)
+	case method.Alg() == crypto.Unsecured.Alg():
+		report.add("alg", ErrorUnsecuredToken)
+	case key != nil && !key.Compatible(method.Alg()):
+		report.add("alg", ErrorAlgorithmMismatch)
+	case key != nil:
+		report.add("signature", jwsToken.Verify(key.VerifyKey(), method))
+	}
+
+	var (
+		expLeeway = time.Duration(factory.ExpLeeway) * time.Second
+		nbfLeeway = time.Duration(factory.NbfLeeway) * time.Second
+	)
+
+	if exp, ok := report.Claims.Expiration(); ok && now.After(exp.Add(expLeeway)) {
+		report.add("exp", jwt.ErrTokenIsExpired)
+	} else {
+		report.add("exp", nil)
+	}
+
+	if nbf, ok := report.Claims.NotBefore(); ok && !now.After(nbf.Add(-nbfLeeway)) {
+		report.add("nbf", jwt.ErrTokenNotYetValid)
+	} else {
+		report.add("nbf", nil)
+	}
+
+	names := make([]string, 0, len(factory.Expected))
+	for name := range factory.Expected {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		if registeredClaims[name] {
+			v := jwt.Validator{Expected: jwt.Claims{name: factory.Expected[name]}}
+			report.add(name, v.Validate(parsed))
+		} else {
+			report.add(name, checkClaim(report.Claims, name, factory.Expected[name]))
+		}
+	}
+
+	if capabilities, ok := report.Claims.Get(basculechecks.CapabilityKey).([]interface{}); ok && len(capabilities) > 0 {
+		report.add(basculechecks.CapabilityKey, nil)
+	} else {
+		report.add(basculechecks.CapabilityKey, basculechecks.ErrNoVals)
+	}
+
+	return report, nil
+}
+
+// normalizeClaim converts a claim value into the form produced by decoding JSON, so that values
+// from different sources can be compared
+func normalizeClaim(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var normalized interface{}
+	err = json.Unmarshal(data, &normalized)
+	return normalized, err
+}
+
+// checkClaim tests that a token claim is present and equal to the expected value
+func checkClaim(claims jwt.Claims, name string, expected interface{}) error {
+	if !claims.Has(name) {
+		return ErrorClaimMismatch
+	}
+
+	actual, err := normalizeClaim(claims.Get(name))
+	if err != nil {
+		return err
+	}
+
+	if expected, err = normalizeClaim(expected); err != nil {
+		return err
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		return ErrorClaimMismatch
+	}
+
+	return nil
+}
+
+// Verify decodes and validates a JWT, writing a validation report to output
+func Verify(arguments []string, output io.Writer) error {
+	var (
+		va    VerifyArguments
+		flags = flag.NewFlagSet("verify", flag.ContinueOnError)
+	)
+
+	flags.StringVar(&va.Token, "t", "", "The JWT token.  If not supplied, a token is expected from stdin.")
+	flags.StringVar(&va.KeySource, "k", "", "a PEM key, HMAC secret, or JWK set file or URI.  If not supplied, the signature is not checked.")
+	flags.StringVar(&va.KeyID, "kid", "", "selects the key from a JWK set.  If not supplied, the token's kid header is used.")
+	flags.StringVar(&va.ValidatorFile, "validator", "", "a JSON file containing a JWTValidatorFactory configuration (optional)")
+
+	if err := flags.Parse(arguments); err != nil {
+		return err
+	}
+
+	token := []byte(va.Token)
+	if len(token) == 0 {
+		var err error
+		if token, err = ioutil.ReadAll(os.Stdin); err != nil {
+			return fmt.Errorf("Unable to read token from stdin: %s", err)
+		}
+	}
+
+	factory, err := loadValidatorFactory(va.ValidatorFile)
+	if err != nil {
+		return err
+	}
+
+	var key *Key
+	if len(va.KeySource) > 0 {
+		kid := va.KeyID
+		if len(kid) == 0 {
+			if parsed, err := jws.ParseCompact(bytes.TrimSpace(token)); err == nil {
+				kid, _ = parsed.Protected().Get("kid").(string)
+			}
+		}
+
+		if key, err = LoadKey(va.KeySource, kid); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	report, err := NewReport(token, key, factory, now)
+	if err != nil {
+		return err
+	}
+
+	if err := report.Print(output, now); err != nil {
+		return err
+	}
+
+	if !report.Valid() {
+		return ErrorTokenInvalid
+	}
+
+	return nil
+}
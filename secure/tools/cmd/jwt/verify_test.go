@@ -0,0 +1,216 @@
+package main
+
+import (
+	"bytes"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/SermoDigital/jose/crypto"
+	"github.com/SermoDigital/jose/jws"
+	"github.com/SermoDigital/jose/jwt"
+	"github.com/jithin-kg/webpa-common/basculechecks"
+	"github.com/jithin-kg/webpa-common/secure"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func signTestToken(t *testing.T, claims jws.Claims, method crypto.SigningMethod, key interface{}) []byte {
+	serialized, err := jws.NewJWT(claims, method).Serialize(key)
+	require.NoError(t, err)
+	return serialized
+}
+
+// unsecuredTestToken builds a token with an alg of none by hand, as the jose library refuses to
+func unsecuredTestToken(payload string) []byte {
+	return []byte(
+		base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none","typ":"JWT"}`)) + "." +
+			base64.RawURLEncoding.EncodeToString([]byte(payload)) + ".",
+	)
+}
+
+// findCheck returns the named check from a report
+func findCheck(report *Report, name string) (Check, bool) {
+	for _, c := range report.Checks {
+		if c.Name == name {
+			return c, true
+		}
+	}
+
+	return Check{}, false
+}
+
+func TestNewReport(t *testing.T) {
+	var (
+		now    = time.Now()
+		secret = &Key{Secret: []byte("secret")}
+
+		claims = func(extra map[string]interface{}) jws.Claims {
+			c := jws.Claims{
+				"sub":                       "client:1234",
+				basculechecks.CapabilityKey: []string{"x:webpa:api:.*:all"},
+				"foo":                       map[string]interface{}{"bar": []string{"baz"}},
+			}
+
+			c.SetExpiration(now.Add(time.Hour))
+			for k, v := range extra {
+				c.Set(k, v)
+			}
+
+			return c
+		}
+	)
+
+	var testData = []struct {
+		name          string
+		token         []byte
+		key           *Key
+		expected      jwt.Claims
+		expectValid   bool
+		expectedCheck string
+		expectedErr   error
+	}{
+		{
+			name:        "Valid",
+			token:       signTestToken(t, claims(nil), crypto.SigningMethodHS256, []byte("secret")),
+			key:         secret,
+			expected:    jwt.Claims{"sub": "client:1234", "foo": map[string]interface{}{"bar": []interface{}{"baz"}}},
+			expectValid: true,
+		},
+		{
+			name:        "NoKey",
+			token:       signTestToken(t, claims(nil), crypto.SigningMethodHS256, []byte("secret")),
+			expectValid: true,
+		},
+		{
+			name:          "WrongKey",
+			token:         signTestToken(t, claims(nil), crypto.SigningMethodHS256, []byte("other")),
+			key:           secret,
+			expectedCheck: "signature",
+			expectedErr:   crypto.ErrSignatureInvalid,
+		},
+		{
+			name:          "Unsecured",
+			token:         unsecuredTestToken(`{"sub":"client:1234","capabilities":["x:webpa:api:.*:all"]}`),
+			key:           secret,
+			expectedCheck: "alg",
+			expectedErr:   ErrorUnsecuredToken,
+		},
+		{
+			name:          "UnsecuredNoKey",
+			token:         unsecuredTestToken(`{"sub":"client:1234","capabilities":["x:webpa:api:.*:all"]}`),
+			expectedCheck: "alg",
+			expectedErr:   ErrorUnsecuredToken,
+		},
+		{
+			name:          "AlgorithmMismatch",
+			token:         signTestToken(t, claims(nil), crypto.SigningMethodHS256, []byte("secret")),
+			key:           &Key{ECPublic: &testECKey.PublicKey},
+			expectedCheck: "alg",
+			expectedErr:   ErrorAlgorithmMismatch,
+		},
+		{
+			name:          "Expired",
+			token:         signTestToken(t, claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()}), crypto.SigningMethodHS256, []byte("secret")),
+			key:           secret,
+			expectedCheck: "exp",
+			expectedErr:   jwt.ErrTokenIsExpired,
+		},
+		{
+			name:          "NotYetValid",
+			token:         signTestToken(t, claims(map[string]interface{}{"nbf": now.Add(time.Hour).Unix()}), crypto.SigningMethodHS256, []byte("secret")),
+			key:           secret,
+			expectedCheck: "nbf",
+			expectedErr:   jwt.ErrTokenNotYetValid,
+		},
+		{
+			name:          "RegisteredClaimMismatch",
+			token:         signTestToken(t, claims(nil), crypto.SigningMethodHS256, []byte("secret")),
+			key:           secret,
+			expected:      jwt.Claims{"sub": "client:5678"},
+			expectedCheck: "sub",
+			expectedErr:   jwt.ErrInvalidSUBClaim,
+		},
+		{
+			name:          "CustomClaimMismatch",
+			token:         signTestToken(t, claims(nil), crypto.SigningMethodHS256, []byte("secret")),
+			key:           secret,
+			expected:      jwt.Claims{"foo": "bar"},
+			expectedCheck: "foo",
+			expectedErr:   ErrorClaimMismatch,
+		},
+		{
+			name:          "CustomClaimMissing",
+			token:         signTestToken(t, claims(nil), crypto.SigningMethodHS256, []byte("secret")),
+			key:           secret,
+			expected:      jwt.Claims{"missing": "value"},
+			expectedCheck: "missing",
+			expectedErr:   ErrorClaimMismatch,
+		},
+		{
+			name:          "NoCapabilities",
+			token:         signTestToken(t, claims(map[string]interface{}{basculechecks.CapabilityKey: []string{}}), crypto.SigningMethodHS256, []byte("secret")),
+			key:           secret,
+			expectedCheck: basculechecks.CapabilityKey,
+			expectedErr:   basculechecks.ErrNoVals,
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+			)
+
+			report, err := NewReport(record.token, record.key, &secure.JWTValidatorFactory{Expected: record.expected}, now)
+			require.NoError(err)
+			require.NotNil(report)
+
+			assert.Equal(record.expectValid, report.Valid())
+			if len(record.expectedCheck) > 0 {
+				check, ok := findCheck(report, record.expectedCheck)
+				require.True(ok)
+				assert.Equal(record.expectedErr, check.Err)
+			}
+
+			var output bytes.Buffer
+			assert.NoError(report.Print(&output, now))
+			if record.expectValid {
+				assert.Contains(output.String(), "Result: VALID")
+			} else {
+				assert.Contains(output.String(), "Result: INVALID")
+			}
+		})
+	}
+
+	t.Run("Malformed", func(t *testing.T) {
+		_, err := NewReport([]byte("not a token"), secret, new(secure.JWTValidatorFactory), now)
+		assert.Error(t, err)
+	})
+}
+
+func TestVerify(t *testing.T) {
+	var (
+		require = require.New(t)
+		token   = signTestToken(t, jws.Claims{basculechecks.CapabilityKey: []string{"x:webpa:api:.*:all"}}, crypto.SigningMethodHS256, []byte("secret"))
+	)
+
+	keyFile, cleanup := writeTestFile(t, "secret", "secret")
+	defer cleanup()
+
+	validatorFile, cleanupValidator := writeTestFile(t, "validator.json", `{"expected": {"foo": "bar"}}`)
+	defer cleanupValidator()
+
+	t.Run("Valid", func(t *testing.T) {
+		var output bytes.Buffer
+		require.NoError(Verify([]string{"-t", string(token), "-k", keyFile}, &output))
+		assert.Contains(t, output.String(), "Result: VALID")
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		var output bytes.Buffer
+		assert.Equal(t, ErrorTokenInvalid, Verify([]string{"-t", string(token), "-k", keyFile, "-validator", validatorFile}, &output))
+		assert.Contains(t, output.String(), "Result: INVALID")
+	})
+}
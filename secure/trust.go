@@ -0,0 +1,144 @@
+package secure
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// TrustInput is the information about a validated credential from which trust is computed
+type TrustInput struct {
+	// Issuer is the iss claim of a JWT or the issuer common name of a client certificate
+	Issuer string
+
+	// Subject is the sub claim of a JWT or the identity a CertificateRule established for a client certificate
+	Subject string
+
+	// KeyID is the key used to verify a JWT, if any
+	KeyID string
+
+	// Claims are the JWT claims, if any
+	Claims map[string]interface{}
+
+	// ClientCertificate indicates whether the request presented a verified client certificate
+	ClientCertificate bool
+}
+
+// TrustRule assigns a trust level to credentials that match all of its configured conditions.
+// Conditions that are left unset are not checked.  Issuer, Subject and KeyID may end with "*", in which
+// case they match as a prefix.
+type TrustRule struct {
+	// Name identifies this rule when recording which rule produced a trust level.  If unset,
+	// the rule's index in its policy is used.
+	Name string `json:"name"`
+
+	Issuer  string `json:"issuer"`
+	Subject string `json:"subject"`
+	KeyID   string `json:"keyID"`
+
+	// Claims are required claim values.  A claim that is a list matches if any of its elements
+	// equals the required value.
+	Claims map[string]interface{} `json:"claims"`
+
+	// ClientCertificate, if set, requires the presence or absence of a verified client certificate
+	ClientCertificate *bool `json:"clientCertificate"`
+
+	// Trust is the level assigned by this rule
+	Trust string `json:"trust"`
+}
+
+func claimMatches(actual, expected interface{}) bool {
+	if values, ok := actual.([]interface{}); ok {
+		for _, v := range values {
+			if claimMatches(v, expected) {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	return actual != nil && fmt.Sprint(actual) == fmt.Sprint(expected)
+}
+
+// Match tests if the given input satisfies all the conditions of this rule
+func (r TrustRule) Match(input TrustInput) bool {
+	if len(r.Issuer) > 0 && !matchPattern(r.Issuer, input.Issuer) {
+		return false
+	}
+
+	if len(r.Subject) > 0 && !matchPattern(r.Subject, input.Subject) {
+		return false
+	}
+
+	if len(r.KeyID) > 0 && !matchPattern(r.KeyID, input.KeyID) {
+		return false
+	}
+
+	if r.ClientCertificate != nil && *r.ClientCertificate != input.ClientCertificate {
+		return false
+	}
+
+	for name, expected := range r.Claims {
+		if !claimMatches(input.Claims[name], expected) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// TrustPolicy computes the trust level of validated credentials.  Rules are evaluated in order,
+// and the first matching rule determines the trust level.
+type TrustPolicy struct {
+	Rules []TrustRule `json:"rules"`
+
+	// Default is the trust level when no rule matches.  If unset, Untrusted is used.
+	Default string `json:"default"`
+}
+
+// Compute returns the trust level for the given input along with the name of the rule that
+// produced it.  The rule name is empty when the default applies.
+func (tp *TrustPolicy) Compute(input TrustInput) (trust string, rule string) {
+	for i, r := range tp.Rules {
+		if r.Match(input) {
+			rule = r.Name
+			if len(rule) == 0 {
+				rule = strconv.Itoa(i)
+			}
+
+			return r.Trust, rule
+		}
+	}
+
+	if len(tp.Default) > 0 {
+		return tp.Default, ""
+	}
+
+	return Untrusted, ""
+}
+
+// ParseTrust converts a trust level into an integer suitable for comparison.  An empty
+// trust level is the same as Untrusted.
+func ParseTrust(trust string) (int, error) {
+	if len(trust) == 0 {
+		trust = Untrusted
+	}
+
+	return strconv.Atoi(trust)
+}
+
+// TrustAtLeast tests if the given trust level is greater than or equal to the minimum.
+// Unparseable trust levels never meet the minimum.
+func TrustAtLeast(trust, minimum string) bool {
+	t, err := ParseTrust(trust)
+	if err != nil {
+		return false
+	}
+
+	m, err := ParseTrust(minimum)
+	if err != nil {
+		return false
+	}
+
+	return t >= m
+}
@@ -0,0 +1,133 @@
+package secure
+
+import (
+	"context"
+	"crypto/x509"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTrustRuleMatch(t *testing.T) {
+	var (
+		yes = true
+		no  = false
+
+		input = TrustInput{
+			Issuer:  "sat.example.com",
+			Subject: "client:1234",
+			KeyID:   "sat-2019",
+			Claims: map[string]interface{}{
+				"sub":          "client:1234",
+				"capabilities": []interface{}{"x1:webpa:api:.*:all", "x1:issuer:test:.*:all"},
+				"version":      2.0,
+			},
+			ClientCertificate: true,
+		}
+	)
+
+	var testData = []struct {
+		rule     TrustRule
+		expected bool
+	}{
+		{TrustRule{}, true},
+		{TrustRule{Issuer: "sat.example.com"}, true},
+		{TrustRule{Issuer: "sat.*"}, true},
+		{TrustRule{Issuer: "other.example.com"}, false},
+		{TrustRule{Subject: "client:1234"}, true},
+		{TrustRule{Subject: "client:*"}, true},
+		{TrustRule{Subject: "client:5678"}, false},
+		{TrustRule{KeyID: "sat-*"}, true},
+		{TrustRule{KeyID: "other"}, false},
+		{TrustRule{ClientCertificate: &yes}, true},
+		{TrustRule{ClientCertificate: &no}, false},
+		{TrustRule{Claims: map[string]interface{}{"sub": "client:1234"}}, true},
+		{TrustRule{Claims: map[string]interface{}{"sub": "client:5678"}}, false},
+		{TrustRule{Claims: map[string]interface{}{"capabilities": "x1:issuer:test:.*:all"}}, true},
+		{TrustRule{Claims: map[string]interface{}{"capabilities": "x1:webpa:api:.*:get"}}, false},
+		{TrustRule{Claims: map[string]interface{}{"version": 2}}, true},
+		{TrustRule{Claims: map[string]interface{}{"missing": "value"}}, false},
+		{TrustRule{Issuer: "sat.*", KeyID: "other"}, false},
+	}
+
+	for _, record := range testData {
+		assert.Equal(t, record.expected, record.rule.Match(input), "%#v", record.rule)
+	}
+}
+
+func TestTrustPolicyCompute(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		policy = TrustPolicy{
+			Rules: []TrustRule{
+				{Name: "internal", Issuer: "internal", Trust: "1000"},
+				{KeyID: "partner", Trust: "100"},
+			},
+		}
+	)
+
+	trust, rule := policy.Compute(TrustInput{Issuer: "internal"})
+	assert.Equal("1000", trust)
+	assert.Equal("internal", rule)
+
+	trust, rule = policy.Compute(TrustInput{KeyID: "partner"})
+	assert.Equal("100", trust)
+	assert.Equal("1", rule)
+
+	trust, rule = policy.Compute(TrustInput{})
+	assert.Equal(Untrusted, trust)
+	assert.Empty(rule)
+
+	policy.Default = "10"
+	trust, rule = policy.Compute(TrustInput{})
+	assert.Equal("10", trust)
+	assert.Empty(rule)
+}
+
+func TestTrustAtLeast(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.True(TrustAtLeast("1000", "1000"))
+	assert.True(TrustAtLeast("1000", "100"))
+	assert.True(TrustAtLeast("", ""))
+	assert.True(TrustAtLeast("0", ""))
+	assert.False(TrustAtLeast("100", "1000"))
+	assert.False(TrustAtLeast("", "1"))
+	assert.False(TrustAtLeast("bad", "0"))
+	assert.False(TrustAtLeast("1000", "bad"))
+}
+
+func TestJWSValidatorTrust(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		yes     = true
+
+		validator = JWSValidator{
+			Resolver: publicKeyResolver,
+			Cache:    NewValidationCache(10, 0, 0),
+			Trust: &TrustPolicy{
+				Rules: []TrustRule{
+					{Name: "mtls", ClientCertificate: &yes, Trust: "1000"},
+					{Name: "valid", Claims: map[string]interface{}{"valid": true}, Trust: "100"},
+				},
+			},
+		}
+	)
+
+	token := &Token{tokenType: Bearer, value: string(testSerializedJWT), trust: Untrusted}
+	valid, err := validator.Validate(context.Background(), token)
+	require.NoError(err)
+	require.True(valid)
+	assert.Equal("100", token.Trust())
+	assert.Equal("valid", token.TrustRule())
+
+	// the cached validation must still reflect the presence of a client certificate
+	token = &Token{tokenType: Bearer, value: string(testSerializedJWT), trust: Untrusted}
+	valid, err = validator.Validate(WithPeerCertificate(context.Background(), new(x509.Certificate)), token)
+	require.NoError(err)
+	require.True(valid)
+	assert.Equal("1000", token.Trust())
+	assert.Equal("mtls", token.TrustRule())
+}
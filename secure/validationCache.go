@@ -0,0 +1,177 @@
+package secure
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"sync"
+	"time"
+
+	"github.com/SermoDigital/jose/jws"
+	"github.com/jithin-kg/webpa-common/secure/key"
+)
+
+const (
+	// DefaultValidationCacheSize is the maximum number of entries used when a
+	// ValidationCache is created with a nonpositive size
+	DefaultValidationCacheSize = 1000
+)
+
+// validationEntry is a single successful validation
+type validationEntry struct {
+	hash    [sha256.Size]byte
+	keyID   string
+	pair    key.Pair
+	claims  jws.Claims
+	expires time.Time
+}
+
+// ValidationCache is a bounded, least-recently-used cache of successful JWS validations.
+// Entries are keyed by a hash of the token, so raw tokens are never retained.
+//
+// An entry is only honored until the token's exp claim, plus leeway, has passed.  Entries are
+// also discarded when the key used to verify the token is no longer the one returned by the
+// key.Resolver, which happens when a key.Cache rotates its keys.
+type ValidationCache struct {
+	maxEntries int
+	expLeeway  time.Duration
+	maxAge     time.Duration
+	now        func() time.Time
+
+	lock    sync.Mutex
+	entries map[[sha256.Size]byte]*list.Element
+	order   *list.List
+}
+
+// NewValidationCache creates a ValidationCache holding at most maxEntries validations.  The expLeeway
+// should match the leeway used to validate the exp claim, e.g. JWTValidatorFactory.ExpLeeway.
+// If maxAge is positive, no entry is honored for longer than that duration, which bounds the
+// lifetime of tokens without an exp claim.
+func NewValidationCache(maxEntries int, expLeeway, maxAge time.Duration) *ValidationCache {
+	if maxEntries < 1 {
+		maxEntries = DefaultValidationCacheSize
+	}
+
+	return &ValidationCache{
+		maxEntries: maxEntries,
+		expLeeway:  expLeeway,
+		maxAge:     maxAge,
+		now:        time.Now,
+		entries:    make(map[[sha256.Size]byte]*list.Element, maxEntries),
+		order:      list.New(),
+	}
+}
+
+func (vc *ValidationCache) hash(token *Token) [sha256.Size]byte {
+	return sha256.Sum256([]byte(token.value))
+}
+
+// Len returns the number of validations currently cached
+func (vc *ValidationCache) Len() int {
+	vc.lock.Lock()
+	defer vc.lock.Unlock()
+	return vc.order.Len()
+}
+
+// Purge discards all cached validations
+func (vc *ValidationCache) Purge() {
+	vc.lock.Lock()
+	vc.entries = make(map[[sha256.Size]byte]*list.Element, vc.maxEntries)
+	vc.order.Init()
+	vc.lock.Unlock()
+}
+
+// lookup returns the current entry for the given hash, if any, and marks it as recently used.
+// Expired entries are removed.
+func (vc *ValidationCache) lookup(hash [sha256.Size]byte) (validationEntry, bool) {
+	vc.lock.Lock()
+	defer vc.lock.Unlock()
+
+	element, ok := vc.entries[hash]
+	if !ok {
+		return validationEntry{}, false
+	}
+
+	entry := element.Value.(validationEntry)
+	if !entry.expires.IsZero() && vc.now().After(entry.expires) {
+		vc.order.Remove(element)
+		delete(vc.entries, hash)
+		return validationEntry{}, false
+	}
+
+	vc.order.MoveToFront(element)
+	return entry, true
+}
+
+func (vc *ValidationCache) remove(hash [sha256.Size]byte) {
+	vc.lock.Lock()
+	if element, ok := vc.entries[hash]; ok {
+		vc.order.Remove(element)
+		delete(vc.entries, hash)
+	}
+
+	vc.lock.Unlock()
+}
+
+// get returns the entry for a token previously validated with the key currently returned by the resolver
+func (vc *ValidationCache) get(token *Token, resolver key.Resolver) (validationEntry, bool) {
+	hash := vc.hash(token)
+	entry, ok := vc.lookup(hash)
+	if !ok {
+		return validationEntry{}, false
+	}
+
+	if pair, err := resolver.ResolveKey(entry.keyID); err != nil || pair != entry.pair {
+		vc.remove(hash)
+		return validationEntry{}, false
+	}
+
+	return entry, true
+}
+
+// Validate tests if the given token has previously been validated with the key currently
+// returned by the resolver.
+func (vc *ValidationCache) Validate(token *Token, resolver key.Resolver) bool {
+	_, ok := vc.get(token, resolver)
+	return ok
+}
+
+// Add records a successful validation of a token, verified with the given key.
+func (vc *ValidationCache) Add(token *Token, keyID string, pair key.Pair, claims jws.Claims) {
+	now := vc.now()
+	entry := validationEntry{
+		hash:   vc.hash(token),
+		keyID:  keyID,
+		pair:   pair,
+		claims: claims,
+	}
+
+	if exp, ok := claims.Expiration(); ok {
+		entry.expires = exp.Add(vc.expLeeway)
+	}
+
+	if vc.maxAge > 0 {
+		if maxExpires := now.Add(vc.maxAge); entry.expires.IsZero() || maxExpires.Before(entry.expires) {
+			entry.expires = maxExpires
+		}
+	}
+
+	if !entry.expires.IsZero() && now.After(entry.expires) {
+		return
+	}
+
+	vc.lock.Lock()
+	defer vc.lock.Unlock()
+
+	if element, ok := vc.entries[entry.hash]; ok {
+		element.Value = entry
+		vc.order.MoveToFront(element)
+		return
+	}
+
+	vc.entries[entry.hash] = vc.order.PushFront(entry)
+	for vc.order.Len() > vc.maxEntries {
+		oldest := vc.order.Back()
+		vc.order.Remove(oldest)
+		delete(vc.entries, oldest.Value.(validationEntry).hash)
+	}
+}
@@ -0,0 +1,189 @@
+package secure
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SermoDigital/jose/jws"
+	"github.com/jithin-kg/webpa-common/secure/key"
+	"github.com/jithin-kg/webpa-common/xmetrics/xmetricstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestValidationCache(maxEntries int, expLeeway, maxAge time.Duration, now time.Time) *ValidationCache {
+	vc := NewValidationCache(maxEntries, expLeeway, maxAge)
+	vc.now = func() time.Time { return now }
+	return vc
+}
+
+func TestNewValidationCache(t *testing.T) {
+	assert := assert.New(t)
+
+	vc := NewValidationCache(0, 0, 0)
+	assert.Equal(DefaultValidationCacheSize, vc.maxEntries)
+	assert.Equal(0, vc.Len())
+}
+
+func testValidationCacheHit(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		now      = time.Now()
+		vc       = newTestValidationCache(10, 0, 0, now)
+		token    = &Token{tokenType: Bearer, value: "token"}
+		pair     = new(key.MockPair)
+		resolver = new(key.MockResolver)
+	)
+
+	resolver.On("ResolveKey", "kid").Return(pair, nil).Once()
+	assert.False(vc.Validate(token, resolver))
+
+	vc.Add(token, "kid", pair, jws.Claims{})
+	assert.Equal(1, vc.Len())
+	assert.True(vc.Validate(token, resolver))
+
+	vc.Purge()
+	assert.Equal(0, vc.Len())
+	assert.False(vc.Validate(token, resolver))
+	resolver.AssertExpectations(t)
+}
+
+func testValidationCacheExpired(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		now      = time.Now()
+		vc       = newTestValidationCache(10, 5*time.Second, 0, now)
+		token    = &Token{tokenType: Bearer, value: "token"}
+		pair     = new(key.MockPair)
+		resolver = new(key.MockResolver)
+		claims   = jws.Claims{}
+	)
+
+	// already past exp, but within the leeway
+	claims.SetExpiration(now.Add(-2 * time.Second))
+	resolver.On("ResolveKey", "kid").Return(pair, nil).Once()
+	vc.Add(token, "kid", pair, claims)
+	assert.True(vc.Validate(token, resolver))
+
+	vc.now = func() time.Time { return now.Add(10 * time.Second) }
+	assert.False(vc.Validate(token, resolver))
+	assert.Equal(0, vc.Len())
+
+	// tokens already expired beyond the leeway are never cached
+	vc.Add(token, "kid", pair, claims)
+	assert.Equal(0, vc.Len())
+	resolver.AssertExpectations(t)
+}
+
+func testValidationCacheMaxAge(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		now      = time.Now()
+		vc       = newTestValidationCache(10, 0, time.Minute, now)
+		token    = &Token{tokenType: Bearer, value: "token"}
+		pair     = new(key.MockPair)
+		resolver = new(key.MockResolver)
+	)
+
+	resolver.On("ResolveKey", "kid").Return(pair, nil).Once()
+	vc.Add(token, "kid", pair, jws.Claims{})
+	assert.True(vc.Validate(token, resolver))
+
+	vc.now = func() time.Time { return now.Add(2 * time.Minute) }
+	assert.False(vc.Validate(token, resolver))
+	resolver.AssertExpectations(t)
+}
+
+func testValidationCacheKeyRotated(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		vc       = newTestValidationCache(10, 0, 0, time.Now())
+		token    = &Token{tokenType: Bearer, value: "token"}
+		oldPair  = new(key.MockPair)
+		newPair  = new(key.MockPair)
+		resolver = new(key.MockResolver)
+	)
+
+	resolver.On("ResolveKey", "kid").Return(newPair, nil).Once()
+	vc.Add(token, "kid", oldPair, jws.Claims{})
+	assert.False(vc.Validate(token, resolver))
+	assert.Equal(0, vc.Len())
+
+	resolver.On("ResolveKey", "kid").Return(nil, errors.New("expected")).Once()
+	vc.Add(token, "kid", oldPair, jws.Claims{})
+	assert.False(vc.Validate(token, resolver))
+	assert.Equal(0, vc.Len())
+	resolver.AssertExpectations(t)
+}
+
+func testValidationCacheEviction(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		vc       = newTestValidationCache(2, 0, 0, time.Now())
+		first    = &Token{tokenType: Bearer, value: "first"}
+		second   = &Token{tokenType: Bearer, value: "second"}
+		third    = &Token{tokenType: Bearer, value: "third"}
+		pair     = new(key.MockPair)
+		resolver = new(key.MockResolver)
+	)
+
+	resolver.On("ResolveKey", "kid").Return(pair, nil)
+	vc.Add(first, "kid", pair, jws.Claims{})
+	vc.Add(second, "kid", pair, jws.Claims{})
+
+	// touching first makes second the least recently used
+	assert.True(vc.Validate(first, resolver))
+	vc.Add(third, "kid", pair, jws.Claims{})
+
+	assert.Equal(2, vc.Len())
+	assert.True(vc.Validate(first, resolver))
+	assert.False(vc.Validate(second, resolver))
+	assert.True(vc.Validate(third, resolver))
+}
+
+func TestValidationCache(t *testing.T) {
+	t.Run("Hit", testValidationCacheHit)
+	t.Run("Expired", testValidationCacheExpired)
+	t.Run("MaxAge", testValidationCacheMaxAge)
+	t.Run("KeyRotated", testValidationCacheKeyRotated)
+	t.Run("Eviction", testValidationCacheEviction)
+}
+
+func TestJWSValidatorCache(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		registry = xmetricstest.NewProvider(nil, Metrics)
+		parser   = new(mockJWSParser)
+		token    = &Token{tokenType: Bearer, value: string(testSerializedJWT)}
+
+		validator = JWSValidator{
+			Resolver: publicKeyResolver,
+			Cache:    NewValidationCache(10, 0, 0),
+		}
+	)
+
+	validator.DefineMeasures(&JWTValidationMeasures{
+		ValidationReason: registry.NewCounter(JWTValidationReasonCounter),
+		ValidationCache:  registry.NewCounter(JWTValidationCacheCounter),
+	})
+
+	valid, err := validator.Validate(context.Background(), token)
+	require.NoError(err)
+	require.True(valid)
+	assert.Equal(1, validator.Cache.Len())
+	registry.Assert(t, JWTValidationCacheCounter, "outcome", "miss")(xmetricstest.Value(1.0))
+
+	// the parser must not be consulted on a cache hit
+	validator.Parser = parser
+	valid, err = validator.Validate(context.Background(), token)
+	assert.NoError(err)
+	assert.True(valid)
+	registry.Assert(t, JWTValidationCacheCounter, "outcome", "hit")(xmetricstest.Value(1.0))
+
+	parser.AssertNotCalled(t, "ParseJWS", mock.Anything)
+}
@@ -70,7 +70,16 @@ type JWSValidator struct {
 	Resolver      key.Resolver
 	Parser        JWSParser
 	JWTValidators []*jwt.Validator
-	measures      *JWTValidationMeasures
+
+	// Cache is an optional cache of successful validations.  When set, tokens that have
+	// already been validated skip parsing and signature verification.
+	Cache *ValidationCache
+
+	// Trust is the optional policy used to compute the trust of valid tokens.  If unset,
+	// valid tokens remain Untrusted.
+	Trust *TrustPolicy
+
+	measures *JWTValidationMeasures
 }
 
 // capabilityValidation determines if a claim's capability is valid
@@ -96,6 +105,22 @@ func (v JWSValidator) Validate(ctx context.Context, token *Token) (valid bool, e
 		return
 	}
 
+	if v.Cache != nil {
+		if entry, ok := v.Cache.get(token, v.Resolver); ok {
+			v.computeTrust(ctx, token, entry.keyID, entry.claims)
+			if v.measures != nil {
+				v.measures.ValidationCache.With("outcome", "hit").Add(1)
+				v.measures.ValidationReason.With("reason", "ok").Add(1)
+			}
+
+			return true, nil
+		}
+
+		if v.measures != nil {
+			v.measures.ValidationCache.With("outcome", "miss").Add(1)
+		}
+	}
+
 	parser := v.Parser
 	if parser == nil {
 		parser = DefaultJWSParser
@@ -157,7 +182,8 @@ func (v JWSValidator) Validate(ctx context.Context, token *Token) (valid bool, e
 	}
 
 	// validate jwt token claims capabilities
-	if caps, capOkay := jwsToken.Payload().(jws.Claims).Get("capabilities").([]interface{}); capOkay && len(caps) > 0 {
+	claims, _ := jwsToken.Payload().(jws.Claims)
+	if caps, capOkay := claims.Get("capabilities").([]interface{}); capOkay && len(caps) > 0 {
 
 		/*  commenting out for now
 		    1. remove code in use below
@@ -180,6 +206,11 @@ func (v JWSValidator) Validate(ctx context.Context, token *Token) (valid bool, e
 			v.measures.ValidationReason.With("reason", "ok").Add(1)
 		}
 
+		v.computeTrust(ctx, token, keyId, claims)
+		if v.Cache != nil {
+			v.Cache.Add(token, keyId, pair, claims)
+		}
+
 		return true, nil
 		// ***** ^^^^^^^^^^^^^^^ *****
 
@@ -189,6 +220,24 @@ func (v JWSValidator) Validate(ctx context.Context, token *Token) (valid bool, e
 	return
 }
 
+// computeTrust applies the trust policy, if any, to a valid token
+func (v JWSValidator) computeTrust(ctx context.Context, token *Token, keyID string, claims jws.Claims) {
+	if v.Trust == nil {
+		return
+	}
+
+	issuer, _ := claims.Issuer()
+	subject, _ := claims.Subject()
+	_, clientCertificate := PeerCertificateFromContext(ctx)
+	token.trust, token.trustRule = v.Trust.Compute(TrustInput{
+		Issuer:            issuer,
+		Subject:           subject,
+		KeyID:             keyID,
+		Claims:            claims,
+		ClientCertificate: clientCertificate,
+	})
+}
+
 //DefineMeasures defines the metrics tool used by JWSValidator
 func (v *JWSValidator) DefineMeasures(m *JWTValidationMeasures) {
 	v.measures = m
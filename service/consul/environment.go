@@ -1,10 +1,12 @@
 package consul
 
 import (
+	"context"
 	"crypto/rand"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"sync"
 	"time"
 
 	"github.com/go-kit/kit/log"
@@ -29,17 +31,158 @@ type Environment interface {
 
 	// Client returns the custom consul Client interface exposed by this package
 	Client() Client
+
+	// SetMaintenance places this environment's registered services into or out of consul's
+	// maintenance mode.  The maintenance state survives subsequent calls to Register.
+	SetMaintenance(bool) error
+
+	// SetDrainer establishes the Drainer used to wait for in-flight requests before deregistration.
+	// Draining only happens when Options.DrainTimeout is positive.
+	SetDrainer(Drainer)
+
+	// Failover returns the datacenter failover resolved for this environment, or nil if Options.Failover was not set
+	Failover() *Failover
+}
+
+// Drainer is the strategy for waiting on in-flight work to complete.  *xhttp.InFlight implements this interface.
+type Drainer interface {
+	// Wait blocks until all in-flight work has completed or the context is canceled
+	Wait(context.Context) error
 }
 
+// maintainer is the subset of the consul agent API used to toggle maintenance mode
+type maintainer interface {
+	EnableServiceMaintenance(serviceID, reason string) error
+	DisableServiceMaintenance(serviceID string) error
+}
+
+func defaultMaintainerFactory(client *api.Client) maintainer {
+	return client.Agent()
+}
+
+var maintainerFactory = defaultMaintainerFactory
+
 type environment struct {
 	service.Environment
 	client Client
+
+	logger       log.Logger
+	maintainer   maintainer
+	serviceIDs   []string
+	reason       string
+	drainTimeout time.Duration
+	failover     *Failover
+
+	lock        sync.Mutex
+	drainer     Drainer
+	maintenance bool
+	drained     bool
 }
 
-func (e environment) Client() Client {
+func (e *environment) Client() Client {
 	return e.client
 }
 
+func (e *environment) Failover() *Failover {
+	return e.failover
+}
+
+func (e *environment) SetDrainer(d Drainer) {
+	e.lock.Lock()
+	e.drainer = d
+	e.lock.Unlock()
+}
+
+func (e *environment) SetMaintenance(enabled bool) error {
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.maintenance = enabled
+	return e.applyMaintenance(enabled)
+}
+
+// applyMaintenance sends the given maintenance state to consul for every registered service.
+// This method must be invoked under the lock.
+func (e *environment) applyMaintenance(enabled bool) error {
+	var firstErr error
+	for _, id := range e.serviceIDs {
+		var err error
+		if enabled {
+			err = e.maintainer.EnableServiceMaintenance(id, e.reason)
+		} else {
+			err = e.maintainer.DisableServiceMaintenance(id)
+		}
+
+		if err != nil {
+			e.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to set maintenance mode", "id", id, "enabled", enabled, logging.ErrorKey(), err)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+
+	return firstErr
+}
+
+// drain places the services into maintenance, so that no new traffic is routed here, then waits for
+// in-flight work to finish.  Draining happens at most once per registration.  The wait happens outside
+// the lock, so that maintenance can still be toggled while draining.
+func (e *environment) drain() {
+	e.lock.Lock()
+	if e.drained || e.drainer == nil || e.drainTimeout <= 0 {
+		e.lock.Unlock()
+		return
+	}
+
+	e.drained = true
+	e.applyMaintenance(true)
+	drainer := e.drainer
+	e.lock.Unlock()
+
+	ctx, cancel := context.WithTimeout(context.Background(), e.drainTimeout)
+	defer cancel()
+
+	if err := drainer.Wait(ctx); err != nil {
+		e.logger.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "in-flight requests did not drain before deregistration", "drainTimeout", e.drainTimeout, logging.ErrorKey(), err)
+	}
+}
+
+func (e *environment) Register() {
+	e.Environment.Register()
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	e.drained = false
+	if e.maintenance {
+		e.applyMaintenance(true)
+	}
+}
+
+func (e *environment) Deregister() {
+	e.drain()
+	e.Environment.Deregister()
+}
+
+func (e *environment) Close() error {
+	e.drain()
+	return e.Environment.Close()
+}
+
+// NewMaintenanceListener produces a gate listener, suitable for gate.WithListeners, that places the
+// environment's services into maintenance mode whenever the gate is lowered.
+func NewMaintenanceListener(l log.Logger, e Environment) func(bool) {
+	if l == nil {
+		l = logging.DefaultLogger()
+	}
+
+	return func(open bool) {
+		if err := e.SetMaintenance(!open); err != nil {
+			l.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to update maintenance mode from gate", "open", open, logging.ErrorKey(), err)
+		}
+	}
+}
+
 func generateID() string {
 	b := make([]byte, 16)
 	_, err := rand.Read(b)
@@ -131,27 +274,44 @@ func newInstancer(l log.Logger, c Client, w Watch) sd.Instancer {
 	)
 }
 
-func newInstancers(l log.Logger, c Client, co Options) (i service.Instancers, err error) {
-	var datacenters []string
+// needsDatacenters tests if the full list of datacenters must be obtained from consul
+func needsDatacenters(co Options) bool {
+	if co.failover() != nil {
+		return true
+	}
+
+	for _, w := range co.watches() {
+		if w.AllDatacenters {
+			return true
+		}
+	}
+
+	return false
+}
 
+// newInstancers creates the instancers for each watch.  Failover needs an instancer in every datacenter
+// it might route to, so when failover is configured every watch behaves as if AllDatacenters were set.
+func newInstancers(l log.Logger, c Client, co Options, datacenters []string) (i service.Instancers) {
+	watched := make(map[string]bool)
 	for _, w := range co.watches() {
+		if co.failover() != nil {
+			w.AllDatacenters = true
+		}
+
 		key := newInstancerKey(w)
-		if i.Has(key) {
+		if watched[key] {
 			l.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "skipping duplicate watch", "service", w.Service, "tags", w.Tags, "passingOnly", w.PassingOnly, "datacenter", w.QueryOptions.Datacenter)
 			continue
 		}
 
+		watched[key] = true
 		if w.AllDatacenters {
-			if len(datacenters) == 0 {
-				datacenters, err = getDatacenters(l, c, co)
-				if err != nil {
-					return
-				}
-			}
-
+			// each datacenter gets its own instancer, keyed by that datacenter
 			for _, datacenter := range datacenters {
-				w.QueryOptions.Datacenter = datacenter
-				i.Set(key, newInstancer(l, c, w))
+				dw := w
+				dw.AllDatacenters = false
+				dw.QueryOptions.Datacenter = datacenter
+				i.Set(newInstancerKey(dw), newInstancer(l, c, dw))
 			}
 		} else {
 			i.Set(key, newInstancer(l, c, w))
@@ -161,7 +321,7 @@ func newInstancers(l log.Logger, c Client, co Options) (i service.Instancers, er
 	return
 }
 
-func newRegistrars(l log.Logger, registrationScheme string, c gokitconsul.Client, u ttlUpdater, co Options) (r service.Registrars, closer func() error, err error) {
+func newRegistrars(l log.Logger, registrationScheme string, c gokitconsul.Client, u ttlUpdater, co Options) (r service.Registrars, serviceIDs []string, err error) {
 	var consulRegistrar sd.Registrar
 	for _, registration := range co.registrations() {
 		instance := service.FormatInstance(registrationScheme, registration.Address, registration.Port)
@@ -170,6 +330,7 @@ func newRegistrars(l log.Logger, registrationScheme string, c gokitconsul.Client
 			continue
 		}
 
+		prepareRegistration(registrationScheme, co, &registration)
 		if !co.disableGenerateID() {
 			ensureIDs(&registration)
 		}
@@ -180,6 +341,14 @@ func newRegistrars(l log.Logger, registrationScheme string, c gokitconsul.Client
 		}
 
 		r.Add(instance, consulRegistrar)
+
+		// consul identifies a service registered without an ID by its name
+		serviceID := registration.ID
+		if len(serviceID) == 0 {
+			serviceID = registration.Name
+		}
+
+		serviceIDs = append(serviceIDs, serviceID)
 	}
 
 	return
@@ -200,23 +369,46 @@ func NewEnvironment(l log.Logger, registrationScheme string, co Options, eo ...s
 	}
 
 	client, updater := clientFactory(consulClient)
-	r, closer, err := newRegistrars(l, registrationScheme, client, updater, co)
+	r, serviceIDs, err := newRegistrars(l, registrationScheme, client, updater, co)
 	if err != nil {
 		return nil, err
 	}
 
-	i, err := newInstancers(l, client, co)
-	if err != nil {
-		return nil, err
+	var (
+		datacenters []string
+		failover    *Failover
+	)
+
+	if needsDatacenters(co) {
+		datacenters, err = getDatacenters(l, client, co)
+		if err != nil {
+			return nil, err
+		}
+
+		if co.failover() != nil {
+			failover = newFailover(co, datacenters)
+
+			// include any datacenters named only by the failover options
+			datacenters = append([]string{failover.Datacenter}, failover.Order...)
+		}
 	}
 
-	return environment{
-		service.NewEnvironment(
+	i := newInstancers(l, client, co, datacenters)
+
+	return &environment{
+		Environment: service.NewEnvironment(
 			append(
 				eo,
 				service.WithRegistrars(r),
 				service.WithInstancers(i),
-				service.WithCloser(closer),
 			)...,
-		), NewClient(consulClient)}, nil
+		),
+		client:       NewClient(consulClient),
+		logger:       l,
+		maintainer:   maintainerFactory(consulClient),
+		serviceIDs:   serviceIDs,
+		reason:       co.maintenanceReason(),
+		drainTimeout: co.drainTimeout(),
+		failover:     failover,
+	}, nil
 }
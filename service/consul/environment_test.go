@@ -1,7 +1,10 @@
 package consul
 
 import (
+	"context"
+	"errors"
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul/api"
 	"github.com/stretchr/testify/assert"
@@ -136,8 +139,279 @@ func testNewEnvironmentFull(t *testing.T) {
 	ttlUpdater.AssertExpectations(t)
 }
 
+func testNewEnvironmentFailover(t *testing.T) {
+	defer resetClientFactory()
+
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		clientFactory = prepareMockClientFactory()
+		client        = new(mockClient)
+
+		co = Options{
+			Client: &api.Config{Address: "localhost:8500"},
+			Watches: []Watch{
+				Watch{
+					Service:        "foobar",
+					PassingOnly:    true,
+					AllDatacenters: true,
+				},
+			},
+			Failover: &FailoverOptions{
+				Order: []string{"dc3"},
+			},
+		}
+	)
+
+	clientFactory.On("NewClient", mock.MatchedBy(func(*api.Client) bool { return true })).Return(client, new(mockTTLUpdater)).Once()
+	client.On("Datacenters").Return([]string{"dc1", "dc2", "dc3"}, error(nil)).Once()
+	for _, datacenter := range []string{"dc1", "dc2", "dc3"} {
+		datacenter := datacenter
+		client.On("Service",
+			"foobar",
+			"",
+			true,
+			mock.MatchedBy(func(qo *api.QueryOptions) bool { return qo != nil && qo.Datacenter == datacenter }),
+		).Return([]*api.ServiceEntry{}, new(api.QueryMeta), error(nil))
+	}
+
+	e, err := NewEnvironment(logging.NewTestLogger(nil, t), "http", co)
+	require.NoError(err)
+	require.NotNil(e)
+
+	i := e.Instancers()
+	assert.Equal(3, i.Len())
+	for _, datacenter := range []string{"dc1", "dc2", "dc3"} {
+		assert.True(i.Has(newInstancerKey(Watch{Service: "foobar", PassingOnly: true, QueryOptions: api.QueryOptions{Datacenter: datacenter}})))
+	}
+
+	f := e.(Environment).Failover()
+	require.NotNil(f)
+	assert.Equal("dc1", f.Datacenter)
+	assert.Equal([]string{"dc3", "dc2"}, f.Order)
+
+	assert.NoError(e.Close())
+	clientFactory.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+func testNewEnvironmentFailoverWatch(t *testing.T) {
+	defer resetClientFactory()
+
+	var (
+		assert        = assert.New(t)
+		require       = require.New(t)
+		clientFactory = prepareMockClientFactory()
+		client        = new(mockClient)
+
+		co = Options{
+			Client: &api.Config{Address: "localhost:8500"},
+			Watches: []Watch{
+				Watch{
+					Service:     "foobar",
+					PassingOnly: true,
+				},
+			},
+			Failover: &FailoverOptions{
+				Datacenter: "dc2",
+				Order:      []string{"dc3"},
+			},
+		}
+	)
+
+	clientFactory.On("NewClient", mock.MatchedBy(func(*api.Client) bool { return true })).Return(client, new(mockTTLUpdater)).Once()
+	client.On("Datacenters").Return([]string{"dc1", "dc2"}, error(nil)).Once()
+	for _, datacenter := range []string{"dc1", "dc2", "dc3"} {
+		datacenter := datacenter
+		client.On("Service",
+			"foobar",
+			"",
+			true,
+			mock.MatchedBy(func(qo *api.QueryOptions) bool { return qo != nil && qo.Datacenter == datacenter }),
+		).Return([]*api.ServiceEntry{}, new(api.QueryMeta), error(nil))
+	}
+
+	e, err := NewEnvironment(logging.NewTestLogger(nil, t), "http", co)
+	require.NoError(err)
+	require.NotNil(e)
+
+	// a watch without AllDatacenters is still instanced in every failover datacenter
+	i := e.Instancers()
+	assert.Equal(3, i.Len())
+	for _, datacenter := range []string{"dc1", "dc2", "dc3"} {
+		assert.True(i.Has(newInstancerKey(Watch{Service: "foobar", PassingOnly: true, QueryOptions: api.QueryOptions{Datacenter: datacenter}})))
+	}
+
+	assert.NoError(e.Close())
+	clientFactory.AssertExpectations(t)
+	client.AssertExpectations(t)
+}
+
+// newMaintenanceEnvironment creates a consul Environment with one registration, backed by mocks
+func newMaintenanceEnvironment(t *testing.T, co Options) (Environment, *mockClient, *mockMaintainer) {
+	var (
+		require       = require.New(t)
+		clientFactory = prepareMockClientFactory()
+		client        = new(mockClient)
+		maintainer    = prepareMockMaintainer()
+	)
+
+	co.Client = &api.Config{Address: "localhost:8500"}
+	co.Registrations = []api.AgentServiceRegistration{
+		api.AgentServiceRegistration{
+			ID:      "service1",
+			Address: "grubly.com",
+			Port:    1111,
+		},
+	}
+
+	clientFactory.On("NewClient", mock.MatchedBy(func(*api.Client) bool { return true })).Return(client, new(mockTTLUpdater)).Once()
+	client.On("Register", mock.MatchedBy(func(r *api.AgentServiceRegistration) bool { return r.ID == "service1" })).Return(error(nil))
+	client.On("Deregister", mock.MatchedBy(func(r *api.AgentServiceRegistration) bool { return r.ID == "service1" })).Return(error(nil))
+
+	e, err := NewEnvironment(logging.NewTestLogger(nil, t), "http", co)
+	require.NoError(err)
+	require.NotNil(e)
+
+	ce, ok := e.(Environment)
+	require.True(ok)
+	return ce, client, maintainer
+}
+
+func testEnvironmentMaintenance(t *testing.T) {
+	defer resetClientFactory()
+	defer resetMaintainerFactory()
+
+	var (
+		assert                = assert.New(t)
+		e, client, maintainer = newMaintenanceEnvironment(t, Options{MaintenanceReason: "testing"})
+		expectedError         = errors.New("expected")
+		listener              = NewMaintenanceListener(logging.NewTestLogger(nil, t), e)
+	)
+
+	maintainer.On("EnableServiceMaintenance", "service1", "testing").Return(error(nil)).Twice()
+	listener(false)
+	e.Register()
+
+	maintainer.On("DisableServiceMaintenance", "service1").Return(expectedError).Twice()
+	listener(true)
+	assert.Equal(expectedError, e.SetMaintenance(false))
+
+	assert.NoError(e.Close())
+	client.AssertExpectations(t)
+	maintainer.AssertExpectations(t)
+}
+
+func testEnvironmentDrain(t *testing.T) {
+	defer resetClientFactory()
+	defer resetMaintainerFactory()
+
+	var (
+		assert                = assert.New(t)
+		e, client, maintainer = newMaintenanceEnvironment(t, Options{DrainTimeout: time.Minute})
+		drainer               = new(mockDrainer)
+	)
+
+	e.Register()
+	e.SetDrainer(drainer)
+
+	maintainer.On("EnableServiceMaintenance", "service1", DefaultMaintenanceReason).Return(error(nil)).Once()
+	drainer.On("Wait", mock.MatchedBy(func(ctx context.Context) bool {
+		_, ok := ctx.Deadline()
+		return ok
+	})).Return(context.DeadlineExceeded).Once()
+
+	e.Deregister()
+
+	// the services have already been drained, so closing should not drain again
+	assert.NoError(e.Close())
+
+	client.AssertExpectations(t)
+	maintainer.AssertExpectations(t)
+	drainer.AssertExpectations(t)
+}
+
+func testEnvironmentMaintenanceWhileDraining(t *testing.T) {
+	defer resetClientFactory()
+	defer resetMaintainerFactory()
+
+	var (
+		assert                = assert.New(t)
+		e, client, maintainer = newMaintenanceEnvironment(t, Options{DrainTimeout: time.Minute})
+		drainer               = new(mockDrainer)
+	)
+
+	e.Register()
+	e.SetDrainer(drainer)
+
+	maintainer.On("EnableServiceMaintenance", "service1", DefaultMaintenanceReason).Return(error(nil)).Once()
+	maintainer.On("DisableServiceMaintenance", "service1").Return(error(nil)).Once()
+	drainer.On("Wait", mock.Anything).Return(error(nil)).Once().Run(func(mock.Arguments) {
+		// waiting for in-flight work must not block maintenance changes
+		assert.NoError(e.SetMaintenance(false))
+	})
+
+	e.Deregister()
+	assert.NoError(e.Close())
+
+	client.AssertExpectations(t)
+	maintainer.AssertExpectations(t)
+	drainer.AssertExpectations(t)
+}
+
+func testEnvironmentNoDrainTimeout(t *testing.T) {
+	defer resetClientFactory()
+	defer resetMaintainerFactory()
+
+	var (
+		assert                = assert.New(t)
+		e, client, maintainer = newMaintenanceEnvironment(t, Options{})
+		drainer               = new(mockDrainer)
+	)
+
+	e.SetDrainer(drainer)
+	e.Register()
+	assert.NoError(e.Close())
+
+	client.AssertExpectations(t)
+	maintainer.AssertExpectations(t)
+	drainer.AssertExpectations(t)
+}
+
+func testNewRegistrarsServiceIDs(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		co = Options{
+			DisableGenerateID: true,
+			Registrations: []api.AgentServiceRegistration{
+				{Name: "talaria", Address: "talaria-0.example.com", Port: 8080},
+				{ID: "explicit", Name: "talaria", Address: "talaria-1.example.com", Port: 8080},
+			},
+		}
+	)
+
+	r, serviceIDs, err := newRegistrars(logging.NewTestLogger(nil, t), "http", new(mockClient), new(mockTTLUpdater), co)
+	require.NoError(err)
+	assert.Equal(2, r.Len())
+
+	// without generated IDs, a registration with no ID is known to consul by its name
+	assert.Equal([]string{"talaria", "explicit"}, serviceIDs)
+}
+
+func TestEnvironment(t *testing.T) {
+	t.Run("Maintenance", testEnvironmentMaintenance)
+	t.Run("Drain", testEnvironmentDrain)
+	t.Run("MaintenanceWhileDraining", testEnvironmentMaintenanceWhileDraining)
+	t.Run("NoDrainTimeout", testEnvironmentNoDrainTimeout)
+}
+
 func TestNewEnvironment(t *testing.T) {
 	t.Run("Empty", testNewEnvironmentEmpty)
 	t.Run("ClientError", testNewEnvironmentClientError)
 	t.Run("Full", testNewEnvironmentFull)
+	t.Run("Failover", testNewEnvironmentFailover)
+	t.Run("FailoverWatch", testNewEnvironmentFailoverWatch)
+	t.Run("ServiceIDs", testNewRegistrarsServiceIDs)
 }
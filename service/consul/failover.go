@@ -0,0 +1,161 @@
+package consul
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics/provider"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/jithin-kg/webpa-common/service/monitor"
+)
+
+var errUnhealthyDatacenter = errors.New("Too few healthy instances in datacenter")
+
+// Failover is the datacenter failover for a consul environment, resolved against the datacenters
+// that consul knows about.
+type Failover struct {
+	// Datacenter is the local datacenter, which receives traffic whenever it is healthy
+	Datacenter string
+
+	// Order lists the remaining datacenters in the order they are tried when the local datacenter is unhealthy
+	Order []string
+
+	// MinHealthyInstances is the fewest healthy instances a datacenter can have and still receive traffic
+	MinHealthyInstances int
+}
+
+// newFailover resolves the failover options against the known datacenters, which consul orders
+// by round trip time from the agent.
+func newFailover(co Options, datacenters []string) *Failover {
+	fo := co.failover()
+	f := &Failover{
+		Datacenter:          fo.datacenter(),
+		MinHealthyInstances: fo.minHealthyInstances(),
+	}
+
+	if len(f.Datacenter) == 0 {
+		f.Datacenter = co.config().Datacenter
+	}
+
+	if len(f.Datacenter) == 0 && len(datacenters) > 0 {
+		f.Datacenter = datacenters[0]
+	}
+
+	seen := map[string]bool{f.Datacenter: true}
+	for _, list := range [][]string{fo.order(), datacenters} {
+		for _, datacenter := range list {
+			if !seen[datacenter] {
+				seen[datacenter] = true
+				f.Order = append(f.Order, datacenter)
+			}
+		}
+	}
+
+	return f
+}
+
+// NewLayeredAccessor creates a service.LayeredAccessor that tries the failover datacenters in this Failover's order
+func (f *Failover) NewLayeredAccessor() service.LayeredAccessor {
+	return service.NewLayeredAccesor(service.DefaultTrafficRouter(), service.NewZoneOrder(f.Order...))
+}
+
+// eventContext extracts the service name and datacenter from the contextual metadata of a consul instancer
+func eventContext(e monitor.Event) (name, datacenter string) {
+	if c, ok := e.Instancer.(logging.Contextual); ok {
+		m := c.Metadata()
+		name, _ = m["service"].(string)
+		datacenter, _ = m["datacenter"].(string)
+	}
+
+	return
+}
+
+// NewListener produces a monitor.Listener that feeds the given LayeredAccessor with the instances of a single
+// watched service.  Events from the local datacenter, or from a watch without a datacenter, update the primary
+// accessor while events from other datacenters update the failover accessors.  A datacenter with fewer than
+// MinHealthyInstances instances is treated as unavailable, so watches should normally be PassingOnly.
+//
+// If the MetadataAccessorFactory is nil, the default accessor factory is used and metadata is ignored.
+// If the provider is nil, no failover metrics are recorded.
+func (f *Failover) NewListener(l log.Logger, p provider.Provider, serviceName string, mf service.MetadataAccessorFactory, la service.LayeredAccessor) monitor.Listener {
+	if la == nil {
+		panic("A LayeredAccessor is required")
+	}
+
+	if l == nil {
+		l = logging.DefaultLogger()
+	}
+
+	if p == nil {
+		p = provider.NewDiscardProvider()
+	}
+
+	if mf == nil {
+		mf = func(instances []string, _ map[string]service.InstanceMetadata) service.Accessor {
+			return service.DefaultAccessorFactory(instances)
+		}
+	}
+
+	var (
+		failoverActive      = p.NewGauge(service.FailoverActive).With(service.ServiceLabel, serviceName)
+		failoverCount       = p.NewCounter(service.FailoverCount).With(service.ServiceLabel, serviceName)
+		datacenterAvailable = p.NewGauge(service.DatacenterAvailable)
+
+		lock        sync.Mutex
+		failingOver bool
+	)
+
+	return monitor.ListenerFunc(func(e monitor.Event) {
+		name, datacenter := eventContext(e)
+		if e.Stopped || name != serviceName {
+			return
+		}
+
+		if len(datacenter) == 0 {
+			datacenter = f.Datacenter
+		}
+
+		var (
+			a   service.Accessor
+			err = e.Err
+		)
+
+		if err == nil && len(e.Instances) < f.MinHealthyInstances {
+			err = fmt.Errorf("%w: %d healthy instances of %s in %s", errUnhealthyDatacenter, len(e.Instances), serviceName, datacenter)
+		}
+
+		if err == nil {
+			a = mf(e.Instances, e.Metadata)
+			datacenterAvailable.With(service.ServiceLabel, serviceName, service.DatacenterLabel, datacenter).Set(1.0)
+		} else {
+			datacenterAvailable.With(service.ServiceLabel, serviceName, service.DatacenterLabel, datacenter).Set(0.0)
+		}
+
+		if datacenter != f.Datacenter {
+			la.UpdateFailOver(datacenter, a, err)
+			return
+		}
+
+		la.UpdatePrimary(a, err)
+
+		lock.Lock()
+		defer lock.Unlock()
+
+		switch {
+		case err != nil && !failingOver:
+			failingOver = true
+			failoverCount.Add(1.0)
+			failoverActive.Set(1.0)
+			l.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "failing over from local datacenter", "service", serviceName, "datacenter", datacenter, logging.ErrorKey(), err)
+
+		case err == nil && failingOver:
+			failingOver = false
+			failoverActive.Set(0.0)
+			l.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "traffic restored to local datacenter", "service", serviceName, "datacenter", datacenter)
+		}
+	})
+}
@@ -0,0 +1,139 @@
+package consul
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/jithin-kg/webpa-common/service/monitor"
+	"github.com/jithin-kg/webpa-common/xmetrics/xmetricstest"
+)
+
+func testNewFailoverDefaults(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		f      = newFailover(Options{Failover: new(FailoverOptions)}, []string{"dc2", "dc1", "dc3"})
+	)
+
+	assert.Equal("dc2", f.Datacenter)
+	assert.Equal([]string{"dc1", "dc3"}, f.Order)
+	assert.Equal(DefaultMinHealthyInstances, f.MinHealthyInstances)
+}
+
+func testNewFailoverClientDatacenter(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		f      = newFailover(
+			Options{
+				Client:   &api.Config{Datacenter: "dc1"},
+				Failover: new(FailoverOptions),
+			},
+			[]string{"dc2", "dc1", "dc3"},
+		)
+	)
+
+	assert.Equal("dc1", f.Datacenter)
+	assert.Equal([]string{"dc2", "dc3"}, f.Order)
+}
+
+func testNewFailoverCustom(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		f      = newFailover(
+			Options{
+				Client: &api.Config{Datacenter: "dc1"},
+				Failover: &FailoverOptions{
+					Datacenter:          "dc3",
+					Order:               []string{"dc4", "dc3", "dc1", "dc4"},
+					MinHealthyInstances: 3,
+				},
+			},
+			[]string{"dc2", "dc1", "dc3", "dc4"},
+		)
+	)
+
+	assert.Equal("dc3", f.Datacenter)
+	assert.Equal([]string{"dc4", "dc1", "dc2"}, f.Order)
+	assert.Equal(3, f.MinHealthyInstances)
+}
+
+func TestNewFailover(t *testing.T) {
+	t.Run("Defaults", testNewFailoverDefaults)
+	t.Run("ClientDatacenter", testNewFailoverClientDatacenter)
+	t.Run("Custom", testNewFailoverCustom)
+}
+
+func newFailoverEvent(name, datacenter string, err error, instances ...string) monitor.Event {
+	m := map[string]interface{}{"service": name}
+	if len(datacenter) > 0 {
+		m["datacenter"] = datacenter
+	}
+
+	return monitor.Event{
+		Instancer: service.NewContextualInstancer(nil, m),
+		Instances: instances,
+		Err:       err,
+	}
+}
+
+func TestFailoverNewListener(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		f = &Failover{
+			Datacenter:          "dc1",
+			Order:               []string{"dc2", "dc3"},
+			MinHealthyInstances: 2,
+		}
+
+		p  = xmetricstest.NewProvider(nil, service.Metrics)
+		la = f.NewLayeredAccessor()
+		l  = f.NewListener(logging.NewTestLogger(nil, t), p, "foobar", nil, la)
+	)
+
+	assert.Panics(func() {
+		f.NewListener(nil, nil, "foobar", nil, nil)
+	})
+
+	// the local datacenter is healthy, and events for other services are ignored
+	l.MonitorEvent(newFailoverEvent("foobar", "", nil, "http://local1.com", "http://local2.com"))
+	l.MonitorEvent(newFailoverEvent("foobar", "dc2", nil, "http://remote1.com", "http://remote2.com"))
+	l.MonitorEvent(newFailoverEvent("foobar", "dc3", errors.New("expected")))
+	l.MonitorEvent(newFailoverEvent("other", "dc1", errors.New("expected")))
+
+	instance, err := la.Get([]byte("key"))
+	assert.NoError(err)
+	assert.Contains([]string{"http://local1.com", "http://local2.com"}, instance)
+
+	p.Assert(t, service.DatacenterAvailable, service.ServiceLabel, "foobar", service.DatacenterLabel, "dc1")(xmetricstest.Value(1.0))
+	p.Assert(t, service.DatacenterAvailable, service.ServiceLabel, "foobar", service.DatacenterLabel, "dc2")(xmetricstest.Value(1.0))
+	p.Assert(t, service.DatacenterAvailable, service.ServiceLabel, "foobar", service.DatacenterLabel, "dc3")(xmetricstest.Value(0.0))
+	p.Assert(t, service.FailoverActive, service.ServiceLabel, "foobar")(xmetricstest.Value(0.0))
+	p.Assert(t, service.FailoverCount, service.ServiceLabel, "foobar")(xmetricstest.Value(0.0))
+
+	// too few healthy instances in the local datacenter
+	l.MonitorEvent(newFailoverEvent("foobar", "dc1", nil, "http://local1.com"))
+	l.MonitorEvent(newFailoverEvent("foobar", "dc1", errors.New("expected")))
+
+	instance, err = la.Get([]byte("key"))
+	assert.Contains([]string{"http://remote1.com", "http://remote2.com"}, instance)
+	assert.Error(err)
+
+	p.Assert(t, service.DatacenterAvailable, service.ServiceLabel, "foobar", service.DatacenterLabel, "dc1")(xmetricstest.Value(0.0))
+	p.Assert(t, service.FailoverActive, service.ServiceLabel, "foobar")(xmetricstest.Value(1.0))
+	p.Assert(t, service.FailoverCount, service.ServiceLabel, "foobar")(xmetricstest.Value(1.0))
+
+	// the local datacenter recovers
+	l.MonitorEvent(newFailoverEvent("foobar", "dc1", nil, "http://local1.com", "http://local2.com"))
+	l.MonitorEvent(monitor.Event{Stopped: true})
+
+	instance, err = la.Get([]byte("key"))
+	assert.NoError(err)
+	assert.Contains([]string{"http://local1.com", "http://local2.com"}, instance)
+
+	p.Assert(t, service.FailoverActive, service.ServiceLabel, "foobar")(xmetricstest.Value(0.0))
+	p.Assert(t, service.FailoverCount, service.ServiceLabel, "foobar")(xmetricstest.Value(1.0))
+}
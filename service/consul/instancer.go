@@ -14,9 +14,16 @@ import (
 	"github.com/go-kit/kit/util/conn"
 	"github.com/hashicorp/consul/api"
 	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
 )
 
-const defaultIndex = 0
+const (
+	defaultIndex = 0
+
+	// ZoneMetaKey is the service or node metadata key holding an instance's zone.  Service metadata
+	// takes precedence over node metadata.
+	ZoneMetaKey = "zone"
+)
 
 var (
 	errStopped = errors.New("Instancer stopped")
@@ -37,12 +44,13 @@ func NewInstancer(o InstancerOptions) sd.Instancer {
 	}
 
 	i := &instancer{
-		client:      o.Client,
-		logger:      log.With(o.Logger, "service", o.Service, "tags", fmt.Sprint(o.Tags), "passingOnly", o.PassingOnly, "datacenter", o.QueryOptions.Datacenter),
-		service:     o.Service,
-		passingOnly: o.PassingOnly,
-		stop:        make(chan struct{}),
-		registry:    make(map[chan<- sd.Event]bool),
+		client:       o.Client,
+		logger:       log.With(o.Logger, "service", o.Service, "tags", fmt.Sprint(o.Tags), "passingOnly", o.PassingOnly, "datacenter", o.QueryOptions.Datacenter),
+		service:      o.Service,
+		passingOnly:  o.PassingOnly,
+		queryOptions: o.QueryOptions,
+		stop:         make(chan struct{}),
+		registry:     make(map[chan<- sd.Event]bool),
 	}
 
 	if len(o.Tags) > 0 {
@@ -53,14 +61,14 @@ func NewInstancer(o InstancerOptions) sd.Instancer {
 	}
 
 	// grab the initial set of instances
-	instances, index, err := i.getInstances(defaultIndex, nil)
+	instances, metadata, index, err := i.getInstances(defaultIndex, nil)
 	if err == nil {
 		i.logger.Log(level.Key(), level.InfoValue(), "instances", len(instances))
 	} else {
 		i.logger.Log(level.Key(), level.ErrorValue(), logging.ErrorKey(), err)
 	}
 
-	i.update(sd.Event{Instances: instances, Err: err})
+	i.update(sd.Event{Instances: instances, Err: err}, metadata)
 	go i.loop(index)
 
 	return i
@@ -81,19 +89,21 @@ type instancer struct {
 
 	registerLock sync.Mutex
 	state        sd.Event
+	metadata     map[string]service.InstanceMetadata
 	registry     map[chan<- sd.Event]bool
 }
 
-func (i *instancer) update(e sd.Event) {
+func (i *instancer) update(e sd.Event, metadata map[string]service.InstanceMetadata) {
 	sort.Strings(e.Instances)
 	defer i.registerLock.Unlock()
 	i.registerLock.Lock()
 
-	if reflect.DeepEqual(i.state, e) {
+	if reflect.DeepEqual(i.state, e) && reflect.DeepEqual(i.metadata, metadata) {
 		return
 	}
 
 	i.state = e
+	i.metadata = metadata
 	for c := range i.registry {
 		c <- i.state
 	}
@@ -102,12 +112,13 @@ func (i *instancer) update(e sd.Event) {
 func (i *instancer) loop(lastIndex uint64) {
 	var (
 		instances []string
+		metadata  map[string]service.InstanceMetadata
 		err       error
 		d         time.Duration = 10 * time.Millisecond
 	)
 
 	for {
-		instances, lastIndex, err = i.getInstances(lastIndex, i.stop)
+		instances, metadata, lastIndex, err = i.getInstances(lastIndex, i.stop)
 		switch {
 		case err == errStopped:
 			return
@@ -116,10 +127,10 @@ func (i *instancer) loop(lastIndex uint64) {
 			i.logger.Log(logging.ErrorKey(), err)
 			time.Sleep(d)
 			d = conn.Exponential(d)
-			i.update(sd.Event{Err: err})
+			i.update(sd.Event{Err: err}, nil)
 
 		default:
-			i.update(sd.Event{Instances: instances})
+			i.update(sd.Event{Instances: instances}, metadata)
 			d = 10 * time.Millisecond
 		}
 	}
@@ -127,9 +138,10 @@ func (i *instancer) loop(lastIndex uint64) {
 
 // getInstances is implemented similarly to go-kits sd/consul version, albeit with support for
 // arbitrary query options
-func (i *instancer) getInstances(lastIndex uint64, stop <-chan struct{}) ([]string, uint64, error) {
+func (i *instancer) getInstances(lastIndex uint64, stop <-chan struct{}) ([]string, map[string]service.InstanceMetadata, uint64, error) {
 	type response struct {
 		instances []string
+		metadata  map[string]service.InstanceMetadata
 		index     uint64
 		err       error
 	}
@@ -151,15 +163,16 @@ func (i *instancer) getInstances(lastIndex uint64, stop <-chan struct{}) ([]stri
 
 		result <- response{
 			instances: makeInstances(entries),
+			metadata:  makeMetadata(entries),
 			index:     meta.LastIndex,
 		}
 	}()
 
 	select {
 	case r := <-result:
-		return r.instances, r.index, r.err
+		return r.instances, r.metadata, r.index, r.err
 	case <-stop:
-		return nil, 0, errStopped
+		return nil, nil, 0, errStopped
 	}
 }
 
@@ -206,6 +219,43 @@ func makeInstances(entries []*api.ServiceEntry) []string {
 	return instances
 }
 
+// makeMetadata produces the metadata for each instance produced by makeInstances
+func makeMetadata(entries []*api.ServiceEntry) map[string]service.InstanceMetadata {
+	instances := makeInstances(entries)
+	metadata := make(map[string]service.InstanceMetadata, len(entries))
+	for i, entry := range entries {
+		m := service.InstanceMetadata{
+			Datacenter: entry.Node.Datacenter,
+			Zone:       entry.Node.Meta[ZoneMetaKey],
+			Weight:     entry.Service.Weights.Passing,
+			Tags:       entry.Service.Tags,
+			Meta:       entry.Service.Meta,
+		}
+
+		if zone := entry.Service.Meta[ZoneMetaKey]; len(zone) > 0 {
+			m.Zone = zone
+		}
+
+		metadata[instances[i]] = m
+	}
+
+	return metadata
+}
+
+// InstanceMetadata returns the metadata of the current instances, keyed by the same instance strings
+// sent in sd.Events
+func (i *instancer) InstanceMetadata() map[string]service.InstanceMetadata {
+	defer i.registerLock.Unlock()
+	i.registerLock.Lock()
+
+	metadata := make(map[string]service.InstanceMetadata, len(i.metadata))
+	for k, v := range i.metadata {
+		metadata[k] = v
+	}
+
+	return metadata
+}
+
 func (i *instancer) Register(ch chan<- sd.Event) {
 	defer i.registerLock.Unlock()
 	i.registerLock.Lock()
@@ -5,6 +5,7 @@ import (
 	"testing"
 
 	"github.com/hashicorp/consul/api"
+	"github.com/jithin-kg/webpa-common/service"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -130,3 +131,31 @@ func TestMakeInstances(t *testing.T) {
 		})
 	}
 }
+
+func TestMakeMetadata(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		zoned      = newServiceEntry("service1.com", 8080, "foo")
+		nodeZoned  = newServiceEntryNode("node1.com", 9090)
+		overridden = newServiceEntry("service2.com", 1234)
+	)
+
+	zoned.Node.Datacenter = "dc1"
+	zoned.Service.Meta = map[string]string{ZoneMetaKey: "east", "version": "1.0"}
+	zoned.Service.Weights.Passing = 3
+
+	nodeZoned.Node.Meta = map[string]string{ZoneMetaKey: "west"}
+
+	overridden.Node.Meta = map[string]string{ZoneMetaKey: "west"}
+	overridden.Service.Meta = map[string]string{ZoneMetaKey: "north"}
+
+	assert.Equal(
+		map[string]service.InstanceMetadata{
+			"service1.com:8080": {Datacenter: "dc1", Zone: "east", Weight: 3, Tags: []string{"foo"}, Meta: zoned.Service.Meta},
+			"node1.com:9090":    {Zone: "west"},
+			"service2.com:1234": {Zone: "north", Meta: overridden.Service.Meta},
+		},
+		makeMetadata([]*api.ServiceEntry{zoned, nodeZoned, overridden}),
+	)
+}
@@ -1,6 +1,7 @@
 package consul
 
 import (
+	"context"
 	"time"
 
 	"github.com/hashicorp/consul/api"
@@ -87,3 +88,33 @@ type mockTTLUpdater struct {
 func (m *mockTTLUpdater) UpdateTTL(checkID, output, status string) error {
 	return m.Called(checkID, output, status).Error(0)
 }
+
+func resetMaintainerFactory() {
+	maintainerFactory = defaultMaintainerFactory
+}
+
+func prepareMockMaintainer() *mockMaintainer {
+	m := new(mockMaintainer)
+	maintainerFactory = func(*api.Client) maintainer { return m }
+	return m
+}
+
+type mockMaintainer struct {
+	mock.Mock
+}
+
+func (m *mockMaintainer) EnableServiceMaintenance(serviceID, reason string) error {
+	return m.Called(serviceID, reason).Error(0)
+}
+
+func (m *mockMaintainer) DisableServiceMaintenance(serviceID string) error {
+	return m.Called(serviceID).Error(0)
+}
+
+type mockDrainer struct {
+	mock.Mock
+}
+
+func (m *mockDrainer) Wait(ctx context.Context) error {
+	return m.Called(ctx).Error(0)
+}
@@ -1,10 +1,23 @@
 package consul
 
 import (
+	"time"
+
 	"github.com/hashicorp/consul/api"
 )
 
-const DefaultDatacenterRetries = 10
+const (
+	DefaultDatacenterRetries = 10
+
+	// DefaultCheckInterval is the interval used for HTTP, TCP, gRPC and script checks that do not specify one
+	DefaultCheckInterval = "10s"
+
+	// DefaultMaintenanceReason is the reason given to consul when services are placed into maintenance mode
+	DefaultMaintenanceReason = "placed into maintenance by the service gate"
+
+	// DefaultMinHealthyInstances is the fewest healthy instances a datacenter can have and still receive traffic
+	DefaultMinHealthyInstances = 1
+)
 
 type Watch struct {
 	Service        string           `json:"service,omitempty"`
@@ -14,12 +27,73 @@ type Watch struct {
 	QueryOptions   api.QueryOptions `json:"queryOptions"`
 }
 
+// FailoverOptions configures how traffic for watched services fails over between datacenters
+type FailoverOptions struct {
+	// Datacenter is the local datacenter, which receives traffic whenever it is healthy.  If unset, the
+	// datacenter of the client configuration is used, and failing that the nearest datacenter reported by consul.
+	Datacenter string `json:"datacenter,omitempty"`
+
+	// Order is the preferred order of the failover datacenters.  Datacenters not listed here are tried
+	// afterward, nearest first, as reported by consul.
+	Order []string `json:"order,omitempty"`
+
+	// MinHealthyInstances is the fewest healthy instances a datacenter can have and still receive traffic.
+	// If unset, DefaultMinHealthyInstances is used.
+	MinHealthyInstances int `json:"minHealthyInstances,omitempty"`
+}
+
+func (fo *FailoverOptions) datacenter() string {
+	if fo != nil {
+		return fo.Datacenter
+	}
+
+	return ""
+}
+
+func (fo *FailoverOptions) order() []string {
+	if fo != nil && len(fo.Order) > 0 {
+		return fo.Order
+	}
+
+	return nil
+}
+
+func (fo *FailoverOptions) minHealthyInstances() int {
+	if fo != nil && fo.MinHealthyInstances > 0 {
+		return fo.MinHealthyInstances
+	}
+
+	return DefaultMinHealthyInstances
+}
+
 type Options struct {
 	Client            *api.Config                    `json:"client"`
 	DisableGenerateID bool                           `json:"disableGenerateID"`
 	DatacenterRetries int                            `json:"datacenterRetries"`
 	Registrations     []api.AgentServiceRegistration `json:"registrations,omitempty"`
 	Watches           []Watch                        `json:"watches,omitempty"`
+
+	// Tags are added to every registration, in addition to any tags the registration has
+	Tags []string `json:"tags,omitempty"`
+
+	// Meta is added to every registration.  Metadata in a registration takes precedence.
+	Meta map[string]string `json:"meta,omitempty"`
+
+	// CheckInterval is the interval for HTTP, TCP, gRPC and script checks that do not specify one.
+	// If not supplied, DefaultCheckInterval is used.
+	CheckInterval string `json:"checkInterval,omitempty"`
+
+	// MaintenanceReason is the reason given to consul when services are placed into maintenance mode.
+	// If not supplied, DefaultMaintenanceReason is used.
+	MaintenanceReason string `json:"maintenanceReason,omitempty"`
+
+	// DrainTimeout is the longest that deregistration waits for in-flight requests to finish, once
+	// the services are in maintenance mode.  If nonpositive, deregistration does not wait.
+	DrainTimeout time.Duration `json:"drainTimeout,omitempty"`
+
+	// Failover enables datacenter failover, which discovers every datacenter known to consul.  Every watch is
+	// then instanced in each failover datacenter, as if AllDatacenters were set.  See Environment.Failover.
+	Failover *FailoverOptions `json:"failover,omitempty"`
 }
 
 func (o *Options) config() *api.Config {
@@ -61,3 +135,51 @@ func (o *Options) watches() []Watch {
 
 	return nil
 }
+
+func (o *Options) tags() []string {
+	if o != nil && len(o.Tags) > 0 {
+		return o.Tags
+	}
+
+	return nil
+}
+
+func (o *Options) meta() map[string]string {
+	if o != nil && len(o.Meta) > 0 {
+		return o.Meta
+	}
+
+	return nil
+}
+
+func (o *Options) checkInterval() string {
+	if o != nil && len(o.CheckInterval) > 0 {
+		return o.CheckInterval
+	}
+
+	return DefaultCheckInterval
+}
+
+func (o *Options) maintenanceReason() string {
+	if o != nil && len(o.MaintenanceReason) > 0 {
+		return o.MaintenanceReason
+	}
+
+	return DefaultMaintenanceReason
+}
+
+func (o *Options) drainTimeout() time.Duration {
+	if o != nil && o.DrainTimeout > 0 {
+		return o.DrainTimeout
+	}
+
+	return 0
+}
+
+func (o *Options) failover() *FailoverOptions {
+	if o != nil {
+		return o.Failover
+	}
+
+	return nil
+}
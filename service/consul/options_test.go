@@ -2,6 +2,7 @@ package consul
 
 import (
 	"testing"
+	"time"
 
 	"github.com/hashicorp/consul/api"
 	"github.com/stretchr/testify/assert"
@@ -15,6 +16,12 @@ func testOptionsDefault(t *testing.T, o *Options) {
 	assert.False(o.disableGenerateID())
 	assert.Len(o.registrations(), 0)
 	assert.Len(o.watches(), 0)
+	assert.Empty(o.tags())
+	assert.Empty(o.meta())
+	assert.Equal(DefaultCheckInterval, o.checkInterval())
+	assert.Equal(DefaultMaintenanceReason, o.maintenanceReason())
+	assert.Zero(o.drainTimeout())
+	assert.Nil(o.failover())
 }
 
 func testOptionsCustom(t *testing.T) {
@@ -44,6 +51,13 @@ func testOptionsCustom(t *testing.T) {
 					PassingOnly: true,
 				},
 			},
+
+			Tags:              []string{"xmidt"},
+			Meta:              map[string]string{"role": "talaria"},
+			CheckInterval:     "5s",
+			MaintenanceReason: "upgrade",
+			DrainTimeout:      time.Minute,
+			Failover:          &FailoverOptions{Datacenter: "dc1"},
 		}
 	)
 
@@ -74,6 +88,45 @@ func testOptionsCustom(t *testing.T) {
 		},
 		o.watches(),
 	)
+
+	assert.Equal([]string{"xmidt"}, o.tags())
+	assert.Equal(map[string]string{"role": "talaria"}, o.meta())
+	assert.Equal("5s", o.checkInterval())
+	assert.Equal("upgrade", o.maintenanceReason())
+	assert.Equal(time.Minute, o.drainTimeout())
+	assert.Equal(&FailoverOptions{Datacenter: "dc1"}, o.failover())
+}
+
+func testFailoverOptionsDefault(t *testing.T, fo *FailoverOptions) {
+	assert := assert.New(t)
+
+	assert.Empty(fo.datacenter())
+	assert.Empty(fo.order())
+	assert.Equal(DefaultMinHealthyInstances, fo.minHealthyInstances())
+}
+
+func testFailoverOptionsCustom(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		fo     = FailoverOptions{
+			Datacenter:          "dc1",
+			Order:               []string{"dc3", "dc2"},
+			MinHealthyInstances: 5,
+		}
+	)
+
+	assert.Equal("dc1", fo.datacenter())
+	assert.Equal([]string{"dc3", "dc2"}, fo.order())
+	assert.Equal(5, fo.minHealthyInstances())
+}
+
+func TestFailoverOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		testFailoverOptionsDefault(t, nil)
+		testFailoverOptionsDefault(t, new(FailoverOptions))
+	})
+
+	t.Run("Custom", testFailoverOptionsCustom)
 }
 
 func TestOptions(t *testing.T) {
@@ -0,0 +1,82 @@
+package consul
+
+import (
+	"strings"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/jithin-kg/webpa-common/service"
+)
+
+// prepareCheck fills in the defaults for a check.  An HTTP check whose URL is just a path, e.g. "/health",
+// is resolved against the registered instance.  HTTP, TCP, gRPC and script checks without an interval
+// are given the configured interval.
+func prepareCheck(instance string, co Options, c *api.AgentServiceCheck) {
+	if c == nil {
+		return
+	}
+
+	if strings.HasPrefix(c.HTTP, "/") {
+		c.HTTP = instance + c.HTTP
+	}
+
+	if len(c.Interval) == 0 && (len(c.HTTP) > 0 || len(c.TCP) > 0 || len(c.GRPC) > 0 || len(c.Args) > 0) {
+		c.Interval = co.checkInterval()
+	}
+}
+
+// prepareRegistration applies the configured tags, metadata and check defaults to a registration.  The
+// registration is modified in place, so it must be a copy of any configuration.
+func prepareRegistration(registrationScheme string, co Options, r *api.AgentServiceRegistration) {
+	if tags := co.tags(); len(tags) > 0 {
+		r.Tags = append([]string(nil), r.Tags...)
+	}
+
+	for _, tag := range co.tags() {
+		found := false
+		for _, existing := range r.Tags {
+			if existing == tag {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			r.Tags = append(r.Tags, tag)
+		}
+	}
+
+	if meta := co.meta(); len(meta) > 0 {
+		merged := make(map[string]string, len(meta)+len(r.Meta))
+		for k, v := range meta {
+			merged[k] = v
+		}
+
+		for k, v := range r.Meta {
+			merged[k] = v
+		}
+
+		r.Meta = merged
+	}
+
+	instance := service.FormatInstance(registrationScheme, r.Address, r.Port)
+	if r.Check != nil {
+		check := *r.Check
+		prepareCheck(instance, co, &check)
+		r.Check = &check
+	}
+
+	if len(r.Checks) > 0 {
+		checks := make(api.AgentServiceChecks, len(r.Checks))
+		for i, c := range r.Checks {
+			if c != nil {
+				check := *c
+				prepareCheck(instance, co, &check)
+				c = &check
+			}
+
+			checks[i] = c
+		}
+
+		r.Checks = checks
+	}
+}
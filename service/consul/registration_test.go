@@ -0,0 +1,86 @@
+package consul
+
+import (
+	"testing"
+
+	"github.com/hashicorp/consul/api"
+	"github.com/stretchr/testify/assert"
+)
+
+func testPrepareRegistrationDefaults(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		r      = api.AgentServiceRegistration{
+			Address: "grubly.com",
+			Port:    8080,
+			Tags:    []string{"original"},
+		}
+	)
+
+	prepareRegistration("http", Options{}, &r)
+	assert.Equal([]string{"original"}, r.Tags)
+	assert.Empty(r.Meta)
+	assert.Nil(r.Check)
+	assert.Empty(r.Checks)
+}
+
+func testPrepareRegistrationTagsAndMeta(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		tags   = []string{"original", "shared"}
+		r      = api.AgentServiceRegistration{
+			Address: "grubly.com",
+			Port:    8080,
+			Tags:    tags,
+			Meta:    map[string]string{"region": "east"},
+		}
+
+		co = Options{
+			Tags: []string{"shared", "added"},
+			Meta: map[string]string{"region": "west", "version": "1.0"},
+		}
+	)
+
+	prepareRegistration("http", co, &r)
+	assert.Equal([]string{"original", "shared", "added"}, r.Tags)
+	assert.Equal([]string{"original", "shared"}, tags)
+	assert.Equal(map[string]string{"region": "east", "version": "1.0"}, r.Meta)
+}
+
+func testPrepareRegistrationChecks(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		check  = &api.AgentServiceCheck{HTTP: "/health"}
+		r      = api.AgentServiceRegistration{
+			Address: "grubly.com",
+			Port:    8080,
+			Check:   check,
+			Checks: api.AgentServiceChecks{
+				&api.AgentServiceCheck{TCP: "grubly.com:9000"},
+				&api.AgentServiceCheck{HTTP: "http://elsewhere.com/health", Interval: "1m"},
+				&api.AgentServiceCheck{TTL: "30s"},
+			},
+		}
+
+		co = Options{
+			CheckInterval: "15s",
+		}
+	)
+
+	prepareRegistration("https", co, &r)
+	assert.Equal("https://grubly.com:8080/health", r.Check.HTTP)
+	assert.Equal("15s", r.Check.Interval)
+	assert.Equal("/health", check.HTTP, "the original check should not be modified")
+
+	assert.Equal("grubly.com:9000", r.Checks[0].TCP)
+	assert.Equal("15s", r.Checks[0].Interval)
+	assert.Equal("http://elsewhere.com/health", r.Checks[1].HTTP)
+	assert.Equal("1m", r.Checks[1].Interval)
+	assert.Empty(r.Checks[2].Interval)
+}
+
+func TestPrepareRegistration(t *testing.T) {
+	t.Run("Defaults", testPrepareRegistrationDefaults)
+	t.Run("TagsAndMeta", testPrepareRegistrationTagsAndMeta)
+	t.Run("Checks", testPrepareRegistrationChecks)
+}
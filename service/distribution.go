@@ -0,0 +1,78 @@
+package service
+
+// Distribution describes how a set of keys is spread across the instances of an Accessor
+type Distribution struct {
+	// Keys is the number of keys hashed
+	Keys int `json:"keys"`
+
+	// Counts is the number of keys assigned to each instance
+	Counts map[string]int `json:"counts"`
+
+	// Skew is the largest ratio of an instance's share of the keys to its expected share, which is
+	// proportional to its weight.  A perfectly balanced distribution has a skew of 1.
+	Skew float64 `json:"skew"`
+}
+
+// NewDistribution hashes each key with the given Accessor, then computes the skew relative to the
+// weights of the given instances.  Expected shares are computed over all the given instances,
+// including any that receive no keys.
+func NewDistribution(a Accessor, instances []string, weights map[string]int, keys [][]byte) (Distribution, error) {
+	d := Distribution{
+		Keys:   len(keys),
+		Counts: make(map[string]int, len(instances)),
+	}
+
+	for _, k := range keys {
+		i, err := a.Get(k)
+		if err != nil {
+			return Distribution{}, err
+		}
+
+		d.Counts[i]++
+	}
+
+	totalWeight := 0
+	for _, i := range uniqueInstances(instances) {
+		totalWeight += weightOf(weights, i)
+	}
+
+	if d.Keys == 0 || totalWeight == 0 {
+		return d, nil
+	}
+
+	for i, count := range d.Counts {
+		expected := float64(d.Keys) * float64(weightOf(weights, i)) / float64(totalWeight)
+		if ratio := float64(count) / expected; ratio > d.Skew {
+			d.Skew = ratio
+		}
+	}
+
+	return d, nil
+}
+
+// Movement computes the fraction of keys assigned to a different instance by two Accessors.  It is
+// typically used to compare the accessors built before and after an instance is added or removed.
+func Movement(before, after Accessor, keys [][]byte) (float64, error) {
+	if len(keys) == 0 {
+		return 0.0, nil
+	}
+
+	moved := 0
+	for _, k := range keys {
+		b, err := before.Get(k)
+		if err != nil {
+			return 0.0, err
+		}
+
+		a, err := after.Get(k)
+		if err != nil {
+			return 0.0, err
+		}
+
+		if a != b {
+			moved++
+		}
+	}
+
+	return float64(moved) / float64(len(keys)), nil
+}
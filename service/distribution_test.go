@@ -0,0 +1,67 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewDistribution(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		a = MapAccessor{"a": "one", "b": "one", "c": "one", "d": "two"}
+	)
+
+	d, err := NewDistribution(a, []string{"one", "two"}, nil, [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")})
+	require.NoError(err)
+	assert.Equal(4, d.Keys)
+	assert.Equal(map[string]int{"one": 3, "two": 1}, d.Counts)
+	assert.InDelta(1.5, d.Skew, 0.0001)
+
+	// with weights, the same distribution is balanced
+	d, err = NewDistribution(a, []string{"one", "two"}, map[string]int{"one": 3}, [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")})
+	require.NoError(err)
+	assert.InDelta(1.0, d.Skew, 0.0001)
+
+	// an idle instance counts toward the expected shares
+	d, err = NewDistribution(a, []string{"one", "two", "three", "four"}, nil, [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")})
+	require.NoError(err)
+	assert.InDelta(3.0, d.Skew, 0.0001)
+
+	d, err = NewDistribution(a, []string{"one"}, nil, nil)
+	require.NoError(err)
+	assert.Zero(d.Keys)
+	assert.Zero(d.Skew)
+
+	_, err = NewDistribution(a, []string{"one"}, nil, [][]byte{[]byte("nosuch")})
+	assert.Error(err)
+}
+
+func TestMovement(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		before = MapAccessor{"a": "one", "b": "one", "c": "two", "d": "two"}
+		after  = MapAccessor{"a": "one", "b": "three", "c": "two", "d": "three"}
+		keys   = [][]byte{[]byte("a"), []byte("b"), []byte("c"), []byte("d")}
+		failed = AccessorFunc(func([]byte) (string, error) { return "", errors.New("expected") })
+	)
+
+	moved, err := Movement(before, after, keys)
+	assert.NoError(err)
+	assert.Equal(0.5, moved)
+
+	moved, err = Movement(before, after, nil)
+	assert.NoError(err)
+	assert.Zero(moved)
+
+	_, err = Movement(failed, after, keys)
+	assert.Error(err)
+
+	_, err = Movement(before, failed, keys)
+	assert.Error(err)
+}
@@ -0,0 +1,119 @@
+package dns
+
+import (
+	"fmt"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/sd"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
+)
+
+// resolverFactory is the factory function used to create a DNS Resolver.
+// Tests can change this for mocked behavior.
+var resolverFactory = NewResolver
+
+func newInstancerKey(w Watch) string {
+	return fmt.Sprintf("%s{type=%s}{port=%d}", w.Name, w.recordType(), w.Port)
+}
+
+func newInstancer(l log.Logger, r Resolver, w Watch, o Options) sd.Instancer {
+	return service.NewContextualInstancer(
+		NewInstancer(InstancerOptions{
+			Resolver: r,
+			Logger:   l,
+			Watch:    w,
+			Interval: o.interval(),
+			Timeout:  o.timeout(),
+		}),
+		map[string]interface{}{
+			"name": w.Name,
+			"type": w.recordType(),
+			"port": w.Port,
+		},
+	)
+}
+
+func newInstancers(l log.Logger, r Resolver, defaultScheme string, o Options) (i service.Instancers, err error) {
+	// validate every watch first, so that no instancers are started for a bad configuration
+	for _, w := range o.watches() {
+		if t := w.recordType(); t != RecordSRV && t != RecordA {
+			return nil, fmt.Errorf("Unsupported DNS record type for %s: %s", w.Name, t)
+		}
+	}
+
+	for _, w := range o.watches() {
+		key := newInstancerKey(w)
+		if i.Has(key) {
+			l.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "skipping duplicate watch", "name", w.Name)
+			continue
+		}
+
+		if len(w.Scheme) == 0 {
+			w.Scheme = defaultScheme
+		}
+
+		i.Set(key, newInstancer(l, r, w, o))
+	}
+
+	return
+}
+
+// registrar is the sd.Registrar for DNS.  Records are maintained outside of this process, so there is nothing to do.
+type registrar struct {
+	logger log.Logger
+}
+
+func (r registrar) Register() {
+	r.logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "registration is managed by DNS")
+}
+
+func (r registrar) Deregister() {
+	r.logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "deregistration is managed by DNS")
+}
+
+func newRegistrars(l log.Logger, registrationScheme string, o Options) (r service.Registrars) {
+	for _, registration := range o.registrations() {
+		instance, err := service.NormalizeInstance(registrationScheme, registration)
+		if err != nil {
+			l.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "skipping invalid registration", "instance", registration, logging.ErrorKey(), err)
+			continue
+		}
+
+		if r.Has(instance) {
+			l.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "skipping duplicate registration", "instance", instance)
+			continue
+		}
+
+		r.Add(instance, registrar{log.With(l, "instance", instance)})
+	}
+
+	return
+}
+
+// NewEnvironment constructs a DNS-based service.Environment using both DNS Options (typically unmarshaled
+// from configuration) and an optional extra set of environment options.  The registrationScheme is used both for
+// registrations and for watches that do not specify a scheme.
+func NewEnvironment(l log.Logger, registrationScheme string, o Options, eo ...service.Option) (service.Environment, error) {
+	if l == nil {
+		l = logging.DefaultLogger()
+	}
+
+	if len(o.Watches) == 0 && len(o.Registrations) == 0 {
+		return nil, service.ErrIncomplete
+	}
+
+	i, err := newInstancers(l, resolverFactory(o), registrationScheme, o)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.NewEnvironment(
+		append(
+			eo,
+			service.WithRegistrars(newRegistrars(l, registrationScheme, o)),
+			service.WithInstancers(i),
+		)...,
+	), nil
+}
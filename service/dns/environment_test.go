@@ -0,0 +1,89 @@
+package dns
+
+import (
+	"testing"
+
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testNewEnvironmentEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	e, err := NewEnvironment(nil, "http", Options{})
+	assert.Nil(e)
+	assert.Equal(service.ErrIncomplete, err)
+}
+
+func testNewEnvironmentUnsupportedType(t *testing.T) {
+	defer resetResolverFactory()
+
+	var (
+		assert   = assert.New(t)
+		resolver = new(mockResolver)
+	)
+
+	resolverFactory = func(Options) Resolver { return resolver }
+
+	e, err := NewEnvironment(nil, "http", Options{Watches: []Watch{{Name: "scytale.example.com", Type: RecordA}, {Name: "talaria.example.com", Type: "MX"}}})
+	assert.Nil(e)
+	assert.Error(err)
+
+	// no instancers are started for a bad configuration
+	resolver.AssertExpectations(t)
+}
+
+func testNewEnvironmentFull(t *testing.T) {
+	defer resetResolverFactory()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		logger   = logging.NewTestLogger(nil, t)
+		resolver = new(mockResolver)
+
+		o = Options{
+			Server: "10.0.0.53:53",
+			Registrations: []string{
+				"talaria-0.example.com:8080",
+				"http://talaria-0.example.com:8080",
+			},
+			Watches: []Watch{
+				{Name: "_http._tcp.talaria.example.com"},
+				{Name: "_http._tcp.talaria.example.com"},
+				{Name: "scytale.example.com", Type: RecordA, Port: 8080},
+			},
+		}
+	)
+
+	resolverFactory = func(actual Options) Resolver {
+		assert.Equal(o, actual)
+		return resolver
+	}
+
+	resolver.On("LookupSRV", "", "", "_http._tcp.talaria.example.com").Return("", nil, nil).Once()
+	resolver.On("LookupHost", "scytale.example.com").Return([]string{"10.0.0.1"}, nil).Once()
+
+	e, err := NewEnvironment(logger, "http", o)
+	require.NoError(err)
+	require.NotNil(e)
+
+	assert.True(e.IsRegistered("http://talaria-0.example.com:8080"))
+	e.Register()
+
+	assert.Equal(2, e.Instancers().Len())
+	assert.True(e.Instancers().Has(newInstancerKey(Watch{Name: "_http._tcp.talaria.example.com"})))
+	assert.True(e.Instancers().Has(newInstancerKey(Watch{Name: "scytale.example.com", Type: RecordA, Port: 8080})))
+
+	assert.NoError(e.Close())
+	resolver.AssertExpectations(t)
+}
+
+func TestNewEnvironment(t *testing.T) {
+	t.Run("Empty", testNewEnvironmentEmpty)
+	t.Run("UnsupportedType", testNewEnvironmentUnsupportedType)
+	t.Run("Full", testNewEnvironmentFull)
+}
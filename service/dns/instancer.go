@@ -0,0 +1,113 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/sd"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
+)
+
+type InstancerOptions struct {
+	Resolver Resolver
+	Logger   log.Logger
+	Watch    Watch
+
+	// Interval is how often the watch is looked up.  If nonpositive, DefaultInterval is used.
+	Interval time.Duration
+
+	// Timeout bounds each lookup.  If nonpositive, DefaultTimeout is used.
+	Timeout time.Duration
+}
+
+// NewInstancer creates an sd.Instancer which polls DNS for the instances of a watch.  An sd.Event is
+// dispatched only when the instances change.  Lookup errors are sent as sd.Events.
+func NewInstancer(o InstancerOptions) sd.Instancer {
+	if o.Logger == nil {
+		o.Logger = logging.DefaultLogger()
+	}
+
+	if o.Resolver == nil {
+		o.Resolver = net.DefaultResolver
+	}
+
+	if o.Interval <= 0 {
+		o.Interval = DefaultInterval
+	}
+
+	if o.Timeout <= 0 {
+		o.Timeout = DefaultTimeout
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	i := &instancer{
+		resolver: o.Resolver,
+		logger:   log.With(o.Logger, "name", o.Watch.Name, "type", o.Watch.recordType()),
+		watch:    o.Watch,
+		interval: o.Interval,
+		timeout:  o.Timeout,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	// grab the initial set of instances
+	i.poll()
+	i.logger.Log(level.Key(), level.InfoValue(), "instances", len(i.State().Instances))
+
+	go i.loop()
+	return i
+}
+
+type instancer struct {
+	resolver Resolver
+	logger   log.Logger
+	watch    Watch
+	interval time.Duration
+	timeout  time.Duration
+
+	ctx    context.Context
+	cancel func()
+
+	service.InstancerState
+}
+
+func (i *instancer) poll() {
+	ctx, cancel := context.WithTimeout(i.ctx, i.timeout)
+	defer cancel()
+
+	instances, metadata, err := lookup(ctx, i.resolver, i.watch)
+	if i.ctx.Err() != nil {
+		return
+	}
+
+	if err != nil {
+		i.logger.Log(level.Key(), level.ErrorValue(), logging.ErrorKey(), err)
+		i.Update(sd.Event{Err: err}, nil)
+		return
+	}
+
+	i.Update(sd.Event{Instances: instances}, metadata)
+}
+
+func (i *instancer) loop() {
+	ticker := time.NewTicker(i.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-i.ctx.Done():
+			return
+		case <-ticker.C:
+			i.poll()
+		}
+	}
+}
+
+// Stop halts polling.  This method is idempotent.
+func (i *instancer) Stop() {
+	i.cancel()
+}
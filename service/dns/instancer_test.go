@@ -0,0 +1,52 @@
+package dns
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/jithin-kg/webpa-common/service/servicetest"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstancer(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		resolver      = new(mockResolver)
+		expectedError = errors.New("expected")
+		watch         = Watch{Name: "scytale.example.com", Type: RecordA, Port: 8080, Scheme: "http"}
+
+		events = make(chan sd.Event, 1)
+	)
+
+	resolver.On("LookupHost", "scytale.example.com").Return([]string{"10.0.0.1"}, nil).Once()
+	resolver.On("LookupHost", "scytale.example.com").Return([]string{"10.0.0.1"}, nil).Once()
+	resolver.On("LookupHost", "scytale.example.com").Return([]string{"10.0.0.2", "10.0.0.1"}, nil).Once()
+	resolver.On("LookupHost", "scytale.example.com").Return(nil, expectedError)
+
+	i := NewInstancer(InstancerOptions{
+		Resolver: resolver,
+		Logger:   logging.NewTestLogger(nil, t),
+		Watch:    watch,
+		Interval: 10 * time.Millisecond,
+	})
+
+	// the initial lookup happens before NewInstancer returns
+	assert.Equal(map[string]service.InstanceMetadata{"http://10.0.0.1:8080": {}}, service.GetInstanceMetadata(i))
+
+	i.Register(events)
+	assert.Equal(sd.Event{Instances: []string{"http://10.0.0.1:8080"}}, servicetest.NextEvent(t, events))
+
+	// an unchanged lookup does not dispatch an event
+	assert.Equal(sd.Event{Instances: []string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"}}, servicetest.NextEvent(t, events))
+	assert.Equal(sd.Event{Err: expectedError}, servicetest.NextEvent(t, events))
+	assert.Empty(service.GetInstanceMetadata(i))
+
+	i.Deregister(events)
+	i.Stop()
+	i.Stop()
+}
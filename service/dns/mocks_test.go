@@ -0,0 +1,32 @@
+package dns
+
+import (
+	"context"
+	"net"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// resetResolverFactory resets the global singleton factory function
+// to its original value.  This function is handy as a defer for tests.
+func resetResolverFactory() {
+	resolverFactory = NewResolver
+}
+
+type mockResolver struct {
+	mock.Mock
+}
+
+var _ Resolver = (*mockResolver)(nil)
+
+func (m *mockResolver) LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error) {
+	arguments := m.Called(service, proto, name)
+	records, _ := arguments.Get(1).([]*net.SRV)
+	return arguments.String(0), records, arguments.Error(2)
+}
+
+func (m *mockResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	arguments := m.Called(host)
+	addresses, _ := arguments.Get(0).([]string)
+	return addresses, arguments.Error(1)
+}
@@ -0,0 +1,110 @@
+package dns
+
+import (
+	"time"
+
+	"github.com/jithin-kg/webpa-common/service"
+)
+
+const (
+	// RecordSRV looks up SRV records, which supply both the host and port of each instance
+	RecordSRV = "SRV"
+
+	// RecordA looks up the A and AAAA records of a host, using the Watch's Port for every address
+	RecordA = "A"
+
+	DefaultInterval = 30 * time.Second
+	DefaultTimeout  = 5 * time.Second
+)
+
+// Watch describes a DNS name that is polled for instances
+type Watch struct {
+	// Name is the DNS name to look up, e.g. "_http._tcp.talaria.example.com" for SRV records.
+	// This field is required.
+	Name string `json:"name"`
+
+	// Type is the kind of record to look up, either RecordSRV or RecordA.  If not supplied, RecordSRV is used.
+	Type string `json:"type,omitempty"`
+
+	// Port is the port of each instance when Type is RecordA.  It is ignored for SRV records.
+	Port int `json:"port,omitempty"`
+
+	// Scheme is the URI scheme of the discovered instances.  If not supplied, the environment's
+	// default scheme is used.
+	Scheme string `json:"scheme,omitempty"`
+}
+
+func (w Watch) recordType() string {
+	if len(w.Type) > 0 {
+		return w.Type
+	}
+
+	return RecordSRV
+}
+
+func (w Watch) scheme() string {
+	if len(w.Scheme) > 0 {
+		return w.Scheme
+	}
+
+	return service.DefaultScheme
+}
+
+// Options represents the set of configurable attributes for DNS service discovery
+type Options struct {
+	// Server is the host:port of the DNS server to query.  If not supplied, the system resolver is used.
+	Server string `json:"server,omitempty"`
+
+	// Interval is how often each name is looked up.  If not supplied, DefaultInterval is used.
+	Interval time.Duration `json:"interval,omitempty"`
+
+	// Timeout bounds each lookup.  If not supplied, DefaultTimeout is used.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	// Registrations are the instances this process advertises.  DNS records are maintained outside
+	// of this process, so registration is a no-op; these are used only to identify this process.
+	Registrations []string `json:"registrations,omitempty"`
+
+	// Watches are the names to poll.  There is no default for this field.
+	Watches []Watch `json:"watches,omitempty"`
+}
+
+func (o *Options) server() string {
+	if o != nil {
+		return o.Server
+	}
+
+	return ""
+}
+
+func (o *Options) interval() time.Duration {
+	if o != nil && o.Interval > 0 {
+		return o.Interval
+	}
+
+	return DefaultInterval
+}
+
+func (o *Options) timeout() time.Duration {
+	if o != nil && o.Timeout > 0 {
+		return o.Timeout
+	}
+
+	return DefaultTimeout
+}
+
+func (o *Options) registrations() []string {
+	if o != nil && len(o.Registrations) > 0 {
+		return o.Registrations
+	}
+
+	return nil
+}
+
+func (o *Options) watches() []Watch {
+	if o != nil && len(o.Watches) > 0 {
+		return o.Watches
+	}
+
+	return nil
+}
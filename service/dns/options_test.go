@@ -0,0 +1,57 @@
+package dns
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func testOptionsDefault(t *testing.T, o *Options) {
+	assert := assert.New(t)
+
+	assert.Empty(o.server())
+	assert.Equal(DefaultInterval, o.interval())
+	assert.Equal(DefaultTimeout, o.timeout())
+	assert.Empty(o.registrations())
+	assert.Empty(o.watches())
+}
+
+func testOptionsCustom(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		o = Options{
+			Server:        "10.0.0.53:53",
+			Interval:      time.Minute,
+			Timeout:       time.Second,
+			Registrations: []string{"talaria-0:8080"},
+			Watches:       []Watch{{Name: "_http._tcp.talaria.example.com"}},
+		}
+	)
+
+	assert.Equal("10.0.0.53:53", o.server())
+	assert.Equal(time.Minute, o.interval())
+	assert.Equal(time.Second, o.timeout())
+	assert.Equal([]string{"talaria-0:8080"}, o.registrations())
+	assert.Equal([]Watch{{Name: "_http._tcp.talaria.example.com"}}, o.watches())
+}
+
+func TestOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		testOptionsDefault(t, nil)
+		testOptionsDefault(t, new(Options))
+	})
+
+	t.Run("Custom", testOptionsCustom)
+}
+
+func TestWatch(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(RecordSRV, Watch{}.recordType())
+	assert.Equal(RecordA, Watch{Type: RecordA}.recordType())
+	assert.Equal(service.DefaultScheme, Watch{}.scheme())
+	assert.Equal("http", Watch{Scheme: "http"}.scheme())
+}
@@ -0,0 +1,103 @@
+package dns
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+
+	"github.com/jithin-kg/webpa-common/service"
+)
+
+// Resolver is the subset of *net.Resolver used for service discovery
+type Resolver interface {
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+	LookupHost(ctx context.Context, host string) ([]string, error)
+}
+
+var _ Resolver = (*net.Resolver)(nil)
+
+// NewResolver creates a Resolver for the given options.  If a server is configured, all queries
+// are sent to it rather than to the servers in the system configuration.
+func NewResolver(o Options) Resolver {
+	server := o.server()
+	if len(server) == 0 {
+		return net.DefaultResolver
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, server)
+		},
+	}
+}
+
+// formatAddress produces an instance from a host and port, bracketing IPv6 addresses
+func formatAddress(scheme, host string, port int) string {
+	host = strings.TrimSuffix(host, ".")
+	if strings.Contains(host, ":") {
+		host = "[" + host + "]"
+	}
+
+	return service.FormatInstance(scheme, host, port)
+}
+
+// lookup resolves the instances of a watch.  The returned instances are sorted and unique.
+func lookup(ctx context.Context, r Resolver, w Watch) ([]string, map[string]service.InstanceMetadata, error) {
+	var (
+		instances []string
+		metadata  = make(map[string]service.InstanceMetadata)
+	)
+
+	switch w.recordType() {
+	case RecordSRV:
+		_, records, err := r.LookupSRV(ctx, "", "", w.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// only the targets with the lowest priority value are used, as the others are backups
+		var minPriority uint16
+		for i, record := range records {
+			if i == 0 || record.Priority < minPriority {
+				minPriority = record.Priority
+			}
+		}
+
+		for _, record := range records {
+			if record.Priority != minPriority {
+				continue
+			}
+
+			instance := formatAddress(w.scheme(), record.Target, int(record.Port))
+			if _, ok := metadata[instance]; !ok {
+				instances = append(instances, instance)
+			}
+
+			metadata[instance] = service.InstanceMetadata{Weight: int(record.Weight)}
+		}
+
+	case RecordA:
+		addresses, err := r.LookupHost(ctx, w.Name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		for _, address := range addresses {
+			instance := formatAddress(w.scheme(), address, w.Port)
+			if _, ok := metadata[instance]; !ok {
+				instances = append(instances, instance)
+				metadata[instance] = service.InstanceMetadata{}
+			}
+		}
+
+	default:
+		return nil, nil, fmt.Errorf("Unsupported DNS record type: %s", w.Type)
+	}
+
+	sort.Strings(instances)
+	return instances, metadata, nil
+}
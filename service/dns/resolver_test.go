@@ -0,0 +1,109 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewResolver(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(net.DefaultResolver, NewResolver(Options{}))
+
+	r, ok := NewResolver(Options{Server: "10.0.0.53:53"}).(*net.Resolver)
+	assert.True(ok)
+	assert.True(r.PreferGo)
+	assert.NotNil(r.Dial)
+}
+
+func testLookupSRV(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		resolver = new(mockResolver)
+	)
+
+	resolver.On("LookupSRV", "", "", "_http._tcp.talaria.example.com").Return(
+		"",
+		[]*net.SRV{
+			{Target: "talaria-2.example.com.", Port: 8080, Priority: 10, Weight: 1},
+			{Target: "talaria-1.example.com.", Port: 8080, Priority: 10, Weight: 5},
+			{Target: "backup.example.com.", Port: 8080, Priority: 20, Weight: 1},
+			{Target: "talaria-1.example.com.", Port: 8080, Priority: 10, Weight: 5},
+		},
+		nil,
+	).Once()
+
+	instances, metadata, err := lookup(context.Background(), resolver, Watch{Name: "_http._tcp.talaria.example.com", Scheme: "http"})
+	require.NoError(err)
+	assert.Equal([]string{"http://talaria-1.example.com:8080", "http://talaria-2.example.com:8080"}, instances)
+	assert.Equal(
+		map[string]service.InstanceMetadata{
+			"http://talaria-1.example.com:8080": {Weight: 5},
+			"http://talaria-2.example.com:8080": {Weight: 1},
+		},
+		metadata,
+	)
+
+	resolver.AssertExpectations(t)
+}
+
+func testLookupA(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		resolver = new(mockResolver)
+	)
+
+	resolver.On("LookupHost", "scytale.example.com").Return([]string{"10.0.0.2", "fd00::1", "10.0.0.1", "10.0.0.2"}, nil).Once()
+
+	instances, metadata, err := lookup(context.Background(), resolver, Watch{Name: "scytale.example.com", Type: RecordA, Port: 8443})
+	require.NoError(err)
+	assert.Equal([]string{"https://10.0.0.1:8443", "https://10.0.0.2:8443", "https://[fd00::1]:8443"}, instances)
+	assert.Len(metadata, 3)
+
+	resolver.AssertExpectations(t)
+}
+
+func testLookupError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		resolver      = new(mockResolver)
+		expectedError = errors.New("expected")
+	)
+
+	resolver.On("LookupSRV", "", "", "_http._tcp.talaria.example.com").Return("", nil, expectedError).Once()
+	resolver.On("LookupHost", "scytale.example.com").Return(nil, expectedError).Once()
+
+	instances, metadata, err := lookup(context.Background(), resolver, Watch{Name: "_http._tcp.talaria.example.com"})
+	assert.Empty(instances)
+	assert.Empty(metadata)
+	assert.Equal(expectedError, err)
+
+	instances, metadata, err = lookup(context.Background(), resolver, Watch{Name: "scytale.example.com", Type: RecordA})
+	assert.Empty(instances)
+	assert.Empty(metadata)
+	assert.Equal(expectedError, err)
+
+	instances, metadata, err = lookup(context.Background(), resolver, Watch{Name: "scytale.example.com", Type: "MX"})
+	assert.Empty(instances)
+	assert.Empty(metadata)
+	assert.Error(err)
+
+	resolver.AssertExpectations(t)
+}
+
+func TestLookup(t *testing.T) {
+	t.Run("SRV", testLookupSRV)
+	t.Run("A", testLookupA)
+	t.Run("Error", testLookupError)
+}
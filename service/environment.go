@@ -41,6 +41,11 @@ type Environment interface {
 	// Typically, this factory is set via configuration by some external source.
 	AccessorFactory() AccessorFactory
 
+	// MetadataAccessorFactory returns the creation strategy for Accessors that take instance metadata, such
+	// as zones and weights, into account.  If none was configured, the AccessorFactory is used and
+	// metadata is ignored.
+	MetadataAccessorFactory() MetadataAccessorFactory
+
 	// Closed returns a channel that is closed when this Environment in closed.
 	Closed() <-chan struct{}
 }
@@ -91,6 +96,14 @@ func WithAccessorFactory(af AccessorFactory) Option {
 	}
 }
 
+// WithMetadataAccessorFactory configures the creation strategy for Accessor objects that use instance
+// metadata.  Passing nil via this option resets the environment to adapting its AccessorFactory.
+func WithMetadataAccessorFactory(maf MetadataAccessorFactory) Option {
+	return func(e *environment) {
+		e.metadataAccessorFactory = maf
+	}
+}
+
 // WithCloser configures the function used to completely shut down the service discover backend.
 // By default, NopCloser is used.  Passing a nil function for this option sets (or resets)
 // the closer back to the NopCloser.
@@ -127,6 +140,8 @@ type environment struct {
 	instancers      Instancers
 	accessorFactory AccessorFactory
 
+	metadataAccessorFactory MetadataAccessorFactory
+
 	closeOnce sync.Once
 	closer    func() error
 	closed    chan struct{}
@@ -148,6 +163,17 @@ func (e *environment) AccessorFactory() AccessorFactory {
 	return e.accessorFactory
 }
 
+func (e *environment) MetadataAccessorFactory() MetadataAccessorFactory {
+	if e.metadataAccessorFactory != nil {
+		return e.metadataAccessorFactory
+	}
+
+	af := e.accessorFactory
+	return func(instances []string, _ map[string]InstanceMetadata) Accessor {
+		return af(instances)
+	}
+}
+
 func (e *environment) Register() {
 	e.registrars.Register()
 }
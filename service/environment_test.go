@@ -157,6 +157,40 @@ func testNewEnvironmentExplicitDefaultAccessorFactory(t *testing.T) {
 	assert.NotNil(e.AccessorFactory())
 }
 
+func testNewEnvironmentMetadataAccessorFactory(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		accessorFactoryCalled = false
+		accessorFactory       = AccessorFactory(func(i []string) Accessor {
+			accessorFactoryCalled = true
+			assert.Equal([]string{"instance"}, i)
+			return EmptyAccessor()
+		})
+
+		metadataAccessorFactoryCalled = false
+		metadataAccessorFactory       = MetadataAccessorFactory(func(i []string, m map[string]InstanceMetadata) Accessor {
+			metadataAccessorFactoryCalled = true
+			assert.Equal("east", m["instance"].Zone)
+			return EmptyAccessor()
+		})
+
+		metadata = map[string]InstanceMetadata{"instance": {Zone: "east"}}
+	)
+
+	// without a MetadataAccessorFactory, the AccessorFactory is used
+	e := NewEnvironment(WithAccessorFactory(accessorFactory))
+	require.NotNil(e.MetadataAccessorFactory())
+	assert.NotNil(e.MetadataAccessorFactory()([]string{"instance"}, metadata))
+	assert.True(accessorFactoryCalled)
+
+	e = NewEnvironment(WithAccessorFactory(accessorFactory), WithMetadataAccessorFactory(metadataAccessorFactory))
+	require.NotNil(e.MetadataAccessorFactory())
+	assert.NotNil(e.MetadataAccessorFactory()([]string{"instance"}, metadata))
+	assert.True(metadataAccessorFactoryCalled)
+}
+
 func testNewEnvironmentExplicitNopCloser(t *testing.T) {
 	var (
 		assert  = assert.New(t)
@@ -191,6 +225,7 @@ func TestNewEnvironment(t *testing.T) {
 	t.Run("NoOptions", testNewEnvironmentNoOptions)
 	t.Run("WithOptions", testNewEnvironmentWithOptions)
 	t.Run("ExplicitDefaultAccessorFactory", testNewEnvironmentExplicitDefaultAccessorFactory)
+	t.Run("MetadataAccessorFactory", testNewEnvironmentMetadataAccessorFactory)
 	t.Run("ExplicitNopCloser", testNewEnvironmentExplicitNopCloser)
 	t.Run("ExplicitDefaultScheme", testNewEnvironmentExplicitDefaultScheme)
 }
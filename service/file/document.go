@@ -0,0 +1,82 @@
+package file
+
+import (
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/jithin-kg/webpa-common/service"
+	"gopkg.in/yaml.v2"
+)
+
+// Instance is an entry in a file of instances, along with any metadata
+type Instance struct {
+	// Instance is the instance string, e.g. "http://talaria-1.example.com:8080".  If there is
+	// no scheme, the watch's scheme is used.
+	Instance string `json:"instance" yaml:"instance"`
+
+	service.InstanceMetadata `yaml:",inline"`
+}
+
+// Document is the content of a file of instances.  A file may also simply hold a list of instance strings.
+type Document struct {
+	Instances []Instance `json:"instances" yaml:"instances"`
+}
+
+var errEmptyFile = errors.New("The instances file is empty")
+
+type unmarshalFunc func([]byte, interface{}) error
+
+func unmarshalerFor(path string) unmarshalFunc {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal
+	default:
+		return json.Unmarshal
+	}
+}
+
+// parse decodes the content of a file of instances.  The returned instances are normalized, sorted and unique.
+// An empty file is an error, as it is most likely one that is being rewritten and would otherwise decode as no instances.
+func parse(w Watch, data []byte) ([]string, map[string]service.InstanceMetadata, error) {
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil, nil, errEmptyFile
+	}
+
+	var (
+		unmarshal = unmarshalerFor(w.Path)
+		list      []string
+		document  Document
+	)
+
+	if err := unmarshal(data, &list); err == nil {
+		for _, instance := range list {
+			document.Instances = append(document.Instances, Instance{Instance: instance})
+		}
+	} else if err := unmarshal(data, &document); err != nil {
+		return nil, nil, err
+	}
+
+	var (
+		instances []string
+		metadata  = make(map[string]service.InstanceMetadata, len(document.Instances))
+	)
+
+	for _, entry := range document.Instances {
+		instance, err := service.NormalizeInstance(w.scheme(), entry.Instance)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		if _, ok := metadata[instance]; !ok {
+			instances = append(instances, instance)
+		}
+
+		metadata[instance] = entry.InstanceMetadata
+	}
+
+	sort.Strings(instances)
+	return instances, metadata, nil
+}
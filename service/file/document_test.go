@@ -0,0 +1,103 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testParseList(t *testing.T, path, data string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	instances, metadata, err := parse(Watch{Path: path, Scheme: "http"}, []byte(data))
+	require.NoError(err)
+	assert.Equal([]string{"http://talaria-0:8080", "https://talaria-1"}, instances)
+	assert.Equal(
+		map[string]service.InstanceMetadata{
+			"http://talaria-0:8080": {},
+			"https://talaria-1":     {},
+		},
+		metadata,
+	)
+}
+
+func testParseDocument(t *testing.T, path, data string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	instances, metadata, err := parse(Watch{Path: path, Scheme: "http"}, []byte(data))
+	require.NoError(err)
+	assert.Equal([]string{"http://talaria-0:8080", "http://talaria-1:8080"}, instances)
+	assert.Equal(
+		map[string]service.InstanceMetadata{
+			"http://talaria-0:8080": {Datacenter: "dc1", Zone: "east", Weight: 2, Tags: []string{"canary"}},
+			"http://talaria-1:8080": {Meta: map[string]string{"rack": "r1"}},
+		},
+		metadata,
+	)
+}
+
+func testParseError(t *testing.T, path, data string) {
+	assert := assert.New(t)
+
+	instances, metadata, err := parse(Watch{Path: path}, []byte(data))
+	assert.Empty(instances)
+	assert.Empty(metadata)
+	assert.Error(err)
+}
+
+func TestParse(t *testing.T) {
+	t.Run("JSONList", func(t *testing.T) {
+		testParseList(t, "instances.json", `["talaria-0:8080", "https://talaria-1:443", "talaria-0:8080"]`)
+	})
+
+	t.Run("YAMLList", func(t *testing.T) {
+		testParseList(t, "instances.YML", "- talaria-0:8080\n- https://talaria-1:443\n")
+	})
+
+	t.Run("JSONDocument", func(t *testing.T) {
+		testParseDocument(t, "instances", `{
+			"instances": [
+				{"instance": "talaria-0:8080", "datacenter": "dc1", "zone": "east", "weight": 2, "tags": ["canary"]},
+				{"instance": "http://talaria-1:8080", "meta": {"rack": "r1"}}
+			]
+		}`)
+	})
+
+	t.Run("YAMLDocument", func(t *testing.T) {
+		testParseDocument(t, "instances.yaml", `
+instances:
+  - instance: talaria-0:8080
+    datacenter: dc1
+    zone: east
+    weight: 2
+    tags: [canary]
+  - instance: http://talaria-1:8080
+    meta:
+      rack: r1
+`)
+	})
+
+	t.Run("Syntax", func(t *testing.T) {
+		testParseError(t, "instances.json", `{"instances": [`)
+	})
+
+	t.Run("BlankInstance", func(t *testing.T) {
+		testParseError(t, "instances.yaml", "- talaria-0:8080\n- ' '\n")
+	})
+
+	t.Run("EmptyYAML", func(t *testing.T) {
+		testParseError(t, "instances.yaml", " \n")
+	})
+
+	t.Run("EmptyJSON", func(t *testing.T) {
+		testParseError(t, "instances.json", "")
+	})
+}
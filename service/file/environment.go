@@ -0,0 +1,95 @@
+package file
+
+import (
+	"path/filepath"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/sd"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
+)
+
+func newInstancer(l log.Logger, w Watch, o Options) sd.Instancer {
+	return service.NewContextualInstancer(
+		NewInstancer(InstancerOptions{
+			Logger:   l,
+			Watch:    w,
+			Interval: o.interval(),
+		}),
+		map[string]interface{}{
+			"path": w.Path,
+		},
+	)
+}
+
+func newInstancers(l log.Logger, defaultScheme string, o Options) (i service.Instancers) {
+	for _, w := range o.watches() {
+		key := filepath.Clean(w.Path)
+		if i.Has(key) {
+			l.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "skipping duplicate watch", "path", w.Path)
+			continue
+		}
+
+		if len(w.Scheme) == 0 {
+			w.Scheme = defaultScheme
+		}
+
+		i.Set(key, newInstancer(l, w, o))
+	}
+
+	return
+}
+
+// registrar is the sd.Registrar for files.  The files are maintained outside of this process, so there is nothing to do.
+type registrar struct {
+	logger log.Logger
+}
+
+func (r registrar) Register() {
+	r.logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "registration is managed by the watched files")
+}
+
+func (r registrar) Deregister() {
+	r.logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "deregistration is managed by the watched files")
+}
+
+func newRegistrars(l log.Logger, registrationScheme string, o Options) (r service.Registrars) {
+	for _, registration := range o.registrations() {
+		instance, err := service.NormalizeInstance(registrationScheme, registration)
+		if err != nil {
+			l.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "skipping invalid registration", "instance", registration, logging.ErrorKey(), err)
+			continue
+		}
+
+		if r.Has(instance) {
+			l.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "skipping duplicate registration", "instance", instance)
+			continue
+		}
+
+		r.Add(instance, registrar{log.With(l, "instance", instance)})
+	}
+
+	return
+}
+
+// NewEnvironment constructs a file-based service.Environment using both file Options (typically unmarshaled
+// from configuration) and an optional extra set of environment options.  The registrationScheme is used both for
+// registrations and for instances that do not specify a scheme.
+func NewEnvironment(l log.Logger, registrationScheme string, o Options, eo ...service.Option) (service.Environment, error) {
+	if l == nil {
+		l = logging.DefaultLogger()
+	}
+
+	if len(o.Watches) == 0 && len(o.Registrations) == 0 {
+		return nil, service.ErrIncomplete
+	}
+
+	return service.NewEnvironment(
+		append(
+			eo,
+			service.WithRegistrars(newRegistrars(l, registrationScheme, o)),
+			service.WithInstancers(newInstancers(l, registrationScheme, o)),
+		)...,
+	), nil
+}
@@ -0,0 +1,52 @@
+package file
+
+import (
+	"testing"
+
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testNewEnvironmentEmpty(t *testing.T) {
+	assert := assert.New(t)
+
+	e, err := NewEnvironment(nil, "http", Options{})
+	assert.Nil(e)
+	assert.Equal(service.ErrIncomplete, err)
+}
+
+func testNewEnvironmentFull(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		o = Options{
+			Registrations: []string{"talaria-0:8080", "this is not valid:1234:5678"},
+			Watches: []Watch{
+				{Path: "/nosuch/talaria.json"},
+				{Path: "/nosuch/../nosuch/talaria.json"},
+				{Path: "/nosuch/scytale.yaml", Scheme: "https"},
+			},
+		}
+	)
+
+	e, err := NewEnvironment(logging.NewTestLogger(nil, t), "http", o)
+	require.NoError(err)
+	require.NotNil(e)
+
+	assert.True(e.IsRegistered("http://talaria-0:8080"))
+	e.Register()
+
+	assert.Equal(2, e.Instancers().Len())
+	assert.True(e.Instancers().Has("/nosuch/talaria.json"))
+	assert.True(e.Instancers().Has("/nosuch/scytale.yaml"))
+
+	assert.NoError(e.Close())
+}
+
+func TestNewEnvironment(t *testing.T) {
+	t.Run("Empty", testNewEnvironmentEmpty)
+	t.Run("Full", testNewEnvironmentFull)
+}
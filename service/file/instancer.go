@@ -0,0 +1,127 @@
+package file
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/sd"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
+)
+
+type InstancerOptions struct {
+	Logger log.Logger
+	Watch  Watch
+
+	// Interval is how often the file is checked for modification.  If nonpositive, DefaultInterval is used.
+	Interval time.Duration
+}
+
+// NewInstancer creates an sd.Instancer which reads instances from a file, reloading the file whenever its
+// modification time or size changes.  The file is polled rather than watched with filesystem notifications,
+// as notifications are unreliable for bind mounts and for files replaced by renaming.  Errors reading or
+// parsing the file are sent as sd.Events until the file has been loaded once.  After that, errors are only
+// logged and the last good set of instances is kept.
+func NewInstancer(o InstancerOptions) sd.Instancer {
+	if o.Logger == nil {
+		o.Logger = logging.DefaultLogger()
+	}
+
+	if o.Interval <= 0 {
+		o.Interval = DefaultInterval
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	i := &instancer{
+		logger:   log.With(o.Logger, "path", o.Watch.Path),
+		watch:    o.Watch,
+		interval: o.Interval,
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	// grab the initial set of instances
+	i.reload()
+	i.logger.Log(level.Key(), level.InfoValue(), "instances", len(i.State().Instances))
+
+	go i.loop()
+	return i
+}
+
+type instancer struct {
+	logger   log.Logger
+	watch    Watch
+	interval time.Duration
+
+	ctx    context.Context
+	cancel func()
+
+	// modTime and size identify the last version of the file that was read
+	modTime time.Time
+	size    int64
+
+	// loaded indicates that the file has been successfully read at least once
+	loaded bool
+
+	service.InstancerState
+}
+
+// reload reads the file if it has changed since it was last read
+func (i *instancer) reload() {
+	info, err := os.Stat(i.watch.Path)
+	if err == nil {
+		if info.ModTime().Equal(i.modTime) && info.Size() == i.size {
+			return
+		}
+
+		i.modTime, i.size = info.ModTime(), info.Size()
+		var data []byte
+		if data, err = ioutil.ReadFile(i.watch.Path); err == nil {
+			var (
+				instances []string
+				metadata  map[string]service.InstanceMetadata
+			)
+
+			if instances, metadata, err = parse(i.watch, data); err == nil {
+				i.logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "reloaded instances", "instances", len(instances))
+				i.loaded = true
+				i.Update(sd.Event{Instances: instances}, metadata)
+				return
+			}
+		}
+	} else {
+		// ensure the file is read again once it reappears
+		i.modTime, i.size = time.Time{}, 0
+	}
+
+	if i.loaded {
+		i.logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "keeping the last good instances", logging.ErrorKey(), err)
+		return
+	}
+
+	i.logger.Log(level.Key(), level.ErrorValue(), logging.ErrorKey(), err)
+	i.Update(sd.Event{Err: err}, nil)
+}
+
+func (i *instancer) loop() {
+	ticker := time.NewTicker(i.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-i.ctx.Done():
+			return
+		case <-ticker.C:
+			i.reload()
+		}
+	}
+}
+
+// Stop halts watching the file.  This method is idempotent.
+func (i *instancer) Stop() {
+	i.cancel()
+}
@@ -0,0 +1,153 @@
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/jithin-kg/webpa-common/service/servicetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInstancer(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	dir, err := ioutil.TempDir("", "instancer")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	var (
+		path   = filepath.Join(dir, "instances.json")
+		events = make(chan sd.Event, 1)
+	)
+
+	require.NoError(ioutil.WriteFile(path, []byte(`["talaria-0:8080"]`), 0644))
+
+	i := NewInstancer(InstancerOptions{
+		Logger:   logging.NewTestLogger(nil, t),
+		Watch:    Watch{Path: path, Scheme: "http"},
+		Interval: 10 * time.Millisecond,
+	})
+
+	defer i.(*instancer).Stop()
+
+	// the file is read before NewInstancer returns
+	assert.Equal(map[string]service.InstanceMetadata{"http://talaria-0:8080": {}}, service.GetInstanceMetadata(i))
+
+	i.Register(events)
+	assert.Equal(sd.Event{Instances: []string{"http://talaria-0:8080"}}, servicetest.NextEvent(t, events))
+
+	require.NoError(ioutil.WriteFile(path, []byte(`{"instances": [{"instance": "talaria-1:8080", "zone": "east"}]}`), 0644))
+	assert.Equal(sd.Event{Instances: []string{"http://talaria-1:8080"}}, servicetest.NextEvent(t, events))
+	assert.Equal(map[string]service.InstanceMetadata{"http://talaria-1:8080": {Zone: "east"}}, service.GetInstanceMetadata(i))
+
+	// once the file has been loaded, errors keep the last good instances
+	require.NoError(os.Remove(path))
+	assertNoEvent(t, events)
+
+	// restoring the file restores the instances
+	require.NoError(ioutil.WriteFile(path, []byte(`["talaria-0:8080"]`), 0644))
+	assert.Equal(sd.Event{Instances: []string{"http://talaria-0:8080"}}, servicetest.NextEvent(t, events))
+
+	i.Deregister(events)
+}
+
+// assertNoEvent verifies that no sd.Event is dispatched over several polling intervals
+func assertNoEvent(t *testing.T, events <-chan sd.Event) {
+	select {
+	case e := <-events:
+		assert.Fail(t, "Unexpected event", "%#v", e)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func testInstancerKeepsLastGoodState(t *testing.T, name, contents string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	dir, err := ioutil.TempDir("", "instancer")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	var (
+		path   = filepath.Join(dir, name)
+		events = make(chan sd.Event, 1)
+	)
+
+	require.NoError(ioutil.WriteFile(path, []byte(`["talaria-0:8080"]`), 0644))
+
+	i := NewInstancer(InstancerOptions{
+		Logger:   logging.NewTestLogger(nil, t),
+		Watch:    Watch{Path: path, Scheme: "http"},
+		Interval: 10 * time.Millisecond,
+	})
+
+	defer i.(*instancer).Stop()
+
+	i.Register(events)
+	assert.Equal(sd.Event{Instances: []string{"http://talaria-0:8080"}}, servicetest.NextEvent(t, events))
+
+	require.NoError(ioutil.WriteFile(path, []byte(contents), 0644))
+	assertNoEvent(t, events)
+	assert.Equal(map[string]service.InstanceMetadata{"http://talaria-0:8080": {}}, service.GetInstanceMetadata(i))
+
+	i.Deregister(events)
+}
+
+func testInstancerInitialError(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	dir, err := ioutil.TempDir("", "instancer")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	var (
+		path   = filepath.Join(dir, "instances.yaml")
+		events = make(chan sd.Event, 1)
+	)
+
+	require.NoError(ioutil.WriteFile(path, nil, 0644))
+
+	i := NewInstancer(InstancerOptions{
+		Logger:   logging.NewTestLogger(nil, t),
+		Watch:    Watch{Path: path, Scheme: "http"},
+		Interval: 10 * time.Millisecond,
+	})
+
+	defer i.(*instancer).Stop()
+
+	// until the file has been loaded, errors are dispatched
+	i.Register(events)
+	assert.Equal(sd.Event{Err: errEmptyFile}, servicetest.NextEvent(t, events))
+
+	require.NoError(ioutil.WriteFile(path, []byte("- talaria-0:8080\n"), 0644))
+	assert.Equal(sd.Event{Instances: []string{"http://talaria-0:8080"}}, servicetest.NextEvent(t, events))
+
+	i.Deregister(events)
+}
+
+func TestInstancerErrors(t *testing.T) {
+	t.Run("EmptyYAML", func(t *testing.T) {
+		testInstancerKeepsLastGoodState(t, "instances.yaml", "")
+	})
+
+	t.Run("PartialJSON", func(t *testing.T) {
+		testInstancerKeepsLastGoodState(t, "instances.json", `{"instances": [{"instance": "talar`)
+	})
+
+	t.Run("Initial", testInstancerInitialError)
+}
@@ -0,0 +1,66 @@
+package file
+
+import (
+	"time"
+
+	"github.com/jithin-kg/webpa-common/service"
+)
+
+// DefaultInterval is how often a watched file is checked for modification
+const DefaultInterval = 5 * time.Second
+
+// Watch describes a file of instances
+type Watch struct {
+	// Path is the location of the file.  Files ending in .yaml or .yml are parsed as YAML, all others
+	// as JSON.  This field is required.
+	Path string `json:"path"`
+
+	// Scheme is the URI scheme assumed for instances in the file that do not specify one.  If not supplied,
+	// the environment's default scheme is used.
+	Scheme string `json:"scheme,omitempty"`
+}
+
+func (w Watch) scheme() string {
+	if len(w.Scheme) > 0 {
+		return w.Scheme
+	}
+
+	return service.DefaultScheme
+}
+
+// Options represents the set of configurable attributes for file-based service discovery
+type Options struct {
+	// Interval is how often each file is checked for modification.  If not supplied, DefaultInterval is used.
+	Interval time.Duration `json:"interval,omitempty"`
+
+	// Registrations are the instances this process advertises.  The files are maintained outside
+	// of this process, so registration is a no-op; these are used only to identify this process.
+	Registrations []string `json:"registrations,omitempty"`
+
+	// Watches are the files to watch.  There is no default for this field.
+	Watches []Watch `json:"watches,omitempty"`
+}
+
+func (o *Options) interval() time.Duration {
+	if o != nil && o.Interval > 0 {
+		return o.Interval
+	}
+
+	return DefaultInterval
+}
+
+func (o *Options) registrations() []string {
+	if o != nil && len(o.Registrations) > 0 {
+		return o.Registrations
+	}
+
+	return nil
+}
+
+func (o *Options) watches() []Watch {
+	if o != nil && len(o.Watches) > 0 {
+		return o.Watches
+	}
+
+	return nil
+}
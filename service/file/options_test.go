@@ -0,0 +1,49 @@
+package file
+
+import (
+	"testing"
+	"time"
+
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func testOptionsDefault(t *testing.T, o *Options) {
+	assert := assert.New(t)
+
+	assert.Equal(DefaultInterval, o.interval())
+	assert.Empty(o.registrations())
+	assert.Empty(o.watches())
+}
+
+func testOptionsCustom(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		o = Options{
+			Interval:      time.Minute,
+			Registrations: []string{"talaria-0:8080"},
+			Watches:       []Watch{{Path: "/etc/talaria.json"}},
+		}
+	)
+
+	assert.Equal(time.Minute, o.interval())
+	assert.Equal([]string{"talaria-0:8080"}, o.registrations())
+	assert.Equal([]Watch{{Path: "/etc/talaria.json"}}, o.watches())
+}
+
+func TestOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		testOptionsDefault(t, nil)
+		testOptionsDefault(t, new(Options))
+	})
+
+	t.Run("Custom", testOptionsCustom)
+}
+
+func TestWatchScheme(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(service.DefaultScheme, Watch{}.scheme())
+	assert.Equal("http", Watch{Scheme: "http"}.scheme())
+}
@@ -0,0 +1,408 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/spaolacci/murmur3"
+)
+
+const (
+	// ConsistentHashing places instances on a ring of virtual nodes.  This is the default algorithm.
+	ConsistentHashing = "consistent"
+
+	// RendezvousHashing selects the instance with the highest random weight for a key.  It has the
+	// best balance and moves the fewest keys, at the cost of a lookup proportional to the number of instances.
+	RendezvousHashing = "rendezvous"
+
+	// JumpHashing uses jump consistent hashing over the sorted instances.  Lookups need no memory
+	// beyond the instance list, but adding or removing an instance other than the last moves more keys.
+	JumpHashing = "jump"
+
+	// MaglevHashing uses a Maglev lookup table, which gives constant time lookups with near perfect balance.
+	MaglevHashing = "maglev"
+
+	// DefaultMaglevTableSize is the default number of Maglev lookup table entries.  It must be prime,
+	// and should be much larger than the number of instances.
+	DefaultMaglevTableSize = 65537
+)
+
+// AccessorOptions configure the hashing algorithm used to build Accessors
+type AccessorOptions struct {
+	// Algorithm is one of ConsistentHashing, RendezvousHashing, JumpHashing, or MaglevHashing.
+	// If not supplied, ConsistentHashing is used.
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// VnodeCount is the number of virtual nodes for each unit of weight in a consistent hash ring.
+	// If not supplied, DefaultVnodeCount is used.
+	VnodeCount int `json:"vnodeCount,omitempty"`
+
+	// MaglevTableSize is the size of the Maglev lookup table.  If not supplied, DefaultMaglevTableSize is used.
+	MaglevTableSize int `json:"maglevTableSize,omitempty"`
+
+	// Weights maps instances to their relative capacity.  Instances that are not present, or that
+	// have a nonpositive weight, have a weight of 1.
+	Weights map[string]int `json:"weights,omitempty"`
+
+	// Zone is the zone of the local process.  If supplied, metadata-aware accessors prefer instances
+	// in the same zone.
+	Zone string `json:"zone,omitempty"`
+
+	// Datacenter is the datacenter of the local process.  If supplied, metadata-aware accessors prefer
+	// instances in the same datacenter.
+	Datacenter string `json:"datacenter,omitempty"`
+
+	// SpilloverThreshold is the minimum fraction, between 0 and 1, of the total instance weight that
+	// the local zone or datacenter must hold for keys to stay local.  If not supplied, any local
+	// instance keeps keys local.
+	SpilloverThreshold float64 `json:"spilloverThreshold,omitempty"`
+}
+
+func (o *AccessorOptions) algorithm() string {
+	if o != nil && len(o.Algorithm) > 0 {
+		return strings.ToLower(o.Algorithm)
+	}
+
+	return ConsistentHashing
+}
+
+func (o *AccessorOptions) vnodeCount() int {
+	if o != nil && o.VnodeCount > 0 {
+		return o.VnodeCount
+	}
+
+	return DefaultVnodeCount
+}
+
+func (o *AccessorOptions) maglevTableSize() int {
+	if o != nil && o.MaglevTableSize > 0 {
+		return o.MaglevTableSize
+	}
+
+	return DefaultMaglevTableSize
+}
+
+func (o *AccessorOptions) weights() map[string]int {
+	if o != nil {
+		return o.Weights
+	}
+
+	return nil
+}
+
+func (o *AccessorOptions) zone() string {
+	if o != nil {
+		return o.Zone
+	}
+
+	return ""
+}
+
+func (o *AccessorOptions) datacenter() string {
+	if o != nil {
+		return o.Datacenter
+	}
+
+	return ""
+}
+
+func (o *AccessorOptions) spilloverThreshold() float64 {
+	if o != nil && o.SpilloverThreshold > 0 {
+		return o.SpilloverThreshold
+	}
+
+	return 0.0
+}
+
+// NewAccessorFactory produces an AccessorFactory for the configured hashing algorithm.  With the
+// default options, the DefaultAccessorFactory is returned.  An error is returned if the algorithm
+// is not supported.
+func NewAccessorFactory(o *AccessorOptions) (AccessorFactory, error) {
+	build, err := newAlgorithm(o)
+	if err != nil {
+		return nil, err
+	}
+
+	weights := o.weights()
+	if o.algorithm() == ConsistentHashing && len(weights) == 0 {
+		return NewConsistentAccessorFactory(o.vnodeCount()), nil
+	}
+
+	return func(instances []string) Accessor {
+		return build(weights, instances)
+	}, nil
+}
+
+// newAlgorithm returns a closure that builds an Accessor for a set of weighted instances using
+// the configured hashing algorithm
+func newAlgorithm(o *AccessorOptions) (func(map[string]int, []string) Accessor, error) {
+	switch o.algorithm() {
+	case ConsistentHashing:
+		vnodeCount := o.vnodeCount()
+		return func(weights map[string]int, instances []string) Accessor {
+			if len(weights) == 0 {
+				return newConsistentAccessor(vnodeCount, instances)
+			}
+
+			return newWeightedConsistentAccessor(vnodeCount, weights, instances)
+		}, nil
+
+	case RendezvousHashing:
+		return newRendezvousAccessor, nil
+
+	case JumpHashing:
+		return newJumpAccessor, nil
+
+	case MaglevHashing:
+		tableSize := o.maglevTableSize()
+		if !isPrime(tableSize) {
+			return nil, fmt.Errorf("The Maglev table size must be prime: %d", tableSize)
+		}
+
+		return func(weights map[string]int, instances []string) Accessor {
+			return newMaglevAccessor(tableSize, weights, instances)
+		}, nil
+
+	default:
+		return nil, fmt.Errorf("Unsupported hashing algorithm: %s", o.Algorithm)
+	}
+}
+
+// weightOf returns the weight of an instance, defaulting to 1
+func weightOf(weights map[string]int, instance string) int {
+	if w := weights[instance]; w > 0 {
+		return w
+	}
+
+	return 1
+}
+
+// uniqueInstances returns the distinct instances in sorted order, so that accessors do not depend
+// on the order in which service discovery reports instances
+func uniqueInstances(instances []string) []string {
+	unique := make([]string, 0, len(instances))
+	seen := make(map[string]bool, len(instances))
+	for _, i := range instances {
+		if !seen[i] {
+			seen[i] = true
+			unique = append(unique, i)
+		}
+	}
+
+	sort.Strings(unique)
+	return unique
+}
+
+// mix64 is the splitmix64 finalizer, used to combine key and instance hashes
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
+
+// weightedConsistentAccessor is a consistent hash ring in which each instance has a number of virtual
+// nodes proportional to its weight.  Tokens are computed the same way as consistentHash, so an
+// unweighted ring maps keys identically.
+type weightedConsistentAccessor struct {
+	tokens    []uint64
+	instances []string
+}
+
+func newWeightedConsistentAccessor(vnodeCount int, weights map[string]int, instances []string) Accessor {
+	instances = uniqueInstances(instances)
+	if len(instances) == 0 {
+		return emptyAccessor{}
+	}
+
+	type vnode struct {
+		token    uint64
+		instance string
+	}
+
+	var vnodes []vnode
+	for _, i := range instances {
+		for v := 0; v < vnodeCount*weightOf(weights, i); v++ {
+			vnodes = append(vnodes, vnode{murmur3.Sum64([]byte(strconv.Itoa(v) + "=" + i)), i})
+		}
+	}
+
+	sort.Slice(vnodes, func(i, j int) bool { return vnodes[i].token < vnodes[j].token })
+	wca := &weightedConsistentAccessor{
+		tokens:    make([]uint64, len(vnodes)),
+		instances: make([]string, len(vnodes)),
+	}
+
+	for i, v := range vnodes {
+		wca.tokens[i] = v.token
+		wca.instances[i] = v.instance
+	}
+
+	return wca
+}
+
+func (wca *weightedConsistentAccessor) Get(key []byte) (string, error) {
+	token := murmur3.Sum64(key)
+	index := sort.Search(len(wca.tokens), func(i int) bool { return wca.tokens[i] >= token })
+	if index == len(wca.tokens) {
+		index = 0
+	}
+
+	return wca.instances[index], nil
+}
+
+// rendezvousAccessor implements weighted rendezvous, or highest random weight, hashing
+type rendezvousAccessor struct {
+	instances []string
+	hashes    []uint64
+	weights   []float64
+}
+
+func newRendezvousAccessor(weights map[string]int, instances []string) Accessor {
+	instances = uniqueInstances(instances)
+	if len(instances) == 0 {
+		return emptyAccessor{}
+	}
+
+	ra := &rendezvousAccessor{
+		instances: instances,
+		hashes:    make([]uint64, len(instances)),
+		weights:   make([]float64, len(instances)),
+	}
+
+	for i, instance := range instances {
+		ra.hashes[i] = murmur3.Sum64([]byte(instance))
+		ra.weights[i] = float64(weightOf(weights, instance))
+	}
+
+	return ra
+}
+
+func (ra *rendezvousAccessor) Get(key []byte) (string, error) {
+	var (
+		keyHash = murmur3.Sum64(key)
+		best    = 0
+		max     = math.Inf(-1)
+	)
+
+	for i, h := range ra.hashes {
+		// a uniform value in (0, 1), scored so that each instance wins in proportion to its weight
+		u := (float64(mix64(keyHash^h)>>11) + 0.5) / (1 << 53)
+		if score := -ra.weights[i] / math.Log(u); score > max {
+			best, max = i, score
+		}
+	}
+
+	return ra.instances[best], nil
+}
+
+// jumpAccessor implements jump consistent hashing.  Weighted instances occupy several buckets.
+type jumpAccessor struct {
+	buckets []string
+}
+
+func newJumpAccessor(weights map[string]int, instances []string) Accessor {
+	instances = uniqueInstances(instances)
+	if len(instances) == 0 {
+		return emptyAccessor{}
+	}
+
+	ja := new(jumpAccessor)
+	for _, i := range instances {
+		for w := weightOf(weights, i); w > 0; w-- {
+			ja.buckets = append(ja.buckets, i)
+		}
+	}
+
+	return ja
+}
+
+// jumpHash is the algorithm from Lamping and Veach, "A Fast, Minimal Memory, Consistent Hash Algorithm"
+func jumpHash(key uint64, buckets int) int {
+	var b, j int64 = -1, 0
+	for j < int64(buckets) {
+		b = j
+		key = key*2862933555777941757 + 1
+		j = int64(float64(b+1) * (float64(int64(1)<<31) / float64((key>>33)+1)))
+	}
+
+	return int(b)
+}
+
+func (ja *jumpAccessor) Get(key []byte) (string, error) {
+	return ja.buckets[jumpHash(murmur3.Sum64(key), len(ja.buckets))], nil
+}
+
+// maglevAccessor implements Maglev hashing, as described in "Maglev: A Fast and Reliable Software
+// Network Load Balancer".  Weighted instances claim proportionally more entries in each round.
+type maglevAccessor struct {
+	instances []string
+	table     []int
+}
+
+func newMaglevAccessor(tableSize int, weights map[string]int, instances []string) Accessor {
+	instances = uniqueInstances(instances)
+	if len(instances) == 0 {
+		return emptyAccessor{}
+	}
+
+	var (
+		size    = uint64(tableSize)
+		offsets = make([]uint64, len(instances))
+		skips   = make([]uint64, len(instances))
+		next    = make([]uint64, len(instances))
+		table   = make([]int, tableSize)
+		filled  = 0
+	)
+
+	for slot := range table {
+		table[slot] = -1
+	}
+
+	for i, instance := range instances {
+		h1, h2 := murmur3.Sum128([]byte(instance))
+		offsets[i] = h1 % size
+		skips[i] = h2%(size-1) + 1
+	}
+
+	for filled < tableSize {
+		for i, instance := range instances {
+			for w := weightOf(weights, instance); w > 0 && filled < tableSize; w-- {
+				slot := (offsets[i] + next[i]*skips[i]) % size
+				for table[slot] >= 0 {
+					next[i]++
+					slot = (offsets[i] + next[i]*skips[i]) % size
+				}
+
+				table[slot] = i
+				next[i]++
+				filled++
+			}
+		}
+	}
+
+	return &maglevAccessor{instances: instances, table: table}
+}
+
+func (ma *maglevAccessor) Get(key []byte) (string, error) {
+	return ma.instances[ma.table[murmur3.Sum64(key)%uint64(len(ma.table))]], nil
+}
+
+func isPrime(n int) bool {
+	if n < 2 {
+		return false
+	}
+
+	for d := 2; d*d <= n; d++ {
+		if n%d == 0 {
+			return false
+		}
+	}
+
+	return true
+}
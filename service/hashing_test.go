@@ -0,0 +1,168 @@
+package service
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/billhathaway/consistentHash"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKeys(count int) [][]byte {
+	keys := make([][]byte, count)
+	for i := range keys {
+		keys[i] = []byte(fmt.Sprintf("mac:%012x", i))
+	}
+
+	return keys
+}
+
+func testInstances(count int) []string {
+	instances := make([]string, count)
+	for i := range instances {
+		instances[i] = fmt.Sprintf("instance-%02d.example.com:8080", i)
+	}
+
+	return instances
+}
+
+func testNewAccessorFactoryDefault(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	for _, o := range []*AccessorOptions{nil, new(AccessorOptions), {Algorithm: "CONSISTENT"}} {
+		af, err := NewAccessorFactory(o)
+		require.NoError(err)
+		require.NotNil(af)
+
+		_, isDefault := af([]string{"an instance"}).(*consistentHash.ConsistentHash)
+		assert.True(isDefault)
+	}
+}
+
+func testNewAccessorFactoryInvalid(t *testing.T) {
+	for _, o := range []*AccessorOptions{{Algorithm: "nosuch"}, {Algorithm: MaglevHashing, MaglevTableSize: 1000}} {
+		af, err := NewAccessorFactory(o)
+		assert.Nil(t, af)
+		assert.Error(t, err)
+	}
+}
+
+func testNewAccessorFactoryAlgorithm(t *testing.T, algorithm string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		keys      = testKeys(10000)
+		instances = testInstances(10)
+	)
+
+	af, err := NewAccessorFactory(&AccessorOptions{Algorithm: algorithm, MaglevTableSize: 2053})
+	require.NoError(err)
+	require.NotNil(af)
+
+	empty := af(nil)
+	require.NotNil(empty)
+	_, err = empty.Get([]byte("test"))
+	assert.Error(err)
+
+	a := af(instances)
+	d, err := NewDistribution(a, instances, nil, keys)
+	require.NoError(err)
+	assert.Len(d.Counts, len(instances))
+	assert.True(d.Skew < 1.2, "skew: %f", d.Skew)
+
+	// the order in which instances are reported does not affect placement
+	reversed := make([]string, len(instances))
+	for i, instance := range instances {
+		reversed[len(instances)-1-i] = instance
+	}
+
+	moved, err := Movement(a, af(append(reversed, instances[0])), keys)
+	require.NoError(err)
+	assert.Zero(moved)
+
+	// adding an instance moves only a fraction of the keys, most of which go to the new instance
+	moved, err = Movement(a, af(append(instances, "new-instance")), keys)
+	require.NoError(err)
+	assert.True(moved < 0.2, "moved: %f", moved)
+}
+
+func testNewAccessorFactoryWeighted(t *testing.T, algorithm string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		keys      = testKeys(20000)
+		instances = testInstances(4)
+		weights   = map[string]int{instances[0]: 3, instances[1]: -1}
+	)
+
+	af, err := NewAccessorFactory(&AccessorOptions{Algorithm: algorithm, Weights: weights})
+	require.NoError(err)
+
+	d, err := NewDistribution(af(instances), instances, weights, keys)
+	require.NoError(err)
+	assert.True(d.Skew < 1.2, "skew: %f", d.Skew)
+
+	// the heavier instance receives about half of the keys
+	share := float64(d.Counts[instances[0]]) / float64(len(keys))
+	assert.InDelta(0.5, share, 0.06)
+}
+
+func TestNewAccessorFactory(t *testing.T) {
+	t.Run("Default", testNewAccessorFactoryDefault)
+	t.Run("Invalid", testNewAccessorFactoryInvalid)
+
+	for _, algorithm := range []string{ConsistentHashing, RendezvousHashing, JumpHashing, MaglevHashing} {
+		t.Run(algorithm, func(t *testing.T) {
+			t.Run("Unweighted", func(t *testing.T) {
+				testNewAccessorFactoryAlgorithm(t, algorithm)
+			})
+
+			t.Run("Weighted", func(t *testing.T) {
+				testNewAccessorFactoryWeighted(t, algorithm)
+			})
+		})
+	}
+}
+
+func TestWeightedConsistentAccessorMatchesDefault(t *testing.T) {
+	var (
+		require = require.New(t)
+
+		keys      = testKeys(1000)
+		instances = testInstances(5)
+	)
+
+	moved, err := Movement(DefaultAccessorFactory(instances), newWeightedConsistentAccessor(DefaultVnodeCount, nil, instances), keys)
+	require.NoError(err)
+	require.Zero(moved)
+}
+
+func TestJumpHash(t *testing.T) {
+	assert := assert.New(t)
+	for key := uint64(0); key < 1000; key++ {
+		assert.Zero(jumpHash(key, 1))
+
+		// growing the buckets either keeps a key in place or moves it to the new bucket
+		for buckets := 2; buckets < 10; buckets++ {
+			before, after := jumpHash(key, buckets-1), jumpHash(key, buckets)
+			assert.True(before == after || after == buckets-1)
+		}
+	}
+}
+
+func TestIsPrime(t *testing.T) {
+	assert := assert.New(t)
+	for _, n := range []int{2, 3, 5, 251, 2053, DefaultMaglevTableSize} {
+		assert.True(isPrime(n), n)
+	}
+
+	for _, n := range []int{-7, 0, 1, 4, 1000, 65536} {
+		assert.False(isPrime(n), n)
+	}
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/go-kit/kit/sd"
+)
+
+// InstancerState is the current state of a polling or watching sd.Instancer, along with the channels
+// registered to receive it.  It supplies the Register, Deregister, and InstanceMetadata methods of a
+// MetadataInstancer, so instancers embed it and call Update as they discover instances.
+//
+// The zero value of this type is ready to use.
+type InstancerState struct {
+	lock     sync.Mutex
+	state    sd.Event
+	metadata map[string]InstanceMetadata
+	registry map[chan<- sd.Event]bool
+}
+
+// Update replaces the current state and metadata, dispatching the new state to every registered
+// channel.  If neither has changed, nothing is dispatched.
+func (s *InstancerState) Update(e sd.Event, metadata map[string]InstanceMetadata) {
+	defer s.lock.Unlock()
+	s.lock.Lock()
+
+	if reflect.DeepEqual(s.state, e) && reflect.DeepEqual(s.metadata, metadata) {
+		return
+	}
+
+	s.state = e
+	s.metadata = metadata
+	for c := range s.registry {
+		c <- s.state
+	}
+}
+
+// State returns the current state
+func (s *InstancerState) State() sd.Event {
+	defer s.lock.Unlock()
+	s.lock.Lock()
+	return s.state
+}
+
+// InstanceMetadata returns a copy of the current metadata
+func (s *InstancerState) InstanceMetadata() map[string]InstanceMetadata {
+	defer s.lock.Unlock()
+	s.lock.Lock()
+
+	metadata := make(map[string]InstanceMetadata, len(s.metadata))
+	for k, v := range s.metadata {
+		metadata[k] = v
+	}
+
+	return metadata
+}
+
+// Register adds a channel to receive state changes, immediately sending it the current state
+func (s *InstancerState) Register(ch chan<- sd.Event) {
+	defer s.lock.Unlock()
+	s.lock.Lock()
+
+	if s.registry == nil {
+		s.registry = make(map[chan<- sd.Event]bool)
+	}
+
+	s.registry[ch] = true
+
+	// push the current state to the new channel
+	ch <- s.state
+}
+
+// Deregister removes a channel, which will no longer receive state changes
+func (s *InstancerState) Deregister(ch chan<- sd.Event) {
+	defer s.lock.Unlock()
+	s.lock.Lock()
+	delete(s.registry, ch)
+}
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstancerState(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		s      InstancerState
+		events = make(chan sd.Event, 1)
+
+		instances = sd.Event{Instances: []string{"http://talaria-0:8080"}}
+		metadata  = map[string]InstanceMetadata{"http://talaria-0:8080": {Zone: "east"}}
+	)
+
+	// the zero value is ready to use
+	assert.Equal(sd.Event{}, s.State())
+	assert.Empty(s.InstanceMetadata())
+
+	s.Register(events)
+	assert.Equal(sd.Event{}, <-events)
+
+	s.Update(instances, metadata)
+	assert.Equal(instances, <-events)
+	assert.Equal(instances, s.State())
+	assert.Equal(metadata, s.InstanceMetadata())
+
+	// the returned metadata is a copy
+	s.InstanceMetadata()["http://talaria-0:8080"] = InstanceMetadata{}
+	assert.Equal(metadata, s.InstanceMetadata())
+
+	// an unchanged state is not dispatched, as the buffered channel would otherwise block
+	s.Update(instances, metadata)
+	s.Update(instances, metadata)
+	assert.Len(events, 0)
+
+	// a change to only the metadata is dispatched
+	s.Update(instances, nil)
+	assert.Equal(instances, <-events)
+	assert.Empty(s.InstanceMetadata())
+
+	s.Deregister(events)
+	s.Update(sd.Event{Err: errors.New("expected")}, nil)
+	assert.Len(events, 0)
+	assert.Error(s.State().Err)
+}
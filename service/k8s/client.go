@@ -0,0 +1,369 @@
+package k8s
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jithin-kg/webpa-common/service"
+)
+
+// Endpoints is the set of ready instances of a service at a point in time
+type Endpoints struct {
+	// Instances are the instance URLs, formatted with service.FormatInstance
+	Instances []string
+
+	// Metadata describes the Instances, such as their zone
+	Metadata map[string]service.InstanceMetadata
+
+	// ResourceVersion is the Kubernetes resource version the Instances were read at
+	ResourceVersion string
+}
+
+// Client is the subset of the Kubernetes API used for service discovery
+type Client interface {
+	// Endpoints lists the current ready instances of a watched service
+	Endpoints(ctx context.Context, w Watch) (Endpoints, error)
+
+	// WaitForChange blocks until the watched service's endpoints change after the given resource version,
+	// the watch times out, or the context is canceled
+	WaitForChange(ctx context.Context, w Watch, resourceVersion string) error
+}
+
+// NewClient creates a Client which talks directly to the Kubernetes API server described by the options
+func NewClient(o Options) (Client, error) {
+	tlsConfig := new(tls.Config)
+	caFile := o.caFile()
+	if len(caFile) == 0 {
+		if _, err := os.Stat(DefaultCAFile); err == nil {
+			caFile = DefaultCAFile
+		}
+	}
+
+	if len(caFile) > 0 {
+		pem, err := ioutil.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("No certificates found in %s", caFile)
+		}
+	}
+
+	return &client{
+		server:         o.server(),
+		tokenFile:      o.tokenFile(),
+		namespace:      o.namespace(),
+		watchTimeout:   o.watchTimeout(),
+		requestTimeout: o.requestTimeout(),
+		http: &http.Client{
+			Transport: &http.Transport{
+				Proxy:           http.ProxyFromEnvironment,
+				TLSClientConfig: tlsConfig,
+			},
+		},
+	}, nil
+}
+
+type client struct {
+	server         string
+	tokenFile      string
+	namespace      string
+	watchTimeout   time.Duration
+	requestTimeout time.Duration
+	http           *http.Client
+}
+
+// The subset of the core/v1 Endpoints and discovery.k8s.io/v1 EndpointSlice resources used for discovery
+
+type objectMeta struct {
+	ResourceVersion string `json:"resourceVersion"`
+}
+
+type endpointAddress struct {
+	IP       string  `json:"ip"`
+	NodeName *string `json:"nodeName"`
+}
+
+type endpointPort struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+}
+
+type endpointSubset struct {
+	Addresses []endpointAddress `json:"addresses"`
+	Ports     []endpointPort    `json:"ports"`
+}
+
+type endpointsResource struct {
+	Metadata objectMeta       `json:"metadata"`
+	Subsets  []endpointSubset `json:"subsets"`
+}
+
+type sliceEndpoint struct {
+	Addresses  []string `json:"addresses"`
+	Conditions struct {
+		Ready *bool `json:"ready"`
+	} `json:"conditions"`
+	NodeName *string `json:"nodeName"`
+	Zone     *string `json:"zone"`
+}
+
+type endpointSlice struct {
+	AddressType string          `json:"addressType"`
+	Endpoints   []sliceEndpoint `json:"endpoints"`
+	Ports       []endpointPort  `json:"ports"`
+}
+
+type endpointSliceList struct {
+	Metadata objectMeta      `json:"metadata"`
+	Items    []endpointSlice `json:"items"`
+}
+
+type watchEvent struct {
+	Type   string          `json:"type"`
+	Object json.RawMessage `json:"object"`
+}
+
+// selectPort returns the port with the given name, or the first port if the name is empty
+func selectPort(ports []endpointPort, name string) (int, bool) {
+	for _, p := range ports {
+		if len(name) == 0 || p.Name == name {
+			return p.Port, true
+		}
+	}
+
+	return 0, false
+}
+
+func (c *client) namespaceOf(w Watch) string {
+	if len(w.Namespace) > 0 {
+		return w.Namespace
+	}
+
+	return c.namespace
+}
+
+// resourcePath returns the collection path and query for a watch
+func (c *client) resourcePath(w Watch) (string, url.Values) {
+	query := make(url.Values)
+	if w.EndpointSlices {
+		query.Set("labelSelector", "kubernetes.io/service-name="+w.Service)
+		return fmt.Sprintf("/apis/discovery.k8s.io/v1/namespaces/%s/endpointslices", c.namespaceOf(w)), query
+	}
+
+	query.Set("fieldSelector", "metadata.name="+w.Service)
+	return fmt.Sprintf("/api/v1/namespaces/%s/endpoints", c.namespaceOf(w)), query
+}
+
+func (c *client) get(ctx context.Context, path string, query url.Values) (*http.Response, error) {
+	request, err := http.NewRequest(http.MethodGet, c.server+path+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if token, err := ioutil.ReadFile(c.tokenFile); err == nil {
+		request.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	}
+
+	request.Header.Set("Accept", "application/json")
+	response, err := c.http.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	if response.StatusCode != http.StatusOK {
+		response.Body.Close()
+		return nil, fmt.Errorf("Kubernetes API server responded to %s with status %d", path, response.StatusCode)
+	}
+
+	return response, nil
+}
+
+func (c *client) Endpoints(ctx context.Context, w Watch) (Endpoints, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.requestTimeout)
+	defer cancel()
+
+	path, query := c.resourcePath(w)
+	response, err := c.get(ctx, path, query)
+	if err != nil {
+		return Endpoints{}, err
+	}
+
+	defer response.Body.Close()
+	if w.EndpointSlices {
+		var list endpointSliceList
+		if err := json.NewDecoder(response.Body).Decode(&list); err != nil {
+			return Endpoints{}, err
+		}
+
+		return fromEndpointSlices(w, list), nil
+	}
+
+	// the field selector yields a list of at most one Endpoints resource
+	var list struct {
+		Metadata objectMeta          `json:"metadata"`
+		Items    []endpointsResource `json:"items"`
+	}
+
+	if err := json.NewDecoder(response.Body).Decode(&list); err != nil {
+		return Endpoints{}, err
+	}
+
+	e := Endpoints{ResourceVersion: list.Metadata.ResourceVersion}
+	for _, item := range list.Items {
+		e.merge(fromEndpoints(w, item))
+	}
+
+	return e, nil
+}
+
+func (e *Endpoints) merge(other Endpoints) {
+	e.Instances = append(e.Instances, other.Instances...)
+	if len(other.Metadata) > 0 && e.Metadata == nil {
+		e.Metadata = make(map[string]service.InstanceMetadata, len(other.Metadata))
+	}
+
+	for k, v := range other.Metadata {
+		e.Metadata[k] = v
+	}
+}
+
+func (e *Endpoints) add(scheme, address string, port int, m service.InstanceMetadata) {
+	if strings.Contains(address, ":") {
+		// IPv6 addresses must be bracketed to be joined with a port
+		address = "[" + address + "]"
+	}
+
+	instance := service.FormatInstance(scheme, address, port)
+	e.Instances = append(e.Instances, instance)
+	if e.Metadata == nil {
+		e.Metadata = make(map[string]service.InstanceMetadata)
+	}
+
+	e.Metadata[instance] = m
+}
+
+// fromEndpoints extracts the ready instances from an Endpoints resource.  Only addresses, not
+// notReadyAddresses, are used.
+func fromEndpoints(w Watch, r endpointsResource) Endpoints {
+	e := Endpoints{ResourceVersion: r.Metadata.ResourceVersion}
+	for _, subset := range r.Subsets {
+		port, ok := selectPort(subset.Ports, w.PortName)
+		if !ok {
+			continue
+		}
+
+		for _, a := range subset.Addresses {
+			var m service.InstanceMetadata
+			if a.NodeName != nil {
+				m.Meta = map[string]string{"nodeName": *a.NodeName}
+			}
+
+			e.add(w.scheme(), a.IP, port, m)
+		}
+	}
+
+	return e
+}
+
+// fromEndpointSlices extracts the ready instances from a list of EndpointSlices.  An endpoint
+// whose readiness is unknown is treated as ready, as the API recommends.  Only slices of the watch's
+// address type are used, which skips FQDN slices and the second family of dual-stack services.
+func fromEndpointSlices(w Watch, list endpointSliceList) Endpoints {
+	e := Endpoints{ResourceVersion: list.Metadata.ResourceVersion}
+	for _, slice := range list.Items {
+		if slice.AddressType != w.addressType() {
+			continue
+		}
+
+		port, ok := selectPort(slice.Ports, w.PortName)
+		if !ok {
+			continue
+		}
+
+		for _, endpoint := range slice.Endpoints {
+			if endpoint.Conditions.Ready != nil && !*endpoint.Conditions.Ready {
+				continue
+			}
+
+			var m service.InstanceMetadata
+			if endpoint.Zone != nil {
+				m.Zone = *endpoint.Zone
+			}
+
+			if endpoint.NodeName != nil {
+				m.Meta = map[string]string{"nodeName": *endpoint.NodeName}
+			}
+
+			// the addresses of an endpoint are fungible, so only the first is used
+			if len(endpoint.Addresses) > 0 {
+				e.add(w.scheme(), endpoint.Addresses[0], port, m)
+			}
+		}
+	}
+
+	return e
+}
+
+func (c *client) WaitForChange(ctx context.Context, w Watch, resourceVersion string) error {
+	path, query := c.resourcePath(w)
+	query.Set("watch", "true")
+	query.Set("allowWatchBookmarks", "true")
+	query.Set("timeoutSeconds", strconv.Itoa(int(c.watchTimeout/time.Second)))
+	if len(resourceVersion) > 0 {
+		query.Set("resourceVersion", resourceVersion)
+	}
+
+	// the server ends the watch after watchTimeout, so a watch held open much longer than that is stalled
+	ctx, cancel := context.WithTimeout(ctx, c.watchTimeout+c.requestTimeout)
+	defer cancel()
+
+	response, err := c.get(ctx, path, query)
+	if err != nil {
+		return err
+	}
+
+	defer response.Body.Close()
+	decoder := json.NewDecoder(response.Body)
+	for {
+		var event watchEvent
+		if err := decoder.Decode(&event); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+
+			// the server closed the watch, typically because of the timeout
+			return nil
+		}
+
+		switch event.Type {
+		case "BOOKMARK":
+			// bookmarks only advance the resource version
+
+		case "ERROR":
+			// most often, the resource version is too old and the instances must be listed again
+			var status struct {
+				Message string `json:"message"`
+			}
+
+			json.Unmarshal(event.Object, &status)
+			return errors.New("Kubernetes watch error: " + status.Message)
+
+		default:
+			return nil
+		}
+	}
+}
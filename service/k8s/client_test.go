@@ -0,0 +1,360 @@
+package k8s
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testEndpoints = `{
+		"metadata": {"resourceVersion": "100"},
+		"items": [
+			{
+				"metadata": {"resourceVersion": "99"},
+				"subsets": [
+					{
+						"addresses": [{"ip": "10.0.0.1", "nodeName": "node1"}, {"ip": "10.0.0.2"}],
+						"notReadyAddresses": [{"ip": "10.0.0.3"}],
+						"ports": [{"name": "metrics", "port": 9090}, {"name": "http", "port": 8080}]
+					},
+					{
+						"addresses": [{"ip": "10.0.0.4"}],
+						"ports": [{"name": "metrics", "port": 9090}]
+					},
+					{
+						"addresses": [{"ip": "fd00::5"}],
+						"ports": [{"name": "http", "port": 8080}]
+					}
+				]
+			}
+		]
+	}`
+
+	testEndpointSlices = `{
+		"metadata": {"resourceVersion": "200"},
+		"items": [
+			{
+				"addressType": "IPv4",
+				"endpoints": [
+					{"addresses": ["10.0.1.1", "10.0.1.9"], "conditions": {"ready": true}, "nodeName": "node1", "zone": "east"},
+					{"addresses": ["10.0.1.2"], "conditions": {"ready": false}, "zone": "east"},
+					{"addresses": ["10.0.1.3"], "conditions": {}, "zone": "west"}
+				],
+				"ports": [{"name": "http", "port": 8080}]
+			},
+			{
+				"addressType": "IPv6",
+				"endpoints": [
+					{"addresses": ["fd00::1"], "conditions": {"ready": true}, "zone": "east"}
+				],
+				"ports": [{"name": "http", "port": 8080}]
+			},
+			{
+				"addressType": "FQDN",
+				"endpoints": [
+					{"addresses": ["talaria.example.com"], "conditions": {"ready": true}}
+				],
+				"ports": [{"name": "http", "port": 8080}]
+			}
+		]
+	}`
+)
+
+// newTestServer creates a fake API server which records each request it receives
+func newTestServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, <-chan *http.Request) {
+	requests := make(chan *http.Request, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		requests <- request
+		handler(response, request)
+	}))
+
+	return server, requests
+}
+
+// newTestTokenFile creates a temporary token file, returning its name
+func newTestTokenFile(t *testing.T, token string) string {
+	f, err := ioutil.TempFile("", "token")
+	require.NoError(t, err)
+	defer f.Close()
+
+	_, err = f.WriteString(token + "\n")
+	require.NoError(t, err)
+	return f.Name()
+}
+
+func newTestClient(t *testing.T, server *httptest.Server, tokenFile string) Client {
+	c, err := NewClient(Options{
+		Server:       server.URL + "/",
+		TokenFile:    tokenFile,
+		Namespace:    "xmidt",
+		WatchTimeout: 30 * time.Second,
+	})
+
+	require.NoError(t, err)
+	require.NotNil(t, c)
+	return c
+}
+
+func testClientEndpoints(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		tokenFile = newTestTokenFile(t, "secret")
+
+		server, requests = newTestServer(t, func(response http.ResponseWriter, request *http.Request) {
+			fmt.Fprint(response, testEndpoints)
+		})
+	)
+
+	defer os.Remove(tokenFile)
+	defer server.Close()
+
+	e, err := newTestClient(t, server, tokenFile).Endpoints(context.Background(), Watch{Service: "talaria", PortName: "http", Scheme: "http"})
+	require.NoError(err)
+
+	request := <-requests
+	assert.Equal("/api/v1/namespaces/xmidt/endpoints", request.URL.Path)
+	assert.Equal("metadata.name=talaria", request.URL.Query().Get("fieldSelector"))
+	assert.Equal("Bearer secret", request.Header.Get("Authorization"))
+
+	assert.Equal("100", e.ResourceVersion)
+	assert.Equal([]string{"http://10.0.0.1:8080", "http://10.0.0.2:8080", "http://[fd00::5]:8080"}, e.Instances)
+	assert.Equal(
+		map[string]service.InstanceMetadata{
+			"http://10.0.0.1:8080":  {Meta: map[string]string{"nodeName": "node1"}},
+			"http://10.0.0.2:8080":  {},
+			"http://[fd00::5]:8080": {},
+		},
+		e.Metadata,
+	)
+}
+
+func testClientEndpointSlices(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server, requests = newTestServer(t, func(response http.ResponseWriter, request *http.Request) {
+			fmt.Fprint(response, testEndpointSlices)
+		})
+	)
+
+	defer server.Close()
+
+	// a missing token file means requests are not authenticated
+	e, err := newTestClient(t, server, "/nosuch/token").Endpoints(context.Background(), Watch{Namespace: "other", Service: "talaria", EndpointSlices: true})
+	require.NoError(err)
+
+	request := <-requests
+	assert.Equal("/apis/discovery.k8s.io/v1/namespaces/other/endpointslices", request.URL.Path)
+	assert.Equal("kubernetes.io/service-name=talaria", request.URL.Query().Get("labelSelector"))
+	assert.Empty(request.Header.Get("Authorization"))
+
+	assert.Equal("200", e.ResourceVersion)
+	assert.Equal([]string{"https://10.0.1.1:8080", "https://10.0.1.3:8080"}, e.Instances)
+	assert.Equal(
+		map[string]service.InstanceMetadata{
+			"https://10.0.1.1:8080": {Zone: "east", Meta: map[string]string{"nodeName": "node1"}},
+			"https://10.0.1.3:8080": {Zone: "west"},
+		},
+		e.Metadata,
+	)
+}
+
+func testClientEndpointSlicesIPv6(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server, _ = newTestServer(t, func(response http.ResponseWriter, request *http.Request) {
+			fmt.Fprint(response, testEndpointSlices)
+		})
+	)
+
+	defer server.Close()
+
+	e, err := newTestClient(t, server, "/nosuch/token").Endpoints(context.Background(), Watch{Service: "talaria", EndpointSlices: true, AddressType: "IPv6"})
+	require.NoError(err)
+
+	assert.Equal([]string{"https://[fd00::1]:8080"}, e.Instances)
+	assert.Equal(map[string]service.InstanceMetadata{"https://[fd00::1]:8080": {Zone: "east"}}, e.Metadata)
+}
+
+func testClientEndpointsError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		server, _ = newTestServer(t, func(response http.ResponseWriter, request *http.Request) {
+			response.WriteHeader(http.StatusForbidden)
+		})
+	)
+
+	defer server.Close()
+
+	e, err := newTestClient(t, server, "").Endpoints(context.Background(), Watch{Service: "talaria"})
+	assert.Equal(Endpoints{}, e)
+	assert.Error(err)
+}
+
+func testClientWaitForChange(t *testing.T, events string, expectError bool) {
+	var (
+		assert = assert.New(t)
+
+		server, requests = newTestServer(t, func(response http.ResponseWriter, request *http.Request) {
+			fmt.Fprint(response, events)
+		})
+	)
+
+	defer server.Close()
+
+	err := newTestClient(t, server, "").WaitForChange(context.Background(), Watch{Service: "talaria"}, "100")
+	if expectError {
+		assert.Error(err)
+	} else {
+		assert.NoError(err)
+	}
+
+	query := (<-requests).URL.Query()
+	assert.Equal("true", query.Get("watch"))
+	assert.Equal("true", query.Get("allowWatchBookmarks"))
+	assert.Equal("30", query.Get("timeoutSeconds"))
+	assert.Equal("100", query.Get("resourceVersion"))
+}
+
+func testClientWaitForChangeCanceled(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		done      = make(chan struct{})
+		server, _ = newTestServer(t, func(response http.ResponseWriter, request *http.Request) {
+			// hold the watch open with only a bookmark
+			fmt.Fprint(response, `{"type": "BOOKMARK", "object": {}}`)
+			response.(http.Flusher).Flush()
+			select {
+			case <-request.Context().Done():
+			case <-done:
+			}
+		})
+
+		ctx, cancel = context.WithCancel(context.Background())
+		result      = make(chan error, 1)
+	)
+
+	defer server.Close()
+	defer close(done)
+
+	go func() {
+		result <- newTestClient(t, server, "").WaitForChange(ctx, Watch{Service: "talaria"}, "")
+	}()
+
+	cancel()
+	select {
+	case err := <-result:
+		assert.Error(err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("WaitForChange did not honor cancelation")
+	}
+}
+
+func testClientStalled(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		done      = make(chan struct{})
+		server, _ = newTestServer(t, func(response http.ResponseWriter, request *http.Request) {
+			// an unresponsive API server never completes the response
+			if request.URL.Query().Get("watch") == "true" {
+				fmt.Fprint(response, `{"type": "BOOKMARK", "object": {}}`)
+				response.(http.Flusher).Flush()
+			}
+
+			select {
+			case <-request.Context().Done():
+			case <-done:
+			}
+		})
+	)
+
+	defer server.Close()
+	defer close(done)
+
+	c, err := NewClient(Options{
+		Server:         server.URL,
+		TokenFile:      "/nosuch/token",
+		WatchTimeout:   50 * time.Millisecond,
+		RequestTimeout: 50 * time.Millisecond,
+	})
+
+	require.NoError(err)
+
+	result := make(chan error, 2)
+	go func() {
+		_, err := c.Endpoints(context.Background(), Watch{Service: "talaria"})
+		result <- err
+	}()
+
+	go func() {
+		result <- c.WaitForChange(context.Background(), Watch{Service: "talaria"}, "")
+	}()
+
+	for i := 0; i < 2; i++ {
+		select {
+		case err := <-result:
+			assert.Error(err)
+		case <-time.After(5 * time.Second):
+			assert.Fail("A stalled request was not timed out")
+		}
+	}
+}
+
+func TestClient(t *testing.T) {
+	t.Run("Endpoints", testClientEndpoints)
+	t.Run("EndpointSlices", testClientEndpointSlices)
+	t.Run("EndpointSlicesIPv6", testClientEndpointSlicesIPv6)
+	t.Run("Error", testClientEndpointsError)
+	t.Run("Stalled", testClientStalled)
+
+	t.Run("WaitForChange", func(t *testing.T) {
+		t.Run("Modified", func(t *testing.T) {
+			testClientWaitForChange(t, `{"type": "BOOKMARK", "object": {}}{"type": "MODIFIED", "object": {}}`, false)
+		})
+
+		t.Run("Timeout", func(t *testing.T) {
+			testClientWaitForChange(t, `{"type": "BOOKMARK", "object": {}}`, false)
+		})
+
+		t.Run("Expired", func(t *testing.T) {
+			testClientWaitForChange(t, `{"type": "ERROR", "object": {"message": "too old resource version"}}`, true)
+		})
+
+		t.Run("Canceled", testClientWaitForChangeCanceled)
+	})
+}
+
+func TestNewClientBadCAFile(t *testing.T) {
+	assert := assert.New(t)
+
+	c, err := NewClient(Options{CAFile: "/nosuch/ca.crt"})
+	assert.Nil(c)
+	assert.Error(err)
+
+	// a file without certificates is also an error
+	notPEM := newTestTokenFile(t, "not a certificate")
+	defer os.Remove(notPEM)
+
+	c, err = NewClient(Options{CAFile: notPEM})
+	assert.Nil(c)
+	assert.Error(err)
+}
@@ -0,0 +1,119 @@
+package k8s
+
+import (
+	"fmt"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/sd"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
+)
+
+// clientFactory is the factory function used to create a Kubernetes Client.
+// Tests can change this for mocked behavior.
+var clientFactory = NewClient
+
+func newInstancerKey(w Watch) string {
+	return fmt.Sprintf(
+		"%s{namespace=%s}{port=%s}{endpointSlices=%t}{addressType=%s}",
+		w.Service,
+		w.Namespace,
+		w.PortName,
+		w.EndpointSlices,
+		w.addressType(),
+	)
+}
+
+func newInstancer(l log.Logger, c Client, w Watch) sd.Instancer {
+	return service.NewContextualInstancer(
+		NewInstancer(InstancerOptions{
+			Client: c,
+			Logger: l,
+			Watch:  w,
+		}),
+		map[string]interface{}{
+			"service":        w.Service,
+			"namespace":      w.Namespace,
+			"portName":       w.PortName,
+			"endpointSlices": w.EndpointSlices,
+		},
+	)
+}
+
+func newInstancers(l log.Logger, c Client, defaultScheme string, ko Options) (i service.Instancers) {
+	for _, w := range ko.watches() {
+		key := newInstancerKey(w)
+		if i.Has(key) {
+			l.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "skipping duplicate watch", "service", w.Service, "namespace", w.Namespace)
+			continue
+		}
+
+		if len(w.Scheme) == 0 {
+			w.Scheme = defaultScheme
+		}
+
+		i.Set(key, newInstancer(l, c, w))
+	}
+
+	return
+}
+
+// registrar is the sd.Registrar for Kubernetes.  Endpoints are published by Kubernetes itself
+// based on readiness probes, so there is nothing to do.
+type registrar struct {
+	logger log.Logger
+}
+
+func (r registrar) Register() {
+	r.logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "registration is managed by kubernetes readiness")
+}
+
+func (r registrar) Deregister() {
+	r.logger.Log(level.Key(), level.DebugValue(), logging.MessageKey(), "deregistration is managed by kubernetes readiness")
+}
+
+func newRegistrars(l log.Logger, registrationScheme string, ko Options) (r service.Registrars) {
+	for _, registration := range ko.registrations() {
+		instance, err := service.NormalizeInstance(registrationScheme, registration)
+		if err != nil {
+			l.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "skipping invalid registration", "instance", registration, logging.ErrorKey(), err)
+			continue
+		}
+
+		if r.Has(instance) {
+			l.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "skipping duplicate registration", "instance", instance)
+			continue
+		}
+
+		r.Add(instance, registrar{log.With(l, "instance", instance)})
+	}
+
+	return
+}
+
+// NewEnvironment constructs a Kubernetes-based service.Environment using both a Kubernetes Options (typically unmarshaled
+// from configuration) and an optional extra set of environment options.  The registrationScheme is used both for
+// registrations and for watches that do not specify a scheme.
+func NewEnvironment(l log.Logger, registrationScheme string, ko Options, eo ...service.Option) (service.Environment, error) {
+	if l == nil {
+		l = logging.DefaultLogger()
+	}
+
+	if len(ko.Watches) == 0 && len(ko.Registrations) == 0 {
+		return nil, service.ErrIncomplete
+	}
+
+	c, err := clientFactory(ko)
+	if err != nil {
+		return nil, err
+	}
+
+	return service.NewEnvironment(
+		append(
+			eo,
+			service.WithRegistrars(newRegistrars(l, registrationScheme, ko)),
+			service.WithInstancers(newInstancers(l, c, registrationScheme, ko)),
+		)...,
+	), nil
+}
@@ -0,0 +1,108 @@
+package k8s
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func testNewEnvironmentEmpty(t *testing.T) {
+	defer resetClientFactory()
+
+	var (
+		assert = assert.New(t)
+		called = false
+	)
+
+	clientFactory = func(Options) (Client, error) {
+		called = true
+		return nil, nil
+	}
+
+	e, err := NewEnvironment(nil, "http", Options{})
+	assert.Nil(e)
+	assert.Equal(service.ErrIncomplete, err)
+	assert.False(called)
+}
+
+func testNewEnvironmentClientError(t *testing.T) {
+	defer resetClientFactory()
+
+	var (
+		assert        = assert.New(t)
+		expectedError = errors.New("expected")
+	)
+
+	clientFactory = func(Options) (Client, error) {
+		return nil, expectedError
+	}
+
+	e, err := NewEnvironment(nil, "http", Options{Watches: []Watch{{Service: "talaria"}}})
+	assert.Nil(e)
+	assert.Equal(expectedError, err)
+}
+
+func testNewEnvironmentFull(t *testing.T) {
+	defer resetClientFactory()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		logger = logging.NewTestLogger(nil, t)
+		client = new(mockClient)
+		block  = make(chan time.Time)
+
+		ko = Options{
+			Namespace: "xmidt",
+			Registrations: []string{
+				"talaria-0.talaria:8080",
+				"http://talaria-0.talaria:8080",
+				"this is not valid:1234:5678",
+			},
+			Watches: []Watch{
+				{Service: "talaria", PortName: "http"},
+				{Service: "talaria", PortName: "http"},
+				{Service: "scytale", Scheme: "https", EndpointSlices: true},
+			},
+		}
+	)
+
+	clientFactory = func(actual Options) (Client, error) {
+		assert.Equal(ko, actual)
+		return client, nil
+	}
+
+	defer close(block)
+	client.On("Endpoints", mock.Anything, Watch{Service: "talaria", PortName: "http", Scheme: "http"}).
+		Return(Endpoints{Instances: []string{"http://10.0.0.1:8080"}, ResourceVersion: "1"}, nil).Once()
+	client.On("Endpoints", mock.Anything, Watch{Service: "scytale", Scheme: "https", EndpointSlices: true}).
+		Return(Endpoints{Instances: []string{"https://10.0.0.2"}, ResourceVersion: "1"}, nil).Once()
+	client.On("WaitForChange", mock.Anything, mock.Anything, "1").WaitUntil(block).Return(nil).Maybe()
+
+	e, err := NewEnvironment(logger, "http", ko)
+	require.NoError(err)
+	require.NotNil(e)
+	defer e.Close()
+
+	assert.True(e.IsRegistered("http://talaria-0.talaria:8080"))
+	assert.False(e.IsRegistered("this is not valid:1234:5678"))
+	e.Register()
+	e.Deregister()
+
+	assert.Equal(2, e.Instancers().Len())
+	assert.True(e.Instancers().Has(newInstancerKey(Watch{Service: "talaria", PortName: "http"})))
+	assert.True(e.Instancers().Has(newInstancerKey(Watch{Service: "scytale", Scheme: "https", EndpointSlices: true})))
+}
+
+func TestNewEnvironment(t *testing.T) {
+	t.Run("Empty", testNewEnvironmentEmpty)
+	t.Run("ClientError", testNewEnvironmentClientError)
+	t.Run("Full", testNewEnvironmentFull)
+}
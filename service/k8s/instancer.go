@@ -0,0 +1,123 @@
+package k8s
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/sd"
+	"github.com/go-kit/kit/util/conn"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
+)
+
+const (
+	initialBackoff = 10 * time.Millisecond
+	maxBackoff     = 30 * time.Second
+)
+
+type InstancerOptions struct {
+	Client Client
+	Logger log.Logger
+	Watch  Watch
+}
+
+// NewInstancer creates an sd.Instancer which lists the ready endpoints of a Kubernetes service, then
+// watches the API server for changes.  Errors listing the endpoints are sent as sd.Events.
+func NewInstancer(o InstancerOptions) sd.Instancer {
+	if o.Logger == nil {
+		o.Logger = logging.DefaultLogger()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	i := &instancer{
+		client: o.Client,
+		logger: log.With(o.Logger, "service", o.Watch.Service, "namespace", o.Watch.Namespace, "endpointSlices", o.Watch.EndpointSlices),
+		watch:  o.Watch,
+		ctx:    ctx,
+		cancel: cancel,
+	}
+
+	// grab the initial set of instances
+	resourceVersion, err := i.list()
+	if err == nil {
+		i.logger.Log(level.Key(), level.InfoValue(), "instances", len(i.State().Instances))
+	} else {
+		i.logger.Log(level.Key(), level.ErrorValue(), logging.ErrorKey(), err)
+	}
+
+	go i.loop(resourceVersion, err)
+	return i
+}
+
+type instancer struct {
+	client Client
+	logger log.Logger
+	watch  Watch
+
+	ctx    context.Context
+	cancel func()
+
+	service.InstancerState
+}
+
+// list fetches the current endpoints and dispatches them, returning the resource version to watch from
+func (i *instancer) list() (string, error) {
+	e, err := i.client.Endpoints(i.ctx, i.watch)
+	if err != nil {
+		if i.ctx.Err() == nil {
+			i.Update(sd.Event{Err: err}, nil)
+		}
+
+		return "", err
+	}
+
+	sort.Strings(e.Instances)
+	i.Update(sd.Event{Instances: e.Instances}, e.Metadata)
+	return e.ResourceVersion, nil
+}
+
+// sleep waits for a backoff interval, returning false if the instancer was stopped in the meantime
+func (i *instancer) sleep(d time.Duration) bool {
+	select {
+	case <-i.ctx.Done():
+		return false
+	case <-time.After(d):
+		return true
+	}
+}
+
+func (i *instancer) loop(resourceVersion string, err error) {
+	d := initialBackoff
+	for {
+		if err == nil {
+			err = i.client.WaitForChange(i.ctx, i.watch, resourceVersion)
+		}
+
+		if i.ctx.Err() != nil {
+			return
+		}
+
+		if err != nil {
+			i.logger.Log(level.Key(), level.ErrorValue(), logging.ErrorKey(), err)
+			if !i.sleep(d) {
+				return
+			}
+
+			if d = conn.Exponential(d); d > maxBackoff {
+				d = maxBackoff
+			}
+		} else {
+			d = initialBackoff
+		}
+
+		resourceVersion, err = i.list()
+	}
+}
+
+// Stop halts the watch.  This method is idempotent.
+func (i *instancer) Stop() {
+	i.cancel()
+}
@@ -0,0 +1,132 @@
+package k8s
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/jithin-kg/webpa-common/service/servicetest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func testInstancerChange(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		client = new(mockClient)
+		watch  = Watch{Service: "talaria", Scheme: "http"}
+
+		initial = Endpoints{
+			Instances:       []string{"http://10.0.0.2", "http://10.0.0.1"},
+			Metadata:        map[string]service.InstanceMetadata{"http://10.0.0.1": {Zone: "east"}},
+			ResourceVersion: "1",
+		}
+
+		changed = Endpoints{
+			Instances:       []string{"http://10.0.0.3"},
+			ResourceVersion: "2",
+		}
+
+		block  = make(chan time.Time)
+		events = make(chan sd.Event, 1)
+	)
+
+	client.On("Endpoints", mock.Anything, watch).Return(initial, nil).Once()
+	client.On("WaitForChange", mock.Anything, watch, "1").Return(nil).Once()
+	client.On("Endpoints", mock.Anything, watch).Return(changed, nil).Once()
+	client.On("WaitForChange", mock.Anything, watch, "2").WaitUntil(block).Return(errors.New("expected")).Once()
+
+	i := NewInstancer(InstancerOptions{
+		Client: client,
+		Logger: logging.NewTestLogger(nil, t),
+		Watch:  watch,
+	})
+
+	defer close(block)
+	defer i.(*instancer).Stop()
+
+	i.Register(events)
+	e := servicetest.NextEvent(t, events)
+	if len(e.Instances) == 2 {
+		// the initial state was received before the watch reported a change
+		assert.Equal([]string{"http://10.0.0.1", "http://10.0.0.2"}, e.Instances)
+		assert.NoError(e.Err)
+		e = servicetest.NextEvent(t, events)
+	}
+
+	assert.Equal([]string{"http://10.0.0.3"}, e.Instances)
+	assert.NoError(e.Err)
+	assert.Empty(i.(service.MetadataInstancer).InstanceMetadata())
+
+	i.Deregister(events)
+}
+
+func testInstancerError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		client        = new(mockClient)
+		watch         = Watch{Service: "talaria"}
+		expectedError = errors.New("expected")
+
+		events = make(chan sd.Event, 1)
+	)
+
+	client.On("Endpoints", mock.Anything, watch).Return(Endpoints{}, expectedError)
+
+	i := NewInstancer(InstancerOptions{
+		Client: client,
+		Watch:  watch,
+	})
+
+	i.Register(events)
+	e := servicetest.NextEvent(t, events)
+	assert.Empty(e.Instances)
+	assert.Equal(expectedError, e.Err)
+
+	i.Deregister(events)
+
+	// Stop is idempotent
+	i.(*instancer).Stop()
+	i.(*instancer).Stop()
+	client.AssertNotCalled(t, "WaitForChange", mock.Anything, mock.Anything, mock.Anything)
+}
+
+func testInstancerMetadata(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		client   = new(mockClient)
+		watch    = Watch{Service: "talaria", EndpointSlices: true}
+		metadata = map[string]service.InstanceMetadata{"https://10.0.0.1": {Zone: "east"}}
+
+		block = make(chan time.Time)
+	)
+
+	client.On("Endpoints", mock.Anything, watch).Return(Endpoints{Instances: []string{"https://10.0.0.1"}, Metadata: metadata, ResourceVersion: "1"}, nil).Once()
+	client.On("WaitForChange", mock.Anything, watch, "1").WaitUntil(block).Return(nil).Maybe()
+
+	i := NewInstancer(InstancerOptions{
+		Client: client,
+		Watch:  watch,
+	})
+
+	defer close(block)
+	defer i.(*instancer).Stop()
+
+	assert.Equal(metadata, service.GetInstanceMetadata(i))
+
+	// the returned metadata is a copy
+	service.GetInstanceMetadata(i)["https://10.0.0.2"] = service.InstanceMetadata{}
+	assert.Equal(metadata, service.GetInstanceMetadata(i))
+}
+
+func TestInstancer(t *testing.T) {
+	t.Run("Change", testInstancerChange)
+	t.Run("Error", testInstancerError)
+	t.Run("Metadata", testInstancerMetadata)
+}
@@ -0,0 +1,29 @@
+package k8s
+
+import (
+	"context"
+
+	"github.com/stretchr/testify/mock"
+)
+
+// resetClientFactory resets the global singleton factory function
+// to its original value.  This function is handy as a defer for tests.
+func resetClientFactory() {
+	clientFactory = NewClient
+}
+
+type mockClient struct {
+	mock.Mock
+}
+
+var _ Client = (*mockClient)(nil)
+
+func (m *mockClient) Endpoints(ctx context.Context, w Watch) (Endpoints, error) {
+	arguments := m.Called(ctx, w)
+	return arguments.Get(0).(Endpoints), arguments.Error(1)
+}
+
+func (m *mockClient) WaitForChange(ctx context.Context, w Watch, resourceVersion string) error {
+	arguments := m.Called(ctx, w, resourceVersion)
+	return arguments.Error(0)
+}
@@ -0,0 +1,175 @@
+package k8s
+
+import (
+	"io/ioutil"
+	"strings"
+	"time"
+
+	"github.com/jithin-kg/webpa-common/service"
+)
+
+const (
+	// DefaultServer is the in-cluster address of the Kubernetes API server
+	DefaultServer = "https://kubernetes.default.svc"
+
+	DefaultTokenFile     = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+	DefaultCAFile        = "/var/run/secrets/kubernetes.io/serviceaccount/ca.crt"
+	DefaultNamespaceFile = "/var/run/secrets/kubernetes.io/serviceaccount/namespace"
+	DefaultNamespace     = "default"
+
+	// DefaultWatchTimeout is the longest a single watch request is held open.  When it expires,
+	// the instances are listed again, which also serves as a periodic resync.
+	DefaultWatchTimeout = 5 * time.Minute
+
+	// DefaultRequestTimeout is the longest a request to list instances may take.  Watch requests are
+	// allowed this long in addition to their WatchTimeout.
+	DefaultRequestTimeout = 30 * time.Second
+
+	// DefaultAddressType is the address family of the EndpointSlices that are watched by default
+	DefaultAddressType = "IPv4"
+)
+
+// Watch describes a Kubernetes service whose ready endpoints are discovered
+type Watch struct {
+	// Namespace is the namespace of the service.  If not supplied, the Options namespace is used.
+	Namespace string `json:"namespace,omitempty"`
+
+	// Service is the name of the Kubernetes service.  This field is required.
+	Service string `json:"service"`
+
+	// PortName selects the named endpoint port.  If not supplied, the first port is used.
+	PortName string `json:"portName,omitempty"`
+
+	// Scheme is the URI scheme of the discovered instances.  If not supplied, the environment's
+	// default scheme is used.
+	Scheme string `json:"scheme,omitempty"`
+
+	// EndpointSlices watches the discovery.k8s.io/v1 EndpointSlices of the service rather than its
+	// core/v1 Endpoints.  EndpointSlices scale to large services and carry each endpoint's zone.
+	EndpointSlices bool `json:"endpointSlices"`
+
+	// AddressType selects the EndpointSlices of a single address family, either IPv4 or IPv6, so that dual-stack
+	// endpoints are not discovered twice.  If not supplied, DefaultAddressType is used.  Endpoints resources
+	// hold only the cluster's primary address family, so this field applies only to EndpointSlices.
+	AddressType string `json:"addressType,omitempty"`
+}
+
+func (w Watch) scheme() string {
+	if len(w.Scheme) > 0 {
+		return w.Scheme
+	}
+
+	return service.DefaultScheme
+}
+
+func (w Watch) addressType() string {
+	if len(w.AddressType) > 0 {
+		return w.AddressType
+	}
+
+	return DefaultAddressType
+}
+
+// Options represents the set of configurable attributes for Kubernetes service discovery
+type Options struct {
+	// Server is the URL of the API server.  If not supplied, DefaultServer is used.
+	Server string `json:"server,omitempty"`
+
+	// TokenFile holds the bearer token used to authenticate to the API server.  It is reread for each
+	// request so that rotated tokens are honored.  If not supplied, DefaultTokenFile is used.  If the
+	// file does not exist, requests are not authenticated.
+	TokenFile string `json:"tokenFile,omitempty"`
+
+	// CAFile holds the certificate authority of the API server.  If not supplied, DefaultCAFile is used
+	// if it exists, otherwise the system roots are used.
+	CAFile string `json:"caFile,omitempty"`
+
+	// Namespace is the default namespace for watches.  If not supplied, the namespace of the pod's
+	// service account is used, or DefaultNamespace outside of a pod.
+	Namespace string `json:"namespace,omitempty"`
+
+	// WatchTimeout is the longest a watch request is held open.  If not supplied, DefaultWatchTimeout is used.
+	WatchTimeout time.Duration `json:"watchTimeout,omitempty"`
+
+	// RequestTimeout is the longest a request to list instances may take, and the slack allowed a watch
+	// beyond its WatchTimeout, so that an unresponsive API server cannot stall discovery.  If not supplied,
+	// DefaultRequestTimeout is used.
+	RequestTimeout time.Duration `json:"requestTimeout,omitempty"`
+
+	// Registrations are the instances this process advertises.  Kubernetes publishes endpoints based on
+	// readiness probes, so registration is a no-op; these are used only to identify this process.
+	Registrations []string `json:"registrations,omitempty"`
+
+	// Watches are the services to discover.  There is no default for this field.
+	Watches []Watch `json:"watches,omitempty"`
+}
+
+func (o *Options) server() string {
+	if o != nil && len(o.Server) > 0 {
+		return strings.TrimSuffix(o.Server, "/")
+	}
+
+	return DefaultServer
+}
+
+func (o *Options) tokenFile() string {
+	if o != nil && len(o.TokenFile) > 0 {
+		return o.TokenFile
+	}
+
+	return DefaultTokenFile
+}
+
+func (o *Options) caFile() string {
+	if o != nil && len(o.CAFile) > 0 {
+		return o.CAFile
+	}
+
+	return ""
+}
+
+func (o *Options) namespace() string {
+	if o != nil && len(o.Namespace) > 0 {
+		return o.Namespace
+	}
+
+	if data, err := ioutil.ReadFile(DefaultNamespaceFile); err == nil {
+		if namespace := strings.TrimSpace(string(data)); len(namespace) > 0 {
+			return namespace
+		}
+	}
+
+	return DefaultNamespace
+}
+
+func (o *Options) watchTimeout() time.Duration {
+	if o != nil && o.WatchTimeout > 0 {
+		return o.WatchTimeout
+	}
+
+	return DefaultWatchTimeout
+}
+
+func (o *Options) requestTimeout() time.Duration {
+	if o != nil && o.RequestTimeout > 0 {
+		return o.RequestTimeout
+	}
+
+	return DefaultRequestTimeout
+}
+
+func (o *Options) registrations() []string {
+	if o != nil && len(o.Registrations) > 0 {
+		return o.Registrations
+	}
+
+	return nil
+}
+
+func (o *Options) watches() []Watch {
+	if o != nil && len(o.Watches) > 0 {
+		return o.Watches
+	}
+
+	return nil
+}
@@ -0,0 +1,63 @@
+package k8s
+
+import (
+	"testing"
+
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/stretchr/testify/assert"
+)
+
+func testOptionsDefault(t *testing.T, o *Options) {
+	assert := assert.New(t)
+
+	assert.Equal(DefaultServer, o.server())
+	assert.Equal(DefaultTokenFile, o.tokenFile())
+	assert.Empty(o.caFile())
+	assert.NotEmpty(o.namespace())
+	assert.Equal(DefaultWatchTimeout, o.watchTimeout())
+	assert.Equal(DefaultRequestTimeout, o.requestTimeout())
+	assert.Empty(o.registrations())
+	assert.Empty(o.watches())
+}
+
+func testOptionsCustom(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		o = Options{
+			Server:         "https://k8s.example.com/",
+			TokenFile:      "/etc/token",
+			CAFile:         "/etc/ca.crt",
+			Namespace:      "xmidt",
+			WatchTimeout:   DefaultWatchTimeout / 2,
+			RequestTimeout: DefaultRequestTimeout / 2,
+			Registrations:  []string{"talaria-0.talaria:8080"},
+			Watches:        []Watch{{Service: "talaria"}},
+		}
+	)
+
+	assert.Equal("https://k8s.example.com", o.server())
+	assert.Equal("/etc/token", o.tokenFile())
+	assert.Equal("/etc/ca.crt", o.caFile())
+	assert.Equal("xmidt", o.namespace())
+	assert.Equal(DefaultWatchTimeout/2, o.watchTimeout())
+	assert.Equal(DefaultRequestTimeout/2, o.requestTimeout())
+	assert.Equal([]string{"talaria-0.talaria:8080"}, o.registrations())
+	assert.Equal([]Watch{{Service: "talaria"}}, o.watches())
+}
+
+func TestOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		testOptionsDefault(t, nil)
+		testOptionsDefault(t, new(Options))
+	})
+
+	t.Run("Custom", testOptionsCustom)
+}
+
+func TestWatchScheme(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(service.DefaultScheme, Watch{}.scheme())
+	assert.Equal("https", Watch{Scheme: "https"}.scheme())
+}
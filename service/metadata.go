@@ -0,0 +1,166 @@
+package service
+
+import (
+	"sort"
+
+	"github.com/go-kit/kit/sd"
+)
+
+// InstanceMetadata describes where a discovered instance runs and how much capacity it has.
+// Service discovery backends populate whatever they know; the zero value means nothing is known.
+type InstanceMetadata struct {
+	// Datacenter is the datacenter the instance runs in
+	Datacenter string `json:"datacenter,omitempty"`
+
+	// Zone is the availability zone, or rack, within the datacenter
+	Zone string `json:"zone,omitempty"`
+
+	// Weight is the relative capacity of the instance.  A nonpositive weight is treated as 1.
+	Weight int `json:"weight,omitempty"`
+
+	// Tags are the tags the instance was registered with
+	Tags []string `json:"tags,omitempty"`
+
+	// Meta is any other key/value metadata the instance was registered with
+	Meta map[string]string `json:"meta,omitempty"`
+}
+
+// MetadataInstancer is implemented by sd.Instancer objects that know the metadata of the
+// instances they discover
+type MetadataInstancer interface {
+	sd.Instancer
+
+	// InstanceMetadata returns the metadata of the current instances, keyed by the instance strings
+	// sent in sd.Events.  Instances with no known metadata may be omitted.
+	InstanceMetadata() map[string]InstanceMetadata
+}
+
+// GetInstanceMetadata returns the current instance metadata of an sd.Instancer, or nil if the
+// sd.Instancer does not supply metadata
+func GetInstanceMetadata(i sd.Instancer) map[string]InstanceMetadata {
+	if mi, ok := i.(MetadataInstancer); ok {
+		return mi.InstanceMetadata()
+	}
+
+	return nil
+}
+
+func (ci contextualInstancer) InstanceMetadata() map[string]InstanceMetadata {
+	return GetInstanceMetadata(ci.Instancer)
+}
+
+// MetadataAccessorFactory is an AccessorFactory that can also use the metadata of the instances,
+// such as zones and weights.  The metadata map may be nil or incomplete.
+type MetadataAccessorFactory func([]string, map[string]InstanceMetadata) Accessor
+
+// NewMetadataAccessorFactory produces a MetadataAccessorFactory that places keys with the configured
+// hashing algorithm, weighting each instance by its metadata.  Weights configured in the options
+// take precedence over those in the metadata.
+//
+// If a Zone or Datacenter is configured, only instances in the same zone, or else the same
+// datacenter, are used provided that they hold at least the SpilloverThreshold fraction of the
+// total weight.  Otherwise, keys spill over to the next, wider set of instances.
+func NewMetadataAccessorFactory(o *AccessorOptions) (MetadataAccessorFactory, error) {
+	build, err := newAlgorithm(o)
+	if err != nil {
+		return nil, err
+	}
+
+	var (
+		configured = o.weights()
+		zone       = o.zone()
+		datacenter = o.datacenter()
+		threshold  = o.spilloverThreshold()
+	)
+
+	return func(instances []string, metadata map[string]InstanceMetadata) Accessor {
+		weights := make(map[string]int, len(instances))
+		for _, i := range instances {
+			if w, ok := configured[i]; ok {
+				weights[i] = w
+			} else if w := metadata[i].Weight; w > 0 {
+				weights[i] = w
+			}
+		}
+
+		var tiers [][]string
+		if len(zone) > 0 {
+			tiers = append(tiers, selectInstances(instances, func(m InstanceMetadata) bool {
+				return m.Zone == zone && (len(datacenter) == 0 || m.Datacenter == datacenter)
+			}, metadata))
+		}
+
+		if len(datacenter) > 0 {
+			tiers = append(tiers, selectInstances(instances, func(m InstanceMetadata) bool {
+				return m.Datacenter == datacenter
+			}, metadata))
+		}
+
+		total := totalWeight(weights, instances)
+		for _, tier := range tiers {
+			if len(tier) > 0 && float64(totalWeight(weights, tier)) >= threshold*float64(total) {
+				return build(weights, tier)
+			}
+		}
+
+		return build(weights, instances)
+	}, nil
+}
+
+// selectInstances returns the instances whose metadata satisfies a predicate
+func selectInstances(instances []string, p func(InstanceMetadata) bool, metadata map[string]InstanceMetadata) []string {
+	var selected []string
+	for _, i := range instances {
+		if p(metadata[i]) {
+			selected = append(selected, i)
+		}
+	}
+
+	return selected
+}
+
+func totalWeight(weights map[string]int, instances []string) int {
+	total := 0
+	for _, i := range uniqueInstances(instances) {
+		total += weightOf(weights, i)
+	}
+
+	return total
+}
+
+// zoneOrder is an AccessorQueue that orders failover keys by proximity
+type zoneOrder struct {
+	rank map[string]int
+}
+
+// NewZoneOrder produces an AccessorQueue for a LayeredAccessor which tries failover accessors
+// in the given order of preference, typically nearest zone or datacenter first.  Keys that are
+// not listed are tried afterward, in lexical order, so that failover is deterministic.
+func NewZoneOrder(preferred ...string) AccessorQueue {
+	zo := zoneOrder{rank: make(map[string]int, len(preferred))}
+	for i, p := range preferred {
+		if _, ok := zo.rank[p]; !ok {
+			zo.rank[p] = i
+		}
+	}
+
+	return zo
+}
+
+func (zo zoneOrder) Order(keys []string) []string {
+	ordered := append([]string{}, keys...)
+	sort.SliceStable(ordered, func(i, j int) bool {
+		ri, iRanked := zo.rank[ordered[i]]
+		rj, jRanked := zo.rank[ordered[j]]
+		switch {
+		case iRanked && jRanked:
+			return ri < rj
+		case iRanked != jRanked:
+			return iRanked
+		default:
+			return ordered[i] < ordered[j]
+		}
+	})
+
+	return ordered
+}
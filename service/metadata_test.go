@@ -0,0 +1,168 @@
+package service
+
+import (
+	"testing"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testMetadataInstancer is a MetadataInstancer with fixed metadata
+type testMetadataInstancer struct {
+	sd.Instancer
+	metadata map[string]InstanceMetadata
+}
+
+func (tmi testMetadataInstancer) InstanceMetadata() map[string]InstanceMetadata {
+	return tmi.metadata
+}
+
+func TestGetInstanceMetadata(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		metadata = map[string]InstanceMetadata{"instance1": {Zone: "east"}}
+		plain    = new(MockInstancer)
+		enriched = testMetadataInstancer{plain, metadata}
+	)
+
+	assert.Nil(GetInstanceMetadata(plain))
+	assert.Equal(metadata, GetInstanceMetadata(enriched))
+
+	// contextual instancers expose the metadata of the instancer they decorate
+	assert.Nil(GetInstanceMetadata(NewContextualInstancer(plain, map[string]interface{}{"key": "value"})))
+	assert.Equal(metadata, GetInstanceMetadata(NewContextualInstancer(enriched, map[string]interface{}{"key": "value"})))
+}
+
+// placements returns the instances chosen for a set of keys
+func placements(t *testing.T, a Accessor, keys [][]byte) map[string]int {
+	counts := make(map[string]int)
+	for _, k := range keys {
+		i, err := a.Get(k)
+		require.NoError(t, err)
+		counts[i]++
+	}
+
+	return counts
+}
+
+func TestNewMetadataAccessorFactory(t *testing.T) {
+	var (
+		keys = testKeys(10000)
+
+		instances = []string{"east1", "east2", "west1", "west2", "west3", "remote1"}
+		metadata  = map[string]InstanceMetadata{
+			"east1":   {Datacenter: "dc1", Zone: "east", Weight: 3},
+			"east2":   {Datacenter: "dc1", Zone: "east"},
+			"west1":   {Datacenter: "dc1", Zone: "west"},
+			"west2":   {Datacenter: "dc1", Zone: "west"},
+			"west3":   {Datacenter: "dc1", Zone: "west"},
+			"remote1": {Datacenter: "dc2", Zone: "east"},
+		}
+	)
+
+	t.Run("Invalid", func(t *testing.T) {
+		maf, err := NewMetadataAccessorFactory(&AccessorOptions{Algorithm: "nosuch"})
+		assert.Nil(t, maf)
+		assert.Error(t, err)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		maf, err := NewMetadataAccessorFactory(nil)
+		require.NoError(t, err)
+
+		_, err = maf(nil, nil).Get([]byte("test"))
+		assert.Error(t, err)
+	})
+
+	t.Run("NoZone", func(t *testing.T) {
+		maf, err := NewMetadataAccessorFactory(&AccessorOptions{Algorithm: RendezvousHashing})
+		require.NoError(t, err)
+
+		counts := placements(t, maf(instances, metadata), keys)
+		assert.Len(t, counts, len(instances))
+
+		// the metadata weight gives east1 three eighths of the keys
+		assert.InDelta(t, 0.375, float64(counts["east1"])/float64(len(keys)), 0.05)
+	})
+
+	t.Run("ConfiguredWeights", func(t *testing.T) {
+		maf, err := NewMetadataAccessorFactory(&AccessorOptions{Algorithm: RendezvousHashing, Weights: map[string]int{"east1": 1}})
+		require.NoError(t, err)
+
+		counts := placements(t, maf(instances, metadata), keys)
+		assert.InDelta(t, 1.0/6.0, float64(counts["east1"])/float64(len(keys)), 0.05)
+	})
+
+	t.Run("SameZone", func(t *testing.T) {
+		maf, err := NewMetadataAccessorFactory(&AccessorOptions{Zone: "east", Datacenter: "dc1"})
+		require.NoError(t, err)
+
+		counts := placements(t, maf(instances, metadata), keys)
+		assert.Len(t, counts, 2)
+		assert.True(t, counts["east1"] > counts["east2"])
+	})
+
+	t.Run("ZoneAcrossDatacenters", func(t *testing.T) {
+		maf, err := NewMetadataAccessorFactory(&AccessorOptions{Zone: "east"})
+		require.NoError(t, err)
+
+		counts := placements(t, maf(instances, metadata), keys)
+		assert.Len(t, counts, 3)
+		assert.Contains(t, counts, "remote1")
+	})
+
+	t.Run("SpillToDatacenter", func(t *testing.T) {
+		// the east zone holds 4 of 8 units of weight, which is below the threshold
+		maf, err := NewMetadataAccessorFactory(&AccessorOptions{Zone: "east", Datacenter: "dc1", SpilloverThreshold: 0.6})
+		require.NoError(t, err)
+
+		counts := placements(t, maf(instances, metadata), keys)
+		assert.Len(t, counts, 5)
+		assert.NotContains(t, counts, "remote1")
+	})
+
+	t.Run("SpillToAll", func(t *testing.T) {
+		maf, err := NewMetadataAccessorFactory(&AccessorOptions{Zone: "north", Datacenter: "dc3"})
+		require.NoError(t, err)
+
+		counts := placements(t, maf(instances, metadata), keys)
+		assert.Len(t, counts, len(instances))
+	})
+
+	t.Run("NoMetadata", func(t *testing.T) {
+		maf, err := NewMetadataAccessorFactory(&AccessorOptions{Zone: "east"})
+		require.NoError(t, err)
+
+		moved, err := Movement(DefaultAccessorFactory(instances), maf(instances, nil), keys)
+		require.NoError(t, err)
+		assert.Zero(t, moved)
+	})
+}
+
+func TestNewZoneOrder(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal([]string{"a", "b", "c"}, NewZoneOrder().Order([]string{"c", "a", "b"}))
+	assert.Equal([]string{"west", "east", "a", "z"}, NewZoneOrder("west", "north", "east", "west").Order([]string{"z", "east", "a", "west"}))
+}
+
+func TestLayeredAccessorZoneOrder(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		la     = NewLayeredAccesor(DefaultTrafficRouter(), NewZoneOrder("near", "far"))
+	)
+
+	la.SetError(errNoInstances)
+	la.UpdateFailOver("far", MapAccessor{"key": "far-instance"}, nil)
+	la.UpdateFailOver("near", MapAccessor{"key": "near-instance"}, nil)
+	la.UpdateFailOver("other", MapAccessor{"key": "other-instance"}, nil)
+
+	// failover always tries the nearest zone first, rather than in map order
+	for i := 0; i < 20; i++ {
+		instance, err := la.Get([]byte("key"))
+		assert.Equal("near-instance", instance)
+		assert.Error(err)
+	}
+}
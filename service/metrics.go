@@ -10,8 +10,19 @@ const (
 	InstanceCount       = "sd_instance_count"
 	LastErrorTimestamp  = "sd_last_error_timestamp"
 	LastUpdateTimestamp = "sd_last_update_timestamp"
+	SnapshotAge         = "sd_snapshot_age_seconds"
+	InstanceSource      = "sd_instance_source"
+	FailoverActive      = "sd_failover_active"
+	FailoverCount       = "sd_failover_count"
+	DatacenterAvailable = "sd_datacenter_available"
 
-	ServiceLabel = "service"
+	ServiceLabel    = "service"
+	SourceLabel     = "source"
+	DatacenterLabel = "datacenter"
+
+	// BackendSource and SnapshotSource are the values of SourceLabel
+	BackendSource  = "backend"
+	SnapshotSource = "snapshot"
 )
 
 // Metrics is the service discovery module function for metrics
@@ -47,5 +58,35 @@ func Metrics() []xmetrics.Metric {
 			Help:       "The last time the service discovery backend sent updated instances for a given service",
 			LabelNames: []string{ServiceLabel},
 		},
+		{
+			Name:       SnapshotAge,
+			Type:       "gauge",
+			Help:       "The age of the stale snapshot served for a given service, or zero when the instances are current",
+			LabelNames: []string{ServiceLabel},
+		},
+		{
+			Name:       InstanceSource,
+			Type:       "gauge",
+			Help:       "Set to 1 for the source, either the backend or a snapshot, of the instances currently served for a given service",
+			LabelNames: []string{ServiceLabel, SourceLabel},
+		},
+		{
+			Name:       FailoverActive,
+			Type:       "gauge",
+			Help:       "Set to 1 while traffic for a given service is failing over from the local datacenter",
+			LabelNames: []string{ServiceLabel},
+		},
+		{
+			Name:       FailoverCount,
+			Type:       "counter",
+			Help:       "The total count of times traffic for a given service failed over from the local datacenter",
+			LabelNames: []string{ServiceLabel},
+		},
+		{
+			Name:       DatacenterAvailable,
+			Type:       "gauge",
+			Help:       "Set to 1 when a datacenter has enough healthy instances of a given service to receive traffic",
+			LabelNames: []string{ServiceLabel, DatacenterLabel},
+		},
 	}
 }
@@ -24,4 +24,6 @@ func TestMetrics(t *testing.T) {
 	assert.NotNil(r.NewGauge(InstanceCount))
 	assert.NotNil(r.NewGauge(LastErrorTimestamp))
 	assert.NotNil(r.NewGauge(LastUpdateTimestamp))
+	assert.NotNil(r.NewGauge(SnapshotAge))
+	assert.NotNil(r.NewGauge(InstanceSource))
 }
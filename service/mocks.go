@@ -86,6 +86,10 @@ func (m *MockEnvironment) AccessorFactory() AccessorFactory {
 	return m.Called().Get(0).(AccessorFactory)
 }
 
+func (m *MockEnvironment) MetadataAccessorFactory() MetadataAccessorFactory {
+	return m.Called().Get(0).(MetadataAccessorFactory)
+}
+
 func (m *MockEnvironment) Closed() <-chan struct{} {
 	return m.Called().Get(0).(<-chan struct{})
 }
@@ -54,6 +54,10 @@ func TestMockEnvironment(t *testing.T) {
 			accessorFactoryCalled = true
 			return EmptyAccessor()
 		})
+
+		metadataAccessorFactory = MetadataAccessorFactory(func([]string, map[string]InstanceMetadata) Accessor {
+			return EmptyAccessor()
+		})
 	)
 
 	e.On("Register").Once()
@@ -64,6 +68,7 @@ func TestMockEnvironment(t *testing.T) {
 	e.On("DefaultScheme").Return(defaultScheme).Once()
 	e.On("Instancers").Return(instancers).Once()
 	e.On("AccessorFactory").Return(accessorFactory).Once()
+	e.On("MetadataAccessorFactory").Return(metadataAccessorFactory).Once()
 	e.On("Closed").Return((<-chan struct{})(closed))
 
 	e.Register()
@@ -78,6 +83,7 @@ func TestMockEnvironment(t *testing.T) {
 	require.NotNil(af)
 	assert.Equal(EmptyAccessor(), af([]string{}))
 	assert.True(accessorFactoryCalled)
+	assert.NotNil(e.MetadataAccessorFactory())
 
 	assert.Equal((<-chan struct{})(closed), e.Closed())
 
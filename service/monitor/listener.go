@@ -30,9 +30,20 @@ type Event struct {
 	// Err will be nil.
 	Instances []string
 
+	// Metadata describes the Instances, keyed by the same filtered instance strings.  It is nil if the
+	// sd.Instancer does not supply metadata, and may not describe every instance.
+	Metadata map[string]service.InstanceMetadata
+
 	// Err is any service discovery error that occurred.  If this is set, Instances will be empty.
 	Err error
 
+	// Stale is set to true when the service discovery backend reported an error, and the Instances were
+	// instead taken from the last known good snapshot.  See WithSnapshots.
+	Stale bool
+
+	// SnapshotTime is when the Instances of a stale event were last received from the service discovery backend
+	SnapshotTime time.Time
+
 	// Stopped is set to true if and only if this event is being sent to indicate the monitoring goroutine
 	// has exited, either because of being explicitly stopped or because the environment was closed.
 	Stopped bool
@@ -64,12 +75,16 @@ func NewMetricsListener(p provider.Provider) Listener {
 		updateCount   = p.NewCounter(service.UpdateCount)
 		lastUpdate    = p.NewGauge(service.LastUpdateTimestamp)
 		instanceCount = p.NewGauge(service.InstanceCount)
+		snapshotAge   = p.NewGauge(service.SnapshotAge)
+		source        = p.NewGauge(service.InstanceSource)
 	)
 
 	return ListenerFunc(func(e Event) {
-		timestamp := float64(time.Now().Unix())
+		now := time.Now()
+		timestamp := float64(now.Unix())
 
-		if e.Err != nil {
+		// a stale event is the result of a service discovery error
+		if e.Err != nil || e.Stale {
 			errorCount.With(service.ServiceLabel, e.Key).Add(1.0)
 			lastError.With(service.ServiceLabel, e.Key).Set(timestamp)
 		} else {
@@ -77,6 +92,16 @@ func NewMetricsListener(p provider.Provider) Listener {
 			lastUpdate.With(service.ServiceLabel, e.Key).Set(timestamp)
 		}
 
+		if e.Stale {
+			snapshotAge.With(service.ServiceLabel, e.Key).Set(now.Sub(e.SnapshotTime).Seconds())
+			source.With(service.ServiceLabel, e.Key, service.SourceLabel, service.BackendSource).Set(0.0)
+			source.With(service.ServiceLabel, e.Key, service.SourceLabel, service.SnapshotSource).Set(1.0)
+		} else {
+			snapshotAge.With(service.ServiceLabel, e.Key).Set(0.0)
+			source.With(service.ServiceLabel, e.Key, service.SourceLabel, service.BackendSource).Set(1.0)
+			source.With(service.ServiceLabel, e.Key, service.SourceLabel, service.SnapshotSource).Set(0.0)
+		}
+
 		instanceCount.With(service.ServiceLabel, e.Key).Set(float64(len(e.Instances)))
 	})
 }
@@ -111,6 +136,34 @@ func NewAccessorListener(f service.AccessorFactory, next func(service.Accessor,
 	})
 }
 
+// NewMetadataAccessorListener is like NewAccessorListener, except that the Accessors are created from both
+// the instances and their metadata.  If the MetadataAccessorFactory is nil, DefaultAccessorFactory is used
+// and metadata is ignored.
+func NewMetadataAccessorListener(f service.MetadataAccessorFactory, next func(service.Accessor, error)) Listener {
+	if next == nil {
+		panic("A next closure is required to receive Accessors")
+	}
+
+	if f == nil {
+		f = func(instances []string, _ map[string]service.InstanceMetadata) service.Accessor {
+			return service.DefaultAccessorFactory(instances)
+		}
+	}
+
+	return ListenerFunc(func(e Event) {
+		switch {
+		case e.Err != nil:
+			next(nil, e.Err)
+
+		case len(e.Instances) > 0:
+			next(f(e.Instances, e.Metadata), nil)
+
+		default:
+			next(service.EmptyAccessor(), nil)
+		}
+	})
+}
+
 func NewKeyAccessorListener(f service.AccessorFactory, key string, next func(string, service.Accessor, error)) Listener {
 	if next == nil {
 		panic("A next closure is required to receive Accessors")
@@ -80,7 +80,10 @@ func testNewMetricsListenerUpdate(t *testing.T) {
 			Expect(service.LastUpdateTimestamp, service.ServiceLabel, "test")(xmetricstest.Minimum(now)).
 			Expect(service.ErrorCount, service.ServiceLabel, "test")(xmetricstest.Value(0.0)).
 			Expect(service.LastErrorTimestamp, service.ServiceLabel, "test")(xmetricstest.Value(0.0)).
-			Expect(service.InstanceCount, service.ServiceLabel, "test")(xmetricstest.Value(2.0))
+			Expect(service.InstanceCount, service.ServiceLabel, "test")(xmetricstest.Value(2.0)).
+			Expect(service.SnapshotAge, service.ServiceLabel, "test")(xmetricstest.Value(0.0)).
+			Expect(service.InstanceSource, service.ServiceLabel, "test", service.SourceLabel, service.BackendSource)(xmetricstest.Value(1.0)).
+			Expect(service.InstanceSource, service.ServiceLabel, "test", service.SourceLabel, service.SnapshotSource)(xmetricstest.Value(0.0))
 		l = NewMetricsListener(p)
 	)
 
@@ -88,6 +91,25 @@ func testNewMetricsListenerUpdate(t *testing.T) {
 	p.AssertExpectations(t)
 }
 
+func testNewMetricsListenerStale(t *testing.T) {
+	var (
+		now = float64(time.Now().Unix())
+
+		p = xmetricstest.NewProvider(nil, service.Metrics).
+			Expect(service.UpdateCount, service.ServiceLabel, "test")(xmetricstest.Value(0.0)).
+			Expect(service.ErrorCount, service.ServiceLabel, "test")(xmetricstest.Value(1.0)).
+			Expect(service.LastErrorTimestamp, service.ServiceLabel, "test")(xmetricstest.Minimum(now)).
+			Expect(service.InstanceCount, service.ServiceLabel, "test")(xmetricstest.Value(1.0)).
+			Expect(service.SnapshotAge, service.ServiceLabel, "test")(xmetricstest.Minimum(60.0)).
+			Expect(service.InstanceSource, service.ServiceLabel, "test", service.SourceLabel, service.BackendSource)(xmetricstest.Value(0.0)).
+			Expect(service.InstanceSource, service.ServiceLabel, "test", service.SourceLabel, service.SnapshotSource)(xmetricstest.Value(1.0))
+		l = NewMetricsListener(p)
+	)
+
+	l.MonitorEvent(Event{Key: "test", Instances: []string{"instance1"}, Stale: true, SnapshotTime: time.Now().Add(-time.Minute)})
+	p.AssertExpectations(t)
+}
+
 func testNewMetricsListenerError(t *testing.T) {
 	var (
 		now = float64(time.Now().Unix())
@@ -108,6 +130,7 @@ func testNewMetricsListenerError(t *testing.T) {
 func TestNewMetricsListener(t *testing.T) {
 	t.Run("Update", testNewMetricsListenerUpdate)
 	t.Run("Error", testNewMetricsListenerError)
+	t.Run("Stale", testNewMetricsListenerStale)
 }
 
 func testNewAccessorListenerMissingNext(t *testing.T) {
@@ -219,6 +242,101 @@ func TestNewAccessorListener(t *testing.T) {
 	})
 }
 
+func TestNewMetadataAccessorListener(t *testing.T) {
+	t.Run("MissingNext", func(t *testing.T) {
+		assert.Panics(t, func() {
+			NewMetadataAccessorListener(nil, nil)
+		})
+	})
+
+	t.Run("Error", func(t *testing.T) {
+		var (
+			assert        = assert.New(t)
+			expectedError = errors.New("expected")
+			nextCalled    = false
+		)
+
+		l := NewMetadataAccessorListener(nil, func(a service.Accessor, err error) {
+			nextCalled = true
+			assert.Nil(a)
+			assert.Equal(expectedError, err)
+		})
+
+		l.MonitorEvent(Event{Err: expectedError})
+		assert.True(nextCalled)
+	})
+
+	t.Run("DefaultAccessorFactory", func(t *testing.T) {
+		var (
+			assert     = assert.New(t)
+			require    = require.New(t)
+			nextCalled = false
+		)
+
+		l := NewMetadataAccessorListener(nil, func(a service.Accessor, err error) {
+			nextCalled = true
+			require.NotNil(a)
+			assert.NoError(err)
+
+			i, err := a.Get([]byte("asdfasdfasdfsdf"))
+			assert.Equal("instance1", i)
+			assert.NoError(err)
+		})
+
+		l.MonitorEvent(Event{Instances: []string{"instance1"}})
+		assert.True(nextCalled)
+	})
+
+	t.Run("Metadata", func(t *testing.T) {
+		var (
+			assert     = assert.New(t)
+			require    = require.New(t)
+			nextCalled = false
+		)
+
+		f, err := service.NewMetadataAccessorFactory(&service.AccessorOptions{Zone: "east"})
+		require.NoError(err)
+
+		l := NewMetadataAccessorListener(f, func(a service.Accessor, err error) {
+			nextCalled = true
+			require.NotNil(a)
+			assert.NoError(err)
+
+			for _, key := range []string{"a", "b", "c", "d", "e"} {
+				i, err := a.Get([]byte(key))
+				assert.Equal("instance2", i)
+				assert.NoError(err)
+			}
+		})
+
+		l.MonitorEvent(Event{
+			Instances: []string{"instance1", "instance2"},
+			Metadata: map[string]service.InstanceMetadata{
+				"instance1": {Zone: "west"},
+				"instance2": {Zone: "east"},
+			},
+		})
+
+		assert.True(nextCalled)
+	})
+
+	t.Run("Empty", func(t *testing.T) {
+		var (
+			assert     = assert.New(t)
+			nextCalled = false
+		)
+
+		l := NewMetadataAccessorListener(nil, func(a service.Accessor, err error) {
+			nextCalled = true
+			assert.Equal(service.EmptyAccessor(), a)
+			assert.NoError(err)
+		})
+
+		l.MonitorEvent(Event{})
+		assert.True(nextCalled)
+	})
+}
+
 func testNewRegistrarListenerNilRegistrar(t *testing.T) {
 	var (
 		assert = assert.New(t)
@@ -3,6 +3,7 @@ package monitor
 import (
 	"errors"
 	"sync"
+	"time"
 
 	"github.com/go-kit/kit/log"
 	"github.com/go-kit/kit/log/level"
@@ -89,6 +90,23 @@ func WithEnvironment(e service.Environment) Option {
 	}
 }
 
+// WithSnapshots configures a store for the last known good instances of each sd.Instancer.  Every successful
+// update is saved to the store.  When the service discovery backend reports an error, including at startup, the
+// saved instances are dispatched instead as a stale Event, provided the outage has lasted no longer than maxStaleness.
+// A snapshot loaded at startup is treated as if the outage began when it was saved.  Once the outage exceeds
+// maxStaleness, the error is dispatched.  If maxStaleness is nonpositive, DefaultMaxStaleness is used.
+// A nil store disables snapshots, which is the default.
+func WithSnapshots(s SnapshotStore, maxStaleness time.Duration) Option {
+	return func(m *monitor) {
+		m.snapshots = s
+		if maxStaleness > 0 {
+			m.maxStaleness = maxStaleness
+		} else {
+			m.maxStaleness = DefaultMaxStaleness
+		}
+	}
+}
+
 // New begins monitoring one or more sd.Instancer objects, dispatching events to any Listeners that are configured.
 // This function returns an error if i is empty or nil.
 func New(options ...Option) (Interface, error) {
@@ -97,6 +115,7 @@ func New(options ...Option) (Interface, error) {
 			logger:  logging.DefaultLogger(),
 			stopped: make(chan struct{}),
 			filter:  DefaultFilter(),
+			now:     time.Now,
 		}
 	)
 
@@ -119,6 +138,10 @@ type monitor struct {
 	filter     Filter
 	listeners  Listeners
 
+	snapshots    SnapshotStore
+	maxStaleness time.Duration
+	now          func() time.Time
+
 	closed   <-chan struct{}
 	stopped  chan struct{}
 	stopOnce sync.Once
@@ -162,6 +185,40 @@ func (m *monitor) dispatchEvents(key string, l log.Logger, i sd.Instancer) {
 
 	logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "subscription monitor starting")
 
+	var (
+		// last is the last known good snapshot, which is served in place of errors
+		last    Snapshot
+		hasLast bool
+
+		// outage is when service discovery started failing, which is when staleness is measured from.
+		// It is zero while service discovery is working.
+		outage time.Time
+
+		lastErr error
+		expiry  *time.Timer
+		expired <-chan time.Time
+	)
+
+	stopExpiry := func() {
+		if expiry != nil {
+			expiry.Stop()
+			expiry, expired = nil, nil
+		}
+	}
+
+	defer stopExpiry()
+	if m.snapshots != nil {
+		if s, ok, err := m.snapshots.Load(key); err != nil {
+			logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to load snapshot", logging.ErrorKey(), err)
+		} else if ok {
+			logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "loaded snapshot", "instances", len(s.Instances), "timestamp", s.Timestamp)
+			last, hasLast = s, true
+
+			// nothing is known about service discovery since the snapshot was taken
+			outage = s.Timestamp
+		}
+	}
+
 	defer i.Deregister(events)
 	i.Register(events)
 
@@ -169,6 +226,7 @@ func (m *monitor) dispatchEvents(key string, l log.Logger, i sd.Instancer) {
 		select {
 		case sdEvent := <-events:
 			eventCount++
+			stopExpiry()
 			event := Event{
 				Key:        key,
 				Instancer:  i,
@@ -178,15 +236,43 @@ func (m *monitor) dispatchEvents(key string, l log.Logger, i sd.Instancer) {
 			if sdEvent.Err != nil {
 				logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "service discovery error", logging.ErrorKey(), sdEvent.Err)
 				event.Err = sdEvent.Err
+				lastErr = sdEvent.Err
+				if outage.IsZero() {
+					outage = m.now()
+				}
+
+				if m.snapshots != nil && hasLast {
+					if remaining, ok := m.serveSnapshot(&event, last, outage); ok {
+						logger.Log(level.Key(), level.WarnValue(), logging.MessageKey(), "serving stale snapshot", "timestamp", last.Timestamp)
+						expiry = time.NewTimer(remaining)
+						expired = expiry.C
+					}
+				}
 			} else {
 				logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "service discovery update", "instances", sdEvent.Instances)
+				outage = time.Time{}
 				if len(sdEvent.Instances) > 0 {
 					event.Instances = m.filter(sdEvent.Instances)
+					event.Metadata = m.filterMetadata(service.GetInstanceMetadata(i))
+				}
+
+				if m.snapshots != nil {
+					last = Snapshot{Key: key, Instances: event.Instances, Metadata: event.Metadata, Timestamp: m.now()}
+					hasLast = true
+					if err := m.snapshots.Save(last); err != nil {
+						logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to save snapshot", logging.ErrorKey(), err)
+					}
 				}
 			}
 
 			m.listeners.MonitorEvent(event)
 
+		case <-expired:
+			expiry, expired = nil, nil
+			eventCount++
+			logger.Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "snapshot exceeded the maximum staleness", logging.ErrorKey(), lastErr)
+			m.listeners.MonitorEvent(Event{Key: key, Instancer: i, EventCount: eventCount, Err: lastErr})
+
 		case <-m.stopped:
 			logger.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "subscription monitor was stopped")
 			m.listeners.MonitorEvent(Event{Key: key, Instancer: i, EventCount: eventCount, Stopped: true})
@@ -200,3 +286,38 @@ func (m *monitor) dispatchEvents(key string, l log.Logger, i sd.Instancer) {
 		}
 	}
 }
+
+// serveSnapshot replaces an error event with the instances of a snapshot, flagged as stale, provided the
+// outage has not lasted longer than the maximum staleness.  Staleness is measured from the start of the outage
+// rather than the snapshot's timestamp, since a stable cluster produces no new snapshots.  The returned duration
+// is how much longer the snapshot may be served.
+func (m *monitor) serveSnapshot(event *Event, s Snapshot, outage time.Time) (time.Duration, bool) {
+	remaining := outage.Add(m.maxStaleness).Sub(m.now())
+	if remaining <= 0 {
+		return 0, false
+	}
+
+	event.Err = nil
+	event.Instances = s.Instances
+	event.Metadata = s.Metadata
+	event.Stale = true
+	event.SnapshotTime = s.Timestamp
+	return remaining, true
+}
+
+// filterMetadata keys instance metadata by the filtered form of each instance, so that it matches
+// the instances sent to listeners.  Metadata for instances rejected by the filter is dropped.
+func (m *monitor) filterMetadata(metadata map[string]service.InstanceMetadata) map[string]service.InstanceMetadata {
+	if len(metadata) == 0 {
+		return nil
+	}
+
+	filtered := make(map[string]service.InstanceMetadata, len(metadata))
+	for instance, md := range metadata {
+		if f := m.filter([]string{instance}); len(f) == 1 {
+			filtered[f[0]] = md
+		}
+	}
+
+	return filtered
+}
@@ -276,3 +276,23 @@ func TestNew(t *testing.T) {
 	t.Run("Stop", testNewStop)
 	t.Run("WithEnvironment", testNewWithEnvironment)
 }
+
+func TestMonitorFilterMetadata(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		m      = &monitor{filter: DefaultFilter()}
+	)
+
+	assert.Nil(m.filterMetadata(nil))
+	assert.Equal(
+		map[string]service.InstanceMetadata{
+			"https://host1.com:8080": {Zone: "east"},
+			"http://host2.com":       {Weight: 2},
+		},
+		m.filterMetadata(map[string]service.InstanceMetadata{
+			"host1.com:8080":   {Zone: "east"},
+			"http://host2.com": {Weight: 2},
+			"   ":              {Zone: "west"},
+		}),
+	)
+}
@@ -0,0 +1,106 @@
+package monitor
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/jithin-kg/webpa-common/service"
+)
+
+// DefaultMaxStaleness is the oldest snapshot that will be served in place of service discovery errors
+const DefaultMaxStaleness = 24 * time.Hour
+
+// Snapshot is the last known good set of instances for an sd.Instancer
+type Snapshot struct {
+	// Key is the in-process identifier of the sd.Instancer
+	Key string `json:"key"`
+
+	// Instances are the filtered instances, as sent to listeners
+	Instances []string `json:"instances"`
+
+	// Metadata describes the Instances.  It may be nil.
+	Metadata map[string]service.InstanceMetadata `json:"metadata,omitempty"`
+
+	// Timestamp is when the Instances were last received from the service discovery backend
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// SnapshotStore persists snapshots, so that they survive a restart
+type SnapshotStore interface {
+	// Load returns the snapshot for a key.  If there is no such snapshot, this method returns false with a nil error.
+	Load(key string) (Snapshot, bool, error)
+
+	// Save stores a snapshot under its key, replacing any existing snapshot
+	Save(Snapshot) error
+}
+
+// fileSnapshotStore is a SnapshotStore that holds one JSON file per key
+type fileSnapshotStore struct {
+	dir string
+}
+
+// NewFileSnapshotStore produces a SnapshotStore which keeps each snapshot in a JSON file in the given directory.
+// The directory is created if necessary.  Files are replaced atomically, so a crash never leaves a partial snapshot.
+func NewFileSnapshotStore(dir string) (SnapshotStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+
+	return fileSnapshotStore{dir}, nil
+}
+
+func (fss fileSnapshotStore) path(key string) string {
+	return filepath.Join(fss.dir, url.PathEscape(key)+".json")
+}
+
+func (fss fileSnapshotStore) Load(key string) (Snapshot, bool, error) {
+	data, err := ioutil.ReadFile(fss.path(key))
+	if os.IsNotExist(err) {
+		return Snapshot{}, false, nil
+	} else if err != nil {
+		return Snapshot{}, false, err
+	}
+
+	var s Snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return Snapshot{}, false, err
+	}
+
+	return s, true, nil
+}
+
+func (fss fileSnapshotStore) Save(s Snapshot) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(fss.dir, ".snapshot")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(data)
+	if err == nil {
+		// flush the data before the rename makes it visible, so a crash cannot leave an empty snapshot
+		err = f.Sync()
+	}
+
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), fss.path(s.Key))
+	}
+
+	if err != nil {
+		os.Remove(f.Name())
+	}
+
+	return err
+}
@@ -0,0 +1,326 @@
+package monitor
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSnapshotStore(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	dir, err := ioutil.TempDir("", "snapshots")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	store, err := NewFileSnapshotStore(filepath.Join(dir, "nested"))
+	require.NoError(err)
+	require.NotNil(store)
+
+	s, ok, err := store.Load("talaria/{passingOnly=true}")
+	assert.Equal(Snapshot{}, s)
+	assert.False(ok)
+	assert.NoError(err)
+
+	expected := Snapshot{
+		Key:       "talaria/{passingOnly=true}",
+		Instances: []string{"http://talaria-0:8080", "http://talaria-1:8080"},
+		Metadata:  map[string]service.InstanceMetadata{"http://talaria-0:8080": {Zone: "east"}},
+		Timestamp: time.Date(2020, 3, 1, 12, 0, 0, 0, time.UTC),
+	}
+
+	require.NoError(store.Save(expected))
+	s, ok, err = store.Load(expected.Key)
+	assert.Equal(expected, s)
+	assert.True(ok)
+	assert.NoError(err)
+
+	// saving replaces the previous snapshot, and leaves no temporary files behind
+	expected.Instances = []string{"http://talaria-2:8080"}
+	require.NoError(store.Save(expected))
+	s, ok, err = store.Load(expected.Key)
+	assert.Equal(expected, s)
+	assert.True(ok)
+	assert.NoError(err)
+
+	files, err := ioutil.ReadDir(filepath.Join(dir, "nested"))
+	require.NoError(err)
+	assert.Len(files, 1)
+
+	// a corrupt snapshot is an error
+	require.NoError(ioutil.WriteFile(filepath.Join(dir, "nested", files[0].Name()), []byte("{"), 0644))
+	s, ok, err = store.Load(expected.Key)
+	assert.Equal(Snapshot{}, s)
+	assert.False(ok)
+	assert.Error(err)
+}
+
+func TestNewFileSnapshotStoreError(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	f, err := ioutil.TempFile("", "snapshots")
+	require.NoError(err)
+	f.Close()
+	defer os.Remove(f.Name())
+
+	// a regular file cannot be used as the directory
+	store, err := NewFileSnapshotStore(f.Name())
+	assert.Nil(store)
+	assert.Error(err)
+}
+
+// testSnapshotStore is an in-memory SnapshotStore
+type testSnapshotStore struct {
+	lock      sync.Mutex
+	snapshots map[string]Snapshot
+	loadErr   error
+}
+
+func (tss *testSnapshotStore) Load(key string) (Snapshot, bool, error) {
+	tss.lock.Lock()
+	defer tss.lock.Unlock()
+
+	s, ok := tss.snapshots[key]
+	return s, ok, tss.loadErr
+}
+
+func (tss *testSnapshotStore) Save(s Snapshot) error {
+	tss.lock.Lock()
+	defer tss.lock.Unlock()
+
+	tss.snapshots[s.Key] = s
+	return nil
+}
+
+func (tss *testSnapshotStore) get(key string) Snapshot {
+	tss.lock.Lock()
+	defer tss.lock.Unlock()
+	return tss.snapshots[key]
+}
+
+// testInstancer is an sd.Instancer that hands its registered channel to the test
+type testInstancer struct {
+	registered chan chan<- sd.Event
+}
+
+func (ti testInstancer) Register(ch chan<- sd.Event) { ti.registered <- ch }
+func (ti testInstancer) Deregister(chan<- sd.Event)  {}
+func (ti testInstancer) Stop()                       {}
+
+// startSnapshotMonitor starts a monitor with snapshots, returning the channel used to send sd.Events and
+// the channel of dispatched monitor Events
+func startSnapshotMonitor(t *testing.T, store SnapshotStore, maxStaleness time.Duration) (Interface, chan<- sd.Event, <-chan Event) {
+	var (
+		instancer = testInstancer{make(chan chan<- sd.Event, 1)}
+		events    = make(chan Event, 5)
+	)
+
+	m, err := New(
+		WithLogger(logging.NewTestLogger(nil, t)),
+		WithListeners(ListenerFunc(func(e Event) {
+			if !e.Stopped {
+				events <- e
+			}
+		})),
+		WithInstancers(service.Instancers{"test": instancer}),
+		WithSnapshots(store, maxStaleness),
+	)
+
+	require.NoError(t, err)
+	select {
+	case ch := <-instancer.registered:
+		return m, ch, events
+	case <-time.After(5 * time.Second):
+		m.Stop()
+		require.FailNow(t, "Failed to receive registered event channel")
+		return nil, nil, nil
+	}
+}
+
+func nextMonitorEvent(t *testing.T, events <-chan Event) Event {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(5 * time.Second):
+		require.FailNow(t, "Failed to receive monitor event")
+		return Event{}
+	}
+}
+
+func testMonitorSnapshotsStartup(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		snapshotTime  = time.Now().Add(-time.Minute)
+		expectedError = errors.New("expected")
+		store         = &testSnapshotStore{
+			snapshots: map[string]Snapshot{
+				"test": {
+					Key:       "test",
+					Instances: []string{"http://talaria-0:8080"},
+					Metadata:  map[string]service.InstanceMetadata{"http://talaria-0:8080": {Zone: "east"}},
+					Timestamp: snapshotTime,
+				},
+			},
+		}
+
+		m, sdEvents, events = startSnapshotMonitor(t, store, time.Hour)
+	)
+
+	defer m.Stop()
+
+	// the backend is unavailable at startup
+	sdEvents <- sd.Event{Err: expectedError}
+	e := nextMonitorEvent(t, events)
+	assert.NoError(e.Err)
+	assert.True(e.Stale)
+	assert.Equal(snapshotTime, e.SnapshotTime)
+	assert.Equal([]string{"http://talaria-0:8080"}, e.Instances)
+	assert.Equal(map[string]service.InstanceMetadata{"http://talaria-0:8080": {Zone: "east"}}, e.Metadata)
+
+	// the backend recovers
+	sdEvents <- sd.Event{Instances: []string{"http://talaria-1:8080"}}
+	e = nextMonitorEvent(t, events)
+	assert.NoError(e.Err)
+	assert.False(e.Stale)
+	assert.True(e.SnapshotTime.IsZero())
+	assert.Equal([]string{"http://talaria-1:8080"}, e.Instances)
+
+	s := store.get("test")
+	assert.Equal([]string{"http://talaria-1:8080"}, s.Instances)
+	assert.True(s.Timestamp.After(snapshotTime))
+
+	// the new snapshot is served during an outage
+	sdEvents <- sd.Event{Err: expectedError}
+	e = nextMonitorEvent(t, events)
+	assert.True(e.Stale)
+	assert.Equal(s.Timestamp, e.SnapshotTime)
+	assert.Equal([]string{"http://talaria-1:8080"}, e.Instances)
+}
+
+func testMonitorSnapshotsTooStale(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		expectedError = errors.New("expected")
+		store         = &testSnapshotStore{
+			snapshots: map[string]Snapshot{
+				"test": {Key: "test", Instances: []string{"http://talaria-0:8080"}, Timestamp: time.Now().Add(-2 * time.Hour)},
+			},
+		}
+
+		m, sdEvents, events = startSnapshotMonitor(t, store, time.Hour)
+	)
+
+	defer m.Stop()
+
+	sdEvents <- sd.Event{Err: expectedError}
+	e := nextMonitorEvent(t, events)
+	assert.Equal(expectedError, e.Err)
+	assert.False(e.Stale)
+	assert.Empty(e.Instances)
+}
+
+func testMonitorSnapshotsExpire(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		expectedError = errors.New("expected")
+		store         = &testSnapshotStore{
+			snapshots: map[string]Snapshot{
+				"test": {Key: "test", Instances: []string{"http://talaria-0:8080"}, Timestamp: time.Now().Add(100*time.Millisecond - time.Hour)},
+			},
+		}
+
+		m, sdEvents, events = startSnapshotMonitor(t, store, time.Hour)
+	)
+
+	defer m.Stop()
+
+	sdEvents <- sd.Event{Err: expectedError}
+	e := nextMonitorEvent(t, events)
+	assert.True(e.Stale)
+	assert.Equal(1, e.EventCount)
+
+	// once the snapshot exceeds the maximum staleness, the error is dispatched
+	e = nextMonitorEvent(t, events)
+	assert.Equal(expectedError, e.Err)
+	assert.False(e.Stale)
+	assert.Empty(e.Instances)
+	assert.Equal(2, e.EventCount)
+}
+
+func testMonitorSnapshotsStableCluster(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		expectedError = errors.New("expected")
+		store         = &testSnapshotStore{snapshots: map[string]Snapshot{}}
+
+		m, sdEvents, events = startSnapshotMonitor(t, store, 100*time.Millisecond)
+	)
+
+	defer m.Stop()
+
+	sdEvents <- sd.Event{Instances: []string{"http://talaria-0:8080"}}
+	e := nextMonitorEvent(t, events)
+	assert.False(e.Stale)
+
+	// no changes arrive for longer than the maximum staleness, but the outage itself has just begun
+	time.Sleep(200 * time.Millisecond)
+	sdEvents <- sd.Event{Err: expectedError}
+	e = nextMonitorEvent(t, events)
+	assert.NoError(e.Err)
+	assert.True(e.Stale)
+	assert.Equal([]string{"http://talaria-0:8080"}, e.Instances)
+
+	// the outage outlasts the maximum staleness
+	e = nextMonitorEvent(t, events)
+	assert.Equal(expectedError, e.Err)
+	assert.False(e.Stale)
+}
+
+func testMonitorSnapshotsLoadError(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		expectedError = errors.New("expected")
+		store         = &testSnapshotStore{
+			snapshots: map[string]Snapshot{},
+			loadErr:   errors.New("expected load error"),
+		}
+
+		m, sdEvents, events = startSnapshotMonitor(t, store, 0)
+	)
+
+	defer m.Stop()
+
+	sdEvents <- sd.Event{Err: expectedError}
+	e := nextMonitorEvent(t, events)
+	assert.Equal(expectedError, e.Err)
+	assert.False(e.Stale)
+}
+
+func TestMonitorSnapshots(t *testing.T) {
+	t.Run("Startup", testMonitorSnapshotsStartup)
+	t.Run("TooStale", testMonitorSnapshotsTooStale)
+	t.Run("Expire", testMonitorSnapshotsExpire)
+	t.Run("StableCluster", testMonitorSnapshotsStableCluster)
+	t.Run("LoadError", testMonitorSnapshotsLoadError)
+}
@@ -9,13 +9,19 @@ import (
 	"github.com/jithin-kg/webpa-common/logging"
 	"github.com/jithin-kg/webpa-common/service"
 	"github.com/jithin-kg/webpa-common/service/consul"
+	"github.com/jithin-kg/webpa-common/service/dns"
+	"github.com/jithin-kg/webpa-common/service/file"
+	"github.com/jithin-kg/webpa-common/service/k8s"
 	"github.com/jithin-kg/webpa-common/service/zk"
 	"github.com/jithin-kg/webpa-common/xviper"
 )
 
 var (
-	zookeeperEnvironmentFactory = zk.NewEnvironment
-	consulEnvironmentFactory    = consul.NewEnvironment
+	zookeeperEnvironmentFactory  = zk.NewEnvironment
+	consulEnvironmentFactory     = consul.NewEnvironment
+	kubernetesEnvironmentFactory = k8s.NewEnvironment
+	dnsEnvironmentFactory        = dns.NewEnvironment
+	fileEnvironmentFactory       = file.NewEnvironment
 
 	errNoServiceDiscovery = errors.New("No service discovery configured")
 )
@@ -30,10 +36,19 @@ func NewEnvironment(l log.Logger, u xviper.Unmarshaler, options ...service.Optio
 		return nil, err
 	}
 
+	af, err := service.NewAccessorFactory(o.accessorOptions())
+	if err != nil {
+		return nil, err
+	}
+
+	maf, err := service.NewMetadataAccessorFactory(o.accessorOptions())
+	if err != nil {
+		return nil, err
+	}
+
 	eo := []service.Option{
-		service.WithAccessorFactory(
-			service.NewConsistentAccessorFactory(o.vnodeCount()),
-		),
+		service.WithAccessorFactory(af),
+		service.WithMetadataAccessorFactory(maf),
 		service.WithDefaultScheme(o.defaultScheme()),
 	}
 
@@ -65,5 +80,20 @@ func NewEnvironment(l log.Logger, u xviper.Unmarshaler, options ...service.Optio
 		return consulEnvironmentFactory(l, o.DefaultScheme, *o.Consul, eo...)
 	}
 
+	if o.Kubernetes != nil {
+		l.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "using kubernetes for service discovery")
+		return kubernetesEnvironmentFactory(l, o.defaultScheme(), *o.Kubernetes, eo...)
+	}
+
+	if o.DNS != nil {
+		l.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "using DNS for service discovery")
+		return dnsEnvironmentFactory(l, o.defaultScheme(), *o.DNS, eo...)
+	}
+
+	if o.File != nil {
+		l.Log(level.Key(), level.InfoValue(), logging.MessageKey(), "using files for service discovery")
+		return fileEnvironmentFactory(l, o.defaultScheme(), *o.File, eo...)
+	}
+
 	return nil, errNoServiceDiscovery
 }
@@ -14,6 +14,9 @@ import (
 	"github.com/jithin-kg/webpa-common/logging"
 	"github.com/jithin-kg/webpa-common/service"
 	"github.com/jithin-kg/webpa-common/service/consul"
+	"github.com/jithin-kg/webpa-common/service/dns"
+	"github.com/jithin-kg/webpa-common/service/file"
+	"github.com/jithin-kg/webpa-common/service/k8s"
 	"github.com/jithin-kg/webpa-common/service/zk"
 	"github.com/jithin-kg/webpa-common/xviper"
 )
@@ -70,6 +73,29 @@ func testNewEnvironmentFixed(t *testing.T) {
 	assert.NoError(e.Close())
 }
 
+func testNewEnvironmentUnsupportedAlgorithm(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		v = viper.New()
+
+		configuration = strings.NewReader(`
+			{
+				"algorithm": "nosuch",
+				"fixed": ["instance1.com:1234"]
+			}
+		`)
+	)
+
+	v.SetConfigType("json")
+	require.NoError(v.ReadConfig(configuration))
+
+	e, err := NewEnvironment(nil, v)
+	assert.Nil(e)
+	assert.Error(err)
+}
+
 func testNewEnvironmentZookeeper(t *testing.T) {
 	defer resetEnvironmentFactories()
 
@@ -222,10 +248,183 @@ func testNewEnvironmentConsul(t *testing.T) {
 	assert.NoError(actualEnvironment.Close())
 }
 
+func testNewEnvironmentKubernetes(t *testing.T) {
+	defer resetEnvironmentFactories()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		logger = logging.NewTestLogger(nil, t)
+		v      = viper.New()
+
+		expectedEnvironment = service.NewEnvironment()
+
+		configuration = strings.NewReader(`
+			{
+				"defaultScheme": "http",
+				"kubernetes": {
+					"server": "https://k8s.example.com",
+					"namespace": "xmidt",
+					"registrations": ["talaria-0.talaria:8080"],
+					"watches": [
+						{
+							"service": "talaria",
+							"portName": "http",
+							"endpointSlices": true
+						}
+					]
+				}
+			}
+		`)
+	)
+
+	v.SetConfigType("json")
+	require.NoError(v.ReadConfig(configuration))
+
+	kubernetesEnvironmentFactory = func(l log.Logger, registrationScheme string, ko k8s.Options, eo ...service.Option) (service.Environment, error) {
+		assert.Equal(logger, l)
+		assert.Equal("http", registrationScheme)
+		assert.Equal(
+			k8s.Options{
+				Server:        "https://k8s.example.com",
+				Namespace:     "xmidt",
+				Registrations: []string{"talaria-0.talaria:8080"},
+				Watches: []k8s.Watch{
+					{
+						Service:        "talaria",
+						PortName:       "http",
+						EndpointSlices: true,
+					},
+				},
+			},
+			ko,
+		)
+
+		return expectedEnvironment, nil
+	}
+
+	actualEnvironment, err := NewEnvironment(logger, v)
+	require.NoError(err)
+	assert.Equal(expectedEnvironment, actualEnvironment)
+}
+
+func testNewEnvironmentDNS(t *testing.T) {
+	defer resetEnvironmentFactories()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		logger = logging.NewTestLogger(nil, t)
+		v      = viper.New()
+
+		expectedEnvironment = service.NewEnvironment()
+
+		configuration = strings.NewReader(`
+			{
+				"defaultScheme": "http",
+				"dns": {
+					"server": "10.0.0.53:53",
+					"interval": "10s",
+					"watches": [
+						{
+							"name": "_http._tcp.talaria.example.com"
+						},
+						{
+							"name": "scytale.example.com",
+							"type": "A",
+							"port": 8080
+						}
+					]
+				}
+			}
+		`)
+	)
+
+	v.SetConfigType("json")
+	require.NoError(v.ReadConfig(configuration))
+
+	dnsEnvironmentFactory = func(l log.Logger, registrationScheme string, do dns.Options, eo ...service.Option) (service.Environment, error) {
+		assert.Equal(logger, l)
+		assert.Equal("http", registrationScheme)
+		assert.Equal(
+			dns.Options{
+				Server:   "10.0.0.53:53",
+				Interval: 10 * time.Second,
+				Watches: []dns.Watch{
+					{Name: "_http._tcp.talaria.example.com"},
+					{Name: "scytale.example.com", Type: dns.RecordA, Port: 8080},
+				},
+			},
+			do,
+		)
+
+		return expectedEnvironment, nil
+	}
+
+	actualEnvironment, err := NewEnvironment(logger, v)
+	require.NoError(err)
+	assert.Equal(expectedEnvironment, actualEnvironment)
+}
+
+func testNewEnvironmentFile(t *testing.T) {
+	defer resetEnvironmentFactories()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		logger = logging.NewTestLogger(nil, t)
+		v      = viper.New()
+
+		expectedEnvironment = service.NewEnvironment()
+
+		configuration = strings.NewReader(`
+			{
+				"file": {
+					"registrations": ["talaria-0:8080"],
+					"watches": [
+						{
+							"path": "/etc/xmidt/talaria.yaml",
+							"scheme": "http"
+						}
+					]
+				}
+			}
+		`)
+	)
+
+	v.SetConfigType("json")
+	require.NoError(v.ReadConfig(configuration))
+
+	fileEnvironmentFactory = func(l log.Logger, registrationScheme string, fo file.Options, eo ...service.Option) (service.Environment, error) {
+		assert.Equal(logger, l)
+		assert.Equal(service.DefaultScheme, registrationScheme)
+		assert.Equal(
+			file.Options{
+				Registrations: []string{"talaria-0:8080"},
+				Watches:       []file.Watch{{Path: "/etc/xmidt/talaria.yaml", Scheme: "http"}},
+			},
+			fo,
+		)
+
+		return expectedEnvironment, nil
+	}
+
+	actualEnvironment, err := NewEnvironment(logger, v)
+	require.NoError(err)
+	assert.Equal(expectedEnvironment, actualEnvironment)
+}
+
 func TestNewEnvironment(t *testing.T) {
 	t.Run("Empty", testNewEnvironmentEmpty)
 	t.Run("UnmarshalError", testNewEnvironmentUnmarshalError)
 	t.Run("Fixed", testNewEnvironmentFixed)
+	t.Run("UnsupportedAlgorithm", testNewEnvironmentUnsupportedAlgorithm)
 	t.Run("Zookeeper", testNewEnvironmentZookeeper)
 	t.Run("Consul", testNewEnvironmentConsul)
+	t.Run("Kubernetes", testNewEnvironmentKubernetes)
+	t.Run("DNS", testNewEnvironmentDNS)
+	t.Run("File", testNewEnvironmentFile)
 }
@@ -2,6 +2,9 @@ package servicecfg
 
 import (
 	"github.com/jithin-kg/webpa-common/service/consul"
+	"github.com/jithin-kg/webpa-common/service/dns"
+	"github.com/jithin-kg/webpa-common/service/file"
+	"github.com/jithin-kg/webpa-common/service/k8s"
 	"github.com/jithin-kg/webpa-common/service/zk"
 )
 
@@ -9,4 +12,7 @@ import (
 func resetEnvironmentFactories() {
 	zookeeperEnvironmentFactory = zk.NewEnvironment
 	consulEnvironmentFactory = consul.NewEnvironment
+	kubernetesEnvironmentFactory = k8s.NewEnvironment
+	dnsEnvironmentFactory = dns.NewEnvironment
+	fileEnvironmentFactory = file.NewEnvironment
 }
@@ -3,6 +3,9 @@ package servicecfg
 import (
 	"github.com/jithin-kg/webpa-common/service"
 	"github.com/jithin-kg/webpa-common/service/consul"
+	"github.com/jithin-kg/webpa-common/service/dns"
+	"github.com/jithin-kg/webpa-common/service/file"
+	"github.com/jithin-kg/webpa-common/service/k8s"
 	"github.com/jithin-kg/webpa-common/service/zk"
 )
 
@@ -12,9 +15,30 @@ type Options struct {
 	DisableFilter bool   `json:"disableFilter"`
 	DefaultScheme string `json:"defaultScheme"`
 
-	Fixed     []string        `json:"fixed,omitempty"`
-	Zookeeper *zk.Options     `json:"zookeeper,omitempty"`
-	Consul    *consul.Options `json:"consul,omitempty"`
+	// Algorithm is the hashing algorithm used to map keys onto instances.  If not supplied,
+	// service.ConsistentHashing is used.
+	Algorithm string `json:"algorithm,omitempty"`
+
+	// MaglevTableSize is the lookup table size when Algorithm is service.MaglevHashing
+	MaglevTableSize int `json:"maglevTableSize,omitempty"`
+
+	// Weights maps instances to their relative capacity, for weighted placement
+	Weights map[string]int `json:"weights,omitempty"`
+
+	// Zone and Datacenter locate this process, so that metadata-aware accessors can prefer nearby instances
+	Zone       string `json:"zone,omitempty"`
+	Datacenter string `json:"datacenter,omitempty"`
+
+	// SpilloverThreshold is the minimum fraction of the total instance weight that the local zone or
+	// datacenter must hold for keys to stay local
+	SpilloverThreshold float64 `json:"spilloverThreshold,omitempty"`
+
+	Fixed      []string        `json:"fixed,omitempty"`
+	Zookeeper  *zk.Options     `json:"zookeeper,omitempty"`
+	Consul     *consul.Options `json:"consul,omitempty"`
+	Kubernetes *k8s.Options    `json:"kubernetes,omitempty"`
+	DNS        *dns.Options    `json:"dns,omitempty"`
+	File       *file.Options   `json:"file,omitempty"`
 }
 
 func (o *Options) vnodeCount() int {
@@ -40,3 +64,20 @@ func (o *Options) defaultScheme() string {
 
 	return service.DefaultScheme
 }
+
+func (o *Options) accessorOptions() *service.AccessorOptions {
+	if o == nil {
+		return nil
+	}
+
+	return &service.AccessorOptions{
+		Algorithm:       o.Algorithm,
+		VnodeCount:      o.vnodeCount(),
+		MaglevTableSize: o.MaglevTableSize,
+		Weights:         o.Weights,
+
+		Zone:               o.Zone,
+		Datacenter:         o.Datacenter,
+		SpilloverThreshold: o.SpilloverThreshold,
+	}
+}
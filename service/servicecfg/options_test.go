@@ -12,6 +12,10 @@ func testOptionsDefault(t *testing.T, o *Options) {
 	assert.Equal(service.DefaultVnodeCount, o.vnodeCount())
 	assert.False(o.disableFilter())
 	assert.Equal(service.DefaultScheme, o.defaultScheme())
+
+	af, err := service.NewAccessorFactory(o.accessorOptions())
+	assert.NoError(err)
+	assert.NotNil(af)
 }
 
 func testOptionsCustom(t *testing.T) {
@@ -19,15 +23,35 @@ func testOptionsCustom(t *testing.T) {
 		assert = assert.New(t)
 
 		o = Options{
-			VnodeCount:    345234,
-			DisableFilter: true,
-			DefaultScheme: "ftp",
+			VnodeCount:      345234,
+			DisableFilter:   true,
+			DefaultScheme:   "ftp",
+			Algorithm:       service.MaglevHashing,
+			MaglevTableSize: 251,
+			Weights:         map[string]int{"instance1": 2},
+
+			Zone:               "east",
+			Datacenter:         "dc1",
+			SpilloverThreshold: 0.25,
 		}
 	)
 
 	assert.Equal(345234, o.vnodeCount())
 	assert.True(o.disableFilter())
 	assert.Equal("ftp", o.defaultScheme())
+	assert.Equal(
+		&service.AccessorOptions{
+			Algorithm:       service.MaglevHashing,
+			VnodeCount:      345234,
+			MaglevTableSize: 251,
+			Weights:         map[string]int{"instance1": 2},
+
+			Zone:               "east",
+			Datacenter:         "dc1",
+			SpilloverThreshold: 0.25,
+		},
+		o.accessorOptions(),
+	)
 }
 
 func TestOptions(t *testing.T) {
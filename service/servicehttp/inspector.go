@@ -0,0 +1,224 @@
+package servicehttp
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jithin-kg/webpa-common/device"
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/jithin-kg/webpa-common/service/monitor"
+)
+
+// DefaultSampleSize is the number of synthetic device keys hashed to estimate how a layer's
+// instances share the key space
+const DefaultSampleSize = 10000
+
+// Layer is a snapshot of what an Inspector knows about one service discovery key, such as a
+// datacenter or failover layer
+type Layer struct {
+	// Name is the service discovery key, i.e. the monitor.Event Key
+	Name string `json:"name"`
+
+	// EventCount is the EventCount of the last monitor.Event received for this layer
+	EventCount int `json:"eventCount"`
+
+	// Updated is when the last monitor.Event was received
+	Updated time.Time `json:"updated"`
+
+	// Instances are the current instances of this layer
+	Instances []string `json:"instances"`
+
+	// Metadata describes the Instances, if the service discovery backend supplies metadata
+	Metadata map[string]service.InstanceMetadata `json:"metadata,omitempty"`
+
+	// Stale indicates that the Instances came from a snapshot rather than the service discovery backend
+	Stale        bool       `json:"stale,omitempty"`
+	SnapshotTime *time.Time `json:"snapshotTime,omitempty"`
+
+	// Err is the text of the last service discovery error, if the last event was an error
+	Err string `json:"error,omitempty"`
+}
+
+// inspectedLayer is the internal state of a Layer
+type inspectedLayer struct {
+	Layer
+	accessor service.Accessor
+
+	// distribution is computed lazily and cached until the next event
+	distribution *service.Distribution
+	sampleSize   int
+}
+
+// Inspector is a monitor.Listener which remembers the latest event for every service discovery key and
+// builds an Accessor for each, so that operators can see how keys hash in every layer.  Inspectors
+// are safe for concurrent use.
+type Inspector struct {
+	factory service.MetadataAccessorFactory
+	weights map[string]int
+	now     func() time.Time
+
+	lock   sync.RWMutex
+	layers map[string]*inspectedLayer
+}
+
+// NewInspector creates an Inspector which uses the given factory to build the Accessor for each layer.  The
+// factory should be the same one used for routing, typically service.Environment.MetadataAccessorFactory().
+// If the factory is nil, service.DefaultAccessorFactory is used and metadata is ignored.
+//
+// The options should be those the factory was built from.  Their Weights take precedence over instance
+// metadata when distributions are computed, just as they do for routing.  The options may be nil.
+func NewInspector(f service.MetadataAccessorFactory, o *service.AccessorOptions) *Inspector {
+	if f == nil {
+		f = func(instances []string, _ map[string]service.InstanceMetadata) service.Accessor {
+			return service.DefaultAccessorFactory(instances)
+		}
+	}
+
+	var weights map[string]int
+	if o != nil {
+		weights = o.Weights
+	}
+
+	return &Inspector{
+		factory: f,
+		weights: weights,
+		now:     time.Now,
+		layers:  make(map[string]*inspectedLayer),
+	}
+}
+
+// MonitorEvent records the state of a layer.  Events for stopped monitors are ignored, so that the last
+// known state remains visible.
+func (in *Inspector) MonitorEvent(e monitor.Event) {
+	if e.Stopped {
+		return
+	}
+
+	il := &inspectedLayer{
+		Layer: Layer{
+			Name:       e.Key,
+			EventCount: e.EventCount,
+			Updated:    in.now(),
+			Instances:  e.Instances,
+			Metadata:   e.Metadata,
+			Stale:      e.Stale,
+		},
+	}
+
+	if e.Stale {
+		snapshotTime := e.SnapshotTime
+		il.SnapshotTime = &snapshotTime
+	}
+
+	switch {
+	case e.Err != nil:
+		il.Err = e.Err.Error()
+		il.accessor = service.EmptyAccessor()
+
+	case len(e.Instances) > 0:
+		il.accessor = in.factory(e.Instances, e.Metadata)
+
+	default:
+		il.accessor = service.EmptyAccessor()
+	}
+
+	in.lock.Lock()
+	in.layers[e.Key] = il
+	in.lock.Unlock()
+}
+
+// sortedLayers returns the current layers, sorted by name
+func (in *Inspector) sortedLayers() []*inspectedLayer {
+	in.lock.RLock()
+	layers := make([]*inspectedLayer, 0, len(in.layers))
+	for _, il := range in.layers {
+		layers = append(layers, il)
+	}
+
+	in.lock.RUnlock()
+	sort.Slice(layers, func(i, j int) bool { return layers[i].Name < layers[j].Name })
+	return layers
+}
+
+// Layers returns the current state of each layer, sorted by name
+func (in *Inspector) Layers() []Layer {
+	var layers []Layer
+	for _, il := range in.sortedLayers() {
+		layers = append(layers, il.Layer)
+	}
+
+	return layers
+}
+
+// Placement is the instance a key hashes to in one layer
+type Placement struct {
+	Layer    string `json:"layer"`
+	Instance string `json:"instance,omitempty"`
+	Err      string `json:"error,omitempty"`
+}
+
+// Hash returns the placement of a key in every layer, sorted by layer name
+func (in *Inspector) Hash(key []byte) []Placement {
+	var placements []Placement
+	for _, il := range in.sortedLayers() {
+		p := Placement{Layer: il.Name}
+		if instance, err := il.accessor.Get(key); err != nil {
+			p.Err = err.Error()
+		} else {
+			p.Instance = instance
+		}
+
+		placements = append(placements, p)
+	}
+
+	return placements
+}
+
+// Distribution estimates how the instances of a layer share the key space by hashing sampleSize synthetic
+// device keys.  This works for every hashing algorithm, including weighted ones.  If sampleSize is nonpositive,
+// DefaultSampleSize is used.  This method returns false if there is no such layer, or if the layer has no instances.
+func (in *Inspector) Distribution(name string, sampleSize int) (service.Distribution, bool) {
+	if sampleSize < 1 {
+		sampleSize = DefaultSampleSize
+	}
+
+	in.lock.Lock()
+	defer in.lock.Unlock()
+
+	il, ok := in.layers[name]
+	if !ok || len(il.Instances) == 0 || len(il.Err) > 0 {
+		return service.Distribution{}, false
+	}
+
+	if il.distribution == nil || il.sampleSize != sampleSize {
+		weights := make(map[string]int, len(il.Instances))
+		for _, instance := range il.Instances {
+			if w, ok := in.weights[instance]; ok {
+				weights[instance] = w
+			} else if w := il.Metadata[instance].Weight; w > 0 {
+				weights[instance] = w
+			}
+		}
+
+		d, err := service.NewDistribution(il.accessor, il.Instances, weights, sampleKeys(sampleSize))
+		if err != nil {
+			return service.Distribution{}, false
+		}
+
+		il.distribution = &d
+		il.sampleSize = sampleSize
+	}
+
+	return *il.distribution, true
+}
+
+// sampleKeys produces a deterministic set of device keys for estimating distributions
+func sampleKeys(n int) [][]byte {
+	keys := make([][]byte, n)
+	for i := range keys {
+		keys[i] = device.IntToMAC(uint64(i) * 0x9e3779b97f4a7c15).Bytes()
+	}
+
+	return keys
+}
@@ -0,0 +1,258 @@
+package servicehttp
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/go-kit/kit/log/level"
+	"github.com/jithin-kg/webpa-common/device"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/jithin-kg/webpa-common/xhttp"
+)
+
+const (
+	// KeyParameter is the query parameter holding the key to hash
+	KeyParameter = "key"
+
+	// DefaultMaxKeys is the default limit on the number of keys in a bulk hash request
+	DefaultMaxKeys = 100000
+)
+
+// DeviceKeyParser is a service.KeyParser that canonicalizes device ids with device.ParseID
+func DeviceKeyParser(v string) (service.Key, error) {
+	id, err := device.ParseID(v)
+	if err != nil {
+		return nil, err
+	}
+
+	return id, nil
+}
+
+// LayerDistribution is a Layer together with the estimated distribution of keys across its instances
+type LayerDistribution struct {
+	Layer
+	Distribution *service.Distribution `json:"distribution,omitempty"`
+}
+
+// HashResult describes where a single key hashes
+type HashResult struct {
+	Key string `json:"key"`
+
+	// Selected is the instance chosen by the HashHandler's Accessor, if one is configured
+	Selected      string `json:"selected,omitempty"`
+	SelectedError string `json:"selectedError,omitempty"`
+
+	// Placements are the instances the key hashes to in each layer
+	Placements []Placement `json:"placements,omitempty"`
+
+	// Err is set if the key could not be parsed
+	Err string `json:"error,omitempty"`
+}
+
+// HashResponse is the result of a single key lookup
+type HashResponse struct {
+	HashResult
+	Layers []LayerDistribution `json:"layers"`
+}
+
+// BulkHashResponse is the result of hashing many keys
+type BulkHashResponse struct {
+	Keys    int          `json:"keys"`
+	Results []HashResult `json:"results"`
+
+	// Counts is the number of keys each instance owns, by layer
+	Counts map[string]map[string]int `json:"counts"`
+}
+
+// HashHandler is an http.Handler that shows operators where keys, typically device ids, hash.
+//
+// A GET request hashes the key in the KeyParameter query parameter, returning a HashResponse with the
+// instance chosen in every layer along with the state of each layer.  A POST request hashes every
+// key in the body, one per line, returning a BulkHashResponse.  Blank lines and lines beginning with
+// # are ignored.
+type HashHandler struct {
+	// Inspector supplies the layers.  This field is required.
+	Inspector *Inspector
+
+	// Accessor is optional, and is typically the accessor used for routing, e.g. a service.LayeredAccessor.
+	// If set, its choice is reported for each key.
+	Accessor service.Accessor
+
+	// KeyParser parses each key.  If not set, DeviceKeyParser is used.
+	KeyParser service.KeyParser
+
+	// SampleSize is passed to Inspector.Distribution.  If not set, DefaultSampleSize is used.
+	SampleSize int
+
+	// MaxKeys limits the number of keys in a bulk request.  If not set, DefaultMaxKeys is used.
+	MaxKeys int
+}
+
+func (hh *HashHandler) keyParser() service.KeyParser {
+	if hh.KeyParser != nil {
+		return hh.KeyParser
+	}
+
+	return DeviceKeyParser
+}
+
+func (hh *HashHandler) maxKeys() int {
+	if hh.MaxKeys > 0 {
+		return hh.MaxKeys
+	}
+
+	return DefaultMaxKeys
+}
+
+func (hh *HashHandler) hash(v string) HashResult {
+	result := HashResult{Key: v}
+	key, err := hh.keyParser()(v)
+	if err != nil {
+		result.Err = err.Error()
+		return result
+	}
+
+	if hh.Accessor != nil {
+		// layered accessors may return both an instance and an error describing failovers
+		instance, err := hh.Accessor.Get(key.Bytes())
+		result.Selected = instance
+		if err != nil {
+			result.SelectedError = err.Error()
+		}
+	}
+
+	result.Placements = hh.Inspector.Hash(key.Bytes())
+	return result
+}
+
+func (hh *HashHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	switch request.Method {
+	case http.MethodGet:
+		hh.serveKey(response, request)
+
+	case http.MethodPost:
+		hh.serveBulk(response, request)
+
+	default:
+		response.Header().Set("Allow", "GET, POST")
+		xhttp.WriteErrorf(response, http.StatusMethodNotAllowed, "method %s is not allowed", request.Method)
+	}
+}
+
+func (hh *HashHandler) serveKey(response http.ResponseWriter, request *http.Request) {
+	v := request.URL.Query().Get(KeyParameter)
+	if len(v) == 0 {
+		xhttp.WriteErrorf(response, http.StatusBadRequest, "missing %s parameter", KeyParameter)
+		return
+	}
+
+	result := hh.hash(v)
+	if len(result.Err) > 0 {
+		xhttp.WriteErrorf(response, http.StatusBadRequest, "invalid key %s", v)
+		return
+	}
+
+	writeJSON(
+		response,
+		request,
+		HashResponse{
+			HashResult: result,
+			Layers:     layerDistributions(hh.Inspector, hh.SampleSize),
+		},
+	)
+}
+
+func (hh *HashHandler) serveBulk(response http.ResponseWriter, request *http.Request) {
+	var (
+		maxKeys = hh.maxKeys()
+		bulk    = BulkHashResponse{
+			Counts: make(map[string]map[string]int),
+		}
+
+		scanner = bufio.NewScanner(request.Body)
+	)
+
+	for scanner.Scan() {
+		v := strings.TrimSpace(scanner.Text())
+		if len(v) == 0 || strings.HasPrefix(v, "#") {
+			continue
+		}
+
+		if bulk.Keys >= maxKeys {
+			xhttp.WriteErrorf(response, http.StatusRequestEntityTooLarge, "no more than %d keys are allowed", maxKeys)
+			return
+		}
+
+		result := hh.hash(v)
+		for _, p := range result.Placements {
+			if len(p.Instance) > 0 {
+				if bulk.Counts[p.Layer] == nil {
+					bulk.Counts[p.Layer] = make(map[string]int)
+				}
+
+				bulk.Counts[p.Layer][p.Instance]++
+			}
+		}
+
+		bulk.Keys++
+		bulk.Results = append(bulk.Results, result)
+	}
+
+	if err := scanner.Err(); err != nil {
+		xhttp.WriteErrorf(response, http.StatusBadRequest, "unable to read keys: %s", err)
+		return
+	}
+
+	writeJSON(response, request, bulk)
+}
+
+// LayersHandler is an http.Handler that reports the state of every layer known to an Inspector, including
+// each layer's instances, the event count of its last monitor.Event, and the distribution of keys across its instances.
+type LayersHandler struct {
+	// Inspector supplies the layers.  This field is required.
+	Inspector *Inspector
+
+	// SampleSize is passed to Inspector.Distribution.  If not set, DefaultSampleSize is used.
+	SampleSize int
+}
+
+func (lh *LayersHandler) ServeHTTP(response http.ResponseWriter, request *http.Request) {
+	writeJSON(
+		response,
+		request,
+		struct {
+			Layers []LayerDistribution `json:"layers"`
+		}{
+			Layers: layerDistributions(lh.Inspector, lh.SampleSize),
+		},
+	)
+}
+
+func layerDistributions(in *Inspector, sampleSize int) []LayerDistribution {
+	layers := []LayerDistribution{}
+	for _, l := range in.Layers() {
+		ld := LayerDistribution{Layer: l}
+		if d, ok := in.Distribution(l.Name, sampleSize); ok {
+			ld.Distribution = &d
+		}
+
+		layers = append(layers, ld)
+	}
+
+	return layers
+}
+
+func writeJSON(response http.ResponseWriter, request *http.Request, v interface{}) {
+	data, err := json.Marshal(v)
+	if err != nil {
+		logging.GetLogger(request.Context()).Log(level.Key(), level.ErrorValue(), logging.MessageKey(), "unable to marshal response", logging.ErrorKey(), err)
+		xhttp.WriteError(response, http.StatusInternalServerError, err)
+		return
+	}
+
+	response.Header().Set("Content-Type", "application/json")
+	response.Write(data)
+}
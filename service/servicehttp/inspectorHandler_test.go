@@ -0,0 +1,166 @@
+package servicehttp
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeviceKeyParser(t *testing.T) {
+	assert := assert.New(t)
+
+	key, err := DeviceKeyParser("MAC:11-22-33-44-55-66")
+	assert.NoError(err)
+	assert.Equal([]byte("mac:112233445566"), key.Bytes())
+
+	key, err = DeviceKeyParser("this is not a device")
+	assert.Nil(key)
+	assert.Error(err)
+}
+
+func testHashHandlerKey(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		handler = HashHandler{
+			Inspector:  newTestInspector(),
+			Accessor:   service.MapAccessor{"mac:112233445566": "http://talaria-1:8080"},
+			SampleSize: 100,
+		}
+
+		response = httptest.NewRecorder()
+	)
+
+	handler.ServeHTTP(response, httptest.NewRequest("GET", "/hash?key=mac:11:22:33:44:55:66", nil))
+	require.Equal(http.StatusOK, response.Code)
+	assert.Equal("application/json", response.Header().Get("Content-Type"))
+
+	var hr HashResponse
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &hr))
+	assert.Equal("mac:11:22:33:44:55:66", hr.Key)
+	assert.Equal("http://talaria-1:8080", hr.Selected)
+	assert.Empty(hr.SelectedError)
+	assert.Equal(handler.Inspector.Hash([]byte("mac:112233445566")), hr.Placements)
+
+	require.Len(hr.Layers, 2)
+	assert.Equal("failover", hr.Layers[0].Name)
+	assert.Nil(hr.Layers[0].Distribution)
+	assert.Equal("primary", hr.Layers[1].Name)
+	assert.Equal(3, hr.Layers[1].EventCount)
+	require.NotNil(hr.Layers[1].Distribution)
+	assert.Equal(100, hr.Layers[1].Distribution.Keys)
+}
+
+func testHashHandlerBadKey(t *testing.T, target string) {
+	var (
+		assert   = assert.New(t)
+		handler  = HashHandler{Inspector: newTestInspector()}
+		response = httptest.NewRecorder()
+	)
+
+	handler.ServeHTTP(response, httptest.NewRequest("GET", target, nil))
+	assert.Equal(http.StatusBadRequest, response.Code)
+}
+
+func testHashHandlerBulk(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		handler  = HashHandler{Inspector: newTestInspector()}
+		response = httptest.NewRecorder()
+
+		body = "# devices to trace\nmac:112233445566\n\n  mac:112233445567  \nnot a device\n"
+	)
+
+	handler.ServeHTTP(response, httptest.NewRequest("POST", "/hash", strings.NewReader(body)))
+	require.Equal(http.StatusOK, response.Code)
+
+	var bulk BulkHashResponse
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &bulk))
+	assert.Equal(3, bulk.Keys)
+	require.Len(bulk.Results, 3)
+
+	assert.Equal("mac:112233445566", bulk.Results[0].Key)
+	assert.Len(bulk.Results[0].Placements, 2)
+	assert.Empty(bulk.Results[0].Selected)
+	assert.Equal("mac:112233445567", bulk.Results[1].Key)
+	assert.Equal("not a device", bulk.Results[2].Key)
+	assert.NotEmpty(bulk.Results[2].Err)
+	assert.Empty(bulk.Results[2].Placements)
+
+	// only layers with instances are counted
+	require.Len(bulk.Counts, 1)
+	total := 0
+	for _, count := range bulk.Counts["primary"] {
+		total += count
+	}
+
+	assert.Equal(2, total)
+}
+
+func testHashHandlerTooManyKeys(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		handler  = HashHandler{Inspector: newTestInspector(), MaxKeys: 1}
+		response = httptest.NewRecorder()
+	)
+
+	handler.ServeHTTP(response, httptest.NewRequest("POST", "/hash", strings.NewReader("mac:112233445566\nmac:112233445567\n")))
+	assert.Equal(http.StatusRequestEntityTooLarge, response.Code)
+}
+
+func testHashHandlerMethodNotAllowed(t *testing.T) {
+	var (
+		assert   = assert.New(t)
+		handler  = HashHandler{Inspector: newTestInspector()}
+		response = httptest.NewRecorder()
+	)
+
+	handler.ServeHTTP(response, httptest.NewRequest("DELETE", "/hash", nil))
+	assert.Equal(http.StatusMethodNotAllowed, response.Code)
+	assert.Equal("GET, POST", response.Header().Get("Allow"))
+}
+
+func TestHashHandler(t *testing.T) {
+	t.Run("Key", testHashHandlerKey)
+	t.Run("MissingKey", func(t *testing.T) { testHashHandlerBadKey(t, "/hash") })
+	t.Run("InvalidKey", func(t *testing.T) { testHashHandlerBadKey(t, "/hash?key=nosuch") })
+	t.Run("Bulk", testHashHandlerBulk)
+	t.Run("TooManyKeys", testHashHandlerTooManyKeys)
+	t.Run("MethodNotAllowed", testHashHandlerMethodNotAllowed)
+}
+
+func TestLayersHandler(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		response = httptest.NewRecorder()
+	)
+
+	(&LayersHandler{Inspector: NewInspector(nil, nil)}).ServeHTTP(response, httptest.NewRequest("GET", "/layers", nil))
+	require.Equal(http.StatusOK, response.Code)
+	assert.JSONEq(`{"layers": []}`, response.Body.String())
+
+	response = httptest.NewRecorder()
+	(&LayersHandler{Inspector: newTestInspector(), SampleSize: 50}).ServeHTTP(response, httptest.NewRequest("GET", "/layers", nil))
+	require.Equal(http.StatusOK, response.Code)
+
+	var layers struct {
+		Layers []LayerDistribution `json:"layers"`
+	}
+
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &layers))
+	require.Len(layers.Layers, 2)
+	assert.Equal("expected", layers.Layers[0].Err)
+	require.NotNil(layers.Layers[1].Distribution)
+	assert.Equal(50, layers.Layers[1].Distribution.Keys)
+}
@@ -0,0 +1,161 @@
+package servicehttp
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/jithin-kg/webpa-common/service"
+	"github.com/jithin-kg/webpa-common/service/monitor"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestInspector creates an Inspector with a primary layer of two instances and a failed layer
+func newTestInspector() *Inspector {
+	in := NewInspector(nil, nil)
+	in.MonitorEvent(monitor.Event{Key: "primary", EventCount: 3, Instances: []string{"http://talaria-0:8080", "http://talaria-1:8080"}})
+	in.MonitorEvent(monitor.Event{Key: "failover", EventCount: 1, Err: errors.New("expected")})
+	return in
+}
+
+func TestInspectorLayers(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		snapshotTime = time.Now().Add(-time.Minute)
+		in           = newTestInspector()
+	)
+
+	assert.Len(in.Layers(), 2)
+
+	in.MonitorEvent(monitor.Event{
+		Key:          "stale",
+		EventCount:   2,
+		Instances:    []string{"http://scytale-0:8080"},
+		Metadata:     map[string]service.InstanceMetadata{"http://scytale-0:8080": {Zone: "east"}},
+		Stale:        true,
+		SnapshotTime: snapshotTime,
+	})
+
+	// stopped events do not erase the last known state
+	in.MonitorEvent(monitor.Event{Key: "primary", EventCount: 3, Stopped: true})
+
+	layers := in.Layers()
+	require.Len(t, layers, 3)
+
+	assert.Equal("failover", layers[0].Name)
+	assert.Equal(1, layers[0].EventCount)
+	assert.Equal("expected", layers[0].Err)
+	assert.Empty(layers[0].Instances)
+
+	assert.Equal("primary", layers[1].Name)
+	assert.Equal(3, layers[1].EventCount)
+	assert.Equal([]string{"http://talaria-0:8080", "http://talaria-1:8080"}, layers[1].Instances)
+	assert.False(layers[1].Stale)
+	assert.Nil(layers[1].SnapshotTime)
+	assert.False(layers[1].Updated.IsZero())
+
+	assert.Equal("stale", layers[2].Name)
+	assert.True(layers[2].Stale)
+	require.NotNil(t, layers[2].SnapshotTime)
+	assert.Equal(snapshotTime, *layers[2].SnapshotTime)
+	assert.Equal(map[string]service.InstanceMetadata{"http://scytale-0:8080": {Zone: "east"}}, layers[2].Metadata)
+}
+
+func TestInspectorHash(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		in       = newTestInspector()
+		key      = []byte("mac:112233445566")
+		expected = service.DefaultAccessorFactory([]string{"http://talaria-0:8080", "http://talaria-1:8080"})
+	)
+
+	expectedInstance, err := expected.Get(key)
+	require.NoError(t, err)
+
+	placements := in.Hash(key)
+	require.Len(t, placements, 2)
+
+	assert.Equal("failover", placements[0].Layer)
+	assert.Empty(placements[0].Instance)
+	assert.NotEmpty(placements[0].Err)
+
+	assert.Equal(Placement{Layer: "primary", Instance: expectedInstance}, placements[1])
+
+	// an empty set of instances is reported as an error
+	in.MonitorEvent(monitor.Event{Key: "primary", EventCount: 4})
+	placements = in.Hash(key)
+	assert.Empty(placements[1].Instance)
+	assert.NotEmpty(placements[1].Err)
+}
+
+func TestInspectorDistribution(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		in = NewInspector(func(instances []string, metadata map[string]service.InstanceMetadata) service.Accessor {
+			f, err := service.NewMetadataAccessorFactory(&service.AccessorOptions{Algorithm: service.RendezvousHashing})
+			require.NoError(t, err)
+			return f(instances, metadata)
+		}, nil)
+	)
+
+	in.MonitorEvent(monitor.Event{
+		Key:       "weighted",
+		Instances: []string{"a", "b"},
+		Metadata:  map[string]service.InstanceMetadata{"a": {Weight: 3}},
+	})
+
+	in.MonitorEvent(monitor.Event{Key: "failed", Err: errors.New("expected")})
+
+	_, ok := in.Distribution("nosuch", 0)
+	assert.False(ok)
+
+	_, ok = in.Distribution("failed", 0)
+	assert.False(ok)
+
+	d, ok := in.Distribution("weighted", 0)
+	assert.True(ok)
+	assert.Equal(DefaultSampleSize, d.Keys)
+	assert.InDelta(0.75, float64(d.Counts["a"])/float64(d.Keys), 0.05)
+	assert.InDelta(1.0, d.Skew, 0.1)
+
+	// distributions are cached per sample size
+	cached, ok := in.Distribution("weighted", 0)
+	assert.True(ok)
+	assert.Equal(d, cached)
+
+	small, ok := in.Distribution("weighted", 100)
+	assert.True(ok)
+	assert.Equal(100, small.Keys)
+}
+
+func TestInspectorDistributionConfiguredWeights(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		options = &service.AccessorOptions{
+			Algorithm: service.RendezvousHashing,
+			Weights:   map[string]int{"a": 1, "b": 3},
+		}
+	)
+
+	f, err := service.NewMetadataAccessorFactory(options)
+	require.NoError(err)
+
+	in := NewInspector(f, options)
+	in.MonitorEvent(monitor.Event{
+		Key:       "weighted",
+		Instances: []string{"a", "b"},
+		Metadata:  map[string]service.InstanceMetadata{"a": {Weight: 3}},
+	})
+
+	// the configured weights take precedence over the metadata, both for placement and for the skew
+	d, ok := in.Distribution("weighted", 0)
+	require.True(ok)
+	assert.InDelta(0.75, float64(d.Counts["b"])/float64(d.Keys), 0.05)
+	assert.InDelta(1.0, d.Skew, 0.1)
+}
@@ -0,0 +1,24 @@
+// Package servicetest provides testing support for service discovery backends
+package servicetest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/go-kit/kit/sd"
+	"github.com/stretchr/testify/require"
+)
+
+// EventTimeout is how long NextEvent waits for an sd.Event
+const EventTimeout = 5 * time.Second
+
+// NextEvent waits for an sd.Event on the given channel, failing the test if none arrives within EventTimeout
+func NextEvent(t *testing.T, events <-chan sd.Event) sd.Event {
+	select {
+	case e := <-events:
+		return e
+	case <-time.After(EventTimeout):
+		require.Fail(t, "No event received")
+		return sd.Event{}
+	}
+}
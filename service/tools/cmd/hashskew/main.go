@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jithin-kg/webpa-common/service"
+)
+
+var (
+	ErrorNoInstances     = errors.New("At least one instance is required")
+	ErrorMalformedWeight = errors.New("Weights must be of the form instance=weight")
+
+	allAlgorithms = []string{service.ConsistentHashing, service.RendezvousHashing, service.JumpHashing, service.MaglevHashing}
+)
+
+type Arguments struct {
+	Algorithms      string
+	Instances       string
+	InstanceCount   int
+	Weights         string
+	KeyCount        int
+	KeyFile         string
+	VnodeCount      int
+	MaglevTableSize int
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "Usage: %s [flags]\n", os.Args[0])
+	fmt.Fprintf(os.Stderr, "Reports the key distribution skew of each hashing algorithm, and the fraction of keys\n")
+	fmt.Fprintf(os.Stderr, "moved when an instance is added or removed.\n\n")
+	flag.PrintDefaults()
+}
+
+// instances returns the configured instance names, or generates them
+func (a Arguments) instances() []string {
+	if len(a.Instances) > 0 {
+		return strings.Split(a.Instances, ",")
+	}
+
+	names := make([]string, a.InstanceCount)
+	for i := range names {
+		names[i] = fmt.Sprintf("instance-%02d", i)
+	}
+
+	return names
+}
+
+func (a Arguments) weights() (map[string]int, error) {
+	weights := make(map[string]int)
+	if len(a.Weights) == 0 {
+		return weights, nil
+	}
+
+	for _, pair := range strings.Split(a.Weights, ",") {
+		parts := strings.SplitN(pair, "=", 2)
+		if len(parts) != 2 {
+			return nil, ErrorMalformedWeight
+		}
+
+		weight, err := strconv.Atoi(parts[1])
+		if err != nil {
+			return nil, ErrorMalformedWeight
+		}
+
+		weights[parts[0]] = weight
+	}
+
+	return weights, nil
+}
+
+// keys reads the keys from the key file, one per line, or generates device identifiers
+func (a Arguments) keys() ([][]byte, error) {
+	if len(a.KeyFile) == 0 {
+		keys := make([][]byte, a.KeyCount)
+		for i := range keys {
+			keys[i] = []byte(fmt.Sprintf("mac:%012x", i))
+		}
+
+		return keys, nil
+	}
+
+	file, err := os.Open(a.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	defer file.Close()
+
+	var (
+		keys    [][]byte
+		scanner = bufio.NewScanner(file)
+	)
+
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); len(line) > 0 {
+			keys = append(keys, []byte(line))
+		}
+	}
+
+	return keys, scanner.Err()
+}
+
+// report writes the distribution and movement of each algorithm
+func report(output io.Writer, a Arguments) error {
+	instances := a.instances()
+	if len(instances) == 0 {
+		return ErrorNoInstances
+	}
+
+	weights, err := a.weights()
+	if err != nil {
+		return err
+	}
+
+	keys, err := a.keys()
+	if err != nil {
+		return err
+	}
+
+	algorithms := allAlgorithms
+	if len(a.Algorithms) > 0 {
+		algorithms = strings.Split(a.Algorithms, ",")
+	}
+
+	var (
+		added   = append(append([]string{}, instances...), "instance-added")
+		removed = instances[1:]
+		tw      = tabwriter.NewWriter(output, 0, 8, 2, ' ', 0)
+	)
+
+	fmt.Fprintf(output, "%d instances, %d keys\n\n", len(instances), len(keys))
+	fmt.Fprintf(tw, "algorithm\tskew\tmin\tmax\tmoved on add\tmoved on remove\n")
+	for _, algorithm := range algorithms {
+		af, err := service.NewAccessorFactory(&service.AccessorOptions{
+			Algorithm:       algorithm,
+			VnodeCount:      a.VnodeCount,
+			MaglevTableSize: a.MaglevTableSize,
+			Weights:         weights,
+		})
+
+		if err != nil {
+			return err
+		}
+
+		current := af(instances)
+		d, err := service.NewDistribution(current, instances, weights, keys)
+		if err != nil {
+			return err
+		}
+
+		onAdd, err := service.Movement(current, af(added), keys)
+		if err != nil {
+			return err
+		}
+
+		onRemove := 0.0
+		if len(removed) > 0 {
+			if onRemove, err = service.Movement(current, af(removed), keys); err != nil {
+				return err
+			}
+		}
+
+		min, max := len(keys), 0
+		for _, i := range instances {
+			if c := d.Counts[i]; c < min {
+				min = c
+			}
+
+			if c := d.Counts[i]; c > max {
+				max = c
+			}
+		}
+
+		fmt.Fprintf(tw, "%s\t%.3f\t%d\t%d\t%.2f%%\t%.2f%%\n", algorithm, d.Skew, min, max, onAdd*100, onRemove*100)
+	}
+
+	return tw.Flush()
+}
+
+func main() {
+	flag.Usage = usage
+
+	var arguments Arguments
+	flag.StringVar(&arguments.Algorithms, "algorithms", "", "comma-separated hashing algorithms to report on (default all)")
+	flag.StringVar(&arguments.Instances, "instances", "", "comma-separated instance names (overrides -n)")
+	flag.IntVar(&arguments.InstanceCount, "n", 10, "the number of generated instances")
+	flag.StringVar(&arguments.Weights, "weights", "", "comma-separated instance=weight pairs")
+	flag.IntVar(&arguments.KeyCount, "keys", 100000, "the number of generated device keys")
+	flag.StringVar(&arguments.KeyFile, "f", "", "a file of keys, one per line (overrides -keys)")
+	flag.IntVar(&arguments.VnodeCount, "vnodes", service.DefaultVnodeCount, "the vnode count for consistent hashing")
+	flag.IntVar(&arguments.MaglevTableSize, "table", service.DefaultMaglevTableSize, "the lookup table size for maglev hashing")
+	flag.Parse()
+
+	if err := report(os.Stdout, arguments); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+		os.Exit(1)
+	}
+}
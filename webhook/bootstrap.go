@@ -0,0 +1,422 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/jithin-kg/webpa-common/xhttp"
+)
+
+const (
+	// PeerBootstrap fetches the current webhooks from peer instances
+	PeerBootstrap = "peers"
+
+	// SnapshotBootstrap reads the webhooks from a local snapshot file
+	SnapshotBootstrap = "snapshot"
+
+	// HTTPBootstrap fetches the current webhooks from the URL described by the StartConfig
+	HTTPBootstrap = "http"
+
+	// DefaultPeerHooksPath is the URL path on which peers serve their current webhooks, via Registry.GetPeerRegistry
+	DefaultPeerHooksPath = DefaultSyncPath + "/hooks"
+
+	DefaultBootstrapTimeout        = time.Minute
+	DefaultBootstrapInitialBackoff = time.Second
+	DefaultBootstrapMaxBackoff     = 30 * time.Second
+	DefaultBootstrapMaxAge         = time.Hour
+)
+
+var (
+	// ErrBootstrapTimeout is returned when no bootstrap source yields webhooks in the allotted time
+	ErrBootstrapTimeout = errors.New("Unable to obtain hook list in allotted time.")
+
+	// ErrNoBootstrapSources is returned when bootstrapping is attempted without any sources
+	ErrNoBootstrapSources = errors.New("No webhook bootstrap sources are available")
+
+	// defaultBootstrapSources is the default priority order of bootstrap sources.  Peers are
+	// preferred, as they are the most current, and the snapshot avoids depending on an external service.
+	defaultBootstrapSources = []string{PeerBootstrap, SnapshotBootstrap, HTTPBootstrap}
+)
+
+// BootstrapSource is a strategy for obtaining the current webhooks when an instance starts
+type BootstrapSource interface {
+	// Name identifies this source in log output
+	Name() string
+
+	// Hooks fetches the webhooks, along with the time at which they were current.  An empty list
+	// is treated as the source being unavailable.
+	Hooks(context.Context) ([]W, time.Time, error)
+}
+
+// BootstrapOptions configure how the initial webhooks are obtained
+type BootstrapOptions struct {
+	// Sources lists the bootstrap sources in priority order, using PeerBootstrap, SnapshotBootstrap,
+	// and HTTPBootstrap.  Sources that are not configured are skipped.  If not supplied, all three
+	// are used in that order.
+	Sources []string `json:"sources"`
+
+	// SnapshotFile is the path of the local snapshot, which is rewritten on every update of the
+	// webhook list.  If not supplied, no snapshot is written or read.
+	SnapshotFile string `json:"snapshotFile"`
+
+	// PeerPath is the URL path on which peers serve their webhooks.  If not supplied, DefaultPeerHooksPath is used.
+	PeerPath string `json:"peerPath"`
+
+	// MaxAge is the oldest a source's webhooks can be and still be used.  If not supplied,
+	// DefaultBootstrapMaxAge is used.  Set to a negative value to accept webhooks of any age.
+	MaxAge time.Duration `json:"maxAge"`
+
+	// InitialBackoff is the wait after the first round in which no source yields webhooks.  It doubles
+	// with each subsequent round, up to MaxBackoff.  If not supplied, DefaultBootstrapInitialBackoff is used.
+	InitialBackoff time.Duration `json:"initialBackoff"`
+
+	// MaxBackoff is the longest wait between rounds.  If not supplied, DefaultBootstrapMaxBackoff is used.
+	MaxBackoff time.Duration `json:"maxBackoff"`
+
+	// Timeout is the maximum time spent bootstrapping.  If not supplied, DefaultBootstrapTimeout is used.
+	Timeout time.Duration `json:"timeout"`
+
+	// Logger is the output sink for log messages.  If not supplied, log output is discarded.
+	Logger log.Logger `json:"-"`
+
+	// Now is the closure used to determine the current time.  If not set, time.Now is used.
+	Now func() time.Time `json:"-"`
+}
+
+func (o *BootstrapOptions) sources() []string {
+	if o != nil && len(o.Sources) > 0 {
+		return o.Sources
+	}
+
+	return defaultBootstrapSources
+}
+
+func (o *BootstrapOptions) snapshotFile() string {
+	if o != nil {
+		return o.SnapshotFile
+	}
+
+	return ""
+}
+
+func (o *BootstrapOptions) peerPath() string {
+	if o != nil && len(o.PeerPath) > 0 {
+		return o.PeerPath
+	}
+
+	return DefaultPeerHooksPath
+}
+
+func (o *BootstrapOptions) maxAge() time.Duration {
+	if o != nil && o.MaxAge != 0 {
+		return o.MaxAge
+	}
+
+	return DefaultBootstrapMaxAge
+}
+
+func (o *BootstrapOptions) initialBackoff() time.Duration {
+	if o != nil && o.InitialBackoff > 0 {
+		return o.InitialBackoff
+	}
+
+	return DefaultBootstrapInitialBackoff
+}
+
+func (o *BootstrapOptions) maxBackoff() time.Duration {
+	if o != nil && o.MaxBackoff > 0 {
+		return o.MaxBackoff
+	}
+
+	return DefaultBootstrapMaxBackoff
+}
+
+func (o *BootstrapOptions) timeout() time.Duration {
+	if o != nil && o.Timeout > 0 {
+		return o.Timeout
+	}
+
+	return DefaultBootstrapTimeout
+}
+
+func (o *BootstrapOptions) logger() log.Logger {
+	if o != nil && o.Logger != nil {
+		return o.Logger
+	}
+
+	return log.NewNopLogger()
+}
+
+func (o *BootstrapOptions) now() func() time.Time {
+	if o != nil && o.Now != nil {
+		return o.Now
+	}
+
+	return time.Now
+}
+
+// Bootstrap obtains the initial webhooks from the first source, in priority order, that yields a
+// nonempty list no older than the configured maximum age.  When every source fails, the sources are
+// retried with exponential backoff until the timeout elapses or the context is canceled.
+func Bootstrap(ctx context.Context, o *BootstrapOptions, sources ...BootstrapSource) ([]W, error) {
+	if len(sources) == 0 {
+		return nil, ErrNoBootstrapSources
+	}
+
+	var (
+		errorLog = level.Error(o.logger())
+		debugLog = level.Debug(o.logger())
+		now      = o.now()
+		maxAge   = o.maxAge()
+		backoff  = o.initialBackoff()
+		timeout  = time.NewTimer(o.timeout())
+	)
+
+	defer timeout.Stop()
+	for {
+		for _, s := range sources {
+			hooks, asOf, err := s.Hooks(ctx)
+			switch {
+			case err != nil:
+				errorLog.Log("msg", "webhook bootstrap source failed", "source", s.Name(), "error", err)
+
+			case len(hooks) == 0:
+				debugLog.Log("msg", "webhook bootstrap source has no webhooks", "source", s.Name())
+
+			case maxAge > 0 && now().Sub(asOf) > maxAge:
+				debugLog.Log("msg", "webhook bootstrap source is stale", "source", s.Name(), "asOf", asOf)
+
+			default:
+				debugLog.Log("msg", "webhooks bootstrapped", "source", s.Name(), "count", len(hooks))
+				return hooks, nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-timeout.C:
+			return nil, ErrBootstrapTimeout
+		case <-time.After(backoff):
+		}
+
+		if backoff *= 2; backoff > o.maxBackoff() {
+			backoff = o.maxBackoff()
+		}
+	}
+}
+
+// peerSource is a BootstrapSource which fetches webhooks from the first peer that has any
+type peerSource struct {
+	peers  func() []string
+	path   string
+	secret string
+	client xhttp.Client
+	now    func() time.Time
+}
+
+// NewPeerSource creates a BootstrapSource which GETs the webhooks from peer instances at the given path.
+// The peers closure is invoked on each attempt, so that it can reflect service discovery.  Each request
+// is signed with the given sync secret, which peers require before serving webhooks along with their secrets.
+func NewPeerSource(peers func() []string, path, secret string, client xhttp.Client) BootstrapSource {
+	if client == nil {
+		client = &http.Client{Timeout: DefaultSyncTimeout}
+	}
+
+	return &peerSource{
+		peers:  peers,
+		path:   path,
+		secret: secret,
+		client: client,
+		now:    time.Now,
+	}
+}
+
+func (ps *peerSource) Name() string {
+	return PeerBootstrap
+}
+
+func (ps *peerSource) Hooks(ctx context.Context) ([]W, time.Time, error) {
+	peers := ps.peers()
+	if len(peers) == 0 {
+		return nil, time.Time{}, errors.New("No webhook peers are known")
+	}
+
+	var errs []string
+	for _, peer := range peers {
+		hooks, err := ps.fetch(ctx, peer)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %s", peer, err))
+		} else if len(hooks) > 0 {
+			return hooks, ps.now(), nil
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, time.Time{}, errors.New(strings.Join(errs, "; "))
+	}
+
+	return nil, time.Time{}, nil
+}
+
+func (ps *peerSource) fetch(ctx context.Context, peer string) ([]W, error) {
+	request, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(peer, "/")+ps.path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(ps.secret) > 0 {
+		if err := SignHeader(request.Header, SignatureSHA256, []string{ps.secret}, nil, ps.now()); err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := ps.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return nil, err
+	}
+
+	body, err := getPayload(response)
+	if err != nil {
+		return nil, err
+	}
+
+	var hooks []W
+	err = json.Unmarshal(body, &hooks)
+	return hooks, err
+}
+
+// snapshot is the on-disk format of a SnapshotFile
+type snapshot struct {
+	Timestamp time.Time `json:"timestamp"`
+	Hooks     []W       `json:"hooks"`
+}
+
+// SnapshotFile is a local copy of the webhook list.  It is both a BootstrapSource and, via
+// NewSnapshotList, kept current as the list changes.
+type SnapshotFile struct {
+	Path string
+	Now  func() time.Time
+}
+
+func (sf *SnapshotFile) now() time.Time {
+	if sf.Now != nil {
+		return sf.Now()
+	}
+
+	return time.Now()
+}
+
+// Write atomically replaces the snapshot with the given webhooks
+func (sf *SnapshotFile) Write(hooks []W) error {
+	data, err := json.Marshal(snapshot{Timestamp: sf.now(), Hooks: hooks})
+	if err != nil {
+		return err
+	}
+
+	temp, err := ioutil.TempFile(filepath.Dir(sf.Path), filepath.Base(sf.Path)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = temp.Write(data)
+	if err == nil {
+		// the data must be durable before the rename, or a crash could leave an empty snapshot in place
+		err = temp.Sync()
+	}
+
+	if closeErr := temp.Close(); err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(temp.Name(), sf.Path)
+	}
+
+	if err != nil {
+		os.Remove(temp.Name())
+	}
+
+	return err
+}
+
+func (sf *SnapshotFile) Name() string {
+	return SnapshotBootstrap
+}
+
+// Hooks reads the webhooks from the snapshot, along with the time the snapshot was written
+func (sf *SnapshotFile) Hooks(context.Context) ([]W, time.Time, error) {
+	data, err := ioutil.ReadFile(sf.Path)
+	if err != nil {
+		return nil, time.Time{}, err
+	}
+
+	var s snapshot
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, time.Time{}, err
+	}
+
+	return s.Hooks, s.Timestamp, nil
+}
+
+// snapshotList is an UpdatableList decorator that writes a snapshot whenever the list changes
+type snapshotList struct {
+	UpdatableList
+	file     *SnapshotFile
+	errorLog log.Logger
+}
+
+// NewSnapshotList decorates an UpdatableList so that its contents are written to the given
+// snapshot file after every Update or Filter.  Failures to write the snapshot are logged.
+func NewSnapshotList(list UpdatableList, file *SnapshotFile, logger log.Logger) UpdatableList {
+	if logger == nil {
+		logger = log.NewNopLogger()
+	}
+
+	return &snapshotList{
+		UpdatableList: list,
+		file:          file,
+		errorLog:      level.Error(logger),
+	}
+}
+
+func (sl *snapshotList) Update(items []W) {
+	sl.UpdatableList.Update(items)
+	sl.write()
+}
+
+func (sl *snapshotList) Filter(filter func([]W) []W) {
+	sl.UpdatableList.Filter(filter)
+	sl.write()
+}
+
+func (sl *snapshotList) Snapshot() []W {
+	if s, ok := sl.UpdatableList.(Snapshotter); ok {
+		return s.Snapshot()
+	}
+
+	var hooks []W
+	for _, w := range Snapshot(sl.UpdatableList) {
+		hooks = append(hooks, *w)
+	}
+
+	return hooks
+}
+
+func (sl *snapshotList) write() {
+	hooks := sl.Snapshot()
+
+	if err := sl.file.Write(hooks); err != nil {
+		sl.errorLog.Log("msg", "unable to write webhook snapshot", "path", sl.file.Path, "error", err)
+	}
+}
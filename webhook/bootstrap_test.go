@@ -0,0 +1,380 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSource is a BootstrapSource which returns a fixed sequence of results, repeating the last
+type testSource struct {
+	name    string
+	results []testSourceResult
+	calls   int
+}
+
+type testSourceResult struct {
+	hooks []W
+	asOf  time.Time
+	err   error
+}
+
+func (ts *testSource) Name() string {
+	return ts.name
+}
+
+func (ts *testSource) Hooks(context.Context) ([]W, time.Time, error) {
+	r := ts.results[len(ts.results)-1]
+	if ts.calls < len(ts.results) {
+		r = ts.results[ts.calls]
+	}
+
+	ts.calls++
+	return r.hooks, r.asOf, r.err
+}
+
+func newBootstrapHooks(urls ...string) []W {
+	hooks := make([]W, len(urls))
+	for i, url := range urls {
+		hooks[i].Config.URL = url
+		hooks[i].Events = []string{".*"}
+		hooks[i].Until = time.Now().Add(time.Hour)
+	}
+
+	return hooks
+}
+
+func newTestSnapshotPath(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "webhook-snapshot")
+	require.NoError(t, err)
+	return filepath.Join(dir, "hooks.json"), func() { os.RemoveAll(dir) }
+}
+
+func TestBootstrapPriority(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		now     = time.Now()
+
+		failing = &testSource{name: "failing", results: []testSourceResult{{err: errors.New("expected")}}}
+		empty   = &testSource{name: "empty", results: []testSourceResult{{asOf: now}}}
+		stale   = &testSource{name: "stale", results: []testSourceResult{{hooks: newBootstrapHooks("http://stale.com/"), asOf: now.Add(-2 * time.Hour)}}}
+		fresh   = &testSource{name: "fresh", results: []testSourceResult{{hooks: newBootstrapHooks("http://fresh.com/"), asOf: now}}}
+		unused  = &testSource{name: "unused", results: []testSourceResult{{hooks: newBootstrapHooks("http://unused.com/"), asOf: now}}}
+	)
+
+	hooks, err := Bootstrap(context.Background(), nil, failing, empty, stale, fresh, unused)
+	require.NoError(err)
+	require.Len(hooks, 1)
+	assert.Equal("http://fresh.com/", hooks[0].Config.URL)
+	assert.Equal(1, stale.calls)
+	assert.Zero(unused.calls)
+
+	// any age is accepted when the freshness check is disabled
+	hooks, err = Bootstrap(context.Background(), &BootstrapOptions{MaxAge: -1}, stale, fresh)
+	require.NoError(err)
+	assert.Equal("http://stale.com/", hooks[0].Config.URL)
+}
+
+func TestBootstrapBackoff(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		source = &testSource{
+			name: "flaky",
+			results: []testSourceResult{
+				{err: errors.New("expected")},
+				{err: errors.New("expected")},
+				{hooks: newBootstrapHooks("http://example.com/"), asOf: time.Now()},
+			},
+		}
+	)
+
+	start := time.Now()
+	hooks, err := Bootstrap(context.Background(), &BootstrapOptions{InitialBackoff: 10 * time.Millisecond, MaxBackoff: 15 * time.Millisecond}, source)
+	require.NoError(err)
+	assert.Len(hooks, 1)
+	assert.Equal(3, source.calls)
+	assert.True(time.Since(start) >= 25*time.Millisecond)
+}
+
+func TestBootstrapFailure(t *testing.T) {
+	failing := &testSource{name: "failing", results: []testSourceResult{{err: errors.New("expected")}}}
+
+	t.Run("NoSources", func(t *testing.T) {
+		hooks, err := Bootstrap(context.Background(), nil)
+		assert.Nil(t, hooks)
+		assert.Equal(t, ErrNoBootstrapSources, err)
+	})
+
+	t.Run("Timeout", func(t *testing.T) {
+		hooks, err := Bootstrap(context.Background(), &BootstrapOptions{InitialBackoff: time.Millisecond, Timeout: 20 * time.Millisecond}, failing)
+		assert.Nil(t, hooks)
+		assert.Equal(t, ErrBootstrapTimeout, err)
+	})
+
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		hooks, err := Bootstrap(ctx, nil, failing)
+		assert.Nil(t, hooks)
+		assert.Equal(t, context.Canceled, err)
+	})
+}
+
+func TestPeerSource(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		empty = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.Write([]byte(`[]`))
+		}))
+
+		populated = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			assert.Equal(DefaultPeerHooksPath, request.URL.Path)
+			assert.NoError(NewVerifier(&VerifierOptions{Secrets: []string{"secret"}}).Verify(request.Header, nil))
+			response.Write([]byte(`[{"config": {"url": "http://example.com/"}, "events": [".*"]}]`))
+		}))
+
+		peers []string
+		ps    = NewPeerSource(func() []string { return peers }, DefaultPeerHooksPath, "secret", nil)
+	)
+
+	defer empty.Close()
+	defer populated.Close()
+	assert.Equal(PeerBootstrap, ps.Name())
+
+	_, _, err := ps.Hooks(context.Background())
+	assert.Error(err)
+
+	peers = []string{"http://127.0.0.1:1", empty.URL}
+	hooks, _, err := ps.Hooks(context.Background())
+	assert.Error(err)
+	assert.Empty(hooks)
+
+	peers = []string{"http://127.0.0.1:1", empty.URL, populated.URL + "/"}
+	hooks, asOf, err := ps.Hooks(context.Background())
+	require.NoError(err)
+	require.Len(hooks, 1)
+	assert.Equal("http://example.com/", hooks[0].Config.URL)
+	assert.WithinDuration(time.Now(), asOf, time.Minute)
+}
+
+func TestSnapshotFile(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		path, cleanup = newTestSnapshotPath(t)
+		written       = time.Date(2020, time.January, 1, 0, 0, 0, 0, time.UTC)
+		sf            = &SnapshotFile{Path: path, Now: func() time.Time { return written }}
+	)
+
+	defer cleanup()
+	assert.Equal(SnapshotBootstrap, sf.Name())
+
+	_, _, err := sf.Hooks(context.Background())
+	assert.Error(err)
+
+	require.NoError(sf.Write(newBootstrapHooks("http://one.com/", "http://two.com/")))
+	hooks, asOf, err := sf.Hooks(context.Background())
+	require.NoError(err)
+	assert.Equal(written, asOf.UTC())
+	require.Len(hooks, 2)
+	assert.Equal("http://two.com/", hooks[1].Config.URL)
+
+	// no temporary files are left behind
+	files, err := ioutil.ReadDir(filepath.Dir(path))
+	require.NoError(err)
+	assert.Len(files, 1)
+}
+
+func TestSnapshotList(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		path, cleanup = newTestSnapshotPath(t)
+		sf            = &SnapshotFile{Path: path}
+		list          = NewSnapshotList(NewList(nil), sf, nil)
+	)
+
+	defer cleanup()
+	list.Update(newBootstrapHooks("http://one.com/", "http://two.com/"))
+	hooks, _, err := sf.Hooks(context.Background())
+	require.NoError(err)
+	assert.Len(hooks, 2)
+
+	list.Filter(func(items []W) []W { return items[1:] })
+	hooks, _, err = sf.Hooks(context.Background())
+	require.NoError(err)
+	require.Len(hooks, 1)
+	assert.Equal("http://two.com/", hooks[0].Config.URL)
+}
+
+func TestStartConfigHooks(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		sc      = NewStartFactory(nil)
+	)
+
+	assert.Equal(HTTPBootstrap, sc.Name())
+	sc.Sat.Token = "token"
+	sc.client = testClient(t, `[{"config": {"url": "http://example.com/"}, "events": [".*"]}]`)
+
+	hooks, asOf, err := sc.Hooks(context.Background())
+	require.NoError(err)
+	require.Len(hooks, 1)
+	assert.Equal("http://example.com/", hooks[0].Config.URL)
+	assert.WithinDuration(time.Now(), asOf, time.Minute)
+}
+
+func TestFactoryBootstrapSources(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		f, err := NewFactory(nil)
+		require.NoError(t, err)
+
+		sources, err := f.BootstrapSources()
+		require.NoError(t, err)
+		require.Len(t, sources, 1)
+		assert.Equal(t, HTTPBootstrap, sources[0].Name())
+	})
+
+	t.Run("All", func(t *testing.T) {
+		f, err := NewFactory(nil)
+		require.NoError(t, err)
+		f.Notifier = NewGossipNotifier(&SyncConfig{Secret: "secret"})
+		f.Bootstrap = &BootstrapOptions{SnapshotFile: "hooks.json", Sources: []string{SnapshotBootstrap, PeerBootstrap, HTTPBootstrap}}
+
+		sources, err := f.BootstrapSources()
+		require.NoError(t, err)
+
+		var names []string
+		for _, s := range sources {
+			names = append(names, s.Name())
+		}
+
+		assert.Equal(t, []string{SnapshotBootstrap, PeerBootstrap, HTTPBootstrap}, names)
+	})
+
+	t.Run("Unsupported", func(t *testing.T) {
+		f, err := NewFactory(nil)
+		require.NoError(t, err)
+		f.Bootstrap = &BootstrapOptions{Sources: []string{"carrier-pigeon"}}
+
+		sources, err := f.BootstrapSources()
+		assert.Nil(t, sources)
+		assert.Error(t, err)
+	})
+}
+
+func TestFactoryBootstrapHooks(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		path, cleanup = newTestSnapshotPath(t)
+		existing      = newSyncNode(t, NewGossipNotifier(&SyncConfig{Secret: "secret"}))
+	)
+
+	defer cleanup()
+	defer existing.server.Close()
+
+	require.NoError(existing.factory.PublishMessage(newSyncMessage(t, "http://example.com/")))
+	require.Equal(1, existing.waitForHooks(1))
+
+	// the peer route is served, and refuses unsigned requests
+	response, err := http.Get(existing.server.URL + DefaultPeerHooksPath)
+	require.NoError(err)
+	response.Body.Close()
+	assert.Equal(http.StatusForbidden, response.StatusCode)
+
+	// a cold-started instance obtains its hooks from the route the existing peer serves, and snapshots them
+	gn := NewGossipNotifier(&SyncConfig{Peers: []string{existing.server.URL}, Secret: "secret"})
+	starting := newSyncNode(t, gn)
+	defer starting.server.Close()
+
+	starting.factory.Bootstrap = &BootstrapOptions{Sources: []string{PeerBootstrap}, SnapshotFile: path}
+	starting.factory.m.list = NewSnapshotList(starting.factory.m.list, &SnapshotFile{Path: path}, nil)
+
+	hooks, err := starting.factory.BootstrapHooks(context.Background())
+	require.NoError(err)
+	require.Len(hooks, 1)
+	require.Equal(1, starting.waitForHooks(1))
+	assert.Equal("http://example.com/", starting.factory.m.list.Get(0).Config.URL)
+
+	// with no peers, a restarted instance falls back to its snapshot
+	restarted, err := NewFactory(nil)
+	require.NoError(err)
+	restarted.Bootstrap = &BootstrapOptions{SnapshotFile: path, Sources: []string{PeerBootstrap, SnapshotBootstrap}}
+
+	hooks, err = restarted.BootstrapHooks(context.Background())
+	require.NoError(err)
+	require.Len(hooks, 1)
+	assert.Equal("http://example.com/", hooks[0].Config.URL)
+}
+
+func TestRegistryPeerHooks(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		node     = newSyncNode(t, NewGossipNotifier(&SyncConfig{Secret: "secret"}))
+		loopback = newSyncNode(t, NewLoopbackNotifier())
+		message  = new(W)
+	)
+
+	defer node.server.Close()
+	defer loopback.server.Close()
+
+	require.NoError(json.Unmarshal([]byte(newSyncMessage(t, "http://example.com/")), message))
+	message.Config.Secret = "hook secret"
+	data, err := json.Marshal(message)
+	require.NoError(err)
+	require.NoError(node.factory.PublishMessage(string(data)))
+	require.Equal(1, node.waitForHooks(1))
+
+	get := func(handler http.HandlerFunc, secret string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("GET", DefaultPeerHooksPath, nil)
+		if len(secret) > 0 {
+			require.NoError(SignHeader(request.Header, SignatureSHA256, []string{secret}, nil, time.Now()))
+		}
+
+		response := httptest.NewRecorder()
+		handler(response, request)
+		return response
+	}
+
+	// the public listing never exposes secrets
+	response := get(node.registry.GetRegistry, "")
+	require.Equal(http.StatusOK, response.Code)
+	assert.NotContains(response.Body.String(), "hook secret")
+	assert.Contains(response.Body.String(), "obfuscated")
+
+	// peers must sign their requests with the sync secret
+	assert.Equal(http.StatusForbidden, get(node.registry.GetPeerRegistry, "").Code)
+	assert.Equal(http.StatusForbidden, get(node.registry.GetPeerRegistry, "wrong").Code)
+	assert.Equal(http.StatusForbidden, get(loopback.registry.GetPeerRegistry, "secret").Code)
+
+	response = get(node.registry.GetPeerRegistry, "secret")
+	require.Equal(http.StatusOK, response.Code)
+
+	var hooks []W
+	require.NoError(json.Unmarshal(response.Body.Bytes(), &hooks))
+	require.Len(hooks, 1)
+	assert.Equal("hook secret", hooks[0].Config.Secret)
+}
@@ -0,0 +1,767 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics/provider"
+	"github.com/jithin-kg/webpa-common/device"
+	"github.com/jithin-kg/webpa-common/xhttp"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+const (
+	// SignatureHeader is the HTTP header carrying the HMAC signatures of a delivered event, in the form
+	// algorithm=hex, separated by commas.  It is only set for subscribers that have a secret.  See SignHeader.
+	SignatureHeader = "X-Webpa-Signature"
+
+	// EventHeader is the HTTP header carrying the event type of a delivered event
+	EventHeader = "X-Webpa-Event"
+
+	// DeviceIDHeader is the HTTP header carrying the ID of the device that produced a delivered event
+	DeviceIDHeader = "X-Webpa-Device-Id"
+
+	// EventPrefix is stripped from WRP destinations to produce the event type matched against W.Events
+	EventPrefix = "event:"
+
+	DefaultDispatchQueueSize   = 1000
+	DefaultDispatchWorkers     = 10
+	DefaultDeliveryRetries     = 1
+	DefaultDeliveryTimeout     = 10 * time.Second
+	DefaultDeliveryContentType = "application/msgpack"
+	DefaultCutOffPeriod        = 10 * time.Second
+)
+
+// OverflowPolicy determines what happens to an event when a subscriber's queue is full
+type OverflowPolicy string
+
+const (
+	// DropNewest discards the event that would have overflowed the queue.  This is the default.
+	DropNewest OverflowPolicy = "dropNewest"
+
+	// DropOldest discards the oldest queued event to make room for the new event
+	DropOldest OverflowPolicy = "dropOldest"
+
+	// CutOff discards all queued events, notifies the subscriber's FailureURL, and drops
+	// all events for the subscriber until the cut-off period has elapsed
+	CutOff OverflowPolicy = "cutOff"
+)
+
+// DispatcherOptions represent the available configuration options for a Dispatcher
+type DispatcherOptions struct {
+	// QueueSize is the capacity of each subscriber's queue of events.  When a subscriber's queue
+	// is full, events for that subscriber are dropped.  If not supplied, DefaultDispatchQueueSize is used.
+	QueueSize int `json:"queueSize"`
+
+	// Workers is the number of goroutines delivering events to each subscriber.  If not supplied,
+	// DefaultDispatchWorkers is used.
+	Workers int `json:"workers"`
+
+	// DeliveryRetries is the number of additional attempts made to deliver an event after the first
+	// attempt fails.  Each retry goes to the next of the subscriber's URLs.  If not supplied,
+	// DefaultDeliveryRetries is used.  Set to a negative value to disable retries.
+	DeliveryRetries int `json:"deliveryRetries"`
+
+	// OverflowPolicy is the action taken when a subscriber's queue is full.  If not supplied,
+	// DropNewest is used.
+	OverflowPolicy OverflowPolicy `json:"overflowPolicy"`
+
+	// CutOffPeriod is how long delivery is suspended for a subscriber that has been cut off.
+	// If not supplied, DefaultCutOffPeriod is used.
+	CutOffPeriod time.Duration `json:"cutOffPeriod"`
+
+	// DeliveryLogSize is the number of delivery records kept for each subscriber.  If not supplied,
+	// DefaultDeliveryLogSize is used.  Set to a negative value to disable the delivery log.
+	DeliveryLogSize int `json:"deliveryLogSize"`
+
+	// ReplayBufferSize is the number of recent events kept for each subscriber so that they can be
+	// replayed.  If not supplied, DefaultReplayBufferSize is used.  Set to a negative value to disable replay.
+	ReplayBufferSize int `json:"replayBufferSize"`
+
+	// AllowedNetworks are CIDR blocks that deliveries may connect to even though they fall within the
+	// ranges blocked by the webhook Validator.  Invalid entries are logged and ignored.
+	// Only used when Client is not supplied.
+	AllowedNetworks []string `json:"allowedNetworks"`
+
+	// Client is the HTTP client used to deliver events.  If not supplied, an http.Client with a
+	// DefaultDeliveryTimeout timeout is used.  That client does not follow redirects, and refuses to
+	// connect to blocked addresses outside AllowedNetworks however the webhook's host resolves.
+	Client xhttp.Client `json:"-"`
+
+	// Logger is the output sink for log messages.  If not supplied, log output is discarded.
+	Logger log.Logger `json:"-"`
+
+	// MetricsProvider is the go-kit factory for metrics.  If not supplied, metrics are discarded.
+	MetricsProvider provider.Provider `json:"-"`
+
+	// Now is the closure used to determine the current time.  If not set, time.Now is used.
+	Now func() time.Time `json:"-"`
+}
+
+func (o *DispatcherOptions) queueSize() int {
+	if o != nil && o.QueueSize > 0 {
+		return o.QueueSize
+	}
+
+	return DefaultDispatchQueueSize
+}
+
+func (o *DispatcherOptions) workers() int {
+	if o != nil && o.Workers > 0 {
+		return o.Workers
+	}
+
+	return DefaultDispatchWorkers
+}
+
+func (o *DispatcherOptions) deliveryRetries() int {
+	if o != nil {
+		if o.DeliveryRetries > 0 {
+			return o.DeliveryRetries
+		} else if o.DeliveryRetries < 0 {
+			return 0
+		}
+	}
+
+	return DefaultDeliveryRetries
+}
+
+func (o *DispatcherOptions) overflowPolicy() OverflowPolicy {
+	if o != nil && len(o.OverflowPolicy) > 0 {
+		return o.OverflowPolicy
+	}
+
+	return DropNewest
+}
+
+func (o *DispatcherOptions) cutOffPeriod() time.Duration {
+	if o != nil && o.CutOffPeriod > 0 {
+		return o.CutOffPeriod
+	}
+
+	return DefaultCutOffPeriod
+}
+
+func (o *DispatcherOptions) deliveryLogSize() int {
+	if o != nil && o.DeliveryLogSize != 0 {
+		return o.DeliveryLogSize
+	}
+
+	return DefaultDeliveryLogSize
+}
+
+func (o *DispatcherOptions) replayBufferSize() int {
+	if o != nil && o.ReplayBufferSize != 0 {
+		return o.ReplayBufferSize
+	}
+
+	return DefaultReplayBufferSize
+}
+
+func (o *DispatcherOptions) allowedNetworks(errorLog log.Logger) []*net.IPNet {
+	var allowed []*net.IPNet
+	if o != nil {
+		for _, cidr := range o.AllowedNetworks {
+			if network, err := parseNetworks([]string{cidr}); err != nil {
+				errorLog.Log("msg", "ignoring invalid allowed network", "network", cidr, "error", err)
+			} else {
+				allowed = append(allowed, network...)
+			}
+		}
+	}
+
+	return allowed
+}
+
+func (o *DispatcherOptions) client(errorLog log.Logger) xhttp.Client {
+	if o != nil && o.Client != nil {
+		return o.Client
+	}
+
+	return newRestrictedClient(DefaultDeliveryTimeout, o.allowedNetworks(errorLog))
+}
+
+func (o *DispatcherOptions) logger() log.Logger {
+	if o != nil && o.Logger != nil {
+		return o.Logger
+	}
+
+	return log.NewNopLogger()
+}
+
+func (o *DispatcherOptions) metricsProvider() provider.Provider {
+	if o != nil && o.MetricsProvider != nil {
+		return o.MetricsProvider
+	}
+
+	return provider.NewDiscardProvider()
+}
+
+func (o *DispatcherOptions) now() func() time.Time {
+	if o != nil && o.Now != nil {
+		return o.Now
+	}
+
+	return time.Now
+}
+
+// Sign computes the legacy SignatureSHA1 value of the SignatureHeader for the given body and secret
+func Sign(secret string, body []byte) string {
+	h := hmac.New(sha1.New, []byte(secret))
+	h.Write(body)
+	return "sha1=" + hex.EncodeToString(h.Sum(nil))
+}
+
+// FailureMessage is the notification POSTed to a webhook's FailureURL when the webhook is cut off
+// due to event overflow
+type FailureMessage struct {
+	Text         string `json:"text"`
+	Original     W      `json:"webhook_registration"`
+	CutOffPeriod string `json:"cut_off_period"`
+	QueueSize    int    `json:"queue_size"`
+	Workers      int    `json:"worker_pool"`
+}
+
+// delivery is a single event queued for a subscriber
+type delivery struct {
+	hook            W
+	eventType       string
+	deviceID        string
+	transactionUUID string
+
+	// timestamp is when the event was dispatched
+	timestamp time.Time
+
+	// replay is set for deliveries made in response to a replay request
+	replay bool
+
+	// contents is the event's msgpack-encoded WRP message, copied from the device.Event
+	contents []byte
+}
+
+// subscriber holds the queue and workers for a single webhook
+type subscriber struct {
+	key        hookKey
+	id         string
+	queue      chan delivery
+	generation uint64
+
+	// next is the index of the URL used for the next delivery attempt
+	next uint32
+
+	// cutOffUntil is the time at which delivery resumes for a subscriber that has been cut off
+	cutOffUntil time.Time
+
+	history *history
+}
+
+// url returns the URL to use for the next delivery attempt
+func (s *subscriber) url(hook *W) string {
+	count := uint32(1 + len(hook.Config.AlternativeURLs))
+	index := atomic.LoadUint32(&s.next) % count
+	if index == 0 {
+		return hook.Config.URL
+	}
+
+	return hook.Config.AlternativeURLs[index-1]
+}
+
+// failover moves this subscriber onto its next URL
+func (s *subscriber) failover() {
+	atomic.AddUint32(&s.next, 1)
+}
+
+// Dispatcher delivers device events to the webhooks in a List.  The Dispatcher's OnDeviceEvent
+// method is a device.Listener.
+//
+// Each MessageReceived event is matched against every webhook in the list.  A webhook matches when
+// any of its Events expressions matches the event type, which is the WRP destination with any
+// EventPrefix removed, and its Matcher matches the WRP message and the device that sent it.
+// Each webhook's expressions are compiled once, when it first appears in the list.
+// Matching events are queued for delivery by that webhook's workers.  When a webhook's queue is full,
+// the configured OverflowPolicy applies.
+type Dispatcher struct {
+	list       List
+	queueSize  int
+	workers    int
+	retries    int
+	overflow   OverflowPolicy
+	cutOff     time.Duration
+	logSize    int
+	replaySize int
+	client     xhttp.Client
+	errorLog   log.Logger
+	debugLog   log.Logger
+	measures   WebhookMetrics
+	now        func() time.Time
+	waitGroup  sync.WaitGroup
+	lock       sync.Mutex
+	stopped    bool
+	generation uint64
+
+	subscribers map[hookKey]*subscriber
+	matchers    map[hookKey]*hookMatcher
+}
+
+// hookMatcher caches the compiled form of a webhook in the list.  Updating a list replaces its webhooks,
+// so a webhook that is no longer the cached source has been updated and is recompiled.
+type hookMatcher struct {
+	source     *W
+	generation uint64
+
+	// compiled is nil if the webhook has an invalid expression, in which case it never matches
+	compiled *CompiledMatcher
+}
+
+// NewDispatcher creates a Dispatcher for the given list of webhooks.  The list is consulted for each
+// event, so updates to it take effect immediately.
+func NewDispatcher(o *DispatcherOptions, list List) *Dispatcher {
+	var (
+		logger   = o.logger()
+		errorLog = level.Error(logger)
+	)
+
+	return &Dispatcher{
+		list:        list,
+		queueSize:   o.queueSize(),
+		workers:     o.workers(),
+		retries:     o.deliveryRetries(),
+		overflow:    o.overflowPolicy(),
+		cutOff:      o.cutOffPeriod(),
+		logSize:     o.deliveryLogSize(),
+		replaySize:  o.replayBufferSize(),
+		client:      o.client(errorLog),
+		errorLog:    errorLog,
+		debugLog:    level.Debug(logger),
+		measures:    applyMetrics(o.metricsProvider()),
+		now:         o.now(),
+		subscribers: make(map[hookKey]*subscriber),
+		matchers:    make(map[hookKey]*hookMatcher),
+	}
+}
+
+// matcher returns the compiled matcher for a webhook, compiling it if the webhook is new or
+// has been updated.  This method must be called under the lock.
+func (d *Dispatcher) matcher(hook *W) *CompiledMatcher {
+	key := hook.key()
+	hm := d.matchers[key]
+	if hm == nil || hm.source != hook {
+		hm = &hookMatcher{source: hook}
+		compiled, err := hook.Compile()
+		if err != nil {
+			d.errorLog.Log("msg", "invalid webhook matcher", URLLabel, hook.ID(), "error", err)
+		} else {
+			hm.compiled = compiled
+		}
+
+		d.matchers[key] = hm
+	}
+
+	hm.generation = d.generation
+	return hm.compiled
+}
+
+// OnDeviceEvent matches a device event against the current list of webhooks and queues it
+// for each webhook that matches.  This method never blocks on delivery.
+func (d *Dispatcher) OnDeviceEvent(e *device.Event) {
+	if e.Type != device.MessageReceived {
+		return
+	}
+
+	message, ok := e.Message.(*wrp.Message)
+	if !ok {
+		return
+	}
+
+	var (
+		eventType = strings.TrimPrefix(message.Destination, EventPrefix)
+		deviceID  = string(e.Device.ID())
+		now       = d.now()
+		contents  []byte
+		mc        = newMatchContext(message, e.Device)
+	)
+
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.stopped {
+		return
+	}
+
+	d.generation++
+	for _, hook := range Snapshot(d.list) {
+		if !hook.Until.IsZero() && now.After(hook.Until) {
+			continue
+		}
+
+		s := d.subscribers[hook.key()]
+		if s != nil {
+			s.generation = d.generation
+		}
+
+		m := d.matcher(hook)
+		if m == nil || !m.events.match(eventType) || !m.matcher.match(mc) {
+			continue
+		}
+
+		if s == nil {
+			s = d.newSubscriber(hook.key())
+		}
+
+		if contents == nil {
+			contents = append([]byte{}, e.Contents...)
+		}
+
+		dl := delivery{
+			hook:            *hook,
+			eventType:       eventType,
+			deviceID:        deviceID,
+			transactionUUID: message.TransactionUUID,
+			timestamp:       now,
+			contents:        contents,
+		}
+
+		s.history.retain(dl)
+		d.enqueue(s, now, dl)
+	}
+
+	// discard subscribers whose webhooks are no longer in the list
+	for key, s := range d.subscribers {
+		if s.generation != d.generation {
+			d.debugLog.Log("msg", "removing webhook subscriber", URLLabel, key.url, "owner", key.owner)
+			close(s.queue)
+			delete(d.subscribers, key)
+		}
+	}
+
+	for key, hm := range d.matchers {
+		if hm.generation != d.generation {
+			delete(d.matchers, key)
+		}
+	}
+}
+
+// newSubscriber creates a subscriber and starts its workers.  This method must be called under the lock.
+func (d *Dispatcher) newSubscriber(key hookKey) *subscriber {
+	s := &subscriber{
+		key:        key,
+		id:         key.url,
+		queue:      make(chan delivery, d.queueSize),
+		generation: d.generation,
+		history:    newHistory(d.logSize, d.replaySize),
+	}
+
+	d.subscribers[key] = s
+	d.waitGroup.Add(d.workers)
+	for i := 0; i < d.workers; i++ {
+		go d.work(s)
+	}
+
+	return s
+}
+
+// enqueue adds a delivery to a subscriber's queue, applying the overflow policy if the queue is full.
+// This method must be called under the lock.
+func (d *Dispatcher) enqueue(s *subscriber, now time.Time, dl delivery) {
+	if now.Before(s.cutOffUntil) {
+		d.measures.DroppedEvents.With(URLLabel, s.id, ReasonLabel, CutOffReason).Add(1.0)
+		return
+	}
+
+	select {
+	case s.queue <- dl:
+		d.measures.QueueDepth.With(URLLabel, s.id).Set(float64(len(s.queue)))
+		return
+	default:
+	}
+
+	switch d.overflow {
+	case DropOldest:
+		// workers only ever remove deliveries, and enqueue is serialized by the lock,
+		// so there is always room after removing one
+		select {
+		case <-s.queue:
+			d.measures.DroppedEvents.With(URLLabel, s.id, ReasonLabel, OverflowReason).Add(1.0)
+		default:
+		}
+
+		s.queue <- dl
+
+	case CutOff:
+		d.errorLog.Log("msg", "webhook queue full, cutting off subscriber", URLLabel, s.id, "period", d.cutOff)
+		s.cutOffUntil = now.Add(d.cutOff)
+		d.measures.CutOffs.With(URLLabel, s.id).Add(1.0)
+
+		dropped := 1
+		for drained := false; !drained; {
+			select {
+			case <-s.queue:
+				dropped++
+			default:
+				drained = true
+			}
+		}
+
+		d.measures.DroppedEvents.With(URLLabel, s.id, ReasonLabel, CutOffReason).Add(float64(dropped))
+		if len(dl.hook.FailureURL) > 0 {
+			d.waitGroup.Add(1)
+			go d.notifyFailure(dl.hook, now)
+		}
+
+	default:
+		d.debugLog.Log("msg", "webhook queue full, dropping event", URLLabel, s.id, "event", dl.eventType)
+		d.measures.DroppedEvents.With(URLLabel, s.id, ReasonLabel, QueueFullReason).Add(1.0)
+	}
+
+	d.measures.QueueDepth.With(URLLabel, s.id).Set(float64(len(s.queue)))
+}
+
+// notifyFailure POSTs a FailureMessage to a webhook's FailureURL.  The notification is signed as of
+// the time the subscriber was cut off.
+func (d *Dispatcher) notifyFailure(hook W, now time.Time) {
+	defer d.waitGroup.Done()
+
+	original := hook
+	original.Config.Secret = ""
+	original.Config.PreviousSecret = ""
+	body, err := json.Marshal(FailureMessage{
+		Text:         fmt.Sprintf("Unfortunately, your endpoint is not able to keep up with the traffic being sent to it.  Due to this circumstance, all notification traffic is being cut off and dropped for a period of %s.  Please increase your capacity to handle notifications, or reduce the number of notifications you have requested.", d.cutOff),
+		Original:     original,
+		CutOffPeriod: d.cutOff.String(),
+		QueueSize:    d.queueSize,
+		Workers:      d.workers,
+	})
+
+	if err != nil {
+		d.errorLog.Log("msg", "unable to encode failure notification", URLLabel, hook.ID(), "error", err)
+		return
+	}
+
+	header := make(http.Header)
+	header.Set("Content-Type", "application/json")
+	if err := SignHeader(header, hook.Config.SignatureAlgorithm, hook.ActiveSecrets(now), body, now); err != nil {
+		d.errorLog.Log("msg", "unable to sign failure notification", URLLabel, hook.ID(), "error", err)
+		return
+	}
+
+	code, err := d.post(hook.FailureURL, body, header)
+	if err != nil {
+		d.errorLog.Log("msg", "failure notification failed", URLLabel, hook.FailureURL, "error", err)
+	} else if code < 200 || code >= 300 {
+		d.errorLog.Log("msg", "failure notification rejected", URLLabel, hook.FailureURL, "code", code)
+	}
+}
+
+func (d *Dispatcher) work(s *subscriber) {
+	defer d.waitGroup.Done()
+	for dl := range s.queue {
+		d.measures.QueueDepth.With(URLLabel, s.id).Set(float64(len(s.queue)))
+		d.deliver(s, dl)
+	}
+}
+
+// encode produces the HTTP body and content type for a delivery.  Subscribers with a WRP content type
+// receive the entire WRP message in that format.  Other subscribers receive the WRP payload, using the
+// message's content type if it has one.
+func encode(dl delivery) ([]byte, string, error) {
+	contentType := dl.hook.Config.ContentType
+	if len(contentType) == 0 {
+		contentType = DefaultDeliveryContentType
+	}
+
+	if format, err := wrp.FormatFromContentType(contentType); err == nil {
+		if format == wrp.Msgpack {
+			return dl.contents, contentType, nil
+		}
+
+		var (
+			message wrp.Message
+			body    []byte
+		)
+
+		if err := wrp.NewDecoderBytes(dl.contents, wrp.Msgpack).Decode(&message); err != nil {
+			return nil, "", err
+		}
+
+		if err := wrp.NewEncoderBytes(&body, format).Encode(&message); err != nil {
+			return nil, "", err
+		}
+
+		return body, contentType, nil
+	}
+
+	var message wrp.Message
+	if err := wrp.NewDecoderBytes(dl.contents, wrp.Msgpack).Decode(&message); err != nil {
+		return nil, "", err
+	}
+
+	if len(message.ContentType) > 0 {
+		contentType = message.ContentType
+	}
+
+	return message.Payload, contentType, nil
+}
+
+// deliver POSTs an event to a subscriber, failing over through the subscriber's URLs.  The outcome
+// is recorded in the subscriber's delivery log.
+func (d *Dispatcher) deliver(s *subscriber, dl delivery) {
+	record := DeliveryRecord{
+		Timestamp:       dl.timestamp,
+		URL:             s.id,
+		EventType:       dl.eventType,
+		DeviceID:        dl.deviceID,
+		TransactionUUID: dl.transactionUUID,
+		Replay:          dl.replay,
+	}
+
+	defer func() { s.history.record(record) }()
+
+	body, contentType, err := encode(dl)
+	if err != nil {
+		d.errorLog.Log("msg", "unable to encode event", URLLabel, s.id, "error", err)
+		return
+	}
+
+	for attempt := 0; attempt <= d.retries; attempt++ {
+		var (
+			url   = s.url(&dl.hook)
+			start = d.now()
+		)
+
+		code, err := d.send(url, body, contentType, dl)
+		record.Attempts++
+		record.Latency += d.now().Sub(start)
+		record.StatusCode = code
+		if err != nil {
+			d.errorLog.Log("msg", "event delivery failed", URLLabel, url, "error", err)
+			d.measures.Delivery.With(URLLabel, s.id, CodeLabel, TransportFailureCode).Add(1.0)
+		} else {
+			d.measures.Delivery.With(URLLabel, s.id, CodeLabel, strconv.Itoa(code)).Add(1.0)
+			if code >= 200 && code < 300 {
+				return
+			}
+
+			d.errorLog.Log("msg", "event delivery rejected", URLLabel, url, "code", code)
+		}
+
+		s.failover()
+	}
+}
+
+func (d *Dispatcher) send(url string, body []byte, contentType string, dl delivery) (int, error) {
+	header := make(http.Header)
+	header.Set("Content-Type", contentType)
+	header.Set(EventHeader, dl.eventType)
+	header.Set(DeviceIDHeader, dl.deviceID)
+	now := d.now()
+	if err := SignHeader(header, dl.hook.Config.SignatureAlgorithm, dl.hook.ActiveSecrets(now), body, now); err != nil {
+		return 0, err
+	}
+
+	return d.post(url, body, header)
+}
+
+// post sends a body and returns the response status code
+func (d *Dispatcher) post(url string, body []byte, header http.Header) (int, error) {
+	request, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	request.Header = header
+	response, err := d.client.Do(request)
+	if err != nil {
+		return 0, err
+	}
+
+	io.Copy(ioutil.Discard, response.Body)
+	response.Body.Close()
+	return response.StatusCode, nil
+}
+
+// Deliveries returns the delivery records for the subscriber with the given owner and webhook URL, oldest first.
+// Only records whose events were dispatched within [from, to] are returned.  A zero from or to leaves that end
+// of the range unbounded.  Webhooks that are not managed per owner have an empty owner.
+func (d *Dispatcher) Deliveries(owner, url string, from, to time.Time) []DeliveryRecord {
+	d.lock.Lock()
+	s := d.subscribers[hookKey{owner: owner, url: url}]
+	d.lock.Unlock()
+
+	if s == nil {
+		return nil
+	}
+
+	return s.history.deliveries(from, to)
+}
+
+// Replay queues the retained events dispatched within [from, to] for redelivery to the subscriber with
+// the given owner and webhook URL, using the webhook's current configuration.  The number of events queued
+// is returned.  Replayed events are subject to the overflow policy like any other event.
+func (d *Dispatcher) Replay(owner, url string, from, to time.Time) (int, error) {
+	d.lock.Lock()
+	defer d.lock.Unlock()
+
+	if d.stopped {
+		return 0, ErrDispatcherStopped
+	}
+
+	key := hookKey{owner: owner, url: url}
+	s := d.subscribers[key]
+	if s == nil {
+		return 0, ErrSubscriberNotFound
+	}
+
+	var hook *W
+	for _, candidate := range Snapshot(d.list) {
+		if candidate.key() == key {
+			hook = candidate
+			break
+		}
+	}
+
+	if hook == nil {
+		return 0, ErrSubscriberNotFound
+	}
+
+	now := d.now()
+	replayable := s.history.replayable(from, to)
+	for _, dl := range replayable {
+		dl.hook = *hook
+		dl.replay = true
+		d.enqueue(s, now, dl)
+	}
+
+	d.debugLog.Log("msg", "replayed events", URLLabel, url, "owner", owner, "count", len(replayable))
+	return len(replayable), nil
+}
+
+// Stop shuts down this Dispatcher.  Events already queued are delivered before this method returns.
+// Subsequent events are ignored.
+func (d *Dispatcher) Stop() {
+	d.lock.Lock()
+	if !d.stopped {
+		d.stopped = true
+		for key, s := range d.subscribers {
+			close(s.queue)
+			delete(d.subscribers, key)
+		}
+	}
+
+	d.lock.Unlock()
+	d.waitGroup.Wait()
+}
@@ -0,0 +1,460 @@
+package webhook
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/jithin-kg/webpa-common/device"
+	"github.com/jithin-kg/webpa-common/xmetrics/xmetricstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+// testAllowedNetworks permits deliveries to the loopback addresses used by httptest servers
+var testAllowedNetworks = []string{"127.0.0.0/8", "::1/128"}
+
+type receivedEvent struct {
+	header http.Header
+	body   []byte
+}
+
+// newTestReceiver returns a server which records the events it receives and responds with the given status code
+func newTestReceiver(statusCode int) (*httptest.Server, <-chan receivedEvent) {
+	received := make(chan receivedEvent, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		body, _ := ioutil.ReadAll(request.Body)
+		received <- receivedEvent{header: request.Header, body: body}
+		response.WriteHeader(statusCode)
+	}))
+
+	return server, received
+}
+
+func newTestHook(url string, events ...string) W {
+	w := W{Events: events, Until: time.Now().Add(time.Hour)}
+	w.Config.URL = url
+	w.Matcher.DeviceId = []string{".*"}
+	return w
+}
+
+func newTestEvent(t *testing.T, deviceID device.ID, destination string) (*device.Event, *wrp.Message) {
+	d := new(device.MockDevice)
+	d.On("ID").Return(deviceID)
+
+	message := &wrp.Message{
+		Type:        wrp.SimpleEventMessageType,
+		Source:      string(deviceID),
+		Destination: destination,
+		ContentType: "text/plain",
+		Payload:     []byte("hello"),
+	}
+
+	return &device.Event{
+		Type:     device.MessageReceived,
+		Device:   d,
+		Message:  message,
+		Format:   wrp.Msgpack,
+		Contents: wrp.MustEncode(message, wrp.Msgpack),
+	}, message
+}
+
+func testDispatcherMsgpack(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server, received = newTestReceiver(http.StatusAccepted)
+		hook             = newTestHook(server.URL, "device-status/.*")
+	)
+
+	defer server.Close()
+	hook.Config.ContentType = "application/msgpack"
+	hook.Config.Secret = "secret"
+
+	d := NewDispatcher(&DispatcherOptions{AllowedNetworks: testAllowedNetworks}, NewList([]W{hook}))
+	event, message := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+	d.OnDeviceEvent(event)
+	d.Stop()
+
+	require.Len(received, 1)
+	r := <-received
+	assert.Equal("application/msgpack", r.header.Get("Content-Type"))
+	assert.Equal("device-status/online", r.header.Get(EventHeader))
+	assert.Equal("mac:112233445566", r.header.Get(DeviceIDHeader))
+	assert.Equal(Sign("secret", r.body), r.header.Get(SignatureHeader))
+
+	var actual wrp.Message
+	require.NoError(wrp.NewDecoderBytes(r.body, wrp.Msgpack).Decode(&actual))
+	assert.Equal(*message, actual)
+}
+
+func testDispatcherJSON(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server, received = newTestReceiver(http.StatusOK)
+		hook             = newTestHook(server.URL, ".*")
+	)
+
+	defer server.Close()
+	hook.Config.ContentType = "application/json"
+
+	d := NewDispatcher(&DispatcherOptions{AllowedNetworks: testAllowedNetworks}, NewList([]W{hook}))
+	event, message := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+	d.OnDeviceEvent(event)
+	d.Stop()
+
+	require.Len(received, 1)
+	r := <-received
+	assert.Equal("application/json", r.header.Get("Content-Type"))
+	assert.Empty(r.header.Get(SignatureHeader))
+
+	var actual wrp.Message
+	require.NoError(wrp.NewDecoderBytes(r.body, wrp.JSON).Decode(&actual))
+	assert.Equal(*message, actual)
+}
+
+func testDispatcherPayload(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server, received = newTestReceiver(http.StatusOK)
+		hook             = newTestHook(server.URL, ".*")
+	)
+
+	defer server.Close()
+	hook.Config.ContentType = "application/octet-stream"
+
+	d := NewDispatcher(&DispatcherOptions{AllowedNetworks: testAllowedNetworks}, NewList([]W{hook}))
+	event, _ := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+	d.OnDeviceEvent(event)
+	d.Stop()
+
+	require.Len(received, 1)
+	r := <-received
+	assert.Equal("text/plain", r.header.Get("Content-Type"))
+	assert.Equal([]byte("hello"), r.body)
+}
+
+func testDispatcherNoMatch(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		server, received = newTestReceiver(http.StatusOK)
+		eventHook        = newTestHook(server.URL+"/events", "device-status/.*")
+		deviceHook       = newTestHook(server.URL+"/devices", ".*")
+		invalidHook      = newTestHook(server.URL+"/invalid", "(")
+	)
+
+	defer server.Close()
+	deviceHook.Matcher.DeviceId = []string{"mac:aabbccddeeff"}
+
+	d := NewDispatcher(&DispatcherOptions{AllowedNetworks: testAllowedNetworks}, NewList([]W{eventHook, deviceHook, invalidHook}))
+	event, _ := newTestEvent(t, "mac:112233445566", "event:iot/something")
+	d.OnDeviceEvent(event)
+
+	event.Type = device.Connect
+	d.OnDeviceEvent(event)
+	d.Stop()
+
+	assert.Len(received, 0)
+}
+
+func testDispatcherFailover(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		primary, primaryReceived     = newTestReceiver(http.StatusServiceUnavailable)
+		alternate, alternateReceived = newTestReceiver(http.StatusOK)
+		hook                         = newTestHook(primary.URL, ".*")
+		p                            = xmetricstest.NewProvider(nil, Metrics)
+	)
+
+	defer primary.Close()
+	defer alternate.Close()
+	hook.Config.AlternativeURLs = []string{alternate.URL}
+
+	d := NewDispatcher(&DispatcherOptions{Workers: 1, MetricsProvider: p, AllowedNetworks: testAllowedNetworks}, NewList([]W{hook}))
+	event, _ := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+	d.OnDeviceEvent(event)
+	d.OnDeviceEvent(event)
+
+	s := d.subscribers[hookKey{url: primary.URL}]
+	require.NotNil(s)
+	d.Stop()
+
+	// the first event fails over to the alternate, which then receives subsequent events
+	require.Len(primaryReceived, 1)
+	require.Len(alternateReceived, 2)
+	p.Assert(t, DeliveryCounter, URLLabel, primary.URL, CodeLabel, "503")(xmetricstest.Value(1.0))
+	p.Assert(t, DeliveryCounter, URLLabel, primary.URL, CodeLabel, "200")(xmetricstest.Value(2.0))
+	assert.Equal(uint32(1), s.next)
+}
+
+// newBlockingReceiver returns a server which signals each request it receives and then blocks until the block channel is closed
+func newBlockingReceiver() (*httptest.Server, <-chan string, chan struct{}) {
+	var (
+		block    = make(chan struct{})
+		received = make(chan string, 10)
+		server   = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			body, _ := ioutil.ReadAll(request.Body)
+			received <- string(body)
+			<-block
+		}))
+	)
+
+	return server, received, block
+}
+
+func testDispatcherDropNewest(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		server, received, block = newBlockingReceiver()
+		hook                    = newTestHook(server.URL, ".*")
+		p                       = xmetricstest.NewProvider(nil, Metrics)
+	)
+
+	defer server.Close()
+	hook.Config.ContentType = "text/plain"
+
+	d := NewDispatcher(&DispatcherOptions{Workers: 1, QueueSize: 1, DeliveryRetries: -1, MetricsProvider: p, AllowedNetworks: testAllowedNetworks}, NewList([]W{hook}))
+
+	// the first event occupies the worker, the second fills the queue, and the third is dropped
+	first, _ := newTestEvent(t, "mac:112233445566", "event:first")
+	d.OnDeviceEvent(first)
+	assert.Equal("hello", <-received)
+
+	for _, payload := range []string{"second", "third"} {
+		event, message := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+		message.Payload = []byte(payload)
+		event.Contents = wrp.MustEncode(message, wrp.Msgpack)
+		d.OnDeviceEvent(event)
+	}
+
+	p.Assert(t, DroppedEventsCounter, URLLabel, server.URL, ReasonLabel, QueueFullReason)(xmetricstest.Value(1.0))
+	p.Assert(t, QueueDepthGauge, URLLabel, server.URL)(xmetricstest.Value(1.0))
+	close(block)
+	d.Stop()
+
+	assert.Equal("second", <-received)
+	assert.Len(received, 0)
+}
+
+func testDispatcherDropOldest(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		server, received, block = newBlockingReceiver()
+		hook                    = newTestHook(server.URL, ".*")
+		p                       = xmetricstest.NewProvider(nil, Metrics)
+	)
+
+	defer server.Close()
+	hook.Config.ContentType = "text/plain"
+
+	d := NewDispatcher(&DispatcherOptions{Workers: 1, QueueSize: 1, DeliveryRetries: -1, OverflowPolicy: DropOldest, MetricsProvider: p, AllowedNetworks: testAllowedNetworks}, NewList([]W{hook}))
+
+	first, _ := newTestEvent(t, "mac:112233445566", "event:first")
+	d.OnDeviceEvent(first)
+	assert.Equal("hello", <-received)
+
+	for _, payload := range []string{"second", "third"} {
+		event, message := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+		message.Payload = []byte(payload)
+		event.Contents = wrp.MustEncode(message, wrp.Msgpack)
+		d.OnDeviceEvent(event)
+	}
+
+	p.Assert(t, DroppedEventsCounter, URLLabel, server.URL, ReasonLabel, OverflowReason)(xmetricstest.Value(1.0))
+	close(block)
+	d.Stop()
+
+	assert.Equal("third", <-received)
+	assert.Len(received, 0)
+}
+
+func testDispatcherCutOff(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server, received, block = newBlockingReceiver()
+		failure, failures       = newTestReceiver(http.StatusOK)
+		hook                    = newTestHook(server.URL, ".*")
+		p                       = xmetricstest.NewProvider(nil, Metrics)
+
+		now     = time.Now()
+		options = &DispatcherOptions{
+			Workers:         1,
+			QueueSize:       1,
+			DeliveryRetries: -1,
+			OverflowPolicy:  CutOff,
+			CutOffPeriod:    time.Minute,
+			AllowedNetworks: testAllowedNetworks,
+			MetricsProvider: p,
+			Now:             func() time.Time { return now },
+		}
+	)
+
+	defer server.Close()
+	defer failure.Close()
+	hook.FailureURL = failure.URL
+	hook.Config.Secret = "secret"
+
+	d := NewDispatcher(options, NewList([]W{hook}))
+	event, _ := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+
+	// the first event occupies the worker, the second fills the queue, and the third overflows
+	d.OnDeviceEvent(event)
+	<-received
+	d.OnDeviceEvent(event)
+	d.OnDeviceEvent(event)
+
+	p.Assert(t, CutOffCounter, URLLabel, server.URL)(xmetricstest.Value(1.0))
+	p.Assert(t, DroppedEventsCounter, URLLabel, server.URL, ReasonLabel, CutOffReason)(xmetricstest.Value(2.0))
+	p.Assert(t, QueueDepthGauge, URLLabel, server.URL)(xmetricstest.Value(0.0))
+
+	// events are dropped during the cut-off period
+	now = now.Add(30 * time.Second)
+	d.OnDeviceEvent(event)
+	p.Assert(t, DroppedEventsCounter, URLLabel, server.URL, ReasonLabel, CutOffReason)(xmetricstest.Value(3.0))
+
+	// delivery resumes once the cut-off period has elapsed
+	now = now.Add(time.Minute)
+	d.OnDeviceEvent(event)
+	close(block)
+	d.Stop()
+
+	assert.Len(received, 1)
+	require.Len(failures, 1)
+	f := <-failures
+	assert.Equal("application/json", f.header.Get("Content-Type"))
+	assert.Equal(Sign("secret", f.body), f.header.Get(SignatureHeader))
+
+	var message FailureMessage
+	require.NoError(json.Unmarshal(f.body, &message))
+	assert.NotEmpty(message.Text)
+	assert.Equal("1m0s", message.CutOffPeriod)
+	assert.Equal(1, message.QueueSize)
+	assert.Equal(1, message.Workers)
+	assert.Equal(server.URL, message.Original.Config.URL)
+	assert.Empty(message.Original.Config.Secret)
+}
+
+func testDispatcherRemovedHook(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		server, _ = newTestReceiver(http.StatusOK)
+		hook      = newTestHook(server.URL, ".*")
+		list      = NewList([]W{hook})
+	)
+
+	defer server.Close()
+
+	d := NewDispatcher(&DispatcherOptions{AllowedNetworks: testAllowedNetworks}, list)
+	event, _ := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+	d.OnDeviceEvent(event)
+	assert.Len(d.subscribers, 1)
+
+	list.Filter(func([]W) []W { return nil })
+	d.OnDeviceEvent(event)
+	assert.Len(d.subscribers, 0)
+
+	d.Stop()
+}
+
+func testDispatcherRestricted(t *testing.T) {
+	var (
+		server, received = newTestReceiver(http.StatusOK)
+		hook             = newTestHook(server.URL, ".*")
+		p                = xmetricstest.NewProvider(nil, Metrics)
+	)
+
+	defer server.Close()
+
+	// without an allowed network, the loopback address of the test server is refused when dialed
+	d := NewDispatcher(&DispatcherOptions{Workers: 1, DeliveryRetries: -1, MetricsProvider: p}, NewList([]W{hook}))
+	event, _ := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+	d.OnDeviceEvent(event)
+	d.Stop()
+
+	assert.Len(t, received, 0)
+	p.Assert(t, DeliveryCounter, URLLabel, server.URL, CodeLabel, TransportFailureCode)(xmetricstest.Value(1.0))
+}
+
+func testDispatcherRedirect(t *testing.T) {
+	var (
+		target, received = newTestReceiver(http.StatusOK)
+		redirect         = httptest.NewServer(http.RedirectHandler(target.URL, http.StatusTemporaryRedirect))
+		hook             = newTestHook(redirect.URL, ".*")
+		p                = xmetricstest.NewProvider(nil, Metrics)
+	)
+
+	defer target.Close()
+	defer redirect.Close()
+
+	d := NewDispatcher(&DispatcherOptions{Workers: 1, DeliveryRetries: -1, AllowedNetworks: testAllowedNetworks, MetricsProvider: p}, NewList([]W{hook}))
+	event, _ := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+	d.OnDeviceEvent(event)
+	d.Stop()
+
+	assert.Len(t, received, 0)
+	p.Assert(t, DeliveryCounter, URLLabel, redirect.URL, CodeLabel, "307")(xmetricstest.Value(1.0))
+}
+
+func testDispatcherShrinkingList(t *testing.T) {
+	var (
+		server, _ = newTestReceiver(http.StatusOK)
+		hooks     = []W{newTestHook(server.URL+"/a", "nomatch"), newTestHook(server.URL+"/b", "nomatch")}
+		list      = NewList(hooks)
+		done      = make(chan struct{})
+	)
+
+	defer server.Close()
+
+	go func() {
+		defer close(done)
+		for i := 0; i < 1000; i++ {
+			list.Filter(func([]W) []W { return nil })
+			list.Update(hooks)
+		}
+	}()
+
+	d := NewDispatcher(&DispatcherOptions{AllowedNetworks: testAllowedNetworks}, list)
+	event, _ := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			d.OnDeviceEvent(event)
+		}
+	}
+
+	d.Stop()
+}
+
+func TestDispatcher(t *testing.T) {
+	t.Run("Msgpack", testDispatcherMsgpack)
+	t.Run("JSON", testDispatcherJSON)
+	t.Run("Payload", testDispatcherPayload)
+	t.Run("NoMatch", testDispatcherNoMatch)
+	t.Run("Failover", testDispatcherFailover)
+	t.Run("DropNewest", testDispatcherDropNewest)
+	t.Run("DropOldest", testDispatcherDropOldest)
+	t.Run("CutOff", testDispatcherCutOff)
+	t.Run("RemovedHook", testDispatcherRemovedHook)
+	t.Run("Restricted", testDispatcherRestricted)
+	t.Run("Redirect", testDispatcherRedirect)
+	t.Run("ShrinkingList", testDispatcherShrinkingList)
+}
@@ -1,9 +1,14 @@
 package webhook
 
 import (
+	"context"
+	"fmt"
 	"net/http"
+	"net/url"
 	"time"
 
+	"github.com/go-kit/kit/log"
+	"github.com/gorilla/mux"
 	"github.com/spf13/viper"
 	AWS "github.com/jithin-kg/webpa-common/webhook/aws"
 	"github.com/jithin-kg/webpa-common/xhttp"
@@ -32,11 +37,27 @@ type Factory struct {
 	// internal handler for webhook
 	m *monitor `json:"-"`
 
-	// internal handler for AWS SNS Server
+	// validator is created from Validation, and is nil if no validation is configured
+	validator Validator `json:"-"`
+
+	// Notifier propagates registrations across the cluster.  It is AWS SNS unless Sync selects
+	// another transport.  For the gossip transport, this is a *GossipNotifier, which should be
+	// added as a listener to a service discovery monitor.
 	AWS.Notifier `json:"-"`
 
+	// Sync configures the transport used to propagate registrations across the cluster
+	Sync *SyncConfig `json:"sync"`
+
+	// Validation, if set, vets the URLs of each webhook registered through the Registry.
+	// If unset, webhook URLs are not checked.
+	Validation *ValidatorOptions `json:"validation"`
+
 	// StartConfig is the contains the data need to obtain the current system's listeners
 	Start *StartConfig `json:"start"`
+
+	// Bootstrap configures the sources of the initial webhooks used by BootstrapHooks, and the
+	// snapshot file kept by the Registry's list
+	Bootstrap *BootstrapOptions `json:"bootstrap"`
 }
 
 // NewFactory creates a Factory from a Viper environment.  This function always returns
@@ -67,7 +88,14 @@ func NewFactory(v *viper.Viper) (f *Factory, err error) {
 	}
 
 	f.undertaker = f.Prune
-	f.Notifier, err = AWS.NewNotifier(v)
+	f.Notifier, err = f.Sync.NewNotifier(v)
+	if err != nil {
+		return
+	}
+
+	if f.Validation != nil {
+		f.validator, err = NewValidator(f.Validation)
+	}
 
 	return
 }
@@ -95,14 +123,20 @@ func (f *Factory) NewRegistryAndHandler(registry xmetrics.Registry) (Registry, h
 		tick = time.Tick
 	}
 
+	list := NewList(nil)
+	if path := f.Bootstrap.snapshotFile(); len(path) > 0 {
+		list = NewSnapshotList(list, &SnapshotFile{Path: path}, f.Bootstrap.logger())
+	}
+
 	monitor := &monitor{
-		list:             NewList(nil),
+		list:             list,
 		undertaker:       f.undertaker,
 		changes:          make(chan []W, 10),
 		undertakerTicker: tick(f.UndertakerInterval),
 	}
 	f.m = monitor
 	f.m.Notifier = f.Notifier
+	f.m.validator = f.validator
 	f.m.metrics = ApplyMetricsData(registry)
 
 	reg := NewRegistry(f.m)
@@ -111,6 +145,86 @@ func (f *Factory) NewRegistryAndHandler(registry xmetrics.Registry) (Registry, h
 	return reg, monitor
 }
 
+// Initialize initializes the Notifier.  When the Notifier is a *GossipNotifier, this method also serves the
+// Registry's webhooks to bootstrapping peers on the bootstrap PeerPath via Registry.GetPeerRegistry.  That
+// route requires that NewRegistryAndHandler has been called first.
+func (f *Factory) Initialize(rtr *mux.Router, selfURL *url.URL, soaProvider string, handler http.Handler, logger log.Logger, registry xmetrics.Registry, now func() time.Time) {
+	f.Notifier.Initialize(rtr, selfURL, soaProvider, handler, logger, registry, now)
+
+	if _, ok := f.Notifier.(*GossipNotifier); ok && rtr != nil && f.m != nil {
+		reg := NewRegistry(f.m)
+		rtr.HandleFunc(f.Bootstrap.peerPath(), reg.GetPeerRegistry).Methods(http.MethodGet)
+	}
+}
+
+// BootstrapSources returns the configured bootstrap sources, in priority order.  The peer source is
+// only available when the Notifier is a *GossipNotifier, and the snapshot source is only available
+// when a snapshot file is configured.
+func (f *Factory) BootstrapSources() ([]BootstrapSource, error) {
+	var sources []BootstrapSource
+	for _, name := range f.Bootstrap.sources() {
+		switch name {
+		case PeerBootstrap:
+			if gn, ok := f.Notifier.(*GossipNotifier); ok {
+				peers := func() (result []string) {
+					for _, peer := range gn.Peers() {
+						if !gn.isSelf(peer) {
+							result = append(result, peer)
+						}
+					}
+
+					return
+				}
+
+				sources = append(sources, NewPeerSource(peers, f.Bootstrap.peerPath(), gn.secret, gn.client))
+			}
+
+		case SnapshotBootstrap:
+			if path := f.Bootstrap.snapshotFile(); len(path) > 0 {
+				sources = append(sources, &SnapshotFile{Path: path})
+			}
+
+		case HTTPBootstrap:
+			if f.Start != nil {
+				sources = append(sources, f.Start)
+			}
+
+		default:
+			return nil, fmt.Errorf("Unsupported webhook bootstrap source: %s", name)
+		}
+	}
+
+	return sources, nil
+}
+
+// BootstrapHooks obtains the initial webhooks from the configured sources, discarding any that have
+// expired.  If NewRegistryAndHandler has been called, the webhooks are applied to the Registry's list.
+func (f *Factory) BootstrapHooks(ctx context.Context) ([]W, error) {
+	sources, err := f.BootstrapSources()
+	if err != nil {
+		return nil, err
+	}
+
+	hooks, err := Bootstrap(ctx, f.Bootstrap, sources...)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.undertaker != nil {
+		hooks = f.undertaker(hooks)
+	}
+
+	if f.m != nil {
+		select {
+		case f.m.changes <- hooks:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+
+	return hooks, nil
+}
+
 // SetExternalUpdate is a specified function that takes an []W argument
 // This function is called when monitor.changes receives a message
 func (f *Factory) SetExternalUpdate(fn func([]W)) {
@@ -125,6 +239,7 @@ type monitor struct {
 	changes          chan []W
 	undertakerTicker <-chan time.Time
 	AWS.Notifier
+	validator      Validator
 	externalUpdate func([]W)
 	metrics        WebhookMetrics
 }
@@ -171,6 +286,15 @@ func (m *monitor) ServeHTTP(response http.ResponseWriter, request *http.Request)
 		m.metrics.NotificationUnmarshallFailed.Add(1.0)
 		return
 	}
+
+	// registrations from other instances have not been through this instance's Registry
+	if m.validator != nil && !isLocalRegistration(request) {
+		if err := m.validator.Validate(request.Context(), w); err != nil {
+			xhttp.WriteError(response, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
 	m.sendNewHooks([]W{*w})
 
 	m.metrics.ListSize.Set(float64(m.list.Len()))
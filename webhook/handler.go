@@ -2,7 +2,6 @@ package webhook
 
 import (
 	"encoding/json"
-	"fmt"
 	"io/ioutil"
 	"net/http"
 )
@@ -21,18 +20,28 @@ func NewRegistry(mon *monitor) Registry {
 
 // jsonResponse is an internal convenience function to write a json response
 func jsonResponse(rw http.ResponseWriter, code int, msg string) {
+	body, _ := json.Marshal(struct {
+		Message string `json:"message"`
+	}{msg})
+
 	rw.Header().Set("Content-Type", "application/json")
 	rw.WriteHeader(code)
-	rw.Write([]byte(fmt.Sprintf(`{"message":"%s"}`, msg)))
+	rw.Write(body)
 }
 
-// get is an api call to return all the registered listeners
-func (r *Registry) GetRegistry(rw http.ResponseWriter, req *http.Request) {
-	var items = []*W{}
-	for i := 0; i < r.m.list.Len(); i++ {
-		items = append(items, r.m.list.Get(i))
+// obfuscatedSecret replaces secrets in the public listing of webhooks
+const obfuscatedSecret = "<obfuscated>"
+
+func obfuscate(secret string) string {
+	if len(secret) > 0 {
+		return obfuscatedSecret
 	}
 
+	return secret
+}
+
+// writeRegistry writes the given webhooks as a JSON array
+func writeRegistry(rw http.ResponseWriter, items []W) {
 	if msg, err := json.Marshal(items); err != nil {
 		jsonResponse(rw, http.StatusInternalServerError, err.Error())
 	} else {
@@ -41,6 +50,51 @@ func (r *Registry) GetRegistry(rw http.ResponseWriter, req *http.Request) {
 	}
 }
 
+// get is an api call to return all the registered listeners.  Secrets are write-only, so they are obfuscated.
+func (r *Registry) GetRegistry(rw http.ResponseWriter, req *http.Request) {
+	var items = []W{}
+	for _, w := range Snapshot(r.m.list) {
+		item := *w
+		item.Config.Secret = obfuscate(item.Config.Secret)
+		item.Config.PreviousSecret = obfuscate(item.Config.PreviousSecret)
+		items = append(items, item)
+	}
+
+	writeRegistry(rw, items)
+}
+
+// GetPeerRegistry returns all the registered listeners, including their secrets, to peer instances
+// bootstrapping their webhooks.  It is normally served on DefaultPeerHooksPath.  Requests must be signed
+// with the sync secret of a GossipNotifier, as done by NewPeerSource, and are refused when the Notifier is
+// not a GossipNotifier.
+func (r *Registry) GetPeerRegistry(rw http.ResponseWriter, req *http.Request) {
+	gn, ok := r.m.Notifier.(*GossipNotifier)
+	if !ok || gn.verifier == nil {
+		jsonResponse(rw, http.StatusForbidden, "webhook peers are not enabled")
+		return
+	}
+
+	body, err := ioutil.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		jsonResponse(rw, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	if err := gn.verifier.Verify(req.Header, body); err != nil {
+		gn.errorLog.Log("msg", "invalid webhook peer signature", "remoteAddr", req.RemoteAddr, "error", err)
+		jsonResponse(rw, http.StatusForbidden, "invalid signature")
+		return
+	}
+
+	var items = []W{}
+	for _, w := range Snapshot(r.m.list) {
+		items = append(items, *w)
+	}
+
+	writeRegistry(rw, items)
+}
+
 // update is an api call to processes a listenener registration for adding and updating
 func (r *Registry) UpdateRegistry(rw http.ResponseWriter, req *http.Request) {
 	payload, err := ioutil.ReadAll(req.Body)
@@ -52,6 +106,13 @@ func (r *Registry) UpdateRegistry(rw http.ResponseWriter, req *http.Request) {
 		return
 	}
 
+	if r.m.validator != nil {
+		if err := r.m.validator.Validate(req.Context(), w); err != nil {
+			jsonResponse(rw, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+
 	s, err := json.Marshal(w)
 	if err != nil {
 		jsonResponse(rw, http.StatusInternalServerError, err.Error())
@@ -0,0 +1,148 @@
+package webhook
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+const (
+	DefaultDeliveryLogSize  = 100
+	DefaultReplayBufferSize = 100
+)
+
+var (
+	// ErrSubscriberNotFound is returned when replaying events to a webhook that is not in the
+	// Dispatcher's list, or that has not yet been sent any events
+	ErrSubscriberNotFound = errors.New("No such webhook subscriber")
+
+	// ErrDispatcherStopped is returned when replaying events through a Dispatcher that has been stopped
+	ErrDispatcherStopped = errors.New("The dispatcher has been stopped")
+)
+
+// DeliveryRecord describes the outcome of delivering a single event to a subscriber
+type DeliveryRecord struct {
+	// Timestamp is when the event was dispatched to the subscriber
+	Timestamp time.Time `json:"timestamp"`
+
+	// URL identifies the subscriber, and is the webhook's primary URL
+	URL string `json:"url"`
+
+	EventType       string `json:"event_type"`
+	DeviceID        string `json:"device_id"`
+	TransactionUUID string `json:"transaction_uuid,omitempty"`
+
+	// StatusCode is the HTTP status of the last attempt, or 0 if no response was received
+	StatusCode int `json:"status_code"`
+
+	// Latency is the total time spent on all attempts
+	Latency time.Duration `json:"latency"`
+
+	// Attempts is the number of HTTP requests made, including failovers
+	Attempts int `json:"attempts"`
+
+	// Replay indicates that the event was delivered in response to a replay request
+	Replay bool `json:"replay,omitempty"`
+}
+
+// ring is a fixed-capacity buffer that overwrites its oldest values
+type ring struct {
+	values []interface{}
+	next   int
+	full   bool
+}
+
+func newRing(capacity int) *ring {
+	if capacity < 1 {
+		return nil
+	}
+
+	return &ring{values: make([]interface{}, capacity)}
+}
+
+func (r *ring) add(v interface{}) {
+	if r == nil {
+		return
+	}
+
+	r.values[r.next] = v
+	r.next = (r.next + 1) % len(r.values)
+	r.full = r.full || r.next == 0
+}
+
+// each visits the values in this ring, oldest first
+func (r *ring) each(f func(interface{})) {
+	if r == nil {
+		return
+	}
+
+	if r.full {
+		for _, v := range r.values[r.next:] {
+			f(v)
+		}
+	}
+
+	for _, v := range r.values[:r.next] {
+		f(v)
+	}
+}
+
+// inRange tests if t falls within [from, to].  A zero bound is unbounded.
+func inRange(t, from, to time.Time) bool {
+	return (from.IsZero() || !t.Before(from)) && (to.IsZero() || !t.After(to))
+}
+
+// history is a subscriber's bounded log of delivery records, along with the recent events
+// available for replay
+type history struct {
+	lock    sync.Mutex
+	records *ring
+	events  *ring
+}
+
+func newHistory(logSize, replaySize int) *history {
+	return &history{
+		records: newRing(logSize),
+		events:  newRing(replaySize),
+	}
+}
+
+func (h *history) record(r DeliveryRecord) {
+	h.lock.Lock()
+	h.records.add(r)
+	h.lock.Unlock()
+}
+
+func (h *history) retain(dl delivery) {
+	h.lock.Lock()
+	h.events.add(dl)
+	h.lock.Unlock()
+}
+
+func (h *history) deliveries(from, to time.Time) []DeliveryRecord {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	var result []DeliveryRecord
+	h.records.each(func(v interface{}) {
+		if r := v.(DeliveryRecord); inRange(r.Timestamp, from, to) {
+			result = append(result, r)
+		}
+	})
+
+	return result
+}
+
+func (h *history) replayable(from, to time.Time) []delivery {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	var result []delivery
+	h.events.each(func(v interface{}) {
+		if dl := v.(delivery); inRange(dl.timestamp, from, to) {
+			result = append(result, dl)
+		}
+	})
+
+	return result
+}
@@ -0,0 +1,246 @@
+package webhook
+
+import (
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+func TestRing(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		values = func(r *ring) (result []interface{}) {
+			r.each(func(v interface{}) { result = append(result, v) })
+			return
+		}
+	)
+
+	assert.Nil(newRing(0))
+	assert.Nil(newRing(-1))
+
+	var disabled *ring
+	disabled.add(1)
+	assert.Empty(values(disabled))
+
+	r := newRing(3)
+	assert.Empty(values(r))
+
+	r.add(1)
+	r.add(2)
+	assert.Equal([]interface{}{1, 2}, values(r))
+
+	r.add(3)
+	assert.Equal([]interface{}{1, 2, 3}, values(r))
+
+	r.add(4)
+	r.add(5)
+	assert.Equal([]interface{}{3, 4, 5}, values(r))
+}
+
+// testClock is a settable time source that may be read concurrently by dispatcher workers
+type testClock struct {
+	lock sync.Mutex
+	now  time.Time
+}
+
+func (tc *testClock) Now() time.Time {
+	tc.lock.Lock()
+	defer tc.lock.Unlock()
+	return tc.now
+}
+
+func (tc *testClock) Set(now time.Time) {
+	tc.lock.Lock()
+	tc.now = now
+	tc.lock.Unlock()
+}
+
+// waitForDeliveries waits for a subscriber's delivery log to contain the expected number of records
+func waitForDeliveries(d *Dispatcher, id string, expected int) []DeliveryRecord {
+	records := d.Deliveries("", id, time.Time{}, time.Time{})
+	for i := 0; i < 100 && len(records) != expected; i++ {
+		time.Sleep(10 * time.Millisecond)
+		records = d.Deliveries("", id, time.Time{}, time.Time{})
+	}
+
+	return records
+}
+
+func TestDispatcherDeliveries(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server, received = newTestReceiver(http.StatusAccepted)
+		hook             = newTestHook(server.URL, ".*")
+		start            = time.Now()
+		clock            = &testClock{now: start}
+	)
+
+	defer server.Close()
+	d := NewDispatcher(&DispatcherOptions{Workers: 1, DeliveryLogSize: 2, Now: clock.Now, AllowedNetworks: testAllowedNetworks}, NewList([]W{hook}))
+	defer d.Stop()
+
+	assert.Empty(d.Deliveries("", server.URL, time.Time{}, time.Time{}))
+
+	for i, uuid := range []string{"one", "two", "three"} {
+		clock.Set(start.Add(time.Duration(i) * time.Minute))
+		event, message := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+		message.TransactionUUID = uuid
+		event.Contents = wrp.MustEncode(message, wrp.Msgpack)
+		d.OnDeviceEvent(event)
+		<-received
+
+		// the record is written after the response is read, so wait for it
+		for j := 0; j < 100; j++ {
+			if records := d.Deliveries("", server.URL, time.Time{}, time.Time{}); len(records) > 0 && records[len(records)-1].TransactionUUID == uuid {
+				break
+			}
+
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+
+	// the log is bounded, so only the most recent records remain
+	records := d.Deliveries("", server.URL, time.Time{}, time.Time{})
+	require.Len(records, 2)
+	assert.Equal("two", records[0].TransactionUUID)
+	assert.Equal(start.Add(time.Minute), records[0].Timestamp)
+	assert.Equal(server.URL, records[0].URL)
+	assert.Equal("device-status/online", records[0].EventType)
+	assert.Equal("mac:112233445566", records[0].DeviceID)
+	assert.Equal(http.StatusAccepted, records[0].StatusCode)
+	assert.Equal(1, records[0].Attempts)
+	assert.False(records[0].Replay)
+	assert.Equal("three", records[1].TransactionUUID)
+
+	records = d.Deliveries("", server.URL, start.Add(2*time.Minute), time.Time{})
+	require.Len(records, 1)
+	assert.Equal("three", records[0].TransactionUUID)
+
+	assert.Empty(d.Deliveries("", server.URL, time.Time{}, start))
+	assert.Empty(d.Deliveries("", "http://nosuch.example.com/", time.Time{}, time.Time{}))
+}
+
+func TestDispatcherDeliveriesFailover(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		primary, _   = newTestReceiver(http.StatusServiceUnavailable)
+		alternate, _ = newTestReceiver(http.StatusServiceUnavailable)
+		hook         = newTestHook(primary.URL, ".*")
+	)
+
+	defer primary.Close()
+	defer alternate.Close()
+	hook.Config.AlternativeURLs = []string{alternate.URL}
+
+	d := NewDispatcher(&DispatcherOptions{Workers: 1, DeliveryRetries: 2, AllowedNetworks: testAllowedNetworks}, NewList([]W{hook}))
+	event, _ := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+	d.OnDeviceEvent(event)
+
+	records := waitForDeliveries(d, primary.URL, 1)
+	d.Stop()
+
+	require.Len(records, 1)
+	assert.Equal(3, records[0].Attempts)
+	assert.Equal(http.StatusServiceUnavailable, records[0].StatusCode)
+	assert.True(records[0].Latency > 0)
+}
+
+func TestDispatcherReplay(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server, received = newTestReceiver(http.StatusOK)
+		hook             = newTestHook(server.URL, ".*")
+		start            = time.Now()
+		clock            = &testClock{now: start}
+	)
+
+	defer server.Close()
+	list := NewList([]W{hook})
+	d := NewDispatcher(&DispatcherOptions{Workers: 1, ReplayBufferSize: 2, Now: clock.Now, AllowedNetworks: testAllowedNetworks}, list)
+
+	_, err := d.Replay("", server.URL, time.Time{}, time.Time{})
+	assert.Equal(ErrSubscriberNotFound, err)
+
+	for i := 0; i < 3; i++ {
+		clock.Set(start.Add(time.Duration(i) * time.Minute))
+		event, _ := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+		d.OnDeviceEvent(event)
+		<-received
+	}
+
+	// only the retained events within the range are replayed, using the webhook's current secret
+	hook.Config.Secret = "renewed"
+	list.Update([]W{hook})
+
+	count, err := d.Replay("", server.URL, start, start.Add(90*time.Second))
+	require.NoError(err)
+	assert.Equal(1, count)
+
+	r := <-received
+	assert.Equal(Sign("renewed", r.body), r.header.Get(SignatureHeader))
+
+	records := waitForDeliveries(d, server.URL, 4)
+	require.Len(records, 4)
+	assert.True(records[3].Replay)
+	assert.Equal(start.Add(time.Minute), records[3].Timestamp)
+
+	count, err = d.Replay("", server.URL, time.Time{}, time.Time{})
+	require.NoError(err)
+	assert.Equal(2, count)
+	<-received
+	<-received
+
+	d.Stop()
+	_, err = d.Replay("", server.URL, time.Time{}, time.Time{})
+	assert.Equal(ErrDispatcherStopped, err)
+}
+
+func TestDispatcherDeliveriesByOwner(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server, received = newTestReceiver(http.StatusOK)
+		mine             = newTestHook(server.URL, ".*")
+		theirs           = newTestHook(server.URL, "nomatch")
+	)
+
+	defer server.Close()
+	mine.Owner = "mine"
+	theirs.Owner = "theirs"
+
+	d := NewDispatcher(&DispatcherOptions{Workers: 1, AllowedNetworks: testAllowedNetworks}, NewList([]W{mine, theirs}))
+	event, _ := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+	d.OnDeviceEvent(event)
+	<-received
+
+	// both owners have subscribers for the same URL, but only one received the event
+	for i := 0; i < 100 && len(d.Deliveries("mine", server.URL, time.Time{}, time.Time{})) == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	require.Len(d.Deliveries("mine", server.URL, time.Time{}, time.Time{}), 1)
+	assert.Empty(d.Deliveries("theirs", server.URL, time.Time{}, time.Time{}))
+	assert.Empty(d.Deliveries("", server.URL, time.Time{}, time.Time{}))
+
+	_, err := d.Replay("theirs", server.URL, time.Time{}, time.Time{})
+	assert.Equal(ErrSubscriberNotFound, err)
+
+	count, err := d.Replay("mine", server.URL, time.Time{}, time.Time{})
+	require.NoError(err)
+	assert.Equal(1, count)
+	<-received
+
+	d.Stop()
+}
@@ -0,0 +1,320 @@
+package webhook
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+
+	"github.com/jithin-kg/webpa-common/convey"
+	"github.com/jithin-kg/webpa-common/device"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+// Matcher describes the events a webhook receives beyond its event types.  Each field is a set of
+// regular expressions, and a field matches if any of its expressions matches.  Fields that are empty
+// impose no constraint, and every non-empty field must match for the Matcher to match.
+//
+// Matchers compose: All requires every nested Matcher to match, Any requires at least one nested
+// Matcher to match, and Not requires its nested Matcher to not match.  For example, this matcher
+// selects events from two hardware models that are not sent to the config service:
+//
+//	{
+//	  "any": [{"convey": {"hw-model": ["^TG1682"]}}, {"convey": {"hw-model": ["^TG3482"]}}],
+//	  "not": {"destination": ["/config$"]}
+//	}
+type Matcher struct {
+	// The list of regular expressions to match device id type against.
+	DeviceId []string `json:"device_id"`
+
+	// Source is matched against the WRP message source
+	Source []string `json:"source,omitempty"`
+
+	// Destination is matched against the WRP message destination
+	Destination []string `json:"destination,omitempty"`
+
+	// PartnerIDs is matched against each of the device's partner ids, and those of the WRP message
+	PartnerIDs []string `json:"partner_ids,omitempty"`
+
+	// Convey maps device convey keys, such as hw-model, onto expressions for their values.
+	// A device which does not have a key does not match.
+	Convey map[string][]string `json:"convey,omitempty"`
+
+	// Metadata maps WRP message metadata keys onto expressions for their values.  A message which
+	// does not have a key does not match.
+	Metadata map[string][]string `json:"metadata,omitempty"`
+
+	// All, if not empty, are matchers which must all match
+	All []Matcher `json:"all,omitempty"`
+
+	// Any, if not empty, are matchers of which at least one must match
+	Any []Matcher `json:"any,omitempty"`
+
+	// Not, if set, is a matcher which must not match
+	Not *Matcher `json:"not,omitempty"`
+}
+
+// patterns is a compiled list of regular expressions, any of which may match
+type patterns []*regexp.Regexp
+
+func compilePatterns(field string, expressions []string) (patterns, error) {
+	if len(expressions) == 0 {
+		return nil, nil
+	}
+
+	compiled := make(patterns, len(expressions))
+	for i, e := range expressions {
+		r, err := regexp.Compile(e)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s expression %q: %s", field, e, err)
+		}
+
+		compiled[i] = r
+	}
+
+	return compiled, nil
+}
+
+func (p patterns) match(value string) bool {
+	for _, r := range p {
+		if r.MatchString(value) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (p patterns) matchAny(values []string) bool {
+	for _, v := range values {
+		if p.match(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// keyedPatterns is a compiled Convey or Metadata field
+type keyedPatterns map[string]patterns
+
+func compileKeyedPatterns(field string, expressions map[string][]string) (keyedPatterns, error) {
+	if len(expressions) == 0 {
+		return nil, nil
+	}
+
+	// compile in a fixed order, so that the same error is always reported
+	keys := make([]string, 0, len(expressions))
+	for key := range expressions {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	compiled := make(keyedPatterns, len(keys))
+	for _, key := range keys {
+		p, err := compilePatterns(field+"."+key, expressions[key])
+		if err != nil {
+			return nil, err
+		}
+
+		compiled[key] = p
+	}
+
+	return compiled, nil
+}
+
+func (kp keyedPatterns) match(get func(string) (string, bool)) bool {
+	for key, p := range kp {
+		value, ok := get(key)
+		if !ok || (len(p) > 0 && !p.match(value)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// compiledMatcher is the evaluable form of a Matcher
+type compiledMatcher struct {
+	deviceID    patterns
+	source      patterns
+	destination patterns
+	partnerIDs  patterns
+	convey      keyedPatterns
+	metadata    keyedPatterns
+	all         []*compiledMatcher
+	any         []*compiledMatcher
+	not         *compiledMatcher
+}
+
+func compileMatcher(field string, m *Matcher) (cm *compiledMatcher, err error) {
+	cm = new(compiledMatcher)
+	if cm.deviceID, err = compilePatterns(field+".device_id", m.DeviceId); err != nil {
+		return
+	}
+
+	if cm.source, err = compilePatterns(field+".source", m.Source); err != nil {
+		return
+	}
+
+	if cm.destination, err = compilePatterns(field+".destination", m.Destination); err != nil {
+		return
+	}
+
+	if cm.partnerIDs, err = compilePatterns(field+".partner_ids", m.PartnerIDs); err != nil {
+		return
+	}
+
+	if cm.convey, err = compileKeyedPatterns(field+".convey", m.Convey); err != nil {
+		return
+	}
+
+	if cm.metadata, err = compileKeyedPatterns(field+".metadata", m.Metadata); err != nil {
+		return
+	}
+
+	for i := range m.All {
+		nested, err := compileMatcher(fmt.Sprintf("%s.all[%d]", field, i), &m.All[i])
+		if err != nil {
+			return nil, err
+		}
+
+		cm.all = append(cm.all, nested)
+	}
+
+	for i := range m.Any {
+		nested, err := compileMatcher(fmt.Sprintf("%s.any[%d]", field, i), &m.Any[i])
+		if err != nil {
+			return nil, err
+		}
+
+		cm.any = append(cm.any, nested)
+	}
+
+	if m.Not != nil {
+		if cm.not, err = compileMatcher(field+".not", m.Not); err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+// matchContext holds the values a compiledMatcher is evaluated against.  Device values other than
+// the ID are only retrieved if a matcher needs them.
+type matchContext struct {
+	deviceID string
+	message  *wrp.Message
+	device   device.Interface
+
+	partnerIDs       []string
+	partnerIDsLoaded bool
+	conveyed         convey.Interface
+	conveyedLoaded   bool
+}
+
+func newMatchContext(message *wrp.Message, d device.Interface) *matchContext {
+	mc := &matchContext{
+		message: message,
+		device:  d,
+	}
+
+	if d != nil {
+		mc.deviceID = string(d.ID())
+	}
+
+	return mc
+}
+
+func (mc *matchContext) allPartnerIDs() []string {
+	if !mc.partnerIDsLoaded {
+		mc.partnerIDsLoaded = true
+		if mc.device != nil {
+			mc.partnerIDs = append(mc.partnerIDs, mc.device.PartnerIDs()...)
+		}
+
+		mc.partnerIDs = append(mc.partnerIDs, mc.message.PartnerIDs...)
+	}
+
+	return mc.partnerIDs
+}
+
+func (mc *matchContext) convey(key string) (string, bool) {
+	if !mc.conveyedLoaded {
+		mc.conveyedLoaded = true
+		if mc.device != nil {
+			mc.conveyed = mc.device.Convey()
+		}
+	}
+
+	if mc.conveyed == nil {
+		return "", false
+	}
+
+	return mc.conveyed.GetString(key)
+}
+
+func (mc *matchContext) metadata(key string) (string, bool) {
+	value, ok := mc.message.Metadata[key]
+	return value, ok
+}
+
+func (cm *compiledMatcher) match(mc *matchContext) bool {
+	if (len(cm.deviceID) > 0 && !cm.deviceID.match(mc.deviceID)) ||
+		(len(cm.source) > 0 && !cm.source.match(mc.message.Source)) ||
+		(len(cm.destination) > 0 && !cm.destination.match(mc.message.Destination)) ||
+		(len(cm.partnerIDs) > 0 && !cm.partnerIDs.matchAny(mc.allPartnerIDs())) ||
+		!cm.convey.match(mc.convey) ||
+		!cm.metadata.match(mc.metadata) {
+		return false
+	}
+
+	for _, nested := range cm.all {
+		if !nested.match(mc) {
+			return false
+		}
+	}
+
+	if len(cm.any) > 0 {
+		matched := false
+		for _, nested := range cm.any {
+			if matched = nested.match(mc); matched {
+				break
+			}
+		}
+
+		if !matched {
+			return false
+		}
+	}
+
+	return cm.not == nil || !cm.not.match(mc)
+}
+
+// CompiledMatcher is the compiled form of a webhook's Events and Matcher, which can be evaluated
+// against events without further parsing
+type CompiledMatcher struct {
+	events  patterns
+	matcher *compiledMatcher
+}
+
+// Compile compiles this webhook's Events and Matcher.  If any expression is invalid, the returned
+// error identifies it.
+func (w *W) Compile() (*CompiledMatcher, error) {
+	events, err := compilePatterns("events", w.Events)
+	if err != nil {
+		return nil, err
+	}
+
+	matcher, err := compileMatcher("matcher", &w.Matcher)
+	if err != nil {
+		return nil, err
+	}
+
+	return &CompiledMatcher{events: events, matcher: matcher}, nil
+}
+
+// Match tests if a WRP message of the given event type, sent by the given device, matches.
+// The device may be nil, in which case device criteria only match their zero values.
+func (cm *CompiledMatcher) Match(eventType string, message *wrp.Message, d device.Interface) bool {
+	return cm.events.match(eventType) && cm.matcher.match(newMatchContext(message, d))
+}
@@ -0,0 +1,179 @@
+package webhook
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/jithin-kg/webpa-common/convey"
+	"github.com/jithin-kg/webpa-common/device"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xmidt-org/wrp-go/v3"
+)
+
+func TestWCompileInvalid(t *testing.T) {
+	testData := []struct {
+		w        W
+		expected string
+	}{
+		{W{Events: []string{"("}}, "events"},
+		{W{Events: []string{".*"}, Matcher: Matcher{DeviceId: []string{"["}}}, "matcher.device_id"},
+		{W{Events: []string{".*"}, Matcher: Matcher{Source: []string{"("}}}, "matcher.source"},
+		{W{Events: []string{".*"}, Matcher: Matcher{Destination: []string{"("}}}, "matcher.destination"},
+		{W{Events: []string{".*"}, Matcher: Matcher{PartnerIDs: []string{"("}}}, "matcher.partner_ids"},
+		{W{Events: []string{".*"}, Matcher: Matcher{Convey: map[string][]string{"hw-model": {"("}}}}, "matcher.convey.hw-model"},
+		{W{Events: []string{".*"}, Matcher: Matcher{Metadata: map[string][]string{"/trust": {"("}}}}, "matcher.metadata./trust"},
+		{W{Events: []string{".*"}, Matcher: Matcher{All: []Matcher{{}, {Source: []string{"("}}}}}, "matcher.all[1].source"},
+		{W{Events: []string{".*"}, Matcher: Matcher{Any: []Matcher{{Source: []string{"("}}}}}, "matcher.any[0].source"},
+		{W{Events: []string{".*"}, Matcher: Matcher{Not: &Matcher{Any: []Matcher{{Source: []string{"("}}}}}}, "matcher.not.any[0].source"},
+	}
+
+	for _, record := range testData {
+		t.Run(record.expected, func(t *testing.T) {
+			cm, err := record.w.Compile()
+			assert.Nil(t, cm)
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), "invalid "+record.expected+" expression")
+			}
+		})
+	}
+}
+
+func TestNewWInvalidMatcher(t *testing.T) {
+	w, err := NewW([]byte(`{"config": {"url": "http://example.com/"}, "events": [".*"], "matcher": {"source": ["("]}}`), "")
+	assert.Nil(t, w)
+	assert.Error(t, err)
+}
+
+func TestCompiledMatcherMatch(t *testing.T) {
+	var (
+		message = &wrp.Message{
+			Source:      "mac:112233445566/service",
+			Destination: "event:device-status/online",
+			PartnerIDs:  []string{"message-partner"},
+			Metadata:    map[string]string{"/trust": "1000"},
+		}
+
+		d = new(device.MockDevice)
+	)
+
+	d.On("ID").Return(device.ID("mac:112233445566"))
+	d.On("PartnerIDs").Return([]string{"comcast"})
+	d.On("Convey").Return(convey.C{"hw-model": "TG1682G", "fw-name": "1.0"})
+
+	testData := []struct {
+		description string
+		events      []string
+		matcher     Matcher
+		expected    bool
+	}{
+		{"Empty", []string{".*"}, Matcher{}, true},
+		{"NoEvents", nil, Matcher{}, false},
+		{"EventMismatch", []string{"^iot"}, Matcher{}, false},
+		{"DeviceID", []string{".*"}, Matcher{DeviceId: []string{"^mac:1122"}}, true},
+		{"DeviceIDMismatch", []string{".*"}, Matcher{DeviceId: []string{"^mac:aabb"}}, false},
+		{"Source", []string{".*"}, Matcher{Source: []string{"/service$"}}, true},
+		{"SourceMismatch", []string{".*"}, Matcher{Source: []string{"/other$"}}, false},
+		{"Destination", []string{".*"}, Matcher{Destination: []string{"nothing", "online$"}}, true},
+		{"DestinationMismatch", []string{".*"}, Matcher{Destination: []string{"offline$"}}, false},
+		{"DevicePartner", []string{".*"}, Matcher{PartnerIDs: []string{"^comcast$"}}, true},
+		{"MessagePartner", []string{".*"}, Matcher{PartnerIDs: []string{"^message-partner$"}}, true},
+		{"PartnerMismatch", []string{".*"}, Matcher{PartnerIDs: []string{"^other$"}}, false},
+		{"Convey", []string{".*"}, Matcher{Convey: map[string][]string{"hw-model": {"^TG1682"}, "fw-name": {}}}, true},
+		{"ConveyMismatch", []string{".*"}, Matcher{Convey: map[string][]string{"hw-model": {"^TG3482"}}}, false},
+		{"ConveyMissing", []string{".*"}, Matcher{Convey: map[string][]string{"boot-time": {".*"}}}, false},
+		{"Metadata", []string{".*"}, Matcher{Metadata: map[string][]string{"/trust": {"^1000$"}}}, true},
+		{"MetadataMismatch", []string{".*"}, Matcher{Metadata: map[string][]string{"/trust": {"^0$"}}}, false},
+		{"MetadataMissing", []string{".*"}, Matcher{Metadata: map[string][]string{"/other": {".*"}}}, false},
+		{"FieldsAreConjunctive", []string{".*"}, Matcher{Source: []string{"/service$"}, Destination: []string{"offline$"}}, false},
+		{"All", []string{".*"}, Matcher{All: []Matcher{{Source: []string{"/service$"}}, {PartnerIDs: []string{"comcast"}}}}, true},
+		{"AllMismatch", []string{".*"}, Matcher{All: []Matcher{{Source: []string{"/service$"}}, {PartnerIDs: []string{"other"}}}}, false},
+		{"Any", []string{".*"}, Matcher{Any: []Matcher{{Source: []string{"/other$"}}, {PartnerIDs: []string{"comcast"}}}}, true},
+		{"AnyMismatch", []string{".*"}, Matcher{Any: []Matcher{{Source: []string{"/other$"}}, {PartnerIDs: []string{"other"}}}}, false},
+		{"Not", []string{".*"}, Matcher{Not: &Matcher{Destination: []string{"offline$"}}}, true},
+		{"NotMismatch", []string{".*"}, Matcher{Not: &Matcher{Destination: []string{"online$"}}}, false},
+		{
+			"Nested",
+			[]string{"^device-status/"},
+			Matcher{
+				Any: []Matcher{
+					{Convey: map[string][]string{"hw-model": {"^TG3482"}}},
+					{Convey: map[string][]string{"hw-model": {"^TG1682"}}},
+				},
+				Not: &Matcher{Any: []Matcher{{Metadata: map[string][]string{"/trust": {"^0$"}}}}},
+			},
+			true,
+		},
+	}
+
+	for _, record := range testData {
+		t.Run(record.description, func(t *testing.T) {
+			w := W{Events: record.events, Matcher: record.matcher}
+			cm, err := w.Compile()
+			require.NoError(t, err)
+			assert.Equal(t, record.expected, cm.Match("device-status/online", message, d))
+		})
+	}
+
+	t.Run("NilDevice", func(t *testing.T) {
+		w := W{Events: []string{".*"}, Matcher: Matcher{PartnerIDs: []string{"message-partner"}}}
+		cm, err := w.Compile()
+		require.NoError(t, err)
+		assert.True(t, cm.Match("device-status/online", message, nil))
+
+		w.Matcher.Convey = map[string][]string{"hw-model": {".*"}}
+		cm, err = w.Compile()
+		require.NoError(t, err)
+		assert.False(t, cm.Match("device-status/online", message, nil))
+	})
+}
+
+func TestDispatcherRecompilesUpdatedHooks(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		server, received = newTestReceiver(http.StatusOK)
+		hook             = newTestHook(server.URL, ".*")
+	)
+
+	defer server.Close()
+	hook.Matcher.Destination = []string{"offline$"}
+	list := NewList([]W{hook})
+
+	d := NewDispatcher(&DispatcherOptions{AllowedNetworks: testAllowedNetworks}, list)
+	event, _ := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+	d.OnDeviceEvent(event)
+
+	hook.Matcher.Destination = []string{"online$"}
+	list.Update([]W{hook})
+	d.OnDeviceEvent(event)
+	d.Stop()
+
+	assert.Len(received, 1)
+}
+
+func TestDispatcherMatchersByOwner(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		server, received = newTestReceiver(http.StatusOK)
+		offline          = newTestHook(server.URL, ".*")
+		online           = newTestHook(server.URL, ".*")
+	)
+
+	defer server.Close()
+	offline.Owner = "offline"
+	offline.Matcher.Destination = []string{"offline$"}
+	online.Owner = "online"
+	online.Matcher.Destination = []string{"online$"}
+
+	d := NewDispatcher(&DispatcherOptions{AllowedNetworks: testAllowedNetworks}, NewList([]W{offline, online}))
+	event, _ := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+	d.OnDeviceEvent(event)
+
+	// each owner's webhook is compiled and cached separately, even though they share a URL
+	assert.Len(d.matchers, 2)
+	d.Stop()
+
+	assert.Len(received, 1)
+}
@@ -2,17 +2,47 @@ package webhook
 
 import (
 	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/provider"
 	"github.com/jithin-kg/webpa-common/xmetrics"
 )
 
 const (
 	ListSize                     = "webhook_list_size_value"
 	NotificationUnmarshallFailed = "notification_unmarshall_failed_count"
+	DeliveryCounter              = "webhook_delivery_count"
+	DroppedEventsCounter         = "webhook_dropped_events_count"
+	QueueDepthGauge              = "webhook_queue_depth_value"
+	CutOffCounter                = "webhook_cut_off_count"
+	SyncSentCounter              = "webhook_sync_sent_count"
+)
+
+const (
+	URLLabel    = "url"
+	CodeLabel   = "code"
+	ReasonLabel = "reason"
+
+	// TransportFailureCode is the code label value used when a delivery attempt fails before
+	// any HTTP response is received
+	TransportFailureCode = "failure"
+
+	// QueueFullReason is the reason label value for events dropped because a subscriber's queue was full
+	QueueFullReason = "queue_full"
+
+	// OverflowReason is the reason label value for queued events discarded to make room for newer events
+	OverflowReason = "overflow"
+
+	// CutOffReason is the reason label value for events dropped because a subscriber was cut off
+	CutOffReason = "cut_off"
 )
 
 type WebhookMetrics struct {
 	ListSize                     metrics.Gauge
 	NotificationUnmarshallFailed metrics.Counter
+	Delivery                     metrics.Counter
+	DroppedEvents                metrics.Counter
+	QueueDepth                   metrics.Gauge
+	CutOffs                      metrics.Counter
+	SyncSent                     metrics.Counter
 }
 
 // Metrics returns the defined metrics as a list
@@ -28,20 +58,64 @@ func Metrics() []xmetrics.Metric {
 			Help: "Count of the number notification messages that failed to unmarshall",
 			Type: "counter",
 		},
+		xmetrics.Metric{
+			Name:       DeliveryCounter,
+			Help:       "Count of event delivery attempts to webhook subscribers",
+			Type:       "counter",
+			LabelNames: []string{URLLabel, CodeLabel},
+		},
+		xmetrics.Metric{
+			Name:       DroppedEventsCounter,
+			Help:       "Count of events dropped before delivery to a webhook subscriber",
+			Type:       "counter",
+			LabelNames: []string{URLLabel, ReasonLabel},
+		},
+		xmetrics.Metric{
+			Name:       QueueDepthGauge,
+			Help:       "The number of events waiting to be delivered to a subscriber",
+			Type:       "gauge",
+			LabelNames: []string{URLLabel},
+		},
+		xmetrics.Metric{
+			Name:       CutOffCounter,
+			Help:       "Count of the times a subscriber was cut off due to event overflow",
+			Type:       "counter",
+			LabelNames: []string{URLLabel},
+		},
+		xmetrics.Metric{
+			Name:       SyncSentCounter,
+			Help:       "Count of webhook registrations sent to peers",
+			Type:       "counter",
+			LabelNames: []string{URLLabel, CodeLabel},
+		},
 	}
 }
 
 // ApplyMetricsData is used for setting the counter values on the WebhookMetrics
 // when stored and accessing for later use
 func ApplyMetricsData(registry xmetrics.Registry) (m WebhookMetrics) {
+	return applyMetrics(registry)
+}
+
+func applyMetrics(p provider.Provider) (m WebhookMetrics) {
 	for _, metric := range Metrics() {
 		switch metric.Name {
 		case ListSize:
-			m.ListSize = registry.NewGauge(metric.Name)
+			m.ListSize = p.NewGauge(metric.Name)
 			m.ListSize.Add(0.0)
 		case NotificationUnmarshallFailed:
-			m.NotificationUnmarshallFailed = registry.NewCounter(metric.Name)
+			m.NotificationUnmarshallFailed = p.NewCounter(metric.Name)
 			m.NotificationUnmarshallFailed.Add(0.0)
+		case DeliveryCounter:
+			m.Delivery = p.NewCounter(metric.Name)
+		case DroppedEventsCounter:
+			m.DroppedEvents = p.NewCounter(metric.Name)
+		case QueueDepthGauge:
+			m.QueueDepth = p.NewGauge(metric.Name)
+		case CutOffCounter:
+			m.CutOffs = p.NewCounter(metric.Name)
+		case SyncSentCounter:
+			m.SyncSent = p.NewCounter(metric.Name)
 		}
 	}
 
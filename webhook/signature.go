@@ -0,0 +1,348 @@
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/jithin-kg/webpa-common/xhttp"
+)
+
+const (
+	// SignatureSHA1 is the legacy signature algorithm, which signs only the body.  Signatures made with
+	// this algorithm can be replayed, so it is only the default for compatibility with existing subscribers.
+	SignatureSHA1 = "sha1"
+
+	// SignatureSHA256 signs the timestamp, nonce, and body with HMAC-SHA256
+	SignatureSHA256 = "sha256"
+
+	// SignatureSHA512 signs the timestamp, nonce, and body with HMAC-SHA512
+	SignatureSHA512 = "sha512"
+
+	// SignatureTimestampHeader carries the Unix time, in seconds, at which a request was signed.  It is
+	// not set for SignatureSHA1.
+	SignatureTimestampHeader = "X-Webpa-Signature-Timestamp"
+
+	// SignatureNonceHeader carries a random value unique to each signed request.  It is not set for SignatureSHA1.
+	SignatureNonceHeader = "X-Webpa-Signature-Nonce"
+
+	// DefaultSignatureTolerance is the default maximum difference between a signature's timestamp and
+	// the time it is verified
+	DefaultSignatureTolerance = 5 * time.Minute
+)
+
+var (
+	ErrMissingSignature  = errors.New("The request is not signed")
+	ErrInvalidSignature  = errors.New("The request signature is invalid")
+	ErrExpiredSignature  = errors.New("The request signature has expired")
+	ErrReplayedSignature = errors.New("The request signature has already been used")
+)
+
+// newSignatureHash returns the hash constructor for a signature algorithm.  The empty string
+// denotes SignatureSHA1.
+func newSignatureHash(algorithm string) (func() hash.Hash, error) {
+	switch strings.ToLower(algorithm) {
+	case "", SignatureSHA1:
+		return sha1.New, nil
+	case SignatureSHA256:
+		return sha256.New, nil
+	case SignatureSHA512:
+		return sha512.New, nil
+	default:
+		return nil, fmt.Errorf("Unsupported signature algorithm: %s", algorithm)
+	}
+}
+
+// ValidateSignatureAlgorithm checks that an algorithm is supported.  The empty string, which denotes
+// SignatureSHA1, is valid.
+func ValidateSignatureAlgorithm(algorithm string) error {
+	_, err := newSignatureHash(algorithm)
+	return err
+}
+
+// isLegacySignature tests if an algorithm signs only the body
+func isLegacySignature(algorithm string) bool {
+	return len(algorithm) == 0 || strings.EqualFold(algorithm, SignatureSHA1)
+}
+
+// signedMaterial is the data signed by the timestamped algorithms: the timestamp, the nonce, and the body,
+// separated by periods
+func signedMaterial(timestamp, nonce string, body []byte) []byte {
+	var b bytes.Buffer
+	b.WriteString(timestamp)
+	b.WriteByte('.')
+	b.WriteString(nonce)
+	b.WriteByte('.')
+	b.Write(body)
+	return b.Bytes()
+}
+
+func computeSignature(newHash func() hash.Hash, secret string, material []byte) string {
+	h := hmac.New(newHash, []byte(secret))
+	h.Write(material)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// newNonce produces a random nonce for a signature
+func newNonce() (string, error) {
+	nonce := make([]byte, 16)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(nonce), nil
+}
+
+// SignHeader sets the SignatureHeader for a body, signing it with each of the given secrets so that
+// subscribers holding any of them can verify it.  Multiple signatures are comma-separated.  Except for
+// SignatureSHA1, the SignatureTimestampHeader and SignatureNonceHeader are also set, and are part of the
+// signed material.  If there are no secrets, the header is left untouched.
+func SignHeader(header http.Header, algorithm string, secrets []string, body []byte, now time.Time) error {
+	if len(secrets) == 0 {
+		return nil
+	}
+
+	newHash, err := newSignatureHash(algorithm)
+	if err != nil {
+		return err
+	}
+
+	material, prefix := body, SignatureSHA1
+	if !isLegacySignature(algorithm) {
+		nonce, err := newNonce()
+		if err != nil {
+			return err
+		}
+
+		timestamp := strconv.FormatInt(now.Unix(), 10)
+		header.Set(SignatureTimestampHeader, timestamp)
+		header.Set(SignatureNonceHeader, nonce)
+		material, prefix = signedMaterial(timestamp, nonce, body), strings.ToLower(algorithm)
+	}
+
+	signatures := make([]string, len(secrets))
+	for i, secret := range secrets {
+		signatures[i] = prefix + "=" + computeSignature(newHash, secret, material)
+	}
+
+	header.Set(SignatureHeader, strings.Join(signatures, ","))
+	return nil
+}
+
+// ActiveSecrets returns the secrets a delivery should be signed with at the given time: the current
+// secret and, during a rotation's grace period, the previous secret
+func (w *W) ActiveSecrets(now time.Time) []string {
+	var secrets []string
+	if len(w.Config.Secret) > 0 {
+		secrets = append(secrets, w.Config.Secret)
+	}
+
+	if len(w.Config.PreviousSecret) > 0 && now.Before(w.Config.PreviousSecretUntil) {
+		secrets = append(secrets, w.Config.PreviousSecret)
+	}
+
+	return secrets
+}
+
+// VerifierOptions configure a Verifier
+type VerifierOptions struct {
+	// Secrets are the secrets a signature may have been made with.  Supplying both the current and
+	// previous secrets allows a subscriber to rotate its secret without rejecting deliveries.
+	Secrets []string `json:"secrets"`
+
+	// AllowLegacy permits SignatureSHA1 signatures, which cannot be protected against replay
+	AllowLegacy bool `json:"allowLegacy"`
+
+	// Tolerance is the maximum difference between a signature's timestamp and the current time.
+	// If not supplied, DefaultSignatureTolerance is used.
+	Tolerance time.Duration `json:"tolerance"`
+
+	// Now is the closure used to determine the current time.  If not set, time.Now is used.
+	Now func() time.Time `json:"-"`
+}
+
+func (o *VerifierOptions) secrets() []string {
+	if o != nil {
+		return o.Secrets
+	}
+
+	return nil
+}
+
+func (o *VerifierOptions) allowLegacy() bool {
+	return o != nil && o.AllowLegacy
+}
+
+func (o *VerifierOptions) tolerance() time.Duration {
+	if o != nil && o.Tolerance > 0 {
+		return o.Tolerance
+	}
+
+	return DefaultSignatureTolerance
+}
+
+func (o *VerifierOptions) now() func() time.Time {
+	if o != nil && o.Now != nil {
+		return o.Now
+	}
+
+	return time.Now
+}
+
+// Verifier validates the signatures of webhook deliveries.  A Verifier remembers the nonces of the
+// signatures it has accepted until they expire, so that a captured delivery cannot be replayed.
+type Verifier struct {
+	secrets     []string
+	allowLegacy bool
+	tolerance   time.Duration
+	now         func() time.Time
+
+	lock     sync.Mutex
+	nonces   map[string]bool
+	expiries []nonceExpiry
+}
+
+// nonceExpiry records when a used nonce can be forgotten
+type nonceExpiry struct {
+	nonce  string
+	expiry time.Time
+}
+
+// NewVerifier creates a Verifier from a set of options
+func NewVerifier(o *VerifierOptions) *Verifier {
+	return &Verifier{
+		secrets:     o.secrets(),
+		allowLegacy: o.allowLegacy(),
+		tolerance:   o.tolerance(),
+		now:         o.now(),
+		nonces:      make(map[string]bool),
+	}
+}
+
+// Verify checks the signature headers of a request against its body.  It succeeds if any signature
+// in the SignatureHeader was made with any of this Verifier's secrets.
+func (v *Verifier) Verify(header http.Header, body []byte) error {
+	value := header.Get(SignatureHeader)
+	if len(value) == 0 {
+		return ErrMissingSignature
+	}
+
+	var (
+		timestamp = header.Get(SignatureTimestampHeader)
+		nonce     = header.Get(SignatureNonceHeader)
+		now       = v.now()
+		timed     = false
+	)
+
+	for _, signature := range strings.Split(value, ",") {
+		parts := strings.SplitN(strings.TrimSpace(signature), "=", 2)
+		if len(parts) != 2 {
+			continue
+		}
+
+		newHash, err := newSignatureHash(parts[0])
+		if err != nil {
+			continue
+		}
+
+		material := body
+		if isLegacySignature(parts[0]) {
+			if !v.allowLegacy {
+				continue
+			}
+		} else {
+			if err := v.checkTimestamp(timestamp, nonce, now); err != nil {
+				return err
+			}
+
+			material, timed = signedMaterial(timestamp, nonce, body), true
+		}
+
+		for _, secret := range v.secrets {
+			if hmac.Equal([]byte(parts[1]), []byte(computeSignature(newHash, secret, material))) {
+				if timed {
+					return v.useNonce(nonce, now)
+				}
+
+				return nil
+			}
+		}
+	}
+
+	return ErrInvalidSignature
+}
+
+// checkTimestamp verifies that a signature was made within the tolerance of the current time
+func (v *Verifier) checkTimestamp(timestamp, nonce string, now time.Time) error {
+	if len(timestamp) == 0 || len(nonce) == 0 {
+		return ErrMissingSignature
+	}
+
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return ErrInvalidSignature
+	}
+
+	delta := now.Sub(time.Unix(seconds, 0))
+	if delta > v.tolerance || delta < -v.tolerance {
+		return ErrExpiredSignature
+	}
+
+	return nil
+}
+
+// useNonce records a nonce as used, failing if it has already been used.  Nonces are forgotten once
+// any signature bearing them would have expired.  Every nonce has the same lifetime, so they expire in
+// the order they were used and only the oldest need to be examined.
+func (v *Verifier) useNonce(nonce string, now time.Time) error {
+	v.lock.Lock()
+	defer v.lock.Unlock()
+
+	for len(v.expiries) > 0 && now.After(v.expiries[0].expiry) {
+		delete(v.nonces, v.expiries[0].nonce)
+		v.expiries[0] = nonceExpiry{}
+		v.expiries = v.expiries[1:]
+	}
+
+	if v.nonces[nonce] {
+		return ErrReplayedSignature
+	}
+
+	v.nonces[nonce] = true
+	v.expiries = append(v.expiries, nonceExpiry{nonce: nonce, expiry: now.Add(2 * v.tolerance)})
+	return nil
+}
+
+// Then decorates an http.Handler so that requests are only passed on if their signatures verify.
+// Requests that fail verification receive a 401 response.
+func (v *Verifier) Then(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		body, err := ioutil.ReadAll(request.Body)
+		request.Body.Close()
+		if err != nil {
+			xhttp.WriteError(response, http.StatusBadRequest, "request body error")
+			return
+		}
+
+		if err := v.Verify(request.Header, body); err != nil {
+			xhttp.WriteError(response, http.StatusUnauthorized, err.Error())
+			return
+		}
+
+		request.Body = ioutil.NopCloser(bytes.NewReader(body))
+		next.ServeHTTP(response, request)
+	})
+}
@@ -0,0 +1,283 @@
+package webhook
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignHeaderLegacy(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		body   = []byte("hello")
+		header = make(http.Header)
+	)
+
+	assert.NoError(SignHeader(header, "", nil, body, time.Now()))
+	assert.Empty(header)
+
+	assert.NoError(SignHeader(header, SignatureSHA1, []string{"secret"}, body, time.Now()))
+	assert.Equal(Sign("secret", body), header.Get(SignatureHeader))
+	assert.Empty(header.Get(SignatureTimestampHeader))
+	assert.Empty(header.Get(SignatureNonceHeader))
+
+	assert.Error(SignHeader(header, "md5", []string{"secret"}, body, time.Now()))
+	assert.Error(ValidateSignatureAlgorithm("md5"))
+	assert.NoError(ValidateSignatureAlgorithm("SHA512"))
+}
+
+func TestSignHeaderTimestamped(t *testing.T) {
+	for _, algorithm := range []string{SignatureSHA256, SignatureSHA512} {
+		t.Run(algorithm, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+
+				now    = time.Unix(1500000000, 0)
+				body   = []byte("hello")
+				first  = make(http.Header)
+				second = make(http.Header)
+			)
+
+			require.NoError(SignHeader(first, algorithm, []string{"new", "old"}, body, now))
+			require.NoError(SignHeader(second, algorithm, []string{"new", "old"}, body, now))
+
+			assert.Equal("1500000000", first.Get(SignatureTimestampHeader))
+			assert.NotEmpty(first.Get(SignatureNonceHeader))
+			assert.NotEqual(first.Get(SignatureNonceHeader), second.Get(SignatureNonceHeader))
+
+			signatures := strings.Split(first.Get(SignatureHeader), ",")
+			require.Len(signatures, 2)
+			for _, s := range signatures {
+				assert.True(strings.HasPrefix(s, algorithm+"="))
+			}
+
+			// a subscriber holding either secret can verify the signature
+			for _, secret := range []string{"new", "old"} {
+				v := NewVerifier(&VerifierOptions{Secrets: []string{secret}, Now: func() time.Time { return now }})
+				assert.NoError(v.Verify(first, body), secret)
+			}
+		})
+	}
+}
+
+func TestVerifier(t *testing.T) {
+	var (
+		now  = time.Unix(1500000000, 0)
+		body = []byte("hello")
+
+		signed = func(algorithm, secret string, when time.Time) http.Header {
+			header := make(http.Header)
+			require.NoError(t, SignHeader(header, algorithm, []string{secret}, body, when))
+			return header
+		}
+	)
+
+	testData := []struct {
+		description string
+		options     VerifierOptions
+		header      http.Header
+		body        string
+		expected    error
+	}{
+		{"Valid", VerifierOptions{Secrets: []string{"secret"}}, signed(SignatureSHA256, "secret", now), "hello", nil},
+		{"RotatedSecrets", VerifierOptions{Secrets: []string{"new", "secret"}}, signed(SignatureSHA512, "secret", now), "hello", nil},
+		{"WithinTolerance", VerifierOptions{Secrets: []string{"secret"}}, signed(SignatureSHA256, "secret", now.Add(-4*time.Minute)), "hello", nil},
+		{"Missing", VerifierOptions{Secrets: []string{"secret"}}, http.Header{}, "hello", ErrMissingSignature},
+		{"WrongSecret", VerifierOptions{Secrets: []string{"other"}}, signed(SignatureSHA256, "secret", now), "hello", ErrInvalidSignature},
+		{"TamperedBody", VerifierOptions{Secrets: []string{"secret"}}, signed(SignatureSHA256, "secret", now), "goodbye", ErrInvalidSignature},
+		{"Expired", VerifierOptions{Secrets: []string{"secret"}}, signed(SignatureSHA256, "secret", now.Add(-time.Hour)), "hello", ErrExpiredSignature},
+		{"Future", VerifierOptions{Secrets: []string{"secret"}}, signed(SignatureSHA256, "secret", now.Add(time.Hour)), "hello", ErrExpiredSignature},
+		{"CustomTolerance", VerifierOptions{Secrets: []string{"secret"}, Tolerance: time.Minute}, signed(SignatureSHA256, "secret", now.Add(-2*time.Minute)), "hello", ErrExpiredSignature},
+		{"LegacyRejected", VerifierOptions{Secrets: []string{"secret"}}, signed(SignatureSHA1, "secret", now), "hello", ErrInvalidSignature},
+		{"LegacyAllowed", VerifierOptions{Secrets: []string{"secret"}, AllowLegacy: true}, signed(SignatureSHA1, "secret", now), "hello", nil},
+		{"UnknownAlgorithm", VerifierOptions{Secrets: []string{"secret"}}, http.Header{SignatureHeader: {"md5=abcdef"}}, "hello", ErrInvalidSignature},
+	}
+
+	for _, record := range testData {
+		t.Run(record.description, func(t *testing.T) {
+			record.options.Now = func() time.Time { return now }
+			v := NewVerifier(&record.options)
+			assert.Equal(t, record.expected, v.Verify(record.header, []byte(record.body)))
+		})
+	}
+
+	t.Run("MissingNonce", func(t *testing.T) {
+		header := signed(SignatureSHA256, "secret", now)
+		header.Del(SignatureNonceHeader)
+
+		v := NewVerifier(&VerifierOptions{Secrets: []string{"secret"}, Now: func() time.Time { return now }})
+		assert.Equal(t, ErrMissingSignature, v.Verify(header, body))
+	})
+
+	t.Run("AlteredTimestamp", func(t *testing.T) {
+		header := signed(SignatureSHA256, "secret", now)
+		header.Set(SignatureTimestampHeader, strconv.FormatInt(now.Unix()+1, 10))
+
+		v := NewVerifier(&VerifierOptions{Secrets: []string{"secret"}, Now: func() time.Time { return now }})
+		assert.Equal(t, ErrInvalidSignature, v.Verify(header, body))
+	})
+
+	t.Run("Replay", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			current = now
+			v       = NewVerifier(&VerifierOptions{Secrets: []string{"secret"}, Now: func() time.Time { return current }})
+			header  = signed(SignatureSHA256, "secret", now)
+		)
+
+		assert.NoError(v.Verify(header, body))
+		assert.Equal(ErrReplayedSignature, v.Verify(header, body))
+
+		// once the signature expires, its nonce is forgotten but the timestamp check rejects it
+		current = now.Add(time.Hour)
+		assert.Equal(ErrExpiredSignature, v.Verify(header, body))
+		assert.NoError(v.Verify(signed(SignatureSHA256, "secret", current), body))
+		assert.Len(v.nonces, 1)
+		assert.Len(v.expiries, 1)
+	})
+}
+
+func TestVerifierThen(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		v      = NewVerifier(&VerifierOptions{Secrets: []string{"secret"}})
+
+		handler = v.Then(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			body := make([]byte, 5)
+			request.Body.Read(body)
+			assert.Equal("hello", string(body))
+			response.WriteHeader(http.StatusAccepted)
+		}))
+	)
+
+	request := httptest.NewRequest("POST", "/", strings.NewReader("hello"))
+	require.NoError(t, SignHeader(request.Header, SignatureSHA256, []string{"secret"}, []byte("hello"), time.Now()))
+	response := httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	assert.Equal(http.StatusAccepted, response.Code)
+
+	request = httptest.NewRequest("POST", "/", strings.NewReader("hello"))
+	response = httptest.NewRecorder()
+	handler.ServeHTTP(response, request)
+	assert.Equal(http.StatusUnauthorized, response.Code)
+}
+
+func TestWActiveSecrets(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		now    = time.Now()
+		w      W
+	)
+
+	assert.Empty(w.ActiveSecrets(now))
+
+	w.Config.Secret = "new"
+	w.Config.PreviousSecret = "old"
+	assert.Equal([]string{"new"}, w.ActiveSecrets(now))
+
+	w.Config.PreviousSecretUntil = now.Add(time.Minute)
+	assert.Equal([]string{"new", "old"}, w.ActiveSecrets(now))
+	assert.Equal([]string{"new"}, w.ActiveSecrets(now.Add(time.Hour)))
+}
+
+func TestListUpdateRenewsEntireConfig(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		original = newTestHook("http://example.com/", ".*")
+		renewed  = newTestHook("http://example.com/", "device-status/.*")
+	)
+
+	original.Config.Secret = "old"
+	list := NewList([]W{original})
+
+	renewed.Config.Secret = "new"
+	renewed.Config.SignatureAlgorithm = SignatureSHA512
+	renewed.Config.AlternativeURLs = []string{"http://alternate.example.com/"}
+	renewed.FailureURL = "http://failure.example.com/"
+
+	before := time.Now()
+	list.Update([]W{renewed})
+
+	require.Equal(1, list.Len())
+	current := *list.Get(0)
+	assert.Equal(renewed.Events, current.Events)
+	assert.Equal("new", current.Config.Secret)
+	assert.Equal(SignatureSHA512, current.Config.SignatureAlgorithm)
+	assert.Equal(renewed.Config.AlternativeURLs, current.Config.AlternativeURLs)
+	assert.Equal(renewed.FailureURL, current.FailureURL)
+
+	// the replaced secret remains active for the grace period
+	assert.Equal("old", current.Config.PreviousSecret)
+	assert.False(current.Config.PreviousSecretUntil.Before(before.Add(DefaultSecretRotationGracePeriod)))
+	assert.False(current.Config.PreviousSecretUntil.After(time.Now().Add(DefaultSecretRotationGracePeriod)))
+
+	// a renewal with the same secret keeps the rotation in progress
+	sameSecret := newTestHook("http://example.com/", ".*")
+	sameSecret.Config.Secret = "new"
+	list.Update([]W{sameSecret})
+	assert.Equal("old", list.Get(0).Config.PreviousSecret)
+	assert.Equal(current.Config.PreviousSecretUntil, list.Get(0).Config.PreviousSecretUntil)
+
+	// expired registrations are ignored
+	expired := newTestHook("http://example.com/", ".*")
+	expired.Until = time.Now().Add(-time.Minute)
+	list.Update([]W{expired})
+	assert.Equal("new", list.Get(0).Config.Secret)
+	assert.Equal("old", list.Get(0).Config.PreviousSecret)
+}
+
+func TestNewWClearsPreviousSecret(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	w, err := NewW([]byte(`{"config": {"url": "http://example.com/", "secret": "new", "previous_secret": "old", "previous_secret_until": "2099-01-01T00:00:00Z"}, "events": [".*"]}`), "")
+	require.NoError(err)
+	require.NotNil(w)
+	assert.Equal("new", w.Config.Secret)
+	assert.Empty(w.Config.PreviousSecret)
+	assert.True(w.Config.PreviousSecretUntil.IsZero())
+}
+
+func TestNewWInvalidSignatureAlgorithm(t *testing.T) {
+	w, err := NewW([]byte(`{"config": {"url": "http://example.com/", "signature_algorithm": "md5"}, "events": [".*"]}`), "")
+	assert.Nil(t, w)
+	assert.Error(t, err)
+}
+
+func TestDispatcherSignsWithRotatedSecrets(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server, received = newTestReceiver(http.StatusOK)
+		hook             = newTestHook(server.URL, ".*")
+	)
+
+	defer server.Close()
+	hook.Config.Secret = "new"
+	hook.Config.SignatureAlgorithm = SignatureSHA256
+	hook.Config.PreviousSecret = "old"
+	hook.Config.PreviousSecretUntil = time.Now().Add(time.Hour)
+
+	d := NewDispatcher(&DispatcherOptions{AllowedNetworks: testAllowedNetworks}, NewList([]W{hook}))
+	event, _ := newTestEvent(t, "mac:112233445566", "event:device-status/online")
+	d.OnDeviceEvent(event)
+	d.Stop()
+
+	require.Len(received, 1)
+	r := <-received
+	for _, secret := range []string{"new", "old"} {
+		assert.NoError(NewVerifier(&VerifierOptions{Secrets: []string{secret}}).Verify(r.header, r.body), secret)
+	}
+}
@@ -1,6 +1,7 @@
 package webhook
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -127,10 +128,16 @@ func getPayload(resp *http.Response) (body []byte, err error) {
 }
 
 func (sc *StartConfig) makeRequest() (resp *http.Response, err error) {
+	return sc.makeRequestContext(context.Background())
+}
+
+func (sc *StartConfig) makeRequestContext(ctx context.Context) (resp *http.Response, err error) {
 	req, err := http.NewRequest("GET", sc.ApiPath, nil)
 	if err != nil {
 		return
 	}
+
+	req = req.WithContext(ctx)
 	req.Header.Set("content-type", "application/json")
 
 	if len(sc.Sat.Token) < 1 && len(sc.AuthHeader) > 0 {
@@ -147,6 +154,46 @@ func (sc *StartConfig) makeRequest() (resp *http.Response, err error) {
 	return
 }
 
+// fetch makes a single request for the current hooks
+func (sc *StartConfig) fetch(ctx context.Context) (hooks []W, err error) {
+	resp, err := sc.makeRequestContext(ctx)
+	if err != nil {
+		return
+	}
+
+	body, err := getPayload(resp)
+	if err != nil {
+		return
+	}
+
+	err = json.Unmarshal(body, &hooks)
+
+	// temporary fix to convert old webhook struct to new.
+	if err != nil && strings.HasPrefix(err.Error(), "parsing time") {
+		hooks, err = convertOldHooksToNewHooks(body)
+	}
+
+	return
+}
+
+// Name identifies this StartConfig as the HTTPBootstrap source
+func (sc *StartConfig) Name() string {
+	return HTTPBootstrap
+}
+
+// Hooks makes a single request for the current hooks, obtaining a SAT token first if one is configured
+// and has not yet been obtained.  This allows a StartConfig to be used as a BootstrapSource.
+func (sc *StartConfig) Hooks(ctx context.Context) ([]W, time.Time, error) {
+	if sc.Sat.Token == "" && len(sc.Sat.Path) > 0 {
+		if err := sc.getAuthorization(); err != nil {
+			return nil, time.Time{}, err
+		}
+	}
+
+	hooks, err := sc.fetch(ctx)
+	return hooks, time.Now(), err
+}
+
 func (sc *StartConfig) GetCurrentSystemsHooks(rc chan Result) {
 	var hooks []W
 
@@ -159,15 +206,7 @@ func (sc *StartConfig) GetCurrentSystemsHooks(rc chan Result) {
 	}
 
 	fn := func(sc *StartConfig, rChan chan Result) {
-		resp, err := sc.makeRequest()
-		body, err := getPayload(resp)
-		err = json.Unmarshal(body, &hooks)
-
-		// temporary fix to convert old webhook struct to new.
-		if err != nil && strings.HasPrefix(err.Error(), "parsing time") {
-			hooks, err = convertOldHooksToNewHooks(body)
-		}
-
+		hooks, err := sc.fetch(context.Background())
 		rChan <- Result{hooks, err}
 	}
 
@@ -188,7 +227,7 @@ func (sc *StartConfig) GetCurrentSystemsHooks(rc chan Result) {
 			}
 
 		case <-timeout:
-			rc <- Result{hooks, errors.New("Unable to obtain hook list in allotted time.")}
+			rc <- Result{hooks, ErrBootstrapTimeout}
 			return
 		}
 	}
@@ -0,0 +1,417 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/log/level"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/discard"
+	"github.com/gorilla/mux"
+	"github.com/jithin-kg/webpa-common/service"
+	servicemonitor "github.com/jithin-kg/webpa-common/service/monitor"
+	AWS "github.com/jithin-kg/webpa-common/webhook/aws"
+	"github.com/jithin-kg/webpa-common/xhttp"
+	"github.com/jithin-kg/webpa-common/xmetrics"
+	"github.com/spf13/viper"
+)
+
+const (
+	// SNSTransport propagates webhook registrations through an AWS SNS topic.  This is the default.
+	SNSTransport = "sns"
+
+	// GossipTransport propagates webhook registrations by POSTing them directly to peer instances
+	GossipTransport = "gossip"
+
+	// LoopbackTransport applies webhook registrations only to the local process.  This is useful
+	// for tests and single-node deployments.
+	LoopbackTransport = "loopback"
+
+	DefaultSyncPath    = "/api/v2/webhook/sync"
+	DefaultSyncTimeout = 10 * time.Second
+)
+
+var errSyncSecretRequired = errors.New("The gossip webhook sync transport requires a secret")
+
+// localRegistrationKey is the context key marking registrations published by this process, which
+// were already validated by the Registry before they were published
+type localRegistrationKey struct{}
+
+// isLocalRegistration tests if a request carries a registration published by this process
+func isLocalRegistration(request *http.Request) bool {
+	local, _ := request.Context().Value(localRegistrationKey{}).(bool)
+	return local
+}
+
+// SyncConfig configures how webhook registrations are propagated across the cluster
+type SyncConfig struct {
+	// Transport is one of SNSTransport, GossipTransport, or LoopbackTransport.  If unset,
+	// SNSTransport is used.
+	Transport string `json:"transport"`
+
+	// Path is the URL path on which peers receive registrations.  Only used by the gossip transport.
+	// If unset, DefaultSyncPath is used.
+	Path string `json:"path"`
+
+	// Peers is the initial set of peer instances, e.g. https://host:8080.  When service discovery
+	// is in use, the discovered instances replace these.  Only used by the gossip transport.
+	Peers []string `json:"peers"`
+
+	// ServiceKey is the key of the service discovery instancer whose instances are the peers, as reported
+	// in servicemonitor.Event.Key.  Events from other instancers are ignored, so that a GossipNotifier can
+	// listen to a monitor with several watches.  If unset, the events of every instancer are used, which is
+	// only appropriate when the monitor watches just the peers.  Only used by the gossip transport.
+	ServiceKey string `json:"serviceKey"`
+
+	// Secret is used to sign registrations sent to peers and to verify registrations received
+	// from peers.  It is required by the gossip transport, and unused by the others.
+	Secret string `json:"secret"`
+
+	// Timeout is the HTTP client timeout for requests to peers.  If unset, DefaultSyncTimeout is used.
+	Timeout time.Duration `json:"timeout"`
+}
+
+func (c *SyncConfig) transport() string {
+	if c != nil && len(c.Transport) > 0 {
+		return strings.ToLower(c.Transport)
+	}
+
+	return SNSTransport
+}
+
+func (c *SyncConfig) path() string {
+	if c != nil && len(c.Path) > 0 {
+		return c.Path
+	}
+
+	return DefaultSyncPath
+}
+
+func (c *SyncConfig) peers() []string {
+	if c != nil {
+		return c.Peers
+	}
+
+	return nil
+}
+
+func (c *SyncConfig) serviceKey() string {
+	if c != nil {
+		return c.ServiceKey
+	}
+
+	return ""
+}
+
+func (c *SyncConfig) secret() string {
+	if c != nil {
+		return c.Secret
+	}
+
+	return ""
+}
+
+func (c *SyncConfig) timeout() time.Duration {
+	if c != nil && c.Timeout > 0 {
+		return c.Timeout
+	}
+
+	return DefaultSyncTimeout
+}
+
+// NewNotifier creates the AWS.Notifier for the configured transport.  The viper environment is only
+// consulted for the SNS transport, and may be nil.  The gossip transport accepts registrations from
+// any client that can reach the sync path, so an error is returned if it has no Secret.
+func (c *SyncConfig) NewNotifier(v *viper.Viper) (AWS.Notifier, error) {
+	switch c.transport() {
+	case SNSTransport:
+		return AWS.NewNotifier(v)
+	case GossipTransport:
+		if len(c.secret()) == 0 {
+			return nil, errSyncSecretRequired
+		}
+
+		return NewGossipNotifier(c), nil
+	case LoopbackTransport:
+		return NewLoopbackNotifier(), nil
+	default:
+		return nil, fmt.Errorf("Unsupported webhook sync transport: %s", c.Transport)
+	}
+}
+
+// LoopbackNotifier is an AWS.Notifier that never leaves the process.  Published messages are handed
+// directly to the handler supplied to Initialize, which is normally the webhook monitor.
+type LoopbackNotifier struct {
+	handler  http.Handler
+	errorLog log.Logger
+}
+
+// NewLoopbackNotifier creates a Notifier which applies registrations only to the local process
+func NewLoopbackNotifier() *LoopbackNotifier {
+	return &LoopbackNotifier{
+		errorLog: log.NewNopLogger(),
+	}
+}
+
+func (ln *LoopbackNotifier) Initialize(_ *mux.Router, _ *url.URL, _ string, handler http.Handler, logger log.Logger, _ xmetrics.Registry, _ func() time.Time) {
+	ln.handler = handler
+	if logger != nil {
+		ln.errorLog = level.Error(logger)
+	}
+}
+
+func (ln *LoopbackNotifier) PrepareAndStart() {}
+
+func (ln *LoopbackNotifier) Subscribe() {}
+
+func (ln *LoopbackNotifier) Unsubscribe(string) {}
+
+// PublishMessage applies the message locally by invoking the handler as though a peer had POSTed it
+func (ln *LoopbackNotifier) PublishMessage(message string) error {
+	return ln.apply([]byte(message), nil)
+}
+
+// apply hands a message, along with any headers, to the handler
+func (ln *LoopbackNotifier) apply(body []byte, header http.Header) error {
+	if ln.handler == nil {
+		return errors.New("No webhook handler has been initialized")
+	}
+
+	request, err := http.NewRequest(http.MethodPost, DefaultSyncPath, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	request = request.WithContext(context.WithValue(request.Context(), localRegistrationKey{}, true))
+
+	for name, values := range header {
+		request.Header[name] = values
+	}
+
+	response := httptest.NewRecorder()
+	ln.handler.ServeHTTP(response, request)
+	if response.Code >= http.StatusBadRequest {
+		ln.errorLog.Log("msg", "webhook registration rejected", "code", response.Code)
+		return &xhttp.Error{Code: response.Code, Text: strings.TrimSpace(response.Body.String())}
+	}
+
+	return nil
+}
+
+// NotificationHandle returns the request body, which is the published message
+func (ln *LoopbackNotifier) NotificationHandle(response http.ResponseWriter, request *http.Request) []byte {
+	body, err := ioutil.ReadAll(request.Body)
+	request.Body.Close()
+	if err != nil {
+		xhttp.WriteError(response, http.StatusBadRequest, "request body error")
+		return nil
+	}
+
+	return body
+}
+
+func (ln *LoopbackNotifier) ValidateSubscriptionArn(string) bool { return true }
+
+func (ln *LoopbackNotifier) SNSNotificationReceivedCounter(int) {}
+
+func (ln *LoopbackNotifier) DnsReady() error { return nil }
+
+// GossipNotifier is an AWS.Notifier that propagates registrations by POSTing them to every known peer.
+// Registrations are always applied locally as well.  Peers receive registrations on the configured
+// sync path and apply them without forwarding, so every published message makes exactly one hop.
+//
+// GossipNotifier is also a monitor.Listener, so that the set of peers can be kept current via
+// service discovery.
+type GossipNotifier struct {
+	LoopbackNotifier
+
+	path       string
+	serviceKey string
+	secret     string
+	verifier   *Verifier
+	client     xhttp.Client
+	self       string
+	peers      atomic.Value
+	sent       metrics.Counter
+	debugLog   log.Logger
+}
+
+// NewGossipNotifier creates a Notifier which sends registrations to the peers described by the given configuration.
+// A GossipNotifier without a secret rejects every registration it receives from peers.
+func NewGossipNotifier(c *SyncConfig) *GossipNotifier {
+	gn := &GossipNotifier{
+		LoopbackNotifier: *NewLoopbackNotifier(),
+		path:             c.path(),
+		serviceKey:       c.serviceKey(),
+		secret:           c.secret(),
+		client:           &http.Client{Timeout: c.timeout()},
+		debugLog:         log.NewNopLogger(),
+	}
+
+	if len(gn.secret) > 0 {
+		gn.verifier = NewVerifier(&VerifierOptions{Secrets: []string{gn.secret}})
+	}
+
+	gn.peers.Store(c.peers())
+	return gn
+}
+
+// Initialize registers the sync path on the router, so that this instance can receive registrations
+// from its peers.  The selfURL, if supplied, is used to avoid sending registrations back to this instance.
+func (gn *GossipNotifier) Initialize(rtr *mux.Router, selfURL *url.URL, soaProvider string, handler http.Handler, logger log.Logger, registry xmetrics.Registry, now func() time.Time) {
+	gn.LoopbackNotifier.Initialize(rtr, selfURL, soaProvider, handler, logger, registry, now)
+	if logger != nil {
+		gn.debugLog = level.Debug(logger)
+	}
+
+	if selfURL != nil {
+		gn.self = selfURL.Host
+	}
+
+	if registry != nil {
+		gn.sent = ApplyMetricsData(registry).SyncSent
+	} else {
+		gn.sent = discard.NewCounter()
+	}
+
+	if rtr != nil && handler != nil {
+		rtr.Handle(gn.path, handler).Methods(http.MethodPost)
+	}
+}
+
+// MonitorEvent replaces the current peers with the discovered instances.  Discovery errors leave
+// the current peers untouched, and events from instancers other than the configured ServiceKey are ignored.
+func (gn *GossipNotifier) MonitorEvent(e servicemonitor.Event) {
+	switch {
+	case len(gn.serviceKey) > 0 && e.Key != gn.serviceKey:
+	case e.Stopped:
+	case e.Err != nil:
+		gn.errorLog.Log("msg", "webhook peer discovery error, retaining current peers", "error", e.Err)
+	default:
+		gn.debugLog.Log("msg", "webhook peers updated", "count", len(e.Instances))
+		gn.peers.Store(e.Instances)
+	}
+}
+
+// Peers returns the current peer instances
+func (gn *GossipNotifier) Peers() []string {
+	return gn.peers.Load().([]string)
+}
+
+// isSelf tests if the given peer instance refers to this instance
+func (gn *GossipNotifier) isSelf(peer string) bool {
+	if len(gn.self) == 0 {
+		return false
+	}
+
+	normalized, err := service.NormalizeInstance("http", peer)
+	if err != nil {
+		return false
+	}
+
+	u, err := url.Parse(normalized)
+	return err == nil && u.Host == gn.self
+}
+
+// PublishMessage applies the message locally, then sends it to all peers concurrently.  Failures to
+// reach a peer are logged and counted, but do not fail the publish.
+func (gn *GossipNotifier) PublishMessage(message string) error {
+	var (
+		body      = []byte(message)
+		waitGroup sync.WaitGroup
+	)
+
+	if err := gn.apply(body, gn.header(body)); err != nil {
+		return err
+	}
+
+	for _, peer := range gn.Peers() {
+		if gn.isSelf(peer) {
+			continue
+		}
+
+		waitGroup.Add(1)
+		go func(peer string) {
+			defer waitGroup.Done()
+			gn.send(peer, body)
+		}(peer)
+	}
+
+	waitGroup.Wait()
+	return nil
+}
+
+// header creates the HTTP header sent along with a registration
+func (gn *GossipNotifier) header(body []byte) http.Header {
+	header := http.Header{"Content-Type": {"application/json"}}
+	if len(gn.secret) > 0 {
+		if err := SignHeader(header, SignatureSHA256, []string{gn.secret}, body, time.Now()); err != nil {
+			gn.errorLog.Log("msg", "unable to sign webhook registration", "error", err)
+		}
+	}
+
+	return header
+}
+
+func (gn *GossipNotifier) send(peer string, body []byte) {
+	target := strings.TrimSuffix(peer, "/") + gn.path
+	request, err := http.NewRequest(http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		gn.sent.With(URLLabel, peer, CodeLabel, TransportFailureCode).Add(1.0)
+		gn.errorLog.Log("msg", "invalid webhook peer", URLLabel, peer, "error", err)
+		return
+	}
+
+	request.Header = gn.header(body)
+
+	response, err := gn.client.Do(request)
+	if err != nil {
+		gn.sent.With(URLLabel, peer, CodeLabel, TransportFailureCode).Add(1.0)
+		gn.errorLog.Log("msg", "unable to send webhook registration to peer", URLLabel, peer, "error", err)
+		return
+	}
+
+	ioutil.ReadAll(response.Body)
+	response.Body.Close()
+
+	gn.sent.With(URLLabel, peer, CodeLabel, strconv.Itoa(response.StatusCode)).Add(1.0)
+	if response.StatusCode >= http.StatusBadRequest {
+		gn.errorLog.Log("msg", "webhook registration rejected by peer", URLLabel, peer, "code", response.StatusCode)
+	}
+}
+
+// NotificationHandle returns the request body after verifying its signature.  Registrations are signed with
+// SignatureSHA256, so a captured registration cannot be replayed.  If no secret is configured, every
+// registration is rejected.
+func (gn *GossipNotifier) NotificationHandle(response http.ResponseWriter, request *http.Request) []byte {
+	body := gn.LoopbackNotifier.NotificationHandle(response, request)
+	if body == nil {
+		return nil
+	}
+
+	if gn.verifier == nil {
+		gn.errorLog.Log("msg", "no sync secret configured, rejecting webhook registration", "remoteAddr", request.RemoteAddr)
+		xhttp.WriteError(response, http.StatusForbidden, "invalid signature")
+		return nil
+	}
+
+	if err := gn.verifier.Verify(request.Header, body); err != nil {
+		gn.errorLog.Log("msg", "invalid webhook registration signature", "remoteAddr", request.RemoteAddr, "error", err)
+		xhttp.WriteError(response, http.StatusForbidden, "invalid signature")
+		return nil
+	}
+
+	return body
+}
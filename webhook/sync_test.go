@@ -0,0 +1,288 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	servicemonitor "github.com/jithin-kg/webpa-common/service/monitor"
+	AWS "github.com/jithin-kg/webpa-common/webhook/aws"
+	"github.com/jithin-kg/webpa-common/xhttp"
+	"github.com/jithin-kg/webpa-common/xmetrics"
+	"github.com/jithin-kg/webpa-common/xmetrics/xmetricstest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var (
+	_ AWS.Notifier            = (*LoopbackNotifier)(nil)
+	_ AWS.Notifier            = (*GossipNotifier)(nil)
+	_ servicemonitor.Listener = (*GossipNotifier)(nil)
+)
+
+// syncNode is a single webhook instance in a test cluster
+type syncNode struct {
+	factory  *Factory
+	registry Registry
+	server   *httptest.Server
+	provider xmetricstest.Provider
+}
+
+func newSyncNode(t *testing.T, n AWS.Notifier) *syncNode {
+	f, err := NewFactory(nil)
+	require.NoError(t, err)
+	f.Notifier = n
+
+	metricsRegistry, err := xmetrics.NewRegistry(nil, Metrics)
+	require.NoError(t, err)
+	registry, handler := f.NewRegistryAndHandler(metricsRegistry)
+
+	router := mux.NewRouter()
+	server := httptest.NewServer(router)
+	self, err := url.Parse(server.URL)
+	require.NoError(t, err)
+
+	f.Initialize(router, self, "", handler, nil, metricsRegistry, time.Now)
+
+	// capture gossip metrics so that tests can assert against them
+	provider := xmetricstest.NewProvider(nil, Metrics)
+	if gn, ok := n.(*GossipNotifier); ok {
+		gn.sent = provider.NewCounter(SyncSentCounter)
+	}
+
+	return &syncNode{factory: f, registry: registry, server: server, provider: provider}
+}
+
+// waitForHooks waits for the monitor's listen goroutine to apply the expected number of webhooks
+func (n *syncNode) waitForHooks(expected int) int {
+	for i := 0; i < 100 && n.factory.m.list.Len() != expected; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	return n.factory.m.list.Len()
+}
+
+func newSyncMessage(t *testing.T, url string) string {
+	w := new(W)
+	w.Config.URL = url
+	w.Config.ContentType = "application/json"
+	w.Events = []string{".*"}
+	w.Until = time.Now().Add(time.Hour)
+
+	data, err := json.Marshal(w)
+	require.NoError(t, err)
+	return string(data)
+}
+
+func TestSyncConfigNewNotifier(t *testing.T) {
+	testData := []struct {
+		config   *SyncConfig
+		expected interface{}
+	}{
+		{nil, new(AWS.SNSServer)},
+		{&SyncConfig{Transport: "SNS"}, new(AWS.SNSServer)},
+		{&SyncConfig{Transport: GossipTransport, Secret: "secret"}, new(GossipNotifier)},
+		{&SyncConfig{Transport: LoopbackTransport}, new(LoopbackNotifier)},
+	}
+
+	for _, record := range testData {
+		t.Run(record.config.transport(), func(t *testing.T) {
+			n, err := record.config.NewNotifier(nil)
+			assert.NoError(t, err)
+			assert.IsType(t, record.expected, n)
+		})
+	}
+
+	t.Run("Unsupported", func(t *testing.T) {
+		n, err := (&SyncConfig{Transport: "carrier-pigeon"}).NewNotifier(nil)
+		assert.Nil(t, n)
+		assert.Error(t, err)
+	})
+
+	t.Run("GossipWithoutSecret", func(t *testing.T) {
+		n, err := (&SyncConfig{Transport: GossipTransport}).NewNotifier(nil)
+		assert.Nil(t, n)
+		assert.Equal(t, errSyncSecretRequired, err)
+	})
+}
+
+func TestLoopbackNotifier(t *testing.T) {
+	t.Run("Uninitialized", func(t *testing.T) {
+		assert.Error(t, NewLoopbackNotifier().PublishMessage(newSyncMessage(t, "http://example.com/")))
+	})
+
+	t.Run("Publish", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+			node    = newSyncNode(t, NewLoopbackNotifier())
+		)
+
+		defer node.server.Close()
+		assert.NoError(node.factory.PublishMessage(newSyncMessage(t, "http://example.com/")))
+		require.Equal(1, node.waitForHooks(1))
+		assert.Equal("http://example.com/", node.factory.m.list.Get(0).Config.URL)
+		assert.True(node.factory.ValidateSubscriptionArn("anything"))
+		assert.NoError(node.factory.DnsReady())
+	})
+
+	t.Run("Malformed", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			node   = newSyncNode(t, NewLoopbackNotifier())
+		)
+
+		defer node.server.Close()
+		err := node.factory.PublishMessage("this is not a webhook")
+		if assert.IsType(&xhttp.Error{}, err) {
+			assert.Equal(http.StatusBadRequest, err.(*xhttp.Error).Code)
+		}
+
+		assert.Zero(node.factory.m.list.Len())
+	})
+}
+
+func TestGossipNotifier(t *testing.T) {
+	t.Run("Publish", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			peer = newSyncNode(t, NewGossipNotifier(&SyncConfig{Transport: GossipTransport, Secret: "secret"}))
+		)
+
+		defer peer.server.Close()
+
+		// the publisher's own instance is in its peer list, as it would be with service discovery
+		publisher := newSyncNode(t, NewGossipNotifier(&SyncConfig{Transport: GossipTransport, Secret: "secret"}))
+		defer publisher.server.Close()
+		publisher.factory.Notifier.(*GossipNotifier).MonitorEvent(servicemonitor.Event{
+			Instances: []string{publisher.server.URL, peer.server.URL},
+		})
+
+		require.NoError(publisher.factory.PublishMessage(newSyncMessage(t, "http://example.com/")))
+		assert.Equal(1, publisher.waitForHooks(1))
+		assert.Equal(1, peer.waitForHooks(1))
+
+		p := publisher.provider
+		p.Assert(t, SyncSentCounter, URLLabel, peer.server.URL, CodeLabel, "200")(xmetricstest.Value(1.0))
+		p.Assert(t, SyncSentCounter, URLLabel, publisher.server.URL, CodeLabel, "200")(xmetricstest.Value(0.0))
+	})
+
+	t.Run("BadSignature", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+
+			peer      = newSyncNode(t, NewGossipNotifier(&SyncConfig{Transport: GossipTransport, Secret: "secret"}))
+			publisher = newSyncNode(t, NewGossipNotifier(&SyncConfig{Transport: GossipTransport, Secret: "wrong", Peers: []string{peer.server.URL}}))
+		)
+
+		defer peer.server.Close()
+		defer publisher.server.Close()
+
+		// a peer rejecting the registration does not fail the publish
+		assert.NoError(publisher.factory.PublishMessage(newSyncMessage(t, "http://example.com/")))
+		assert.Equal(1, publisher.waitForHooks(1))
+		assert.Zero(peer.factory.m.list.Len())
+
+		publisher.provider.Assert(t, SyncSentCounter, URLLabel, peer.server.URL, CodeLabel, "403")(xmetricstest.Value(1.0))
+	})
+
+	t.Run("NoSecret", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+
+			peer      = newSyncNode(t, NewGossipNotifier(&SyncConfig{Transport: GossipTransport}))
+			publisher = newSyncNode(t, NewGossipNotifier(&SyncConfig{Transport: GossipTransport, Secret: "secret", Peers: []string{peer.server.URL}}))
+		)
+
+		defer peer.server.Close()
+		defer publisher.server.Close()
+
+		assert.NoError(publisher.factory.PublishMessage(newSyncMessage(t, "http://example.com/")))
+		assert.Equal(1, publisher.waitForHooks(1))
+		assert.Zero(peer.factory.m.list.Len())
+
+		publisher.provider.Assert(t, SyncSentCounter, URLLabel, peer.server.URL, CodeLabel, "403")(xmetricstest.Value(1.0))
+	})
+
+	t.Run("Validation", func(t *testing.T) {
+		var (
+			assert  = assert.New(t)
+			require = require.New(t)
+
+			peer      = newSyncNode(t, NewGossipNotifier(&SyncConfig{Transport: GossipTransport, Secret: "secret"}))
+			publisher = newSyncNode(t, NewGossipNotifier(&SyncConfig{Transport: GossipTransport, Secret: "secret", Peers: []string{peer.server.URL}}))
+		)
+
+		defer peer.server.Close()
+		defer publisher.server.Close()
+
+		validator, err := NewValidator(&ValidatorOptions{DeniedDomains: []string{"example.com"}})
+		require.NoError(err)
+		peer.factory.m.validator = validator
+
+		// the publisher has no validation, but the peer applies its own
+		assert.NoError(publisher.factory.PublishMessage(newSyncMessage(t, "http://example.com/")))
+		assert.Equal(1, publisher.waitForHooks(1))
+		assert.Zero(peer.factory.m.list.Len())
+
+		publisher.provider.Assert(t, SyncSentCounter, URLLabel, peer.server.URL, CodeLabel, "400")(xmetricstest.Value(1.0))
+	})
+
+	t.Run("UnreachablePeer", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+
+			unreachable = httptest.NewServer(http.NotFoundHandler())
+			publisher   = newSyncNode(t, NewGossipNotifier(&SyncConfig{Transport: GossipTransport, Secret: "secret", Peers: []string{unreachable.URL}}))
+		)
+
+		unreachable.Close()
+		defer publisher.server.Close()
+
+		assert.NoError(publisher.factory.PublishMessage(newSyncMessage(t, "http://example.com/")))
+		assert.Equal(1, publisher.waitForHooks(1))
+		publisher.provider.Assert(t, SyncSentCounter, URLLabel, unreachable.URL, CodeLabel, TransportFailureCode)(xmetricstest.Value(1.0))
+	})
+
+	t.Run("MonitorEvent", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			gn     = NewGossipNotifier(&SyncConfig{Peers: []string{"http://initial:8080"}})
+		)
+
+		assert.Equal([]string{"http://initial:8080"}, gn.Peers())
+
+		gn.MonitorEvent(servicemonitor.Event{Instances: []string{"http://discovered:8080"}})
+		assert.Equal([]string{"http://discovered:8080"}, gn.Peers())
+
+		gn.MonitorEvent(servicemonitor.Event{Err: errors.New("expected")})
+		assert.Equal([]string{"http://discovered:8080"}, gn.Peers())
+
+		gn.MonitorEvent(servicemonitor.Event{Stopped: true})
+		assert.Equal([]string{"http://discovered:8080"}, gn.Peers())
+	})
+
+	t.Run("MonitorEventServiceKey", func(t *testing.T) {
+		var (
+			assert = assert.New(t)
+			gn     = NewGossipNotifier(&SyncConfig{Peers: []string{"http://initial:8080"}, ServiceKey: "caduceus"})
+		)
+
+		// events for other watches of the same monitor do not replace the peers
+		gn.MonitorEvent(servicemonitor.Event{Key: "talaria", Instances: []string{"http://talaria:8080"}})
+		assert.Equal([]string{"http://initial:8080"}, gn.Peers())
+
+		gn.MonitorEvent(servicemonitor.Event{Key: "caduceus", Instances: []string{"http://caduceus:8080"}})
+		assert.Equal([]string{"http://caduceus:8080"}, gn.Peers())
+
+		gn.MonitorEvent(servicemonitor.Event{Key: "talaria", Instances: []string{"http://talaria:8080"}})
+		assert.Equal([]string{"http://caduceus:8080"}, gn.Peers())
+	})
+}
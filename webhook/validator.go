@@ -0,0 +1,390 @@
+package webhook
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/jithin-kg/webpa-common/xhttp"
+)
+
+const (
+	// ChallengeParameter is the query parameter carrying the challenge token sent to a webhook during
+	// verification.  The webhook must respond with a 2xx status and the token as its body.
+	ChallengeParameter = "webpa_challenge"
+
+	DefaultValidationTimeout = 10 * time.Second
+)
+
+// blockedNetworks are the address ranges that webhooks may not target unless explicitly allowed.
+// These cover loopback, link-local (including cloud metadata endpoints), private, carrier-grade NAT,
+// benchmarking, unspecified, and multicast addresses, as well as NAT64, which can embed any IPv4 address.
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"224.0.0.0/4",
+	"::/128",
+	"::1/128",
+	"64:ff9b::/96",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+
+		networks[i] = network
+	}
+
+	return networks
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// restrictedAddressError is returned when a connection to a blocked address is refused
+type restrictedAddressError struct {
+	address string
+}
+
+func (e *restrictedAddressError) Error() string {
+	return fmt.Sprintf("connections to %s are not allowed", e.address)
+}
+
+// dialControl produces a net.Dialer Control function which refuses connections to blocked addresses that
+// are not in the allowed networks.  The check happens after the host has been resolved, so it applies
+// even when a webhook's host resolves differently than when the webhook was validated.
+func dialControl(allowed []*net.IPNet) func(string, string, syscall.RawConn) error {
+	return func(_, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+
+		ip := net.ParseIP(host)
+		if ip == nil || (containsIP(blockedNetworks, ip) && !containsIP(allowed, ip)) {
+			return &restrictedAddressError{address: address}
+		}
+
+		return nil
+	}
+}
+
+// refuseRedirect is an http.Client CheckRedirect function which returns redirects to the caller
+// rather than following them, since the redirect target has not been validated
+func refuseRedirect(*http.Request, []*http.Request) error {
+	return http.ErrUseLastResponse
+}
+
+// newRestrictedClient creates an http.Client which neither follows redirects nor connects to blocked
+// addresses outside the allowed networks.  Proxies are not used, since the proxy rather than the target
+// would be checked.
+func newRestrictedClient(timeout time.Duration, allowed []*net.IPNet) *http.Client {
+	dialer := &net.Dialer{
+		Timeout:   timeout,
+		KeepAlive: 30 * time.Second,
+		Control:   dialControl(allowed),
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:       timeout,
+		Transport:     transport,
+		CheckRedirect: refuseRedirect,
+	}
+}
+
+// parseNetworks parses a list of CIDR blocks
+func parseNetworks(cidrs []string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		networks = append(networks, network)
+	}
+
+	return networks, nil
+}
+
+// Resolver is the subset of *net.Resolver used to look up the addresses of webhook hosts
+type Resolver interface {
+	LookupIPAddr(context.Context, string) ([]net.IPAddr, error)
+}
+
+// Validator vets a webhook before it is activated
+type Validator interface {
+	Validate(context.Context, *W) error
+}
+
+// ValidatorOptions configures the Validator returned by NewValidator
+type ValidatorOptions struct {
+	// RequireHTTPS rejects any webhook URL that does not use the https scheme.  Regardless of this
+	// setting, only http and https URLs are accepted.
+	RequireHTTPS bool `json:"requireHTTPS"`
+
+	// AllowedNetworks are CIDR blocks that webhooks may target even though they fall within a
+	// blocked range, e.g. 10.1.0.0/16 for an internal receiver
+	AllowedNetworks []string `json:"allowedNetworks"`
+
+	// AllowedDomains, if not empty, restricts webhooks to these domains and their subdomains
+	AllowedDomains []string `json:"allowedDomains"`
+
+	// DeniedDomains are domains, along with their subdomains, that webhooks may not target.
+	// This list takes precedence over AllowedDomains.
+	DeniedDomains []string `json:"deniedDomains"`
+
+	// Challenge requires that each delivery URL answer a challenge before the webhook is activated
+	Challenge bool `json:"challenge"`
+
+	// Timeout bounds host resolution and each challenge request.  If unset, DefaultValidationTimeout is used.
+	Timeout time.Duration `json:"timeout"`
+
+	// Resolver is used to look up webhook hosts.  If unset, net.DefaultResolver is used.
+	Resolver Resolver `json:"-"`
+
+	// Client is used to send challenges.  If unset, an http.Client that does not follow redirects and
+	// does not connect to blocked addresses is used.
+	Client xhttp.Client `json:"-"`
+}
+
+func (o *ValidatorOptions) timeout() time.Duration {
+	if o != nil && o.Timeout > 0 {
+		return o.Timeout
+	}
+
+	return DefaultValidationTimeout
+}
+
+func (o *ValidatorOptions) resolver() Resolver {
+	if o != nil && o.Resolver != nil {
+		return o.Resolver
+	}
+
+	return net.DefaultResolver
+}
+
+func (o *ValidatorOptions) client(allowed []*net.IPNet) xhttp.Client {
+	if o != nil && o.Client != nil {
+		return o.Client
+	}
+
+	// following a redirect would allow a webhook to bounce the challenge to a blocked address
+	return newRestrictedClient(o.timeout(), allowed)
+}
+
+func normalizeDomains(domains []string) []string {
+	normalized := make([]string, 0, len(domains))
+	for _, d := range domains {
+		if d = strings.Trim(strings.ToLower(strings.TrimSpace(d)), "."); len(d) > 0 {
+			normalized = append(normalized, d)
+		}
+	}
+
+	return normalized
+}
+
+func matchesDomain(domains []string, host string) bool {
+	for _, d := range domains {
+		if host == d || strings.HasSuffix(host, "."+d) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// urlValidator is the Validator implementation returned by NewValidator
+type urlValidator struct {
+	requireHTTPS    bool
+	allowedNetworks []*net.IPNet
+	allowedDomains  []string
+	deniedDomains   []string
+	challenge       bool
+	timeout         time.Duration
+	resolver        Resolver
+	client          xhttp.Client
+}
+
+// NewValidator creates a Validator which guards against webhooks that target internal infrastructure.
+// The webhook's URL, AlternativeURLs and FailureURL must each use an allowed scheme and domain, and must
+// resolve only to addresses outside the blocked ranges.  An error is returned if any AllowedNetworks
+// entry is not a valid CIDR block.
+func NewValidator(o *ValidatorOptions) (Validator, error) {
+	v := &urlValidator{
+		timeout:  o.timeout(),
+		resolver: o.resolver(),
+	}
+
+	if o != nil {
+		var err error
+		if v.allowedNetworks, err = parseNetworks(o.AllowedNetworks); err != nil {
+			return nil, err
+		}
+
+		v.requireHTTPS = o.RequireHTTPS
+		v.allowedDomains = normalizeDomains(o.AllowedDomains)
+		v.deniedDomains = normalizeDomains(o.DeniedDomains)
+		v.challenge = o.Challenge
+	}
+
+	v.client = o.client(v.allowedNetworks)
+	return v, nil
+}
+
+func invalid(format string, args ...interface{}) error {
+	return &xhttp.Error{Code: http.StatusBadRequest, Text: fmt.Sprintf(format, args...)}
+}
+
+func (v *urlValidator) Validate(ctx context.Context, w *W) error {
+	if err := v.validateURL(ctx, "url", w.Config.URL); err != nil {
+		return err
+	}
+
+	for _, alternative := range w.Config.AlternativeURLs {
+		if err := v.validateURL(ctx, "alt_url", alternative); err != nil {
+			return err
+		}
+	}
+
+	if len(w.FailureURL) > 0 {
+		if err := v.validateURL(ctx, "failure_url", w.FailureURL); err != nil {
+			return err
+		}
+	}
+
+	if v.challenge {
+		if err := v.verify(ctx, w.Config.URL); err != nil {
+			return err
+		}
+
+		for _, alternative := range w.Config.AlternativeURLs {
+			if err := v.verify(ctx, alternative); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func (v *urlValidator) validateURL(ctx context.Context, field, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return invalid("invalid %s: %s", field, err)
+	}
+
+	switch scheme := strings.ToLower(u.Scheme); {
+	case scheme == "https":
+	case scheme == "http" && !v.requireHTTPS:
+	default:
+		return invalid("invalid %s: scheme %s is not allowed", field, u.Scheme)
+	}
+
+	host := strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+	if len(host) == 0 {
+		return invalid("invalid %s: missing host", field)
+	}
+
+	if matchesDomain(v.deniedDomains, host) || (len(v.allowedDomains) > 0 && !matchesDomain(v.allowedDomains, host)) {
+		return invalid("invalid %s: host %s is not allowed", field, host)
+	}
+
+	var addresses []net.IP
+	if ip := net.ParseIP(host); ip != nil {
+		addresses = []net.IP{ip}
+	} else {
+		ctx, cancel := context.WithTimeout(ctx, v.timeout)
+		defer cancel()
+
+		resolved, err := v.resolver.LookupIPAddr(ctx, host)
+		if err != nil || len(resolved) == 0 {
+			return invalid("invalid %s: unable to resolve host %s", field, host)
+		}
+
+		for _, address := range resolved {
+			addresses = append(addresses, address.IP)
+		}
+	}
+
+	// every address must be permitted, otherwise a host could mix a public and a private address
+	for _, ip := range addresses {
+		if containsIP(blockedNetworks, ip) && !containsIP(v.allowedNetworks, ip) {
+			return invalid("invalid %s: host %s resolves to a restricted address", field, host)
+		}
+	}
+
+	return nil
+}
+
+// verify sends a challenge token to the given URL and checks that it is echoed back
+func (v *urlValidator) verify(ctx context.Context, raw string) error {
+	token := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, token); err != nil {
+		return err
+	}
+
+	challenge := hex.EncodeToString(token)
+	u, err := url.Parse(raw)
+	if err != nil {
+		return invalid("invalid url: %s", err)
+	}
+
+	query := u.Query()
+	query.Set(ChallengeParameter, challenge)
+	u.RawQuery = query.Encode()
+
+	ctx, cancel := context.WithTimeout(ctx, v.timeout)
+	defer cancel()
+
+	request, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return invalid("invalid url: %s", err)
+	}
+
+	response, err := v.client.Do(request.WithContext(ctx))
+	if err != nil {
+		return invalid("webhook verification failed for %s: %s", raw, err)
+	}
+
+	defer response.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, 1024))
+	if err != nil || response.StatusCode < 200 || response.StatusCode > 299 || strings.TrimSpace(string(body)) != challenge {
+		return invalid("webhook verification failed for %s: challenge not answered", raw)
+	}
+
+	return nil
+}
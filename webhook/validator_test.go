@@ -0,0 +1,233 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/jithin-kg/webpa-common/xhttp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeResolver resolves hosts from a fixed table
+type fakeResolver map[string][]string
+
+func (fr fakeResolver) LookupIPAddr(_ context.Context, host string) ([]net.IPAddr, error) {
+	addresses, ok := fr[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+
+	result := make([]net.IPAddr, len(addresses))
+	for i, a := range addresses {
+		result[i] = net.IPAddr{IP: net.ParseIP(a)}
+	}
+
+	return result, nil
+}
+
+var testResolver = fakeResolver{
+	"example.com":          {"93.184.216.34"},
+	"hooks.example.com":    {"93.184.216.35"},
+	"example.net":          {"93.184.216.36"},
+	"blocked.example.com":  {"93.184.216.37"},
+	"localhost":            {"127.0.0.1", "::1"},
+	"metadata.example.com": {"169.254.169.254"},
+	"mixed.example.com":    {"93.184.216.38", "10.0.0.1"},
+	"internal.example.com": {"10.1.2.3"},
+}
+
+func newTestValidatorW(url string) *W {
+	w := new(W)
+	w.Config.URL = url
+	w.Events = []string{".*"}
+	return w
+}
+
+func TestNewValidatorInvalidNetwork(t *testing.T) {
+	v, err := NewValidator(&ValidatorOptions{AllowedNetworks: []string{"not a cidr"}})
+	assert.Nil(t, v)
+	assert.Error(t, err)
+}
+
+func TestValidatorURL(t *testing.T) {
+	testData := []struct {
+		options  ValidatorOptions
+		url      string
+		expected bool
+	}{
+		{ValidatorOptions{}, "http://example.com/hook", true},
+		{ValidatorOptions{}, "https://example.com:8443/hook", true},
+		{ValidatorOptions{}, "ftp://example.com/hook", false},
+		{ValidatorOptions{}, "file:///etc/passwd", false},
+		{ValidatorOptions{}, "http:///hook", false},
+		{ValidatorOptions{}, "http://nosuch.example.com/hook", false},
+		{ValidatorOptions{RequireHTTPS: true}, "http://example.com/hook", false},
+		{ValidatorOptions{RequireHTTPS: true}, "HTTPS://example.com/hook", true},
+
+		// restricted addresses, whether literal or resolved
+		{ValidatorOptions{}, "http://localhost:8080/hook", false},
+		{ValidatorOptions{}, "http://127.0.0.1/hook", false},
+		{ValidatorOptions{}, "http://[::1]/hook", false},
+		{ValidatorOptions{}, "http://[::ffff:127.0.0.1]/hook", false},
+		{ValidatorOptions{}, "http://169.254.169.254/latest/meta-data/", false},
+		{ValidatorOptions{}, "http://metadata.example.com/", false},
+		{ValidatorOptions{}, "http://192.168.1.1/hook", false},
+		{ValidatorOptions{}, "http://172.20.0.1/hook", false},
+		{ValidatorOptions{}, "http://0.0.0.0/hook", false},
+		{ValidatorOptions{}, "http://[fd00::1]/hook", false},
+		{ValidatorOptions{}, "http://198.18.0.1/hook", false},
+		{ValidatorOptions{}, "http://[64:ff9b::a9fe:a9fe]/hook", false},
+		{ValidatorOptions{}, "http://mixed.example.com/hook", false},
+		{ValidatorOptions{}, "http://internal.example.com/hook", false},
+		{ValidatorOptions{AllowedNetworks: []string{"10.1.0.0/16"}}, "http://internal.example.com/hook", true},
+		{ValidatorOptions{AllowedNetworks: []string{"10.1.0.0/16"}}, "http://10.2.0.1/hook", false},
+
+		// domain lists
+		{ValidatorOptions{AllowedDomains: []string{"example.com"}}, "http://example.com/hook", true},
+		{ValidatorOptions{AllowedDomains: []string{"Example.COM."}}, "http://hooks.example.com/hook", true},
+		{ValidatorOptions{AllowedDomains: []string{"example.com"}}, "http://example.net/hook", false},
+		{ValidatorOptions{DeniedDomains: []string{"blocked.example.com"}}, "http://blocked.example.com/hook", false},
+		{ValidatorOptions{DeniedDomains: []string{"blocked.example.com"}}, "http://hooks.example.com/hook", true},
+		{ValidatorOptions{AllowedDomains: []string{"example.com"}, DeniedDomains: []string{"blocked.example.com"}}, "http://blocked.example.com/hook", false},
+	}
+
+	for i, record := range testData {
+		t.Run(fmt.Sprintf("%d", i), func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+			)
+
+			record.options.Resolver = testResolver
+			v, err := NewValidator(&record.options)
+			require.NoError(err)
+
+			err = v.Validate(context.Background(), newTestValidatorW(record.url))
+			if record.expected {
+				assert.NoError(err, record.url)
+			} else if assert.Error(err, record.url) {
+				assert.Equal(http.StatusBadRequest, err.(*xhttp.Error).StatusCode())
+			}
+		})
+	}
+}
+
+func TestDialControl(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		control = dialControl(mustParseCIDRs("10.1.0.0/16"))
+	)
+
+	assert.NoError(control("tcp", "93.184.216.34:80", nil))
+	assert.NoError(control("tcp", "10.1.2.3:80", nil))
+	assert.Error(control("tcp", "10.2.0.1:80", nil))
+	assert.Error(control("tcp", "127.0.0.1:80", nil))
+	assert.Error(control("tcp6", "[::ffff:169.254.169.254]:80", nil))
+	assert.Error(control("tcp6", "[64:ff9b::7f00:1]:80", nil))
+	assert.Error(control("tcp", "not an address", nil))
+}
+
+func TestValidatorOtherURLs(t *testing.T) {
+	v, err := NewValidator(&ValidatorOptions{Resolver: testResolver})
+	require.NoError(t, err)
+
+	t.Run("AlternativeURLs", func(t *testing.T) {
+		w := newTestValidatorW("http://example.com/hook")
+		w.Config.AlternativeURLs = []string{"http://hooks.example.com/hook", "http://169.254.169.254/"}
+
+		err := v.Validate(context.Background(), w)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "alt_url")
+		}
+	})
+
+	t.Run("FailureURL", func(t *testing.T) {
+		w := newTestValidatorW("http://example.com/hook")
+		w.FailureURL = "http://localhost/failure"
+
+		err := v.Validate(context.Background(), w)
+		if assert.Error(t, err) {
+			assert.Contains(t, err.Error(), "failure_url")
+		}
+	})
+}
+
+func TestValidatorChallenge(t *testing.T) {
+	var (
+		echo = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.Write([]byte(request.URL.Query().Get(ChallengeParameter)))
+		}))
+
+		ignore = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			response.Write([]byte("ok"))
+		}))
+
+		redirect = httptest.NewServer(http.RedirectHandler(echo.URL, http.StatusFound))
+	)
+
+	defer echo.Close()
+	defer ignore.Close()
+	defer redirect.Close()
+
+	// the test servers listen on loopback, so that range must be allowed
+	v, err := NewValidator(&ValidatorOptions{Challenge: true, AllowedNetworks: []string{"127.0.0.0/8"}})
+	require.NoError(t, err)
+
+	testData := []struct {
+		name         string
+		url          string
+		alternatives []string
+		expected     bool
+	}{
+		{"Answered", echo.URL + "/hook?existing=1", nil, true},
+		{"Ignored", ignore.URL, nil, false},
+		{"Redirected", redirect.URL, nil, false},
+		{"AlternativeIgnored", echo.URL, []string{ignore.URL}, false},
+		{"Unreachable", "http://127.0.0.1:1/hook", nil, false},
+	}
+
+	for _, record := range testData {
+		t.Run(record.name, func(t *testing.T) {
+			w := newTestValidatorW(record.url)
+			w.Config.AlternativeURLs = record.alternatives
+
+			err := v.Validate(context.Background(), w)
+			if record.expected {
+				assert.NoError(t, err)
+			} else if assert.Error(t, err) {
+				assert.True(t, strings.HasPrefix(err.Error(), "webhook verification failed"))
+			}
+		})
+	}
+}
+
+func TestRegistryValidation(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+		node    = newSyncNode(t, NewLoopbackNotifier())
+	)
+
+	defer node.server.Close()
+	v, err := NewValidator(&ValidatorOptions{Resolver: testResolver})
+	require.NoError(err)
+	node.factory.m.validator = v
+
+	for url, expected := range map[string]int{"http://169.254.169.254/": http.StatusBadRequest, "http://example.com/": http.StatusOK} {
+		response := httptest.NewRecorder()
+		request := httptest.NewRequest("POST", "/hook", strings.NewReader(fmt.Sprintf(`{"config": {"url": "%s"}, "events": [".*"]}`, url)))
+
+		node.registry.UpdateRegistry(response, request)
+		assert.Equal(expected, response.Code, url)
+	}
+
+	require.Equal(1, node.waitForHooks(1))
+	assert.Equal("http://example.com/", node.factory.m.list.Get(0).Config.URL)
+}
@@ -10,6 +10,10 @@ import (
 
 const (
 	DEFAULT_EXPIRATION_DURATION time.Duration = time.Second * 300
+
+	// DefaultSecretRotationGracePeriod is how long deliveries continue to be signed with a webhook's
+	// previous secret after it is renewed with a new secret
+	DefaultSecretRotationGracePeriod = 24 * time.Hour
 )
 
 // TODO Use below to validate the input
@@ -31,10 +35,22 @@ type W struct {
 		// The content-type to set the messages to (unless specified by WRP).
 		ContentType string `json:"content_type"`
 
-		// The secret to use for the SHA1 HMAC.
+		// The secret to use for the HMAC signature of each delivery.
 		// Optional, set to "" to disable behavior.
 		Secret string `json:"secret,omitempty"`
 
+		// The HMAC algorithm used to sign deliveries: sha1, sha256, or sha512.  sha256 and sha512
+		// also sign a timestamp and nonce, so that deliveries cannot be replayed.
+		// Optional, defaults to sha1 for compatibility.
+		SignatureAlgorithm string `json:"signature_algorithm,omitempty"`
+
+		// The secret replaced by the most recent rotation.  Until PreviousSecretUntil, deliveries are
+		// signed with both secrets.
+		PreviousSecret string `json:"previous_secret,omitempty"`
+
+		// The end of the grace period for PreviousSecret
+		PreviousSecretUntil time.Time `json:"previous_secret_until,omitempty"`
+
 		// alt_urls is a list of explicit URLs that should be round robin on faliure
 		AlternativeURLs []string `json:"alt_urls,omitempty"`
 	} `json:"config"`
@@ -47,10 +63,7 @@ type W struct {
 	Events []string `json:"events"`
 
 	// Matcher type contains values to match against the metadata.
-	Matcher struct {
-		// The list of regular expressions to match device id type against.
-		DeviceId []string `json:"device_id"`
-	} `json:"matcher,omitempty"`
+	Matcher Matcher `json:"matcher,omitempty"`
 
 	// The specified duration for this hook to live
 	Duration time.Duration `json:"duration"`
@@ -60,6 +73,16 @@ type W struct {
 
 	// The address that performed the registration
 	Address string `json:"registered_from_address"`
+
+	// Owner identifies who registered this webhook, for webhooks that are managed per owner as in mhook.
+	// Webhooks of different owners are distinct subscribers, even when they share a URL.
+	Owner string `json:"-"`
+}
+
+// hookKey identifies a subscriber, which is a webhook URL registered by a particular owner
+type hookKey struct {
+	owner string
+	url   string
 }
 
 func NewW(jsonString []byte, ip string) (w *W, err error) {
@@ -101,6 +124,18 @@ func (w *W) sanitize(ip string) (err error) {
 		w.Matcher.DeviceId = []string{".*"} // match anything
 	}
 
+	if _, err = w.Compile(); err != nil {
+		return
+	}
+
+	// only a rotation, when a registration is renewed with a new secret, can set the previous secret
+	w.Config.PreviousSecret = ""
+	w.Config.PreviousSecretUntil = time.Time{}
+
+	if err = ValidateSignatureAlgorithm(w.Config.SignatureAlgorithm); err != nil {
+		return
+	}
+
 	if "" == w.Address && "" != ip {
 		// Record the IP address the request came from
 		host, _, _err := net.SplitHostPort(ip)
@@ -126,6 +161,27 @@ func (w *W) ID() string {
 	return w.Config.URL
 }
 
+// key returns the subscriber key of this webhook
+func (w *W) key() hookKey {
+	return hookKey{owner: w.Owner, url: w.ID()}
+}
+
+// rotate sets this webhook's previous secret from the registration it replaces.  A renewal with the same
+// secret keeps any rotation in progress, while a renewal with a new secret begins a grace period for the old one.
+func (w *W) rotate(existing W, now time.Time) {
+	switch {
+	case w.Config.Secret == existing.Config.Secret:
+		w.Config.PreviousSecret = existing.Config.PreviousSecret
+		w.Config.PreviousSecretUntil = existing.Config.PreviousSecretUntil
+	case len(existing.Config.Secret) > 0:
+		w.Config.PreviousSecret = existing.Config.Secret
+		w.Config.PreviousSecretUntil = now.Add(DefaultSecretRotationGracePeriod)
+	default:
+		w.Config.PreviousSecret = ""
+		w.Config.PreviousSecretUntil = time.Time{}
+	}
+}
+
 // List is a read-only random access interface to a set of W's
 // We don't necessarily need an implementation of just this interface alone.
 type List interface {
@@ -133,6 +189,36 @@ type List interface {
 	Get(int) *W
 }
 
+// Snapshotter is implemented by Lists that can return all of their webhooks atomically.
+// The returned slice is shared and must not be modified.
+type Snapshotter interface {
+	Snapshot() []W
+}
+
+// Snapshot returns the webhooks currently in a List.  Lists that implement Snapshotter are read
+// atomically, so the result is consistent even while the list is being updated.  Other lists are
+// read element by element.
+func Snapshot(l List) []*W {
+	if s, ok := l.(Snapshotter); ok {
+		list := s.Snapshot()
+		items := make([]*W, len(list))
+		for i := range list {
+			items[i] = &list[i]
+		}
+
+		return items
+	}
+
+	var items []*W
+	for i, n := 0, l.Len(); i < n; i++ {
+		if w := l.Get(i); w != nil {
+			items = append(items, w)
+		}
+	}
+
+	return items
+}
+
 // UpdatableList is mutable list that can be updated en masse
 type UpdatableList interface {
 	List
@@ -171,42 +257,51 @@ func (ul *updatableList) Get(index int) *W {
 	return nil
 }
 
+func (ul *updatableList) Snapshot() []W {
+	list, _ := ul.value.Load().([]W)
+	return list
+}
+
+// Update adds webhooks to this list, replacing any existing webhook with the same owner and URL.
+// When a replacement has a new secret, the old secret becomes the previous secret until
+// DefaultSecretRotationGracePeriod has elapsed.  Webhooks that have already expired are ignored.
 func (ul *updatableList) Update(newItems []W) {
-	for _, newItem := range newItems {
-		found := false
-		var items []*W
-		for i := 0; i < ul.Len(); i++ {
-			items = append(items, ul.Get(i))
-		}
+	var (
+		now      = time.Now()
+		list, _  = ul.value.Load().([]W)
+		items    = append(make([]W, 0, len(list)+len(newItems)), list...)
+		modified = false
+	)
 
+	for _, newItem := range newItems {
 		// we want to add items that will expire in the future
-		if newItem.Until.After(time.Now()) {
-			for i := 0; i < len(items) && !found; i++ {
-				if items[i].ID() == newItem.ID() {
-					found = true
-
-					items[i].Matcher = newItem.Matcher
-					items[i].Events = newItem.Events
-					items[i].Config.ContentType = newItem.Config.ContentType
-					items[i].Config.Secret = newItem.Config.Secret
-					items[i].Until = newItem.Until
-				}
-			}
-
-			// add item
-			if !found {
-				items = append(items, &newItem)
-			}
+		if !newItem.Until.After(now) {
+			continue
+		}
 
-			var itemsCopy []W
-			for _, i := range items {
-				itemsCopy = append(itemsCopy, *i)
+		modified = true
+		found := false
+		for i := 0; i < len(items) && !found; i++ {
+			if items[i].key() == newItem.key() {
+				found = true
+
+				// a renewal replaces the entire registration, including its secret and URLs, while the
+				// secret it replaces remains active for a grace period
+				newItem.rotate(items[i], now)
+				items[i] = newItem
 			}
+		}
 
-			// store items
-			ul.set(itemsCopy)
+		// add item
+		if !found {
+			items = append(items, newItem)
 		}
 	}
+
+	if modified {
+		// store items
+		ul.set(items)
+	}
 }
 
 func (ul *updatableList) Filter(filter func([]W) []W) {
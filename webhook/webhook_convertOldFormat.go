@@ -54,7 +54,7 @@ func oldToNewHookConversion(old *oldW) (w *W, err error) {
 	w.Config.ContentType = old.Config.ContentType
 	w.Config.Secret = old.Config.Secret
 	w.Events = old.Events
-	w.Matcher = old.Matcher
+	w.Matcher.DeviceId = old.Matcher.DeviceId
 	w.Address = old.Address
 
 	if old.Duration <= 0 || old.Duration > 300000 {
@@ -52,6 +52,16 @@ func WithGauge(gauge xmetrics.Setter) GateOption {
 	}
 }
 
+// WithListeners configures functions that are invoked each time the state of a gate changes, with true when
+// the gate is raised and false when it is lowered.  Listeners are invoked synchronously, after the change, by the
+// goroutine that changed the state.  They are not invoked for the initial state.  Concurrent changes are
+// serialized, so listeners see transitions in order; a listener must not itself raise or lower the gate.
+func WithListeners(listeners ...func(bool)) GateOption {
+	return func(g *gate) {
+		g.listeners = append(g.listeners, listeners...)
+	}
+}
+
 // New constructs a gate Interface with zero or more options.  The returned gate takes on the given
 // initial state, and any configured gauge is updated to reflect this initial state.
 func New(initial bool, options ...GateOption) Interface {
@@ -77,39 +87,60 @@ func New(initial bool, options ...GateOption) Interface {
 
 // gate is the internal Interface implementation
 type gate struct {
+	// dispatchLock serializes state changes together with their listener calls, so that
+	// listeners observe transitions in the order they happened
+	dispatchLock sync.Mutex
+
 	lock      sync.RWMutex
 	open      bool
 	timestamp time.Time
 	now       func() time.Time
 
-	state xmetrics.Setter
+	state     xmetrics.Setter
+	listeners []func(bool)
+}
+
+func (g *gate) dispatch(open bool) {
+	for _, l := range g.listeners {
+		l(open)
+	}
 }
 
 func (g *gate) Raise() bool {
-	defer g.lock.Unlock()
-	g.lock.Lock()
+	g.dispatchLock.Lock()
+	defer g.dispatchLock.Unlock()
 
+	g.lock.Lock()
 	if g.open {
+		g.lock.Unlock()
 		return false
 	}
 
 	g.open = true
 	g.state.Set(Open)
 	g.timestamp = g.now().UTC()
+	g.lock.Unlock()
+
+	g.dispatch(true)
 	return true
 }
 
 func (g *gate) Lower() bool {
-	defer g.lock.Unlock()
-	g.lock.Lock()
+	g.dispatchLock.Lock()
+	defer g.dispatchLock.Unlock()
 
+	g.lock.Lock()
 	if !g.open {
+		g.lock.Unlock()
 		return false
 	}
 
 	g.open = false
 	g.state.Set(Closed)
 	g.timestamp = g.now().UTC()
+	g.lock.Unlock()
+
+	g.dispatch(false)
 	return true
 }
 
@@ -1,6 +1,7 @@
 package gate
 
 import (
+	"sync"
 	"testing"
 	"time"
 
@@ -165,8 +166,79 @@ func testNewInitiallyClosedWithGauge(t *testing.T) {
 	assert.Equal(Closed, gauge.Value())
 }
 
+func testNewWithListeners(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		first  []bool
+		second []bool
+
+		g = New(
+			true,
+			WithListeners(func(open bool) { first = append(first, open) }),
+			WithListeners(func(open bool) {
+				second = append(second, open)
+			}),
+		)
+	)
+
+	// the initial state is not dispatched
+	assert.Empty(first)
+
+	assert.True(g.Lower())
+	assert.False(g.Lower())
+	assert.True(g.Raise())
+	assert.False(g.Raise())
+
+	assert.Equal([]bool{false, true}, first)
+	assert.Equal([]bool{false, true}, second)
+}
+
+func testNewWithListenersConcurrent(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		transitions []bool
+		g           Interface
+		wg          sync.WaitGroup
+	)
+
+	g = New(
+		true,
+		WithListeners(func(open bool) {
+			assert.Equal(open, g.Open())
+			transitions = append(transitions, open)
+		}),
+	)
+
+	for i := 0; i < 10; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				g.Lower()
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 100; j++ {
+				g.Raise()
+			}
+		}()
+	}
+
+	wg.Wait()
+	for i, open := range transitions {
+		// transitions must alternate, starting from the initially open state
+		assert.Equal(i%2 == 1, open)
+	}
+}
+
 func TestNew(t *testing.T) {
 	t.Run("String", testNewString)
+	t.Run("WithListeners", testNewWithListeners)
+	t.Run("WithListenersConcurrent", testNewWithListenersConcurrent)
 
 	t.Run("InitiallyOpen", func(t *testing.T) {
 		testNewInitiallyOpen(t, New(true))
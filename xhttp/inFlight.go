@@ -0,0 +1,67 @@
+package xhttp
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// InFlight counts the HTTP transactions in progress through decorated handlers, so that a server can
+// wait for them to finish, e.g. before deregistering from service discovery.  The zero value is ready to use.
+type InFlight struct {
+	lock  sync.Mutex
+	count int
+	idle  chan struct{}
+}
+
+// Then is an Alice-style constructor that decorates a handler so that its transactions are counted
+func (f *InFlight) Then(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+		f.add(1)
+		defer f.add(-1)
+		next.ServeHTTP(response, request)
+	})
+}
+
+func (f *InFlight) add(delta int) {
+	f.lock.Lock()
+	f.count += delta
+	if f.count == 0 && f.idle != nil {
+		close(f.idle)
+		f.idle = nil
+	}
+
+	f.lock.Unlock()
+}
+
+// Count returns the number of transactions in progress
+func (f *InFlight) Count() int {
+	f.lock.Lock()
+	count := f.count
+	f.lock.Unlock()
+	return count
+}
+
+// Wait blocks until there are no transactions in progress or the context is canceled, in which
+// case the context's error is returned.  Transactions may begin again after this method returns.
+func (f *InFlight) Wait(ctx context.Context) error {
+	f.lock.Lock()
+	if f.count == 0 {
+		f.lock.Unlock()
+		return nil
+	}
+
+	if f.idle == nil {
+		f.idle = make(chan struct{})
+	}
+
+	idle := f.idle
+	f.lock.Unlock()
+
+	select {
+	case <-idle:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
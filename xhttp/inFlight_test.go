@@ -0,0 +1,59 @@
+package xhttp
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInFlight(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		f       InFlight
+		entered = make(chan struct{})
+		release = make(chan struct{})
+		done    = make(chan struct{})
+
+		handler = f.Then(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+			entered <- struct{}{}
+			<-release
+		}))
+	)
+
+	assert.Zero(f.Count())
+	assert.NoError(f.Wait(context.Background()))
+
+	go func() {
+		defer close(done)
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	}()
+
+	<-entered
+	assert.Equal(1, f.Count())
+
+	// waiting is bounded by the context
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	assert.Equal(context.DeadlineExceeded, f.Wait(ctx))
+
+	waited := make(chan error, 1)
+	go func() {
+		waited <- f.Wait(context.Background())
+	}()
+
+	close(release)
+	select {
+	case err := <-waited:
+		assert.NoError(err)
+	case <-time.After(5 * time.Second):
+		assert.Fail("Wait did not return when the transaction finished")
+	}
+
+	<-done
+	assert.Zero(f.Count())
+}
@@ -0,0 +1,93 @@
+package xresolver
+
+import (
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Stats exposes the state of dialed routes to a Balancer
+type Stats interface {
+	// ActiveConnections returns the number of connections dialed to a route that are still open
+	ActiveConnections(Route) int
+}
+
+// Balancer determines the order in which a resolver tries the routes returned by its lookups
+type Balancer interface {
+	// Order returns the given routes in the order they should be dialed.  The given slice must not be modified.
+	Order(routes []Route, stats Stats) []Route
+}
+
+// BalancerFunc is a function type that implements Balancer
+type BalancerFunc func([]Route, Stats) []Route
+
+func (bf BalancerFunc) Order(routes []Route, stats Stats) []Route {
+	return bf(routes, stats)
+}
+
+// NewLeastConnectionsBalancer produces a Balancer which tries the routes with the fewest open connections
+// first.  Routes with the same number of connections keep their lookup order.
+func NewLeastConnectionsBalancer() Balancer {
+	return BalancerFunc(func(routes []Route, stats Stats) []Route {
+		ordered := append([]Route{}, routes...)
+		sort.SliceStable(ordered, func(i, j int) bool {
+			return stats.ActiveConnections(ordered[i]) < stats.ActiveConnections(ordered[j])
+		})
+
+		return ordered
+	})
+}
+
+func weightOf(r Route) float64 {
+	if r.Weight > 0 {
+		return float64(r.Weight)
+	}
+
+	return 1.0
+}
+
+// weightedRandom is a Balancer that samples routes, without replacement, in proportion to their weights
+type weightedRandom struct {
+	lock sync.Mutex
+	r    *rand.Rand
+}
+
+// NewWeightedRandomBalancer produces a Balancer which randomly orders routes so that each route is
+// tried first in proportion to its Weight.  If r is nil, a source seeded with the current time is used.
+func NewWeightedRandomBalancer(r *rand.Rand) Balancer {
+	if r == nil {
+		r = rand.New(rand.NewSource(time.Now().UnixNano()))
+	}
+
+	return &weightedRandom{r: r}
+}
+
+func (wr *weightedRandom) Order(routes []Route, _ Stats) []Route {
+	// each route gets a random key of u^(1/weight), and sorting by key in descending order
+	// produces a weighted sample without replacement
+	keys := make([]float64, len(routes))
+	wr.lock.Lock()
+	for i, r := range routes {
+		keys[i] = math.Pow(wr.r.Float64(), 1.0/weightOf(r))
+	}
+
+	wr.lock.Unlock()
+
+	indices := make([]int, len(routes))
+	for i := range indices {
+		indices[i] = i
+	}
+
+	sort.SliceStable(indices, func(i, j int) bool {
+		return keys[indices[i]] > keys[indices[j]]
+	})
+
+	ordered := make([]Route, len(routes))
+	for i, index := range indices {
+		ordered[i] = routes[index]
+	}
+
+	return ordered
+}
@@ -0,0 +1,57 @@
+package xresolver
+
+import (
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testStats map[string]int
+
+func (ts testStats) ActiveConnections(r Route) int {
+	return ts[r.String()]
+}
+
+func TestNewLeastConnectionsBalancer(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		a      = Route{Scheme: "http", Host: "a.com"}
+		b      = Route{Scheme: "http", Host: "b.com"}
+		c      = Route{Scheme: "http", Host: "c.com"}
+		routes = []Route{a, b, c}
+
+		balancer = NewLeastConnectionsBalancer()
+	)
+
+	assert.Equal([]Route{b, c, a}, balancer.Order(routes, testStats{a.String(): 2, b.String(): 0, c.String(): 1}))
+	assert.Equal([]Route{a, c, b}, balancer.Order(routes, testStats{b.String(): 1}))
+	assert.Equal([]Route{a, b, c}, routes, "the given routes should not be modified")
+}
+
+func TestNewWeightedRandomBalancer(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		light  = Route{Scheme: "http", Host: "light.com", Weight: 1}
+		heavy  = Route{Scheme: "http", Host: "heavy.com", Weight: 9}
+		routes = []Route{light, heavy}
+
+		balancer = NewWeightedRandomBalancer(rand.New(rand.NewSource(1234)))
+		first    = make(map[Route]int)
+	)
+
+	for i := 0; i < 1000; i++ {
+		ordered := balancer.Order(routes, nil)
+		assert.ElementsMatch(routes, ordered)
+		first[ordered[0]]++
+	}
+
+	assert.True(first[heavy] > 800, "the heavy route should be first about 90% of the time, was %d", first[heavy])
+	assert.True(first[light] > 50, "the light route should be first about 10% of the time, was %d", first[light])
+	assert.Equal([]Route{light, heavy}, routes, "the given routes should not be modified")
+
+	// a nil source should still produce a usable balancer
+	assert.Len(NewWeightedRandomBalancer(nil).Order(routes, nil), 2)
+}
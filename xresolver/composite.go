@@ -0,0 +1,76 @@
+package xresolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+var errNoLookups = errors.New("no lookups configured")
+
+// lookupAll invokes each lookup in order, returning the routes and errors of every one.  When stop
+// is supplied, lookups cease as soon as it returns true for the routes gathered so far.
+func lookupAll(ctx context.Context, host string, lookups []Lookup, stop func([]Route) bool) ([]Route, error) {
+	var (
+		routes  []Route
+		lastErr = errNoLookups
+		seen    = make(map[string]bool)
+	)
+
+	for _, l := range lookups {
+		found, err := l.LookupRoutes(ctx, host)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		for _, r := range found {
+			if !seen[r.String()] {
+				seen[r.String()] = true
+				routes = append(routes, r)
+			}
+		}
+
+		if stop != nil && stop(routes) {
+			break
+		}
+	}
+
+	if len(routes) > 0 {
+		return routes, nil
+	}
+
+	return []Route{}, fmt.Errorf("no routes found for %s: %w", host, lastErr)
+}
+
+// NewPriorityLookup produces a Lookup which consults the given lookups in order of precedence, using the
+// routes of the first lookup that returns any.  Later lookups act as fallbacks, e.g. a static host map
+// behind consul.
+func NewPriorityLookup(lookups ...Lookup) Lookup {
+	return &priorityLookup{lookups: append([]Lookup{}, lookups...)}
+}
+
+// priorityLookup is a pointer type, so that it can be added to and removed from a resolver like any other Lookup
+type priorityLookup struct {
+	lookups []Lookup
+}
+
+func (pl *priorityLookup) LookupRoutes(ctx context.Context, host string) ([]Route, error) {
+	return lookupAll(ctx, host, pl.lookups, func(routes []Route) bool { return len(routes) > 0 })
+}
+
+// NewMergedLookup produces a Lookup which combines the routes of all the given lookups.  Routes appear in the
+// order of the lookups that returned them, and a route returned by more than one lookup appears only once,
+// in the position of the first.  An error is returned only when no lookup produces a route.
+func NewMergedLookup(lookups ...Lookup) Lookup {
+	return &mergedLookup{lookups: append([]Lookup{}, lookups...)}
+}
+
+// mergedLookup is a pointer type, so that it can be added to and removed from a resolver like any other Lookup
+type mergedLookup struct {
+	lookups []Lookup
+}
+
+func (ml *mergedLookup) LookupRoutes(ctx context.Context, host string) ([]Route, error) {
+	return lookupAll(ctx, host, ml.lookups, nil)
+}
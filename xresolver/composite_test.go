@@ -0,0 +1,165 @@
+package xresolver
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewPriorityLookup(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		routeA = Route{Scheme: "http", Host: "a.com", Port: 8080}
+		routeB = Route{Scheme: "http", Host: "b.com", Port: 8080}
+
+		failing  = new(mockLookUp)
+		primary  = new(mockLookUp)
+		fallback = new(mockLookUp)
+	)
+
+	failing.On("LookupRoutes", mock.Anything, "host.com").Return([]Route{}, errors.New("expected"))
+	failing.On("LookupRoutes", mock.Anything, "other.com").Return([]Route{}, errors.New("expected"))
+	primary.On("LookupRoutes", mock.Anything, "host.com").Return([]Route{routeA}, nil)
+	primary.On("LookupRoutes", mock.Anything, "other.com").Return([]Route{}, nil)
+	fallback.On("LookupRoutes", mock.Anything, "other.com").Return([]Route{routeB}, nil)
+
+	l := NewPriorityLookup(failing, primary, fallback)
+
+	routes, err := l.LookupRoutes(context.Background(), "host.com")
+	assert.NoError(err)
+	assert.Equal([]Route{routeA}, routes)
+
+	routes, err = l.LookupRoutes(context.Background(), "other.com")
+	assert.NoError(err)
+	assert.Equal([]Route{routeB}, routes)
+
+	failing.AssertExpectations(t)
+	primary.AssertExpectations(t)
+	fallback.AssertExpectations(t)
+}
+
+func TestNewMergedLookup(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		routeA = Route{Scheme: "http", Host: "a.com", Port: 8080}
+		routeB = Route{Scheme: "http", Host: "b.com", Port: 8080}
+		routeC = Route{Scheme: "http", Host: "c.com", Port: 8080}
+
+		expectedErr = errors.New("expected")
+		first       = new(mockLookUp)
+		second      = new(mockLookUp)
+		failing     = new(mockLookUp)
+	)
+
+	first.On("LookupRoutes", mock.Anything, "host.com").Return([]Route{routeB, routeA}, nil)
+	second.On("LookupRoutes", mock.Anything, "host.com").Return([]Route{routeA, routeC}, nil)
+	failing.On("LookupRoutes", mock.Anything, "host.com").Return([]Route{}, expectedErr)
+
+	routes, err := NewMergedLookup(first, failing, second).LookupRoutes(context.Background(), "host.com")
+	assert.NoError(err)
+	assert.Equal([]Route{routeB, routeA, routeC}, routes)
+
+	routes, err = NewMergedLookup(failing).LookupRoutes(context.Background(), "host.com")
+	assert.Empty(routes)
+	assert.True(errors.Is(err, expectedErr))
+
+	routes, err = NewMergedLookup().LookupRoutes(context.Background(), "host.com")
+	assert.Empty(routes)
+	assert.True(errors.Is(err, errNoLookups))
+
+	first.AssertExpectations(t)
+	second.AssertExpectations(t)
+	failing.AssertExpectations(t)
+}
+
+func TestResolverLookupOrder(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		lookups []*mockLookUp
+		r       = NewResolver(DefaultDialer).(*resolver)
+	)
+
+	for i := 0; i < 10; i++ {
+		l := new(mockLookUp)
+		l.On("LookupRoutes", mock.Anything, "host.com").Return([]Route{{Scheme: "http", Host: "host.com", Port: 8000 + i}}, nil)
+		lookups = append(lookups, l)
+		assert.NoError(r.Add(l))
+	}
+
+	assert.Error(r.Add(lookups[0]))
+	assert.NoError(r.Remove(lookups[4]))
+	assert.Error(r.Remove(lookups[4]))
+
+	routes := r.getRoutes(context.Background(), "host.com")
+	ports := make([]int, 0, len(routes))
+	for _, route := range routes {
+		ports = append(ports, route.Port)
+	}
+
+	assert.Equal([]int{8000, 8001, 8002, 8003, 8005, 8006, 8007, 8008, 8009}, ports)
+}
+
+func TestResolverWithComposites(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, _ *http.Request) {
+		fmt.Fprint(response, "composite")
+	}))
+
+	defer server.Close()
+
+	route, err := CreateRoute(server.URL)
+	require.NoError(t, err)
+
+	composites := map[string]func(...Lookup) Lookup{
+		"Priority": NewPriorityLookup,
+		"Merged":   NewMergedLookup,
+	}
+
+	for name, factory := range composites {
+		t.Run(name, func(t *testing.T) {
+			var (
+				assert  = assert.New(t)
+				require = require.New(t)
+
+				failing = new(mockLookUp)
+				working = new(mockLookUp)
+
+				composite = factory(failing, working)
+				r         = NewResolver(DefaultDialer)
+				client    = &http.Client{
+					Transport: &http.Transport{
+						DialContext:       r.DialContext,
+						DisableKeepAlives: true,
+					},
+				}
+			)
+
+			failing.On("LookupRoutes", mock.Anything, "composite.host.com").Return([]Route{}, errors.New("expected"))
+			working.On("LookupRoutes", mock.Anything, "composite.host.com").Return([]Route{route}, nil)
+
+			require.NoError(r.Add(composite))
+			assert.Error(r.Add(composite))
+
+			response, err := client.Get("http://composite.host.com:8080/")
+			require.NoError(err)
+			body, err := ioutil.ReadAll(response.Body)
+			response.Body.Close()
+			assert.NoError(err)
+			assert.Equal("composite", string(body))
+
+			assert.NoError(r.Remove(composite))
+			failing.AssertExpectations(t)
+			working.AssertExpectations(t)
+		})
+	}
+}
@@ -71,6 +71,7 @@ func (watcher *ConsulWatcher) MonitorEvent(e monitor.Event) {
 				logging.Error(watcher.logger, logging.MessageKey(), "failed to create route", logging.MessageKey(), err, "instance", instance)
 				continue
 			}
+			route.Weight = e.Metadata[instance].Weight
 			routes[index] = route
 		}
 		rr.Update(routes)
@@ -0,0 +1,263 @@
+package dns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/xresolver"
+	mdns "github.com/miekg/dns"
+)
+
+const (
+	DefaultScheme     = "http"
+	DefaultResolvConf = "/etc/resolv.conf"
+	DefaultMinTTL     = time.Second
+	DefaultMaxTTL     = 5 * time.Minute
+	DefaultTimeout    = 5 * time.Second
+)
+
+var errNoServer = errors.New("No DNS server configured")
+
+// Options configures a DNS SRV Lookup
+type Options struct {
+	// Watch maps the hosts that are dialed to the SRV names that supply their routes,
+	// e.g. { "caduceus.example.com" : "_http._tcp.caduceus.example.com" }
+	Watch map[string]string `json:"watch"`
+
+	// Server is the host:port of the DNS server to query.  If not supplied, the first nameserver
+	// in DefaultResolvConf is used.
+	Server string `json:"server,omitempty"`
+
+	// Scheme is the scheme of the routes.  If not supplied, DefaultScheme is used.
+	Scheme string `json:"scheme,omitempty"`
+
+	// MinTTL and MaxTTL bound how long the answer for a name is cached, regardless of the TTL of its records.
+	// If not supplied, DefaultMinTTL and DefaultMaxTTL are used.
+	MinTTL time.Duration `json:"minTTL,omitempty"`
+	MaxTTL time.Duration `json:"maxTTL,omitempty"`
+
+	// Timeout is the longest a single query may take.  If not supplied, DefaultTimeout is used.
+	Timeout time.Duration `json:"timeout,omitempty"`
+
+	Logger log.Logger `json:"-"`
+}
+
+func (o *Options) scheme() string {
+	if o != nil && len(o.Scheme) > 0 {
+		return o.Scheme
+	}
+
+	return DefaultScheme
+}
+
+func (o *Options) minTTL() time.Duration {
+	if o != nil && o.MinTTL > 0 {
+		return o.MinTTL
+	}
+
+	return DefaultMinTTL
+}
+
+func (o *Options) maxTTL() time.Duration {
+	if o != nil && o.MaxTTL > 0 {
+		return o.MaxTTL
+	}
+
+	return DefaultMaxTTL
+}
+
+func (o *Options) timeout() time.Duration {
+	if o != nil && o.Timeout > 0 {
+		return o.Timeout
+	}
+
+	return DefaultTimeout
+}
+
+// exchanger is the strategy for sending DNS queries.  *mdns.Client implements this interface.
+type exchanger interface {
+	ExchangeContext(context.Context, *mdns.Msg, string) (*mdns.Msg, time.Duration, error)
+}
+
+func defaultServer() (string, error) {
+	config, err := mdns.ClientConfigFromFile(DefaultResolvConf)
+	if err != nil {
+		return "", err
+	}
+
+	if len(config.Servers) == 0 {
+		return "", errNoServer
+	}
+
+	return net.JoinHostPort(config.Servers[0], config.Port), nil
+}
+
+// serverFactory is the factory for the default DNS server, which can be replaced for testing
+var serverFactory = defaultServer
+
+type cacheEntry struct {
+	routes  []xresolver.Route
+	expires time.Time
+}
+
+// Lookup is an xresolver.Lookup that obtains routes from DNS SRV records.  Answers are cached for the
+// smallest TTL of their records, bounded by Options.MinTTL and Options.MaxTTL.  Queries are sent over
+// UDP, and truncated answers are retried over TCP.
+type Lookup struct {
+	logger    log.Logger
+	watch     map[string]string
+	server    string
+	scheme    string
+	minTTL    time.Duration
+	maxTTL    time.Duration
+	timeout   time.Duration
+	client    exchanger
+	tcpClient exchanger
+	now       func() time.Time
+
+	lock  sync.Mutex
+	cache map[string]cacheEntry
+}
+
+var _ xresolver.Lookup = (*Lookup)(nil)
+
+// NewLookup creates a DNS SRV Lookup from the given options
+func NewLookup(o Options) (*Lookup, error) {
+	if o.Logger == nil {
+		o.Logger = logging.DefaultLogger()
+	}
+
+	server := o.Server
+	if len(server) == 0 {
+		var err error
+		if server, err = serverFactory(); err != nil {
+			return nil, err
+		}
+	}
+
+	watch := make(map[string]string, len(o.Watch))
+	for host, name := range o.Watch {
+		watch[host] = mdns.Fqdn(name)
+	}
+
+	return &Lookup{
+		logger:    o.Logger,
+		watch:     watch,
+		server:    server,
+		scheme:    o.scheme(),
+		minTTL:    o.minTTL(),
+		maxTTL:    o.maxTTL(),
+		timeout:   o.timeout(),
+		client:    new(mdns.Client),
+		tcpClient: &mdns.Client{Net: "tcp"},
+		now:       time.Now,
+		cache:     make(map[string]cacheEntry),
+	}, nil
+}
+
+// LookupRoutes returns the routes from the SRV records of the name watched for the given host
+func (l *Lookup) LookupRoutes(ctx context.Context, host string) ([]xresolver.Route, error) {
+	name, ok := l.watch[host]
+	if !ok {
+		return []xresolver.Route{}, errors.New(host + " is not part of the dns lookup")
+	}
+
+	now := l.now()
+	l.lock.Lock()
+	entry, ok := l.cache[name]
+	l.lock.Unlock()
+	if ok && now.Before(entry.expires) {
+		// callers own the returned slice, so the cached routes are copied
+		return append([]xresolver.Route(nil), entry.routes...), nil
+	}
+
+	routes, ttl, err := l.query(ctx, name)
+	if err != nil {
+		logging.Error(l.logger, logging.MessageKey(), "SRV lookup failed", "name", name, logging.ErrorKey(), err)
+		return []xresolver.Route{}, err
+	}
+
+	if ttl < l.minTTL {
+		ttl = l.minTTL
+	} else if ttl > l.maxTTL {
+		ttl = l.maxTTL
+	}
+
+	l.lock.Lock()
+	l.cache[name] = cacheEntry{routes: routes, expires: now.Add(ttl)}
+	l.lock.Unlock()
+
+	logging.Debug(l.logger, logging.MessageKey(), "SRV lookup", "name", name, "routes", routes, "ttl", ttl)
+	return append([]xresolver.Route(nil), routes...), nil
+}
+
+// query sends a single SRV query, returning the routes from the records with the lowest priority,
+// as required by RFC 2782, together with the smallest TTL of all the records.  A truncated UDP answer
+// is discarded and the query is repeated over TCP.
+func (l *Lookup) query(ctx context.Context, name string) ([]xresolver.Route, time.Duration, error) {
+	ctx, cancel := context.WithTimeout(ctx, l.timeout)
+	defer cancel()
+
+	request := new(mdns.Msg)
+	request.SetQuestion(name, mdns.TypeSRV)
+	response, _, err := l.client.ExchangeContext(ctx, request, l.server)
+	if err == nil && response.Truncated {
+		response, _, err = l.tcpClient.ExchangeContext(ctx, request, l.server)
+	}
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if response.Rcode != mdns.RcodeSuccess {
+		return nil, 0, fmt.Errorf("SRV lookup of %s failed: %s", name, mdns.RcodeToString[response.Rcode])
+	}
+
+	var (
+		routes   []xresolver.Route
+		priority uint16
+		ttl      time.Duration
+		found    bool
+	)
+
+	for _, answer := range response.Answer {
+		srv, ok := answer.(*mdns.SRV)
+		if !ok {
+			continue
+		}
+
+		if recordTTL := time.Duration(srv.Hdr.Ttl) * time.Second; !found || recordTTL < ttl {
+			ttl = recordTTL
+		}
+
+		if found && srv.Priority > priority {
+			continue
+		}
+
+		if !found || srv.Priority < priority {
+			priority = srv.Priority
+			routes = routes[:0]
+		}
+
+		found = true
+		routes = append(routes, xresolver.Route{
+			Scheme: l.scheme,
+			Host:   strings.TrimSuffix(srv.Target, "."),
+			Port:   int(srv.Port),
+			Weight: int(srv.Weight),
+		})
+	}
+
+	if len(routes) == 0 {
+		return nil, 0, fmt.Errorf("no SRV records found for %s", name)
+	}
+
+	return routes, ttl, nil
+}
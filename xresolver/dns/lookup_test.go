@@ -0,0 +1,319 @@
+package dns
+
+import (
+	"context"
+	"net"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/xresolver"
+	mdns "github.com/miekg/dns"
+)
+
+// startServer runs a DNS server on a loopback UDP port, returning its address and a count of the queries it answered
+func startServer(t *testing.T, handler mdns.HandlerFunc) (string, *int32, func()) {
+	packetConn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	var (
+		queries int32
+		started = make(chan struct{})
+		server  = &mdns.Server{
+			PacketConn:        packetConn,
+			NotifyStartedFunc: func() { close(started) },
+			Handler: mdns.HandlerFunc(func(w mdns.ResponseWriter, r *mdns.Msg) {
+				atomic.AddInt32(&queries, 1)
+				handler(w, r)
+			}),
+		}
+	)
+
+	go server.ActivateAndServe()
+	<-started
+	return packetConn.LocalAddr().String(), &queries, func() { server.Shutdown() }
+}
+
+func newSRV(name string, ttl uint32, priority, weight, port uint16, target string) mdns.RR {
+	return &mdns.SRV{
+		Hdr:      mdns.RR_Header{Name: name, Rrtype: mdns.TypeSRV, Class: mdns.ClassINET, Ttl: ttl},
+		Priority: priority,
+		Weight:   weight,
+		Port:     port,
+		Target:   target,
+	}
+}
+
+// exchangerFunc is a function type that implements exchanger
+type exchangerFunc func(context.Context, *mdns.Msg, string) (*mdns.Msg, time.Duration, error)
+
+func (ef exchangerFunc) ExchangeContext(ctx context.Context, m *mdns.Msg, server string) (*mdns.Msg, time.Duration, error) {
+	return ef(ctx, m, server)
+}
+
+func testOptionsDefault(t *testing.T, o *Options) {
+	assert := assert.New(t)
+
+	assert.Equal(DefaultScheme, o.scheme())
+	assert.Equal(DefaultMinTTL, o.minTTL())
+	assert.Equal(DefaultMaxTTL, o.maxTTL())
+	assert.Equal(DefaultTimeout, o.timeout())
+}
+
+func testOptionsCustom(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		o      = Options{
+			Scheme:  "https",
+			MinTTL:  time.Minute,
+			MaxTTL:  time.Hour,
+			Timeout: time.Millisecond,
+		}
+	)
+
+	assert.Equal("https", o.scheme())
+	assert.Equal(time.Minute, o.minTTL())
+	assert.Equal(time.Hour, o.maxTTL())
+	assert.Equal(time.Millisecond, o.timeout())
+}
+
+func TestOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		testOptionsDefault(t, nil)
+		testOptionsDefault(t, new(Options))
+	})
+
+	t.Run("Custom", testOptionsCustom)
+}
+
+func testNewLookupDefaultServer(t *testing.T) {
+	defer func() { serverFactory = defaultServer }()
+
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	serverFactory = func() (string, error) { return "127.0.0.1:53", nil }
+	l, err := NewLookup(Options{})
+	require.NoError(err)
+	require.NotNil(l)
+	assert.Equal("127.0.0.1:53", l.server)
+
+	serverFactory = func() (string, error) { return "", errNoServer }
+	l, err = NewLookup(Options{})
+	assert.Nil(l)
+	assert.Equal(errNoServer, err)
+}
+
+func testLookupRoutes(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		name = "_http._tcp.caduceus.example.com."
+	)
+
+	server, queries, shutdown := startServer(t, func(w mdns.ResponseWriter, r *mdns.Msg) {
+		m := new(mdns.Msg)
+		m.SetReply(r)
+		m.Answer = []mdns.RR{
+			newSRV(name, 120, 10, 5, 8080, "a.example.com."),
+			newSRV(name, 60, 20, 5, 8080, "backup.example.com."),
+			newSRV(name, 300, 10, 15, 8081, "b.example.com."),
+		}
+
+		w.WriteMsg(m)
+	})
+
+	defer shutdown()
+
+	l, err := NewLookup(Options{
+		Watch:  map[string]string{"caduceus.example.com": "_http._tcp.caduceus.example.com"},
+		Server: server,
+		Logger: logging.NewTestLogger(nil, t),
+	})
+
+	require.NoError(err)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	expected := []xresolver.Route{
+		{Scheme: "http", Host: "a.example.com", Port: 8080, Weight: 5},
+		{Scheme: "http", Host: "b.example.com", Port: 8081, Weight: 15},
+	}
+
+	routes, err := l.LookupRoutes(context.Background(), "caduceus.example.com")
+	assert.NoError(err)
+	assert.Equal(expected, routes)
+	assert.Equal(int32(1), atomic.LoadInt32(queries))
+
+	// the answer is cached for the smallest TTL
+	now = now.Add(59 * time.Second)
+	routes, err = l.LookupRoutes(context.Background(), "caduceus.example.com")
+	assert.NoError(err)
+	assert.Equal(expected, routes)
+	assert.Equal(int32(1), atomic.LoadInt32(queries))
+
+	now = now.Add(time.Second)
+	routes, err = l.LookupRoutes(context.Background(), "caduceus.example.com")
+	assert.NoError(err)
+	assert.Equal(expected, routes)
+	assert.Equal(int32(2), atomic.LoadInt32(queries))
+
+	// the cached routes are copied, so callers cannot alter them
+	routes[0].Host = "altered.example.com"
+	routes, err = l.LookupRoutes(context.Background(), "caduceus.example.com")
+	assert.NoError(err)
+	assert.Equal(expected, routes)
+	assert.Equal(int32(2), atomic.LoadInt32(queries))
+
+	routes, err = l.LookupRoutes(context.Background(), "unwatched.example.com")
+	assert.Error(err)
+	assert.Empty(routes)
+}
+
+func testLookupRoutesMinTTL(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		name = "_http._tcp.caduceus.example.com."
+	)
+
+	server, queries, shutdown := startServer(t, func(w mdns.ResponseWriter, r *mdns.Msg) {
+		m := new(mdns.Msg)
+		m.SetReply(r)
+		m.Answer = []mdns.RR{newSRV(name, 0, 10, 5, 8080, "a.example.com.")}
+		w.WriteMsg(m)
+	})
+
+	defer shutdown()
+
+	l, err := NewLookup(Options{
+		Watch:  map[string]string{"caduceus.example.com": name},
+		Server: server,
+		MinTTL: 10 * time.Second,
+	})
+
+	require.NoError(err)
+	now := time.Now()
+	l.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		routes, err := l.LookupRoutes(context.Background(), "caduceus.example.com")
+		assert.NoError(err)
+		assert.Len(routes, 1)
+	}
+
+	assert.Equal(int32(1), atomic.LoadInt32(queries))
+}
+
+func testLookupRoutesTruncated(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		name   = "_http._tcp.caduceus.example.com."
+		viaTCP int
+	)
+
+	l, err := NewLookup(Options{
+		Watch:  map[string]string{"caduceus.example.com": name},
+		Server: "127.0.0.1:53",
+	})
+
+	require.NoError(err)
+	l.client = exchangerFunc(func(_ context.Context, r *mdns.Msg, _ string) (*mdns.Msg, time.Duration, error) {
+		m := new(mdns.Msg)
+		m.SetReply(r)
+		m.Truncated = true
+		m.Answer = []mdns.RR{newSRV(name, 60, 10, 5, 8080, "partial.example.com.")}
+		return m, 0, nil
+	})
+
+	l.tcpClient = exchangerFunc(func(_ context.Context, r *mdns.Msg, _ string) (*mdns.Msg, time.Duration, error) {
+		viaTCP++
+		m := new(mdns.Msg)
+		m.SetReply(r)
+		m.Answer = []mdns.RR{
+			newSRV(name, 60, 10, 5, 8080, "a.example.com."),
+			newSRV(name, 60, 10, 5, 8080, "b.example.com."),
+		}
+
+		return m, 0, nil
+	})
+
+	routes, err := l.LookupRoutes(context.Background(), "caduceus.example.com")
+	assert.NoError(err)
+	assert.Equal(1, viaTCP)
+	assert.Equal(
+		[]xresolver.Route{
+			{Scheme: "http", Host: "a.example.com", Port: 8080, Weight: 5},
+			{Scheme: "http", Host: "b.example.com", Port: 8080, Weight: 5},
+		},
+		routes,
+	)
+}
+
+func testLookupRoutesError(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	server, _, shutdown := startServer(t, func(w mdns.ResponseWriter, r *mdns.Msg) {
+		m := new(mdns.Msg)
+		m.SetRcode(r, mdns.RcodeNameError)
+		w.WriteMsg(m)
+	})
+
+	defer shutdown()
+
+	l, err := NewLookup(Options{
+		Watch:  map[string]string{"caduceus.example.com": "_http._tcp.caduceus.example.com"},
+		Server: server,
+	})
+
+	require.NoError(err)
+	routes, err := l.LookupRoutes(context.Background(), "caduceus.example.com")
+	assert.Error(err)
+	assert.Empty(routes)
+}
+
+func testLookupRoutesNoRecords(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	server, _, shutdown := startServer(t, func(w mdns.ResponseWriter, r *mdns.Msg) {
+		m := new(mdns.Msg)
+		m.SetReply(r)
+		w.WriteMsg(m)
+	})
+
+	defer shutdown()
+
+	l, err := NewLookup(Options{
+		Watch:  map[string]string{"caduceus.example.com": "_http._tcp.caduceus.example.com"},
+		Server: server,
+	})
+
+	require.NoError(err)
+	routes, err := l.LookupRoutes(context.Background(), "caduceus.example.com")
+	assert.Error(err)
+	assert.Empty(routes)
+}
+
+func TestLookup(t *testing.T) {
+	t.Run("DefaultServer", testNewLookupDefaultServer)
+	t.Run("Routes", testLookupRoutes)
+	t.Run("MinTTL", testLookupRoutesMinTTL)
+	t.Run("Truncated", testLookupRoutesTruncated)
+	t.Run("Error", testLookupRoutesError)
+	t.Run("NoRecords", testLookupRoutesNoRecords)
+}
@@ -0,0 +1,362 @@
+package xresolver
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/provider"
+)
+
+const (
+	DefaultFailureThreshold  = 3
+	DefaultEjectionPeriod    = 30 * time.Second
+	DefaultMaxEjectionPeriod = 5 * time.Minute
+	DefaultRouteExpiry       = 10 * time.Minute
+)
+
+// HealthOptions configures passive health tracking of routes
+type HealthOptions struct {
+	// FailureThreshold is the number of consecutive dial failures that ejects a route.
+	// If unset, DefaultFailureThreshold is used.
+	FailureThreshold int `json:"failureThreshold,omitempty"`
+
+	// EjectionPeriod is how long a route is first ejected for.  Each subsequent ejection without an
+	// intervening success doubles this period.  If unset, DefaultEjectionPeriod is used.
+	EjectionPeriod time.Duration `json:"ejectionPeriod,omitempty"`
+
+	// MaxEjectionPeriod caps the backoff of repeated ejections.  If unset, DefaultMaxEjectionPeriod is used.
+	MaxEjectionPeriod time.Duration `json:"maxEjectionPeriod,omitempty"`
+
+	// RouteExpiry is how long a route is tracked after it was last returned by a lookup.  Expired routes
+	// are no longer probed.  If unset, DefaultRouteExpiry is used.
+	RouteExpiry time.Duration `json:"routeExpiry,omitempty"`
+}
+
+func (o *HealthOptions) failureThreshold() int {
+	if o != nil && o.FailureThreshold > 0 {
+		return o.FailureThreshold
+	}
+
+	return DefaultFailureThreshold
+}
+
+func (o *HealthOptions) ejectionPeriod() time.Duration {
+	if o != nil && o.EjectionPeriod > 0 {
+		return o.EjectionPeriod
+	}
+
+	return DefaultEjectionPeriod
+}
+
+func (o *HealthOptions) maxEjectionPeriod() time.Duration {
+	if o != nil && o.MaxEjectionPeriod > 0 {
+		return o.MaxEjectionPeriod
+	}
+
+	return DefaultMaxEjectionPeriod
+}
+
+func (o *HealthOptions) routeExpiry() time.Duration {
+	if o != nil && o.RouteExpiry > 0 {
+		return o.RouteExpiry
+	}
+
+	return DefaultRouteExpiry
+}
+
+// routeHealth is the tracked state of a single route
+type routeHealth struct {
+	route    Route
+	failures int
+	backoff  time.Duration
+	until    time.Time
+
+	// seen is when this route was last returned by a lookup
+	seen time.Time
+}
+
+// Health passively tracks the outcome of dials to routes.  After FailureThreshold consecutive failures,
+// a route is ejected for a backoff period.  Once that period expires the route is tried again, and a single
+// failure ejects it for twice as long.  Any success restores the route completely.  Routes that no lookup
+// has returned for RouteExpiry are forgotten.
+type Health struct {
+	failureThreshold  int
+	ejectionPeriod    time.Duration
+	maxEjectionPeriod time.Duration
+	routeExpiry       time.Duration
+	now               func() time.Time
+
+	ejections metrics.Counter
+	ejected   metrics.Gauge
+
+	lock   sync.Mutex
+	routes map[string]*routeHealth
+	pruned time.Time
+}
+
+// NewHealth creates a route health tracker.  A nil provider discards the ejection metrics.
+func NewHealth(o HealthOptions, p provider.Provider) *Health {
+	if p == nil {
+		p = provider.NewDiscardProvider()
+	}
+
+	return &Health{
+		failureThreshold:  o.failureThreshold(),
+		ejectionPeriod:    o.ejectionPeriod(),
+		maxEjectionPeriod: o.maxEjectionPeriod(),
+		routeExpiry:       o.routeExpiry(),
+		now:               time.Now,
+		ejections:         p.NewCounter(RouteEjections),
+		ejected:           p.NewGauge(RouteEjected),
+		routes:            make(map[string]*routeHealth),
+	}
+}
+
+// get returns the tracked state for a route, creating it as necessary.  This method must be invoked under the lock.
+func (h *Health) get(r Route) *routeHealth {
+	key := r.String()
+	rh, ok := h.routes[key]
+	if !ok {
+		rh = &routeHealth{route: r, seen: h.now()}
+		h.routes[key] = rh
+	}
+
+	return rh
+}
+
+// prune forgets routes which have not been returned by a lookup within the route expiry.  This method
+// must be invoked under the lock.
+func (h *Health) prune(now time.Time) {
+	h.pruned = now
+	for key, rh := range h.routes {
+		if now.Sub(rh.seen) < h.routeExpiry {
+			continue
+		}
+
+		if !rh.until.IsZero() {
+			h.ejected.With(RouteLabel, key).Set(0.0)
+		}
+
+		delete(h.routes, key)
+	}
+}
+
+// Available tests if a route can currently be dialed, i.e. it is not ejected
+func (h *Health) Available(r Route) bool {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	rh, ok := h.routes[r.String()]
+	return !ok || !h.now().Before(rh.until)
+}
+
+// Filter returns the routes which are currently available, preserving their order.  The routes passed
+// to Filter are those returned by lookups, so they are kept from expiring.
+func (h *Health) Filter(routes []Route) []Route {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	var (
+		now       = h.now()
+		available = make([]Route, 0, len(routes))
+	)
+
+	if now.Sub(h.pruned) >= h.routeExpiry {
+		h.prune(now)
+	}
+
+	for _, r := range routes {
+		rh, ok := h.routes[r.String()]
+		if ok {
+			rh.seen = now
+			if now.Before(rh.until) {
+				continue
+			}
+		}
+
+		available = append(available, r)
+	}
+
+	return available
+}
+
+// Success records a successful dial or probe, which restores the route
+func (h *Health) Success(r Route) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	rh := h.get(r)
+	if !rh.until.IsZero() {
+		h.ejected.With(RouteLabel, r.String()).Set(0.0)
+	}
+
+	rh.failures = 0
+	rh.backoff = 0
+	rh.until = time.Time{}
+}
+
+// Failure records a failed dial or probe, ejecting the route when it has failed too often
+func (h *Health) Failure(r Route) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	var (
+		now = h.now()
+		rh  = h.get(r)
+	)
+
+	if now.Before(rh.until) {
+		// already ejected, e.g. a dial that started before the ejection
+		return
+	}
+
+	rh.failures++
+	if rh.failures < h.failureThreshold && rh.backoff == 0 {
+		return
+	}
+
+	// either the threshold was reached, or this route failed again after its ejection expired
+	if rh.backoff == 0 {
+		rh.backoff = h.ejectionPeriod
+	} else {
+		rh.backoff *= 2
+	}
+
+	if rh.backoff > h.maxEjectionPeriod {
+		rh.backoff = h.maxEjectionPeriod
+	}
+
+	rh.until = now.Add(rh.backoff)
+	h.ejections.With(RouteLabel, r.String()).Add(1.0)
+	h.ejected.With(RouteLabel, r.String()).Set(1.0)
+}
+
+// Routes returns every route this Health is tracking, in no particular order.  Expired routes are not returned.
+func (h *Health) Routes() []Route {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	h.prune(h.now())
+
+	routes := make([]Route, 0, len(h.routes))
+	for _, rh := range h.routes {
+		routes = append(routes, rh.route)
+	}
+
+	return routes
+}
+
+// Probe actively checks every tracked route with the given Prober, recording each outcome
+func (h *Health) Probe(ctx context.Context, p Prober) {
+	for _, r := range h.Routes() {
+		if err := p.Probe(ctx, r); err != nil {
+			h.Failure(r)
+		} else {
+			h.Success(r)
+		}
+	}
+}
+
+// StartProbing probes all tracked routes on the given interval until the returned stop function is called.
+// Each round of probes is bounded by the interval.
+func (h *Health) StartProbing(p Prober, interval time.Duration) (stop func()) {
+	var (
+		ticker = time.NewTicker(interval)
+		done   = make(chan struct{})
+		once   sync.Once
+	)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				h.Probe(ctx, p)
+				cancel()
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// Prober is an active health check for a route
+type Prober interface {
+	Probe(context.Context, Route) error
+}
+
+// ProberFunc is a function type that implements Prober
+type ProberFunc func(context.Context, Route) error
+
+func (pf ProberFunc) Probe(ctx context.Context, r Route) error {
+	return pf(ctx, r)
+}
+
+// probeAddress produces the host:port to probe for a route, using the scheme's default port if the route has none
+func probeAddress(r Route) string {
+	port := r.Port
+	if port == 0 {
+		if r.Scheme == "https" {
+			port = 443
+		} else {
+			port = 80
+		}
+	}
+
+	return net.JoinHostPort(r.Host, strconv.Itoa(port))
+}
+
+// NewTCPProber produces a Prober which checks that a TCP connection can be made to a route
+func NewTCPProber(dialer net.Dialer) Prober {
+	return ProberFunc(func(ctx context.Context, r Route) error {
+		conn, err := dialer.DialContext(ctx, "tcp", probeAddress(r))
+		if err != nil {
+			return err
+		}
+
+		return conn.Close()
+	})
+}
+
+// NewHTTPProber produces a Prober which issues a GET for the given path against a route and expects
+// a 2xx response.  If the client is nil, http.DefaultClient is used.
+func NewHTTPProber(client *http.Client, path string) Prober {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return ProberFunc(func(ctx context.Context, r Route) error {
+		scheme := r.Scheme
+		if len(scheme) == 0 {
+			scheme = "http"
+		}
+
+		request, err := http.NewRequest(http.MethodGet, scheme+"://"+probeAddress(r)+path, nil)
+		if err != nil {
+			return err
+		}
+
+		response, err := client.Do(request.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+
+		response.Body.Close()
+		if response.StatusCode < 200 || response.StatusCode > 299 {
+			return fmt.Errorf("probe of %s returned status %d", r, response.StatusCode)
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,253 @@
+package xresolver
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/jithin-kg/webpa-common/xmetrics/xmetricstest"
+)
+
+func testHealthOptionsDefault(t *testing.T, o *HealthOptions) {
+	assert := assert.New(t)
+
+	assert.Equal(DefaultFailureThreshold, o.failureThreshold())
+	assert.Equal(DefaultEjectionPeriod, o.ejectionPeriod())
+	assert.Equal(DefaultMaxEjectionPeriod, o.maxEjectionPeriod())
+	assert.Equal(DefaultRouteExpiry, o.routeExpiry())
+}
+
+func testHealthOptionsCustom(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		o      = HealthOptions{
+			FailureThreshold:  5,
+			EjectionPeriod:    time.Second,
+			MaxEjectionPeriod: time.Minute,
+			RouteExpiry:       time.Hour,
+		}
+	)
+
+	assert.Equal(5, o.failureThreshold())
+	assert.Equal(time.Second, o.ejectionPeriod())
+	assert.Equal(time.Minute, o.maxEjectionPeriod())
+	assert.Equal(time.Hour, o.routeExpiry())
+}
+
+func TestHealthOptions(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		testHealthOptionsDefault(t, nil)
+		testHealthOptionsDefault(t, new(HealthOptions))
+	})
+
+	t.Run("Custom", testHealthOptionsCustom)
+}
+
+func TestHealth(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		now     = time.Now()
+		p       = xmetricstest.NewProvider(nil, Metrics)
+		h       = NewHealth(HealthOptions{FailureThreshold: 2, EjectionPeriod: time.Second, MaxEjectionPeriod: 3 * time.Second}, p)
+		route   = Route{Scheme: "http", Host: "bad.com", Port: 8080}
+		healthy = Route{Scheme: "http", Host: "good.com", Port: 8080}
+		routes  = []Route{route, healthy}
+	)
+
+	h.now = func() time.Time { return now }
+	assert.True(h.Available(route))
+	assert.Equal(routes, h.Filter(routes))
+
+	h.Success(healthy)
+	h.Failure(route)
+	assert.True(h.Available(route))
+
+	// the threshold ejects the route
+	h.Failure(route)
+	assert.False(h.Available(route))
+	assert.Equal([]Route{healthy}, h.Filter(routes))
+	assert.ElementsMatch(routes, h.Routes())
+	p.Assert(t, RouteEjections, RouteLabel, route.String())(xmetricstest.Value(1.0))
+	p.Assert(t, RouteEjected, RouteLabel, route.String())(xmetricstest.Value(1.0))
+
+	// failures during an ejection are ignored
+	h.Failure(route)
+	p.Assert(t, RouteEjections, RouteLabel, route.String())(xmetricstest.Value(1.0))
+
+	// after the ejection, a single failure ejects for twice as long
+	now = now.Add(time.Second)
+	assert.True(h.Available(route))
+	h.Failure(route)
+	assert.False(h.Available(route))
+	now = now.Add(time.Second)
+	assert.False(h.Available(route))
+	now = now.Add(time.Second)
+	assert.True(h.Available(route))
+
+	// the backoff is capped
+	h.Failure(route)
+	now = now.Add(3 * time.Second)
+	assert.True(h.Available(route))
+	p.Assert(t, RouteEjections, RouteLabel, route.String())(xmetricstest.Value(3.0))
+
+	// a success restores the route completely
+	h.Success(route)
+	p.Assert(t, RouteEjected, RouteLabel, route.String())(xmetricstest.Value(0.0))
+	h.Failure(route)
+	assert.True(h.Available(route))
+}
+
+func TestHealthRouteExpiry(t *testing.T) {
+	var (
+		assert = assert.New(t)
+
+		now     = time.Now()
+		p       = xmetricstest.NewProvider(nil, Metrics)
+		h       = NewHealth(HealthOptions{FailureThreshold: 1, RouteExpiry: time.Minute}, p)
+		current = Route{Scheme: "http", Host: "current.com"}
+		removed = Route{Scheme: "http", Host: "removed.com"}
+	)
+
+	h.now = func() time.Time { return now }
+	h.Success(current)
+	h.Failure(removed)
+	p.Assert(t, RouteEjected, RouteLabel, removed.String())(xmetricstest.Value(1.0))
+	assert.ElementsMatch([]Route{current, removed}, h.Routes())
+
+	// only current is still returned by lookups
+	now = now.Add(45 * time.Second)
+	assert.Equal([]Route{current}, h.Filter([]Route{current}))
+
+	now = now.Add(30 * time.Second)
+	assert.Equal([]Route{current}, h.Routes())
+	assert.True(h.Available(removed))
+	p.Assert(t, RouteEjected, RouteLabel, removed.String())(xmetricstest.Value(0.0))
+
+	// lookups that stop returning a route let it expire as well
+	now = now.Add(time.Minute)
+	assert.Equal([]Route{removed}, h.Filter([]Route{removed}))
+	assert.Empty(h.Routes())
+}
+
+func TestHealthProbe(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		h      = NewHealth(HealthOptions{FailureThreshold: 1}, nil)
+		good   = Route{Scheme: "http", Host: "good.com"}
+		bad    = Route{Scheme: "http", Host: "bad.com"}
+		prober = ProberFunc(func(_ context.Context, r Route) error {
+			if r == bad {
+				return errors.New("expected")
+			}
+
+			return nil
+		})
+	)
+
+	h.Failure(good)
+	h.Success(bad)
+	assert.False(h.Available(good))
+	assert.True(h.Available(bad))
+
+	h.Probe(context.Background(), prober)
+	assert.True(h.Available(good))
+	assert.False(h.Available(bad))
+}
+
+func TestHealthStartProbing(t *testing.T) {
+	var (
+		assert = assert.New(t)
+		h      = NewHealth(HealthOptions{FailureThreshold: 1}, nil)
+		route  = Route{Scheme: "http", Host: "good.com"}
+		probed = make(chan Route, 10)
+		prober = ProberFunc(func(_ context.Context, r Route) error {
+			probed <- r
+			return nil
+		})
+	)
+
+	h.Failure(route)
+	stop := h.StartProbing(prober, 10*time.Millisecond)
+	defer stop()
+
+	select {
+	case r := <-probed:
+		assert.Equal(route, r)
+	case <-time.After(5 * time.Second):
+		assert.Fail("the route was not probed")
+	}
+
+	stop()
+	stop() // idempotent
+}
+
+func TestNewTCPProber(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer listener.Close()
+
+	go func() {
+		for {
+			c, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			c.Close()
+		}
+	}()
+
+	route, err := CreateRoute("tcp://" + listener.Addr().String())
+	require.NoError(err)
+
+	prober := NewTCPProber(DefaultDialer)
+	assert.NoError(prober.Probe(context.Background(), route))
+
+	listener.Close()
+	assert.Error(prober.Probe(context.Background(), route))
+}
+
+func TestNewHTTPProber(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		server = httptest.NewServer(http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			if request.URL.Path == "/health" {
+				response.WriteHeader(http.StatusOK)
+			} else {
+				response.WriteHeader(http.StatusServiceUnavailable)
+			}
+		}))
+	)
+
+	defer server.Close()
+	route, err := CreateRoute(server.URL)
+	require.NoError(err)
+
+	assert.NoError(NewHTTPProber(nil, "/health").Probe(context.Background(), route))
+	assert.Error(NewHTTPProber(server.Client(), "/unavailable").Probe(context.Background(), route))
+
+	server.Close()
+	assert.Error(NewHTTPProber(nil, "/health").Probe(context.Background(), route))
+}
+
+func TestProbeAddress(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("host.com:80", probeAddress(Route{Scheme: "http", Host: "host.com"}))
+	assert.Equal("host.com:443", probeAddress(Route{Scheme: "https", Host: "host.com"}))
+	assert.Equal("host.com:8080", probeAddress(Route{Scheme: "https", Host: "host.com", Port: 8080}))
+}
@@ -0,0 +1,57 @@
+package xresolver
+
+import (
+	"github.com/jithin-kg/webpa-common/xmetrics"
+)
+
+const (
+	DialCount         = "xresolver_dial_count"
+	ActiveConnections = "xresolver_active_connections"
+	RouteEjections    = "xresolver_route_ejection_count"
+	RouteEjected      = "xresolver_route_ejected"
+	LookupErrorCount  = "xresolver_lookup_error_count"
+
+	RouteLabel   = "route"
+	OutcomeLabel = "outcome"
+	HostLabel    = "host"
+
+	// SuccessOutcome and FailureOutcome are the values of OutcomeLabel
+	SuccessOutcome = "success"
+	FailureOutcome = "failure"
+)
+
+// Metrics is the xresolver module function for metrics
+func Metrics() []xmetrics.Metric {
+	return []xmetrics.Metric{
+		{
+			Name:       DialCount,
+			Type:       "counter",
+			Help:       "The total count of dials to a route, by outcome",
+			LabelNames: []string{RouteLabel, OutcomeLabel},
+		},
+		{
+			Name:       ActiveConnections,
+			Type:       "gauge",
+			Help:       "The current number of open connections dialed to a route",
+			LabelNames: []string{RouteLabel},
+		},
+		{
+			Name:       RouteEjections,
+			Type:       "counter",
+			Help:       "The total count of times a route was ejected after consecutive failures",
+			LabelNames: []string{RouteLabel},
+		},
+		{
+			Name:       RouteEjected,
+			Type:       "gauge",
+			Help:       "Set to 1 while a route is ejected",
+			LabelNames: []string{RouteLabel},
+		},
+		{
+			Name:       LookupErrorCount,
+			Type:       "counter",
+			Help:       "The total count of errors from route lookups for a host",
+			LabelNames: []string{HostLabel},
+		},
+	}
+}
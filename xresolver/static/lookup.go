@@ -0,0 +1,231 @@
+package static
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/xresolver"
+	"gopkg.in/yaml.v2"
+)
+
+// Options configures a static host-alias Lookup
+type Options struct {
+	// Hosts maps the hosts that are dialed to the URLs of their routes,
+	// e.g. { "caduceus.example.com" : ["http://10.0.0.1:8080", "http://10.0.0.2:8080"] }.
+	// A URL without a port uses the port that was dialed.
+	Hosts map[string][]string `json:"hosts,omitempty"`
+
+	// File is an optional JSON or YAML document with the same structure as Hosts.  Hosts in the file
+	// take precedence over Hosts in these options.  YAML is used for files ending in .yaml or .yml.
+	File string `json:"file,omitempty"`
+
+	Logger log.Logger `json:"-"`
+}
+
+// parseRoute converts a URL into a Route.  Unlike xresolver.CreateRoute, the port is optional.
+func parseRoute(value string) (xresolver.Route, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return xresolver.Route{}, err
+	}
+
+	if len(u.Scheme) == 0 || len(u.Hostname()) == 0 {
+		return xresolver.Route{}, fmt.Errorf("invalid route %s: a scheme and host are required", value)
+	}
+
+	route := xresolver.Route{
+		Scheme: u.Scheme,
+		Host:   u.Hostname(),
+	}
+
+	if port := u.Port(); len(port) > 0 {
+		if route.Port, err = strconv.Atoi(port); err != nil {
+			return xresolver.Route{}, err
+		}
+	}
+
+	return route, nil
+}
+
+func parseHosts(hosts map[string][]string, routes map[string][]xresolver.Route) error {
+	for host, values := range hosts {
+		parsed := make([]xresolver.Route, 0, len(values))
+		for _, v := range values {
+			route, err := parseRoute(v)
+			if err != nil {
+				return err
+			}
+
+			parsed = append(parsed, route)
+		}
+
+		routes[host] = parsed
+	}
+
+	return nil
+}
+
+func unmarshal(path string, data []byte, v interface{}) error {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return yaml.Unmarshal(data, v)
+	default:
+		return json.Unmarshal(data, v)
+	}
+}
+
+// Lookup is an xresolver.Lookup that supplies fixed routes for host aliases, optionally read from a file
+type Lookup struct {
+	logger log.Logger
+	hosts  map[string][]string
+	file   string
+
+	lock    sync.RWMutex
+	routes  map[string][]xresolver.Route
+	modTime time.Time
+	size    int64
+}
+
+var _ xresolver.Lookup = (*Lookup)(nil)
+
+var errEmptyFile = errors.New("The static routes file is empty")
+
+// NewLookup creates a static Lookup, loading the file if one is configured
+func NewLookup(o Options) (*Lookup, error) {
+	if o.Logger == nil {
+		o.Logger = logging.DefaultLogger()
+	}
+
+	l := &Lookup{
+		logger: o.Logger,
+		hosts:  o.Hosts,
+		file:   o.File,
+	}
+
+	if err := l.Reload(); err != nil {
+		return nil, err
+	}
+
+	return l, nil
+}
+
+// Reload rebuilds the routes from the options and the file.  If an error occurs, the existing routes are kept.
+func (l *Lookup) Reload() error {
+	routes := make(map[string][]xresolver.Route)
+	if err := parseHosts(l.hosts, routes); err != nil {
+		return err
+	}
+
+	var (
+		modTime time.Time
+		size    int64
+	)
+
+	if len(l.file) > 0 {
+		info, err := os.Stat(l.file)
+		if err != nil {
+			return err
+		}
+
+		data, err := ioutil.ReadFile(l.file)
+		if err != nil {
+			return err
+		}
+
+		// an empty file is most likely one that is being rewritten, and would otherwise remove every route
+		if len(strings.TrimSpace(string(data))) == 0 {
+			return errEmptyFile
+		}
+
+		var hosts map[string][]string
+		if err := unmarshal(l.file, data, &hosts); err != nil {
+			return err
+		}
+
+		if err := parseHosts(hosts, routes); err != nil {
+			return err
+		}
+
+		modTime, size = info.ModTime(), info.Size()
+	}
+
+	l.lock.Lock()
+	l.routes = routes
+	l.modTime, l.size = modTime, size
+	l.lock.Unlock()
+
+	logging.Debug(l.logger, logging.MessageKey(), "loaded static routes", "file", l.file, "hosts", len(routes))
+	return nil
+}
+
+// changed tests if the file has been modified since it was last loaded
+func (l *Lookup) changed() bool {
+	info, err := os.Stat(l.file)
+	if err != nil {
+		return false
+	}
+
+	l.lock.RLock()
+	defer l.lock.RUnlock()
+	return !info.ModTime().Equal(l.modTime) || info.Size() != l.size
+}
+
+// StartWatching polls the file on the given interval, reloading it whenever it changes, until the returned
+// stop function is called.  Reload errors are logged and the previous routes are kept.  If there is no file,
+// this method does nothing.
+func (l *Lookup) StartWatching(interval time.Duration) (stop func()) {
+	if len(l.file) == 0 {
+		return func() {}
+	}
+
+	var (
+		ticker = time.NewTicker(interval)
+		done   = make(chan struct{})
+		once   sync.Once
+	)
+
+	go func() {
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				if l.changed() {
+					if err := l.Reload(); err != nil {
+						logging.Error(l.logger, logging.MessageKey(), "unable to reload static routes", "file", l.file, logging.ErrorKey(), err)
+					}
+				}
+			}
+		}
+	}()
+
+	return func() {
+		once.Do(func() { close(done) })
+	}
+}
+
+// LookupRoutes returns the routes for a host alias
+func (l *Lookup) LookupRoutes(_ context.Context, host string) ([]xresolver.Route, error) {
+	l.lock.RLock()
+	routes, ok := l.routes[host]
+	l.lock.RUnlock()
+
+	if !ok || len(routes) == 0 {
+		return []xresolver.Route{}, errors.New(host + " is not part of the static lookup")
+	}
+
+	return append([]xresolver.Route{}, routes...), nil
+}
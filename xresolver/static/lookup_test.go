@@ -0,0 +1,173 @@
+package static
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/jithin-kg/webpa-common/logging"
+	"github.com/jithin-kg/webpa-common/xresolver"
+)
+
+func TestParseRoute(t *testing.T) {
+	testData := []struct {
+		value       string
+		expected    xresolver.Route
+		expectedErr bool
+	}{
+		{"http://host.com", xresolver.Route{Scheme: "http", Host: "host.com"}, false},
+		{"https://host.com:8443", xresolver.Route{Scheme: "https", Host: "host.com", Port: 8443}, false},
+		{"host.com", xresolver.Route{}, true},
+		{"http://host.com:port", xresolver.Route{}, true},
+		{"%%", xresolver.Route{}, true},
+	}
+
+	for _, record := range testData {
+		t.Run(record.value, func(t *testing.T) {
+			assert := assert.New(t)
+			route, err := parseRoute(record.value)
+			assert.Equal(record.expected, route)
+			assert.Equal(record.expectedErr, err != nil)
+		})
+	}
+}
+
+func testLookupHosts(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	l, err := NewLookup(Options{
+		Hosts: map[string][]string{
+			"caduceus.example.com": []string{"http://10.0.0.1:8080", "http://10.0.0.2"},
+		},
+	})
+
+	require.NoError(err)
+	require.NotNil(l)
+
+	routes, err := l.LookupRoutes(context.Background(), "caduceus.example.com")
+	assert.NoError(err)
+	assert.Equal(
+		[]xresolver.Route{
+			{Scheme: "http", Host: "10.0.0.1", Port: 8080},
+			{Scheme: "http", Host: "10.0.0.2"},
+		},
+		routes,
+	)
+
+	routes, err = l.LookupRoutes(context.Background(), "unknown.example.com")
+	assert.Error(err)
+	assert.Empty(routes)
+
+	// a lookup without a file does not watch anything
+	l.StartWatching(time.Millisecond)()
+
+	l, err = NewLookup(Options{Hosts: map[string][]string{"bad.example.com": []string{"not a url"}}})
+	assert.Nil(l)
+	assert.Error(err)
+}
+
+func testLookupFile(t *testing.T, name, initial, updated string) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+	)
+
+	dir, err := ioutil.TempDir("", "static")
+	require.NoError(err)
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, name)
+	require.NoError(ioutil.WriteFile(path, []byte(initial), 0644))
+
+	l, err := NewLookup(Options{
+		Hosts: map[string][]string{
+			"caduceus.example.com": []string{"http://overridden.com"},
+			"talaria.example.com":  []string{"http://talaria.com:8080"},
+		},
+		File:   path,
+		Logger: logging.NewTestLogger(nil, t),
+	})
+
+	require.NoError(err)
+	require.NotNil(l)
+
+	routes, err := l.LookupRoutes(context.Background(), "caduceus.example.com")
+	assert.NoError(err)
+	assert.Equal([]xresolver.Route{{Scheme: "http", Host: "caduceus.com", Port: 8080}}, routes)
+
+	routes, err = l.LookupRoutes(context.Background(), "talaria.example.com")
+	assert.NoError(err)
+	assert.Equal([]xresolver.Route{{Scheme: "http", Host: "talaria.com", Port: 8080}}, routes)
+
+	stop := l.StartWatching(5 * time.Millisecond)
+	defer stop()
+
+	require.NoError(ioutil.WriteFile(path, []byte(updated), 0644))
+	require.NoError(os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute)))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if routes, _ = l.LookupRoutes(context.Background(), "caduceus.example.com"); len(routes) == 2 {
+			break
+		}
+
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	assert.Equal(
+		[]xresolver.Route{
+			{Scheme: "http", Host: "caduceus1.com", Port: 8080},
+			{Scheme: "http", Host: "caduceus2.com", Port: 8080},
+		},
+		routes,
+	)
+
+	// a bad file keeps the existing routes
+	stop()
+	require.NoError(ioutil.WriteFile(path, []byte("{{{"), 0644))
+	assert.Error(l.Reload())
+	routes, err = l.LookupRoutes(context.Background(), "caduceus.example.com")
+	assert.NoError(err)
+	assert.Len(routes, 2)
+
+	require.NoError(ioutil.WriteFile(path, []byte("\n"), 0644))
+	assert.Equal(errEmptyFile, l.Reload())
+
+	os.Remove(path)
+	assert.Error(l.Reload())
+}
+
+func testLookupMissingFile(t *testing.T) {
+	assert := assert.New(t)
+
+	l, err := NewLookup(Options{File: "/this/file/does/not/exist.json"})
+	assert.Nil(l)
+	assert.Error(err)
+}
+
+func TestLookup(t *testing.T) {
+	t.Run("Hosts", testLookupHosts)
+	t.Run("JSON", func(t *testing.T) {
+		testLookupFile(t, "hosts.json",
+			`{"caduceus.example.com": ["http://caduceus.com:8080"]}`,
+			`{"caduceus.example.com": ["http://caduceus1.com:8080", "http://caduceus2.com:8080"]}`,
+		)
+	})
+
+	t.Run("YAML", func(t *testing.T) {
+		testLookupFile(t, "hosts.yaml",
+			"caduceus.example.com:\n  - http://caduceus.com:8080\n",
+			"caduceus.example.com:\n  - http://caduceus1.com:8080\n  - http://caduceus2.com:8080\n",
+		)
+	})
+
+	t.Run("MissingFile", testLookupMissingFile)
+}
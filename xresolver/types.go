@@ -38,6 +38,10 @@ type Route struct {
 	Scheme string
 	Host   string
 	Port   int
+
+	// Weight is the relative share of traffic for this route, used by weighted balancers.
+	// A nonpositive weight is treated as 1.
+	Weight int
 }
 
 func CreateRoute(route string) (Route, error) {
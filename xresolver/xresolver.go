@@ -6,22 +6,79 @@ import (
 	"net"
 	"strconv"
 	"sync"
+
+	"github.com/go-kit/kit/log"
+	"github.com/go-kit/kit/metrics"
+	"github.com/go-kit/kit/metrics/provider"
+	"github.com/jithin-kg/webpa-common/logging"
 )
 
 // Note to self: Dial is not being set for net.Resolver because that is the Dial to the DNS server.
 
 var DefaultDialer = net.Dialer{}
 
+// Options configures a Resolver created with New
+type Options struct {
+	// Dialer is used to connect to routes, and to addresses that have no routes
+	Dialer net.Dialer
+
+	// Health, if supplied, tracks dials to routes and skips routes that have been ejected.
+	// The same Health may be shared by several resolvers and used for active probing.
+	Health *Health
+
+	// Balancer, if supplied, orders the routes before they are dialed.  By default, routes are
+	// dialed in the order the lookups returned them.
+	Balancer Balancer
+
+	// Provider is used to create the per-route metrics.  If nil, metrics are discarded.
+	Provider provider.Provider
+
+	// Logger is used to report lookup errors.  If nil, the default logger is used.
+	Logger log.Logger
+}
+
 type resolver struct {
 	resolvers map[Lookup]bool
+	order     []Lookup
 	lock      sync.RWMutex
 	dialer    net.Dialer
+
+	logger   log.Logger
+	health   *Health
+	balancer Balancer
+
+	dialCount         metrics.Counter
+	activeConnections metrics.Gauge
+	lookupErrors      metrics.Counter
+
+	connectionLock sync.Mutex
+	connections    map[string]int
 }
 
 func NewResolver(dialer net.Dialer, lookups ...Lookup) Resolver {
+	return New(Options{Dialer: dialer}, lookups...)
+}
+
+// New creates a Resolver with health tracking, balancing and metrics as configured by the given Options
+func New(o Options, lookups ...Lookup) Resolver {
+	if o.Provider == nil {
+		o.Provider = provider.NewDiscardProvider()
+	}
+
+	if o.Logger == nil {
+		o.Logger = logging.DefaultLogger()
+	}
+
 	r := &resolver{
-		resolvers: make(map[Lookup]bool),
-		dialer:    dialer,
+		resolvers:         make(map[Lookup]bool),
+		dialer:            o.Dialer,
+		logger:            o.Logger,
+		health:            o.Health,
+		balancer:          o.Balancer,
+		dialCount:         o.Provider.NewCounter(DialCount),
+		activeConnections: o.Provider.NewGauge(ActiveConnections),
+		lookupErrors:      o.Provider.NewCounter(LookupErrorCount),
+		connections:       make(map[string]int),
 	}
 
 	for _, lookup := range lookups {
@@ -31,39 +88,71 @@ func NewResolver(dialer net.Dialer, lookups ...Lookup) Resolver {
 }
 
 func (resolve *resolver) Add(r Lookup) error {
-	resolve.lock.RLock()
-	found := resolve.resolvers[r]
-	resolve.lock.RUnlock()
-	if found {
+	resolve.lock.Lock()
+	defer resolve.lock.Unlock()
+
+	if resolve.resolvers[r] {
 		return errors.New("resolver already exist")
 	}
 
-	resolve.lock.Lock()
 	resolve.resolvers[r] = true
-	resolve.lock.Unlock()
+	resolve.order = append(resolve.order, r)
 	return nil
 }
 
 func (resolve *resolver) Remove(r Lookup) error {
-	resolve.lock.RLock()
-	found := resolve.resolvers[r]
-	resolve.lock.RUnlock()
-	if !found {
+	resolve.lock.Lock()
+	defer resolve.lock.Unlock()
+
+	if !resolve.resolvers[r] {
 		return errors.New("resolver does not exist")
 	}
 
-	resolve.lock.Lock()
 	delete(resolve.resolvers, r)
-	resolve.lock.Unlock()
+	for i, l := range resolve.order {
+		if l == r {
+			resolve.order = append(resolve.order[:i], resolve.order[i+1:]...)
+			break
+		}
+	}
+
 	return nil
 }
 
+// ActiveConnections returns the number of connections dialed to the given route that have not been closed
+func (resolve *resolver) ActiveConnections(r Route) int {
+	resolve.connectionLock.Lock()
+	defer resolve.connectionLock.Unlock()
+	return resolve.connections[r.String()]
+}
+
+func (resolve *resolver) addConnection(r Route, delta int) {
+	resolve.connectionLock.Lock()
+	key := r.String()
+	count := resolve.connections[key] + delta
+	if count > 0 {
+		resolve.connections[key] = count
+	} else {
+		delete(resolve.connections, key)
+	}
+
+	resolve.connectionLock.Unlock()
+	resolve.activeConnections.With(RouteLabel, key).Set(float64(count))
+}
+
 func (resolve *resolver) getRoutes(ctx context.Context, host string) []Route {
+	resolve.lock.RLock()
+	defer resolve.lock.RUnlock()
+
+	// lookups are consulted in the order they were added
 	routes := make([]Route, 0)
-	for r := range resolve.resolvers {
+	for _, r := range resolve.order {
 		tempRoutes, err := r.LookupRoutes(ctx, host)
 		if err == nil {
 			routes = append(routes, tempRoutes...)
+		} else {
+			resolve.lookupErrors.With(HostLabel, host).Add(1.0)
+			logging.Debug(resolve.logger, logging.MessageKey(), "route lookup failed", "host", host, logging.ErrorKey(), err)
 		}
 	}
 
@@ -80,11 +169,19 @@ func (resolve *resolver) DialContext(ctx context.Context, network, addr string)
 		return resolve.dialer.Dial(network, net.JoinHostPort(ip.String(), port))
 	}
 
-	// get records using custom resolvers
+	// get records using custom resolvers, skipping any that are ejected.  If every route
+	// is ejected, the address is dialed directly below.
 	routes := resolve.getRoutes(ctx, host)
+	if resolve.health != nil {
+		routes = resolve.health.Filter(routes)
+	}
+
+	if resolve.balancer != nil && len(routes) > 1 {
+		routes = resolve.balancer.Order(routes, resolve)
+	}
 
 	// generate Conn or err from records
-	con, err = resolve.createConnection(routes, network, port)
+	con, err = resolve.createConnection(ctx, routes, network, port)
 	if err == nil {
 		return
 	}
@@ -93,16 +190,44 @@ func (resolve *resolver) DialContext(ctx context.Context, network, addr string)
 	return resolve.dialer.DialContext(ctx, network, addr)
 }
 
-func (resolve *resolver) createConnection(routes []Route, network, port string) (con net.Conn, err error) {
+func (resolve *resolver) createConnection(ctx context.Context, routes []Route, network, port string) (con net.Conn, err error) {
 	for _, route := range routes {
 		portUsed := port
 		if route.Port != 0 {
 			portUsed = strconv.Itoa(route.Port)
 		}
-		con, err = resolve.dialer.Dial(network, net.JoinHostPort(route.Host, portUsed))
+		con, err = resolve.dialer.DialContext(ctx, network, net.JoinHostPort(route.Host, portUsed))
 		if err == nil {
-			return
+			resolve.dialCount.With(RouteLabel, route.String(), OutcomeLabel, SuccessOutcome).Add(1.0)
+			if resolve.health != nil {
+				resolve.health.Success(route)
+			}
+
+			resolve.addConnection(route, 1)
+			return &trackedConn{Conn: con, release: func() { resolve.addConnection(route, -1) }}, nil
+		}
+
+		resolve.dialCount.With(RouteLabel, route.String(), OutcomeLabel, FailureOutcome).Add(1.0)
+		if ctx.Err() != nil {
+			// the caller gave up, which says nothing about the health of this route
+			return nil, ctx.Err()
+		}
+
+		if resolve.health != nil {
+			resolve.health.Failure(route)
 		}
 	}
 	return nil, errors.New("failed to create connection from routes")
 }
+
+// trackedConn decorates a dialed connection so that closing it updates the route's connection count
+type trackedConn struct {
+	net.Conn
+	once    sync.Once
+	release func()
+}
+
+func (tc *trackedConn) Close() error {
+	tc.once.Do(tc.release)
+	return tc.Conn.Close()
+}
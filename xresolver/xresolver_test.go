@@ -2,10 +2,14 @@ package xresolver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/jithin-kg/webpa-common/xmetrics/xmetricstest"
 	"io/ioutil"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -95,3 +99,79 @@ func TestClientWithResolver(t *testing.T) {
 	res, err = client.Do(req)
 	assert.Error(err)
 }
+
+func TestResolverHealthAndMetrics(t *testing.T) {
+	var (
+		assert  = assert.New(t)
+		require = require.New(t)
+
+		customhost = "custom.host.com"
+		p          = xmetricstest.NewProvider(nil, Metrics)
+		health     = NewHealth(HealthOptions{FailureThreshold: 1}, p)
+	)
+
+	live, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	defer live.Close()
+
+	dead, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(err)
+	dead.Close()
+
+	liveRoute, err := CreateRoute("tcp://" + live.Addr().String())
+	require.NoError(err)
+
+	deadRoute, err := CreateRoute("tcp://" + dead.Addr().String())
+	require.NoError(err)
+
+	failingLookUp := new(mockLookUp)
+	failingLookUp.On("LookupRoutes", mock.Anything, customhost).Return([]Route{}, errors.New("expected"))
+
+	fakeLookUp := new(mockLookUp)
+	fakeLookUp.On("LookupRoutes", mock.Anything, customhost).Return([]Route{deadRoute, liveRoute}, nil)
+
+	r := New(
+		Options{
+			Dialer:   DefaultDialer,
+			Health:   health,
+			Balancer: NewLeastConnectionsBalancer(),
+			Provider: p,
+		},
+		fakeLookUp,
+		failingLookUp,
+	)
+
+	// the dead route is tried first, and ejected
+	first, err := r.DialContext(context.Background(), "tcp", net.JoinHostPort(customhost, "80"))
+	require.NoError(err)
+	assert.False(health.Available(deadRoute))
+	assert.Equal(1, r.(Stats).ActiveConnections(liveRoute))
+
+	// the ejected route is no longer dialed
+	second, err := r.DialContext(context.Background(), "tcp", net.JoinHostPort(customhost, "80"))
+	require.NoError(err)
+	assert.Equal(2, r.(Stats).ActiveConnections(liveRoute))
+
+	p.Assert(t, DialCount, RouteLabel, deadRoute.String(), OutcomeLabel, FailureOutcome)(xmetricstest.Value(1.0))
+	p.Assert(t, DialCount, RouteLabel, liveRoute.String(), OutcomeLabel, SuccessOutcome)(xmetricstest.Value(2.0))
+	p.Assert(t, ActiveConnections, RouteLabel, liveRoute.String())(xmetricstest.Value(2.0))
+	p.Assert(t, LookupErrorCount, HostLabel, customhost)(xmetricstest.Value(2.0))
+	p.Assert(t, RouteEjections, RouteLabel, deadRoute.String())(xmetricstest.Value(1.0))
+
+	assert.NoError(first.Close())
+	first.Close() // closing twice should not double count
+	assert.NoError(second.Close())
+	assert.Zero(r.(Stats).ActiveConnections(liveRoute))
+	p.Assert(t, ActiveConnections, RouteLabel, liveRoute.String())(xmetricstest.Value(0.0))
+
+	// a canceled dial does not count against the health of a route
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	health.Success(deadRoute)
+	_, err = r.DialContext(ctx, "tcp", net.JoinHostPort(customhost, "80"))
+	assert.Error(err)
+	assert.True(health.Available(deadRoute))
+
+	fakeLookUp.AssertExpectations(t)
+	failingLookUp.AssertExpectations(t)
+}